@@ -0,0 +1,255 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package aerospike
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	as "github.com/aerospike/aerospike-client-go/v5"
+	cache "github.com/cludden/http-cache"
+)
+
+// DefaultSet is the set storing responses by default.
+const DefaultSet = "http-cache"
+
+// responseBin is the bin holding the responses.
+const responseBin = "response"
+
+// Client is the part of the Aerospike client API used by the adapter,
+// implemented by *aerospike.Client.
+type Client interface {
+	Get(policy *as.BasePolicy, key *as.Key, binNames ...string) (*as.Record, as.Error)
+	Put(policy *as.WritePolicy, key *as.Key, binMap as.BinMap) as.Error
+	Delete(policy *as.WritePolicy, key *as.Key) (bool, as.Error)
+}
+
+// Adapter is the Aerospike adapter data structure, storing responses in
+// records of a namespace set, expired by the server with their TTL.
+type Adapter struct {
+	client      Client
+	namespace   string
+	set         string
+	readPolicy  *as.BasePolicy
+	writePolicy *as.WritePolicy
+	timeout     time.Duration
+	maxRetries  int
+}
+
+// AdapterOptions is used to set Adapter settings.
+type AdapterOptions func(a *Adapter) error
+
+var _ cache.Adapter = (*Adapter)(nil)
+
+// Get implements the cache Adapter interface Get method.
+func (a *Adapter) Get(ctx context.Context, key string) ([]byte, bool) {
+	k, err := as.NewKey(a.namespace, a.set, key)
+	if err != nil || ctx.Err() != nil {
+		return nil, false
+	}
+	policy := *a.readPolicy
+	policy.TotalTimeout = timeout(ctx, policy.TotalTimeout)
+
+	record, err := a.client.Get(&policy, k, responseBin)
+	if err != nil || record == nil {
+		return nil, false
+	}
+	response, ok := record.Bins[responseBin].([]byte)
+
+	return response, ok
+}
+
+// Set implements the cache Adapter interface Set method. Responses already
+// past their expiration date release the stored one instead.
+func (a *Adapter) Set(ctx context.Context, key string, response []byte, expiration time.Time) {
+	ttl := uint32(as.TTLDontExpire)
+	if !expiration.IsZero() {
+		// TTLs are in seconds, rounded up so as not to expire early.
+		seconds := math.Ceil(time.Until(expiration).Seconds())
+		if seconds <= 0 {
+			a.Release(ctx, key)
+			return
+		}
+		ttl = uint32(math.Min(seconds, math.MaxUint32-1))
+	}
+
+	k, err := as.NewKey(a.namespace, a.set, key)
+	if err != nil || ctx.Err() != nil {
+		return
+	}
+	policy := *a.writePolicy
+	policy.TotalTimeout = timeout(ctx, policy.TotalTimeout)
+	policy.Expiration = ttl
+
+	a.client.Put(&policy, k, as.BinMap{responseBin: response})
+}
+
+// Release implements the cache Adapter interface Release method.
+func (a *Adapter) Release(ctx context.Context, key string) {
+	k, err := as.NewKey(a.namespace, a.set, key)
+	if err != nil || ctx.Err() != nil {
+		return
+	}
+	policy := *a.writePolicy
+	policy.TotalTimeout = timeout(ctx, policy.TotalTimeout)
+
+	a.client.Delete(&policy, k)
+}
+
+// timeout returns the total timeout of a command, shortened to the
+// deadline of ctx, as the client doesn't take a context.
+func timeout(ctx context.Context, total time.Duration) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return total
+	}
+	// A zero total timeout is no timeout, so the remaining time is at
+	// least a nanosecond.
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		remaining = time.Nanosecond
+	}
+	if total == 0 || remaining < total {
+		return remaining
+	}
+
+	return total
+}
+
+// NewAdapter initializes an Aerospike adapter storing responses in a
+// namespace.
+func NewAdapter(client Client, namespace string, opts ...AdapterOptions) (cache.Adapter, error) {
+	if client == nil {
+		return nil, errors.New("aerospike adapter client can not be nil")
+	}
+	if namespace == "" {
+		return nil, errors.New("aerospike adapter namespace can not be empty")
+	}
+
+	a := &Adapter{
+		client:      client,
+		namespace:   namespace,
+		set:         DefaultSet,
+		readPolicy:  as.NewPolicy(),
+		writePolicy: as.NewWritePolicy(0, 0),
+		maxRetries:  -1,
+	}
+
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return nil, err
+		}
+	}
+
+	// The policies are copied so that the options don't modify the ones
+	// given, and applied over them whatever the order of the options.
+	readPolicy, writePolicy := *a.readPolicy, *a.writePolicy
+	a.readPolicy, a.writePolicy = &readPolicy, &writePolicy
+	if a.timeout > 0 {
+		a.readPolicy.TotalTimeout = a.timeout
+		a.writePolicy.TotalTimeout = a.timeout
+	}
+	if a.maxRetries >= 0 {
+		a.readPolicy.MaxRetries = a.maxRetries
+		a.writePolicy.MaxRetries = a.maxRetries
+	}
+
+	return a, nil
+}
+
+// AdapterWithSet sets the set storing responses, and defaults to
+// DefaultSet.
+func AdapterWithSet(name string) AdapterOptions {
+	return func(a *Adapter) error {
+		if name == "" {
+			return errors.New("aerospike adapter set can not be empty")
+		}
+
+		a.set = name
+
+		return nil
+	}
+}
+
+// AdapterWithReadPolicy sets the policy of Get commands, and defaults to
+// aerospike.NewPolicy().
+func AdapterWithReadPolicy(policy *as.BasePolicy) AdapterOptions {
+	return func(a *Adapter) error {
+		if policy == nil {
+			return errors.New("aerospike adapter read policy can not be nil")
+		}
+
+		a.readPolicy = policy
+
+		return nil
+	}
+}
+
+// AdapterWithWritePolicy sets the policy of Set and Release commands, and
+// defaults to aerospike.NewWritePolicy(0, 0). Its expiration is replaced
+// by the TTL of each response.
+func AdapterWithWritePolicy(policy *as.WritePolicy) AdapterOptions {
+	return func(a *Adapter) error {
+		if policy == nil {
+			return errors.New("aerospike adapter write policy can not be nil")
+		}
+
+		a.writePolicy = policy
+
+		return nil
+	}
+}
+
+// AdapterWithTimeout sets the total timeout of every command, including
+// retries, overriding the one of the read and write policies.
+func AdapterWithTimeout(timeout time.Duration) AdapterOptions {
+	return func(a *Adapter) error {
+		if timeout <= 0 {
+			return fmt.Errorf("aerospike adapter timeout %v is invalid", timeout)
+		}
+
+		a.timeout = timeout
+
+		return nil
+	}
+}
+
+// AdapterWithMaxRetries sets the number of retries of every command,
+// overriding the one of the read and write policies. Write policies don't
+// retry by default.
+func AdapterWithMaxRetries(n int) AdapterOptions {
+	return func(a *Adapter) error {
+		if n < 0 {
+			return fmt.Errorf("aerospike adapter max retries %v is invalid", n)
+		}
+
+		a.maxRetries = n
+
+		return nil
+	}
+}
@@ -0,0 +1,135 @@
+package aerospike
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	as "github.com/aerospike/aerospike-client-go/v5"
+)
+
+type clientMock struct {
+	records  map[string][]byte
+	ttls     map[string]uint32
+	timeouts []time.Duration
+	retries  []int
+}
+
+func newClientMock() *clientMock {
+	return &clientMock{records: map[string][]byte{}, ttls: map[string]uint32{}}
+}
+
+func (c *clientMock) Get(policy *as.BasePolicy, key *as.Key, binNames ...string) (*as.Record, as.Error) {
+	c.timeouts = append(c.timeouts, policy.TotalTimeout)
+	c.retries = append(c.retries, policy.MaxRetries)
+	response, ok := c.records[key.String()]
+	if !ok {
+		return nil, as.ErrKeyNotFound
+	}
+	return &as.Record{Bins: as.BinMap{responseBin: response}}, nil
+}
+
+func (c *clientMock) Put(policy *as.WritePolicy, key *as.Key, binMap as.BinMap) as.Error {
+	c.timeouts = append(c.timeouts, policy.TotalTimeout)
+	c.retries = append(c.retries, policy.MaxRetries)
+	c.records[key.String()] = binMap[responseBin].([]byte)
+	c.ttls[key.String()] = policy.Expiration
+	return nil
+}
+
+func (c *clientMock) Delete(policy *as.WritePolicy, key *as.Key) (bool, as.Error) {
+	_, ok := c.records[key.String()]
+	delete(c.records, key.String())
+	return ok, nil
+}
+
+func TestNewAdapter(t *testing.T) {
+	tests := []struct {
+		name      string
+		client    Client
+		namespace string
+		opts      []AdapterOptions
+		wantErr   bool
+	}{
+		{
+			"returns new Adapter",
+			newClientMock(),
+			"cache",
+			[]AdapterOptions{
+				AdapterWithSet("responses"),
+				AdapterWithReadPolicy(as.NewPolicy()),
+				AdapterWithWritePolicy(as.NewWritePolicy(0, 0)),
+				AdapterWithTimeout(time.Second),
+				AdapterWithMaxRetries(3),
+			},
+			false,
+		},
+		{"returns client error", nil, "cache", nil, true},
+		{"returns namespace error", newClientMock(), "", nil, true},
+		{"returns set error", newClientMock(), "cache", []AdapterOptions{AdapterWithSet("")}, true},
+		{"returns read policy error", newClientMock(), "cache", []AdapterOptions{AdapterWithReadPolicy(nil)}, true},
+		{"returns write policy error", newClientMock(), "cache", []AdapterOptions{AdapterWithWritePolicy(nil)}, true},
+		{"returns timeout error", newClientMock(), "cache", []AdapterOptions{AdapterWithTimeout(0)}, true},
+		{"returns max retries error", newClientMock(), "cache", []AdapterOptions{AdapterWithMaxRetries(-1)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewAdapter(tt.client, tt.namespace, tt.opts...); (err != nil) != tt.wantErr {
+				t.Errorf("NewAdapter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAdapter(t *testing.T) {
+	client := newClientMock()
+	a, _ := NewAdapter(client, "cache")
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		key        string
+		response   string
+		expiration time.Time
+		wantTTL    uint32
+		wantOk     bool
+	}{
+		{"stores response", "foo", "value 1", time.Now().Add(90 * time.Second), 90, true},
+		{"stores response without expiration", "bar", "value 2", time.Time{}, as.TTLDontExpire, true},
+		{"releases expired response", "foo", "value 3", time.Now().Add(-time.Minute), 90, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a.Set(ctx, tt.key, []byte(tt.response), tt.expiration)
+			got, ok := a.Get(ctx, tt.key)
+			if ok != tt.wantOk || (ok && string(got) != tt.response) {
+				t.Errorf("aerospike.Get() = %q, %v, want %q, %v", got, ok, tt.response, tt.wantOk)
+			}
+			k, _ := as.NewKey("cache", DefaultSet, tt.key)
+			if ttl := client.ttls[k.String()]; ttl != tt.wantTTL {
+				t.Errorf("aerospike record ttl = %v, want %v", ttl, tt.wantTTL)
+			}
+		})
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	client := newClientMock()
+	write := as.NewWritePolicy(0, 0)
+	a, _ := NewAdapter(client, "cache", AdapterWithTimeout(time.Second), AdapterWithWritePolicy(write), AdapterWithMaxRetries(3))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	a.Set(ctx, "foo", []byte("value 1"), time.Time{})
+	a.Get(context.Background(), "foo")
+
+	if client.timeouts[0] > 100*time.Millisecond || client.timeouts[1] != time.Second {
+		t.Errorf("aerospike timeouts = %v, want the context deadline then 1s", client.timeouts)
+	}
+	if client.retries[0] != 3 || client.retries[1] != 3 {
+		t.Errorf("aerospike retries = %v, want 3", client.retries)
+	}
+	if write.TotalTimeout != 0 || write.MaxRetries != 0 {
+		t.Errorf("aerospike write policy modified: %+v", write)
+	}
+}
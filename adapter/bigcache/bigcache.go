@@ -0,0 +1,94 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package bigcache
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+
+	"github.com/allegro/bigcache"
+	cache "github.com/cludden/http-cache"
+)
+
+// expirationSize is the size of the expiration date stored before each
+// response, in Unix nanoseconds, zero for none.
+const expirationSize = 8
+
+// Adapter is the BigCache adapter data structure, storing responses in
+// BigCache byte shards which the garbage collector doesn't scan.
+//
+// BigCache evicts entries older than its LifeWindow, whatever their
+// expiration date, and its oldest entries when full, so the LifeWindow
+// should be at least the cache client TTL.
+type Adapter struct {
+	store *bigcache.BigCache
+}
+
+var _ cache.Adapter = (*Adapter)(nil)
+
+// Get implements the cache Adapter interface Get method. Entries past their
+// expiration date are released instead of returned.
+func (a *Adapter) Get(ctx context.Context, key string) ([]byte, bool) {
+	b, err := a.store.Get(key)
+	if err != nil || len(b) < expirationSize {
+		return nil, false
+	}
+	if expiration := int64(binary.BigEndian.Uint64(b)); expiration > 0 && expiration <= time.Now().UnixNano() {
+		a.store.Delete(key)
+		return nil, false
+	}
+
+	return b[expirationSize:], true
+}
+
+// Set implements the cache Adapter interface Set method. Responses already
+// past their expiration date release the stored one instead.
+func (a *Adapter) Set(ctx context.Context, key string, response []byte, expiration time.Time) {
+	var nanos int64
+	if !expiration.IsZero() {
+		if nanos = expiration.UnixNano(); nanos <= time.Now().UnixNano() {
+			a.store.Delete(key)
+			return
+		}
+	}
+
+	b := make([]byte, expirationSize+len(response))
+	binary.BigEndian.PutUint64(b, uint64(nanos))
+	copy(b[expirationSize:], response)
+	a.store.Set(key, b)
+}
+
+// Release implements the cache Adapter interface Release method.
+func (a *Adapter) Release(ctx context.Context, key string) {
+	a.store.Delete(key)
+}
+
+// NewAdapter initializes a BigCache adapter.
+func NewAdapter(c *bigcache.BigCache) cache.Adapter {
+	return &Adapter{
+		store: c,
+	}
+}
@@ -0,0 +1,53 @@
+package bigcache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/allegro/bigcache"
+)
+
+func TestAdapter(t *testing.T) {
+	store, err := bigcache.NewBigCache(bigcache.DefaultConfig(time.Hour))
+	if err != nil {
+		t.Fatalf("bigcache.NewBigCache() error = %v", err)
+	}
+	defer store.Close()
+	a := NewAdapter(store)
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		key        string
+		response   string
+		expiration time.Time
+		wantOk     bool
+	}{
+		{"stores response", "foo", "value 1", time.Now().Add(time.Minute), true},
+		{"stores response without expiration", "bar", "value 2", time.Time{}, true},
+		{"releases expired response", "foo", "value 3", time.Now().Add(-time.Minute), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a.Set(ctx, tt.key, []byte(tt.response), tt.expiration)
+			got, ok := a.Get(ctx, tt.key)
+			if ok != tt.wantOk || (ok && string(got) != tt.response) {
+				t.Errorf("bigcache.Get() = %q, %v, want %q, %v", got, ok, tt.response, tt.wantOk)
+			}
+		})
+	}
+
+	store.Set("baz", []byte{0, 0, 0, 0, 0, 0, 0, 1, 'x'})
+	if _, ok := a.Get(ctx, "baz"); ok {
+		t.Errorf("bigcache.Get() hit on an expired entry")
+	}
+	if _, err := store.Get("baz"); err == nil {
+		t.Errorf("bigcache.Get() kept an expired entry")
+	}
+
+	a.Release(ctx, "bar")
+	if _, ok := a.Get(ctx, "bar"); ok {
+		t.Errorf("bigcache.Get() hit after Release")
+	}
+}
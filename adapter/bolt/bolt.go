@@ -0,0 +1,309 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	bolt "go.etcd.io/bbolt"
+)
+
+// DefaultBucket is the bucket storing responses by default.
+const DefaultBucket = "http-cache"
+
+// expirationSize is the size of the expiration date stored before each
+// response, in Unix nanoseconds, zero for none.
+const expirationSize = 8
+
+// Adapter is the Bolt adapter data structure, storing responses in a bbolt
+// database so that they survive restarts without an external service.
+//
+// Responses are stored in a bucket by key, after their expiration date,
+// and indexed by expiration date in a second bucket for Collect. bbolt
+// reuses the pages freed by removed responses but never shrinks its file.
+type Adapter struct {
+	db              *bolt.DB
+	bucket          []byte
+	expirations     []byte
+	cleanupInterval time.Duration
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// AdapterOptions is used to set Adapter settings.
+type AdapterOptions func(a *Adapter) error
+
+var (
+	_ cache.Adapter             = (*Adapter)(nil)
+	_ cache.InvalidatingAdapter = (*Adapter)(nil)
+)
+
+// Get implements the cache Adapter interface Get method. Entries past their
+// expiration date are released instead of returned.
+func (a *Adapter) Get(ctx context.Context, key string) ([]byte, bool) {
+	var response []byte
+	var expired bool
+	a.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(a.bucket).Get([]byte(key))
+		if len(v) < expirationSize {
+			return nil
+		}
+		if expiration := int64(binary.BigEndian.Uint64(v)); expiration > 0 && expiration <= time.Now().UnixNano() {
+			expired = true
+			return nil
+		}
+		// Values are only valid during the transaction.
+		response = append([]byte{}, v[expirationSize:]...)
+		return nil
+	})
+	if expired {
+		a.Release(ctx, key)
+	}
+
+	return response, response != nil
+}
+
+// Set implements the cache Adapter interface Set method. Responses already
+// past their expiration date release the stored one instead.
+func (a *Adapter) Set(ctx context.Context, key string, response []byte, expiration time.Time) {
+	var nanos int64
+	if !expiration.IsZero() {
+		if nanos = expiration.UnixNano(); nanos <= time.Now().UnixNano() {
+			a.Release(ctx, key)
+			return
+		}
+	}
+
+	v := make([]byte, expirationSize+len(response))
+	binary.BigEndian.PutUint64(v, uint64(nanos))
+	copy(v[expirationSize:], response)
+	a.db.Update(func(tx *bolt.Tx) error {
+		if err := a.delete(tx, []byte(key)); err != nil {
+			return err
+		}
+		if nanos > 0 {
+			if err := tx.Bucket(a.expirations).Put(expirationKey(v, key), nil); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(a.bucket).Put([]byte(key), v)
+	})
+}
+
+// Release implements the cache Adapter interface Release method.
+func (a *Adapter) Release(ctx context.Context, key string) {
+	a.db.Update(func(tx *bolt.Tx) error {
+		return a.delete(tx, []byte(key))
+	})
+}
+
+// InvalidatePrefix implements the cache InvalidatingAdapter interface
+// InvalidatePrefix method, seeking the keys in order.
+func (a *Adapter) InvalidatePrefix(ctx context.Context, prefix string) error {
+	return a.invalidate(ctx, []byte(prefix), func(key []byte) bool {
+		return bytes.HasPrefix(key, []byte(prefix))
+	})
+}
+
+// InvalidatePattern implements the cache InvalidatingAdapter interface
+// InvalidatePattern method, scanning keys.
+func (a *Adapter) InvalidatePattern(ctx context.Context, pattern string) error {
+	return a.invalidate(ctx, nil, func(key []byte) bool {
+		return cache.MatchPattern(pattern, string(key))
+	})
+}
+
+// Collect removes the responses past their expiration date, seeking them
+// in the expiration date index.
+func (a *Adapter) Collect(ctx context.Context) error {
+	now := make([]byte, expirationSize)
+	binary.BigEndian.PutUint64(now, uint64(time.Now().UnixNano()))
+
+	return a.db.Update(func(tx *bolt.Tx) error {
+		var keys [][]byte
+		c := tx.Bucket(a.expirations).Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k[:expirationSize], now) <= 0; k, _ = c.Next() {
+			keys = append(keys, append([]byte{}, k[expirationSize:]...))
+		}
+		for _, key := range keys {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := a.delete(tx, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close stops the cleanup goroutine started with AdapterWithCleanupInterval,
+// if any. It doesn't close the database. The adapter returned by NewAdapter
+// implements io.Closer.
+func (a *Adapter) Close() error {
+	a.closeOnce.Do(func() {
+		if a.done != nil {
+			close(a.done)
+		}
+	})
+
+	return nil
+}
+
+// invalidate deletes the keys matching a function, from the first key
+// after start.
+func (a *Adapter) invalidate(ctx context.Context, start []byte, match func(key []byte) bool) error {
+	return a.db.Update(func(tx *bolt.Tx) error {
+		var keys [][]byte
+		c := tx.Bucket(a.bucket).Cursor()
+		for k, _ := c.Seek(start); k != nil; k, _ = c.Next() {
+			if match(k) {
+				keys = append(keys, append([]byte{}, k...))
+			} else if start != nil {
+				break
+			}
+		}
+		for _, key := range keys {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := a.delete(tx, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// delete removes a key and its expiration date index entry.
+func (a *Adapter) delete(tx *bolt.Tx, key []byte) error {
+	b := tx.Bucket(a.bucket)
+	v := b.Get(key)
+	if v == nil {
+		return nil
+	}
+	if len(v) >= expirationSize && binary.BigEndian.Uint64(v) > 0 {
+		if err := tx.Bucket(a.expirations).Delete(expirationKey(v, string(key))); err != nil {
+			return err
+		}
+	}
+
+	return b.Delete(key)
+}
+
+// expirationKey returns the expiration date index key of a stored value,
+// its expiration date followed by its key.
+func expirationKey(v []byte, key string) []byte {
+	return append(append([]byte{}, v[:expirationSize]...), key...)
+}
+
+func (a *Adapter) cleanup() {
+	ticker := time.NewTicker(a.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.Collect(context.Background())
+		case <-a.done:
+			return
+		}
+	}
+}
+
+// NewAdapter initializes a Bolt adapter, creating its buckets in db if
+// needed.
+func NewAdapter(db *bolt.DB, opts ...AdapterOptions) (cache.Adapter, error) {
+	if db == nil {
+		return nil, errors.New("bolt adapter database can not be nil")
+	}
+
+	a := &Adapter{
+		db:     db,
+		bucket: []byte(DefaultBucket),
+	}
+
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return nil, err
+		}
+	}
+	a.expirations = append(append([]byte{}, a.bucket...), ":expirations"...)
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(a.bucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(a.expirations)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bolt adapter buckets: %w", err)
+	}
+
+	if a.cleanupInterval > 0 {
+		a.done = make(chan struct{})
+		go a.cleanup()
+	}
+
+	return a, nil
+}
+
+// AdapterWithBucket sets the bucket storing responses, and defaults to
+// DefaultBucket.
+func AdapterWithBucket(name string) AdapterOptions {
+	return func(a *Adapter) error {
+		if name == "" {
+			return errors.New("bolt adapter bucket can not be empty")
+		}
+
+		a.bucket = []byte(name)
+
+		return nil
+	}
+}
+
+// AdapterWithCleanupInterval starts a goroutine calling Collect every
+// interval, so that responses which are never requested again don't hold
+// disk space. Close stops it.
+func AdapterWithCleanupInterval(interval time.Duration) AdapterOptions {
+	return func(a *Adapter) error {
+		if interval <= 0 {
+			return fmt.Errorf("bolt adapter cleanup interval %v is invalid", interval)
+		}
+
+		a.cleanupInterval = interval
+
+		return nil
+	}
+}
@@ -0,0 +1,146 @@
+package bolt
+
+import (
+	"context"
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func openDB(t *testing.T) *bolt.DB {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "cache.db"), 0o600, nil)
+	if err != nil {
+		t.Fatalf("bolt.Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestNewAdapter(t *testing.T) {
+	tests := []struct {
+		name    string
+		nilDB   bool
+		opts    []AdapterOptions
+		wantErr bool
+	}{
+		{"returns new Adapter", false, []AdapterOptions{AdapterWithBucket("responses"), AdapterWithCleanupInterval(time.Minute)}, false},
+		{"returns database error", true, nil, true},
+		{"returns bucket error", false, []AdapterOptions{AdapterWithBucket("")}, true},
+		{"returns cleanup interval error", false, []AdapterOptions{AdapterWithCleanupInterval(0)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openDB(t)
+			if tt.nilDB {
+				db = nil
+			}
+			a, err := NewAdapter(db, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewAdapter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				a.(*Adapter).Close()
+			}
+		})
+	}
+}
+
+func TestAdapter(t *testing.T) {
+	a, _ := NewAdapter(openDB(t))
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		key        string
+		response   string
+		expiration time.Time
+		wantOk     bool
+	}{
+		{"stores response", "foo", "value 1", time.Now().Add(time.Minute), true},
+		{"stores response without expiration", "bar", "value 2", time.Time{}, true},
+		{"replaces response", "foo", "value 3", time.Now().Add(2 * time.Minute), true},
+		{"releases expired response", "bar", "value 4", time.Now().Add(-time.Minute), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a.Set(ctx, tt.key, []byte(tt.response), tt.expiration)
+			got, ok := a.Get(ctx, tt.key)
+			if ok != tt.wantOk || (ok && string(got) != tt.response) {
+				t.Errorf("bolt.Get() = %q, %v, want %q, %v", got, ok, tt.response, tt.wantOk)
+			}
+		})
+	}
+
+	a.Release(ctx, "foo")
+	if _, ok := a.Get(ctx, "foo"); ok {
+		t.Errorf("bolt.Get() hit after Release")
+	}
+	if n := countKeys(t, a.(*Adapter)); n != 0 {
+		t.Errorf("bolt keys = %v, want 0", n)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	a, _ := NewAdapter(openDB(t))
+	ctx := context.Background()
+	a.Set(ctx, "foo", []byte("value 1"), time.Now().Add(10*time.Millisecond))
+	a.Set(ctx, "bar", []byte("value 2"), time.Now().Add(time.Minute))
+	a.Set(ctx, "baz", []byte("value 3"), time.Time{})
+
+	time.Sleep(20 * time.Millisecond)
+	if err := a.(*Adapter).Collect(ctx); err != nil {
+		t.Fatalf("bolt.Collect() error = %v", err)
+	}
+	if n := countKeys(t, a.(*Adapter)); n != 2 {
+		t.Errorf("bolt keys = %v, want 2", n)
+	}
+	if _, ok := a.Get(ctx, "bar"); !ok {
+		t.Errorf("bolt.Get(bar) miss after Collect")
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	a, _ := NewAdapter(openDB(t))
+	ctx := context.Background()
+	for _, key := range []string{"/api/1", "/api/2", "/web/1", "/web/12"} {
+		a.Set(ctx, key, []byte(key), time.Now().Add(time.Minute))
+	}
+
+	a.(*Adapter).InvalidatePrefix(ctx, "/api/")
+	a.(*Adapter).InvalidatePattern(ctx, "/web/?")
+	for key, want := range map[string]bool{"/api/1": false, "/api/2": false, "/web/1": false, "/web/12": true} {
+		if _, ok := a.Get(ctx, key); ok != want {
+			t.Errorf("bolt.Get(%v) = %v after invalidation, want %v", key, ok, want)
+		}
+	}
+	if n := countKeys(t, a.(*Adapter)); n != 1 {
+		t.Errorf("bolt keys = %v, want 1", n)
+	}
+}
+
+// countKeys returns the number of keys stored, checking that the
+// expiration date index matches.
+func countKeys(t *testing.T, a *Adapter) int {
+	t.Helper()
+	var keys, expiring, indexed int
+	a.db.View(func(tx *bolt.Tx) error {
+		tx.Bucket(a.bucket).ForEach(func(k, v []byte) error {
+			keys++
+			if binary.BigEndian.Uint64(v) != 0 {
+				expiring++
+			}
+			return nil
+		})
+		indexed = tx.Bucket(a.expirations).Stats().KeyN
+		return nil
+	})
+	if indexed != expiring {
+		t.Errorf("bolt indexed expirations = %v, want %v", indexed, expiring)
+	}
+
+	return keys
+}
@@ -0,0 +1,186 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+)
+
+// Adapter is the chain adapter data structure, writing to several adapters
+// and reading from them in priority order, such as a Redis adapter backed by
+// a memory adapter during Redis maintenance windows.
+//
+// Reads are served by the first adapter that answers, whether it holds the
+// response or not, skipping the ones that fail or time out. Writes and
+// releases are applied to every adapter, failing when any of them fails.
+type Adapter struct {
+	adapters []cache.AdapterV2
+	timeout  time.Duration
+}
+
+// AdapterOptions is used to set Adapter settings.
+type AdapterOptions func(a *Adapter) error
+
+// NewAdapter initializes a chain adapter with adapters in priority order,
+// each implementing either cache.Adapter or cache.AdapterV2. Failures are
+// only detected for the latter, along with timeouts.
+func NewAdapter(adapters []interface{}, opts ...AdapterOptions) (cache.AdapterV2, error) {
+	if len(adapters) == 0 {
+		return nil, errors.New("chain adapter adapters can not be empty")
+	}
+
+	a := &Adapter{}
+	for _, adapter := range adapters {
+		switch adapter := adapter.(type) {
+		case cache.AdapterV2:
+			a.adapters = append(a.adapters, adapter)
+		case cache.Adapter:
+			a.adapters = append(a.adapters, cache.AdapterV2Of(adapter))
+		default:
+			return nil, fmt.Errorf("chain adapter adapter type %T is not supported", adapter)
+		}
+	}
+
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+// AdapterWithTimeout sets how long each adapter operation may take before
+// the adapter is skipped, with no limit by default.
+func AdapterWithTimeout(d time.Duration) AdapterOptions {
+	return func(a *Adapter) error {
+		if d <= 0 {
+			return fmt.Errorf("chain adapter timeout %v is invalid", d)
+		}
+
+		a.timeout = d
+
+		return nil
+	}
+}
+
+// Get implements the cache.AdapterV2 interface Get method, failing only
+// when every adapter fails.
+func (a *Adapter) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	var err error
+	for i, adapter := range a.adapters {
+		adapter := adapter
+		var response []byte
+		var ok bool
+		err = a.call(ctx, func(ctx context.Context) error {
+			var err error
+			response, ok, err = adapter.Get(ctx, key)
+			return err
+		})
+		if err == nil {
+			return response, ok, nil
+		}
+		err = fmt.Errorf("chain adapter %d get: %w", i, err)
+	}
+
+	return nil, false, err
+}
+
+// Set implements the cache.AdapterV2 interface Set method.
+func (a *Adapter) Set(ctx context.Context, key string, response []byte, expiration time.Time) error {
+	return a.each(ctx, "set", func(ctx context.Context, adapter cache.AdapterV2) error {
+		return adapter.Set(ctx, key, response, expiration)
+	})
+}
+
+// Release implements the cache.AdapterV2 interface Release method.
+func (a *Adapter) Release(ctx context.Context, key string) error {
+	return a.each(ctx, "release", func(ctx context.Context, adapter cache.AdapterV2) error {
+		return adapter.Release(ctx, key)
+	})
+}
+
+// Ping implements the cache.HealthChecker interface Ping method, failing
+// only when every adapter fails, as Get does. Adapters not implementing it
+// are healthy.
+func (a *Adapter) Ping(ctx context.Context) error {
+	var err error
+	for i, adapter := range a.adapters {
+		checker, ok := cache.UnwrapAdapterV2(adapter).(cache.HealthChecker)
+		if !ok {
+			return nil
+		}
+		if err = a.call(ctx, checker.Ping); err == nil {
+			return nil
+		}
+		err = fmt.Errorf("chain adapter %d ping: %w", i, err)
+	}
+
+	return err
+}
+
+// each applies op to every adapter, returning the first failure.
+func (a *Adapter) each(ctx context.Context, name string, op func(context.Context, cache.AdapterV2) error) error {
+	var first error
+	for i, adapter := range a.adapters {
+		adapter := adapter
+		err := a.call(ctx, func(ctx context.Context) error {
+			return op(ctx, adapter)
+		})
+		if err != nil && first == nil {
+			first = fmt.Errorf("chain adapter %d %s: %w", i, name, err)
+		}
+	}
+
+	return first
+}
+
+// call runs an adapter operation, giving up once the timeout, if any,
+// elapses.
+func (a *Adapter) call(ctx context.Context, op func(context.Context) error) error {
+	if a.timeout <= 0 {
+		return op(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- op(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,181 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+)
+
+type adapterMock struct {
+	sync.Mutex
+	store map[string][]byte
+	err   error
+	delay time.Duration
+}
+
+func newAdapterMock() *adapterMock {
+	return &adapterMock{store: map[string][]byte{}}
+}
+
+func (a *adapterMock) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	time.Sleep(a.delay)
+	a.Lock()
+	defer a.Unlock()
+	b, ok := a.store[key]
+	return b, ok, a.err
+}
+
+func (a *adapterMock) Set(ctx context.Context, key string, response []byte, expiration time.Time) error {
+	a.Lock()
+	defer a.Unlock()
+	if a.err == nil {
+		a.store[key] = response
+	}
+	return a.err
+}
+
+func (a *adapterMock) Release(ctx context.Context, key string) error {
+	a.Lock()
+	defer a.Unlock()
+	if a.err == nil {
+		delete(a.store, key)
+	}
+	return a.err
+}
+
+type legacyAdapterMock struct {
+	sync.Mutex
+	store map[string][]byte
+}
+
+func (a *legacyAdapterMock) Get(ctx context.Context, key string) ([]byte, bool) {
+	a.Lock()
+	defer a.Unlock()
+	b, ok := a.store[key]
+	return b, ok
+}
+
+func (a *legacyAdapterMock) Set(ctx context.Context, key string, response []byte, expiration time.Time) {
+	a.Lock()
+	defer a.Unlock()
+	a.store[key] = response
+}
+
+func (a *legacyAdapterMock) Release(ctx context.Context, key string) {
+	a.Lock()
+	defer a.Unlock()
+	delete(a.store, key)
+}
+
+func TestNewAdapter(t *testing.T) {
+	tests := []struct {
+		name     string
+		adapters []interface{}
+		opts     []AdapterOptions
+		wantErr  bool
+	}{
+		{"returns new Adapter", []interface{}{newAdapterMock(), &legacyAdapterMock{}}, []AdapterOptions{AdapterWithTimeout(1 * time.Second)}, false},
+		{"returns error on no adapters", nil, nil, true},
+		{"returns error on unsupported adapter", []interface{}{"redis"}, nil, true},
+		{"returns error on invalid timeout", []interface{}{newAdapterMock()}, []AdapterOptions{AdapterWithTimeout(0)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAdapter(tt.adapters, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewAdapter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAdapter(t *testing.T) {
+	errDown := errors.New("down")
+	tests := []struct {
+		name     string
+		primary  *adapterMock
+		wantGet  []byte
+		wantOk   bool
+		wantErr  bool
+		wantKeys int
+	}{
+		{"reads from primary", &adapterMock{store: map[string][]byte{"foo": []byte("primary")}}, []byte("primary"), true, false, 1},
+		{"trusts primary miss", newAdapterMock(), nil, false, false, 1},
+		{"falls back on error", &adapterMock{store: map[string][]byte{}, err: errDown}, []byte("fallback"), true, true, 0},
+		{"falls back on timeout", &adapterMock{store: map[string][]byte{"foo": []byte("primary")}, delay: 50 * time.Millisecond}, []byte("fallback"), true, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fallback := &legacyAdapterMock{store: map[string][]byte{"foo": []byte("fallback")}}
+			a, _ := NewAdapter([]interface{}{tt.primary, fallback}, AdapterWithTimeout(10*time.Millisecond))
+			ctx := context.Background()
+
+			b, ok, err := a.Get(ctx, "foo")
+			if err != nil || ok != tt.wantOk || !reflect.DeepEqual(b, tt.wantGet) {
+				t.Errorf("chain.Get() = %s, %v, %v, want %s, %v", b, ok, err, tt.wantGet, tt.wantOk)
+			}
+
+			err = a.Set(ctx, "bar", []byte("value"), time.Now().Add(1*time.Minute))
+			if (err != nil) != tt.wantErr || !errors.Is(err, tt.primary.err) {
+				t.Errorf("chain.Set() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, ok := fallback.store["bar"]; !ok {
+				t.Errorf("chain.Set() did not write to fallback")
+			}
+
+			a.Release(ctx, "foo")
+			if _, ok := fallback.store["foo"]; ok {
+				t.Errorf("chain.Release() did not release fallback")
+			}
+			tt.primary.Lock()
+			if len(tt.primary.store) != tt.wantKeys {
+				t.Errorf("primary store length = %v, want %v", len(tt.primary.store), tt.wantKeys)
+			}
+			tt.primary.Unlock()
+		})
+	}
+}
+
+func TestAdapterGetFailure(t *testing.T) {
+	a, _ := NewAdapter([]interface{}{&adapterMock{err: errors.New("down")}})
+	if _, ok, err := a.Get(context.Background(), "foo"); ok || err == nil {
+		t.Errorf("chain.Get() = %v, %v, want error", ok, err)
+	}
+}
+
+type healthCheckerMock struct {
+	*adapterMock
+	err error
+}
+
+func (a healthCheckerMock) Ping(ctx context.Context) error {
+	return a.err
+}
+
+func TestAdapterPing(t *testing.T) {
+	down := errors.New("connection refused")
+	tests := []struct {
+		name     string
+		adapters []interface{}
+		wantErr  bool
+	}{
+		{"healthy", []interface{}{healthCheckerMock{newAdapterMock(), nil}, healthCheckerMock{newAdapterMock(), nil}}, false},
+		{"one unhealthy", []interface{}{healthCheckerMock{newAdapterMock(), down}, healthCheckerMock{newAdapterMock(), nil}}, false},
+		{"all unhealthy", []interface{}{healthCheckerMock{newAdapterMock(), down}, healthCheckerMock{newAdapterMock(), down}}, true},
+		{"unsupported", []interface{}{healthCheckerMock{newAdapterMock(), down}, &legacyAdapterMock{}}, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := NewAdapter(tt.adapters)
+			if err := a.(cache.HealthChecker).Ping(context.Background()); (err != nil) != tt.wantErr {
+				t.Errorf("Adapter.Ping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,325 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	cache "github.com/cludden/http-cache"
+)
+
+const (
+	// DefaultTable is the table storing responses by default.
+	DefaultTable = "http-cache"
+
+	// DefaultPartitionKey is the string partition key of the table by
+	// default.
+	DefaultPartitionKey = "key"
+
+	// DefaultTTLAttribute is the number attribute holding the expiration
+	// date of the responses by default, in Unix seconds, to be enabled as
+	// the time to live attribute of the table.
+	DefaultTTLAttribute = "expiration"
+)
+
+// responseAttribute is the binary attribute holding the responses.
+const responseAttribute = "response"
+
+// batchSize is the maximum number of requests of a BatchWriteItem call.
+const batchSize = 25
+
+// maxRetries is the number of times the unprocessed requests of a
+// BatchWriteItem call are retried.
+const maxRetries = 8
+
+// retryDelay is the delay before the first retry of unprocessed requests,
+// doubled on each retry as they are left unprocessed when throttled.
+var retryDelay = 50 * time.Millisecond
+
+// Client is the part of the DynamoDB API used by the adapter, implemented by
+// *dynamodb.Client and by DAX clients.
+type Client interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+// Adapter is the DynamoDB adapter data structure, storing responses in a
+// DynamoDB table keyed by a string partition key.
+//
+// DynamoDB deletes expired items up to days after their time to live, so
+// the adapter doesn't return responses past their expiration date either.
+type Adapter struct {
+	client         Client
+	table          string
+	partitionKey   string
+	ttlAttribute   string
+	consistentRead bool
+}
+
+// AdapterOptions is used to set Adapter settings.
+type AdapterOptions func(a *Adapter) error
+
+var (
+	_ cache.Adapter             = (*Adapter)(nil)
+	_ cache.InvalidatingAdapter = (*Adapter)(nil)
+)
+
+// Get implements the cache Adapter interface Get method. Entries past their
+// expiration date are not returned.
+func (a *Adapter) Get(ctx context.Context, key string) ([]byte, bool) {
+	out, err := a.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      &a.table,
+		Key:            a.key(key),
+		ConsistentRead: &a.consistentRead,
+	})
+	if err != nil || out.Item == nil {
+		return nil, false
+	}
+	if ttl, ok := out.Item[a.ttlAttribute].(*types.AttributeValueMemberN); ok {
+		if expiration, err := strconv.ParseInt(ttl.Value, 10, 64); err != nil || expiration <= time.Now().Unix() {
+			return nil, false
+		}
+	}
+	response, ok := out.Item[responseAttribute].(*types.AttributeValueMemberB)
+	if !ok {
+		return nil, false
+	}
+
+	return response.Value, true
+}
+
+// Set implements the cache Adapter interface Set method. Responses already
+// past their expiration date release the stored one instead.
+func (a *Adapter) Set(ctx context.Context, key string, response []byte, expiration time.Time) {
+	if !expiration.IsZero() && !expiration.After(time.Now()) {
+		a.Release(ctx, key)
+		return
+	}
+
+	item := a.key(key)
+	item[responseAttribute] = &types.AttributeValueMemberB{Value: response}
+	if !expiration.IsZero() {
+		item[a.ttlAttribute] = &types.AttributeValueMemberN{Value: strconv.FormatInt(expiration.Unix(), 10)}
+	}
+	a.client.PutItem(ctx, &dynamodb.PutItemInput{TableName: &a.table, Item: item})
+}
+
+// Release implements the cache Adapter interface Release method.
+func (a *Adapter) Release(ctx context.Context, key string) {
+	a.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{TableName: &a.table, Key: a.key(key)})
+}
+
+// InvalidatePrefix implements the cache InvalidatingAdapter interface
+// InvalidatePrefix method, scanning the table.
+func (a *Adapter) InvalidatePrefix(ctx context.Context, prefix string) error {
+	keys, err := a.scan(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	return a.delete(ctx, keys)
+}
+
+// InvalidatePattern implements the cache InvalidatingAdapter interface
+// InvalidatePattern method, scanning the table for the keys starting with
+// the literal prefix of the pattern.
+func (a *Adapter) InvalidatePattern(ctx context.Context, pattern string) error {
+	keys, err := a.scan(ctx, cache.PatternPrefix(pattern))
+	if err != nil {
+		return err
+	}
+
+	matching := keys[:0]
+	for _, key := range keys {
+		if cache.MatchPattern(pattern, key) {
+			matching = append(matching, key)
+		}
+	}
+
+	return a.delete(ctx, matching)
+}
+
+// key returns the primary key of the item of a key.
+func (a *Adapter) key(key string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{a.partitionKey: &types.AttributeValueMemberS{Value: key}}
+}
+
+// scan returns the keys starting with prefix.
+func (a *Adapter) scan(ctx context.Context, prefix string) ([]string, error) {
+	in := &dynamodb.ScanInput{
+		TableName:                &a.table,
+		ProjectionExpression:     strPtr("#k"),
+		ExpressionAttributeNames: map[string]string{"#k": a.partitionKey},
+	}
+	if prefix != "" {
+		in.FilterExpression = strPtr("begins_with(#k, :prefix)")
+		in.ExpressionAttributeValues = map[string]types.AttributeValue{":prefix": &types.AttributeValueMemberS{Value: prefix}}
+	}
+
+	var keys []string
+	for {
+		out, err := a.client.Scan(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range out.Items {
+			if key, ok := item[a.partitionKey].(*types.AttributeValueMemberS); ok {
+				keys = append(keys, key.Value)
+			}
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			return keys, nil
+		}
+		in.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// delete deletes keys in batches, retrying the unprocessed requests with an
+// exponential backoff.
+func (a *Adapter) delete(ctx context.Context, keys []string) error {
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > batchSize {
+			n = batchSize
+		}
+		requests := make([]types.WriteRequest, n)
+		for i, key := range keys[:n] {
+			requests[i] = types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: a.key(key)}}
+		}
+		keys = keys[n:]
+
+		for retry := 0; len(requests) > 0; retry++ {
+			if retry > 0 {
+				if retry > maxRetries {
+					return fmt.Errorf("dynamodb adapter %d deletes unprocessed after %d retries", len(requests), maxRetries)
+				}
+				timer := time.NewTimer(retryDelay << (retry - 1))
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				}
+			}
+
+			out, err := a.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems: map[string][]types.WriteRequest{a.table: requests},
+			})
+			if err != nil {
+				return err
+			}
+			requests = out.UnprocessedItems[a.table]
+		}
+	}
+
+	return nil
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+// NewAdapter initializes a DynamoDB adapter.
+func NewAdapter(client Client, opts ...AdapterOptions) (cache.Adapter, error) {
+	if client == nil {
+		return nil, errors.New("dynamodb adapter client can not be nil")
+	}
+
+	a := &Adapter{
+		client:       client,
+		table:        DefaultTable,
+		partitionKey: DefaultPartitionKey,
+		ttlAttribute: DefaultTTLAttribute,
+	}
+
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+// AdapterWithTable sets the table storing responses, and defaults to
+// DefaultTable.
+func AdapterWithTable(name string) AdapterOptions {
+	return func(a *Adapter) error {
+		if name == "" {
+			return errors.New("dynamodb adapter table can not be empty")
+		}
+
+		a.table = name
+
+		return nil
+	}
+}
+
+// AdapterWithPartitionKey sets the string partition key of the table, and
+// defaults to DefaultPartitionKey.
+func AdapterWithPartitionKey(name string) AdapterOptions {
+	return func(a *Adapter) error {
+		if name == "" {
+			return errors.New("dynamodb adapter partition key can not be empty")
+		}
+
+		a.partitionKey = name
+
+		return nil
+	}
+}
+
+// AdapterWithTTLAttribute sets the attribute holding the expiration dates,
+// and defaults to DefaultTTLAttribute.
+func AdapterWithTTLAttribute(name string) AdapterOptions {
+	return func(a *Adapter) error {
+		if name == "" || name == responseAttribute {
+			return fmt.Errorf("dynamodb adapter ttl attribute %v is invalid", name)
+		}
+
+		a.ttlAttribute = name
+
+		return nil
+	}
+}
+
+// AdapterWithConsistentRead makes Get use strongly consistent reads, so
+// that responses written or released by other instances are seen at once,
+// for twice the read capacity. DAX doesn't cache strongly consistent reads.
+func AdapterWithConsistentRead(enabled bool) AdapterOptions {
+	return func(a *Adapter) error {
+		a.consistentRead = enabled
+		return nil
+	}
+}
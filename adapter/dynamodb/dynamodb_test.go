@@ -0,0 +1,238 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// clientMock is an in-memory table keyed by the "key" attribute, scanned
+// by pages of two items, leaving every delete request unprocessed once, or
+// always when throttled.
+type clientMock struct {
+	sync.Mutex
+	items       map[string]map[string]types.AttributeValue
+	batches     int
+	unprocessed map[string]bool
+	throttled   bool
+}
+
+func newClientMock() *clientMock {
+	return &clientMock{items: map[string]map[string]types.AttributeValue{}, unprocessed: map[string]bool{}}
+}
+
+func keyOf(item map[string]types.AttributeValue) string {
+	return item["key"].(*types.AttributeValueMemberS).Value
+}
+
+func (c *clientMock) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	c.Lock()
+	defer c.Unlock()
+	return &dynamodb.GetItemOutput{Item: c.items[keyOf(params.Key)]}, nil
+}
+
+func (c *clientMock) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	c.Lock()
+	defer c.Unlock()
+	c.items[keyOf(params.Item)] = params.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (c *clientMock) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.items, keyOf(params.Key))
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (c *clientMock) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	c.Lock()
+	defer c.Unlock()
+	c.batches++
+	out := &dynamodb.BatchWriteItemOutput{UnprocessedItems: map[string][]types.WriteRequest{}}
+	for table, requests := range params.RequestItems {
+		for _, r := range requests {
+			key := keyOf(r.DeleteRequest.Key)
+			if !c.unprocessed[key] || c.throttled {
+				c.unprocessed[key] = true
+				out.UnprocessedItems[table] = append(out.UnprocessedItems[table], r)
+				continue
+			}
+			delete(c.items, key)
+		}
+	}
+	return out, nil
+}
+
+func (c *clientMock) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	c.Lock()
+	defer c.Unlock()
+	var prefix string
+	if params.FilterExpression != nil {
+		prefix = params.ExpressionAttributeValues[":prefix"].(*types.AttributeValueMemberS).Value
+	}
+	var keys []string
+	for key := range c.items {
+		if params.ExclusiveStartKey == nil || key > keyOf(params.ExclusiveStartKey) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	out := &dynamodb.ScanOutput{}
+	for i, key := range keys {
+		if i == 2 {
+			out.LastEvaluatedKey = c.items[keys[1]]
+			break
+		}
+		if strings.HasPrefix(key, prefix) {
+			out.Items = append(out.Items, map[string]types.AttributeValue{"key": &types.AttributeValueMemberS{Value: key}})
+		}
+	}
+	return out, nil
+}
+
+func TestNewAdapter(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  Client
+		opts    []AdapterOptions
+		wantErr bool
+	}{
+		{
+			"returns new Adapter",
+			newClientMock(),
+			[]AdapterOptions{
+				AdapterWithTable("responses"),
+				AdapterWithPartitionKey("id"),
+				AdapterWithTTLAttribute("ttl"),
+				AdapterWithConsistentRead(true),
+			},
+			false,
+		},
+		{"returns client error", nil, nil, true},
+		{"returns table error", newClientMock(), []AdapterOptions{AdapterWithTable("")}, true},
+		{"returns partition key error", newClientMock(), []AdapterOptions{AdapterWithPartitionKey("")}, true},
+		{"returns ttl attribute error", newClientMock(), []AdapterOptions{AdapterWithTTLAttribute("response")}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewAdapter(tt.client, tt.opts...); (err != nil) != tt.wantErr {
+				t.Errorf("NewAdapter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAdapter(t *testing.T) {
+	client := newClientMock()
+	a, _ := NewAdapter(client)
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		key        string
+		response   string
+		expiration time.Time
+		wantOk     bool
+	}{
+		{"stores response", "foo", "value 1", time.Now().Add(time.Minute), true},
+		{"stores response without expiration", "bar", "value 2", time.Time{}, true},
+		{"releases expired response", "foo", "value 3", time.Now().Add(-time.Minute), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a.Set(ctx, tt.key, []byte(tt.response), tt.expiration)
+			got, ok := a.Get(ctx, tt.key)
+			if ok != tt.wantOk || (ok && string(got) != tt.response) {
+				t.Errorf("dynamodb.Get() = %q, %v, want %q, %v", got, ok, tt.response, tt.wantOk)
+			}
+		})
+	}
+
+	// DynamoDB deletes expired items lazily.
+	client.items["baz"] = map[string]types.AttributeValue{
+		"key":        &types.AttributeValueMemberS{Value: "baz"},
+		"response":   &types.AttributeValueMemberB{Value: []byte("value 4")},
+		"expiration": &types.AttributeValueMemberN{Value: "1"},
+	}
+	if _, ok := a.Get(ctx, "baz"); ok {
+		t.Errorf("dynamodb.Get() hit on an expired item")
+	}
+
+	a.Release(ctx, "bar")
+	if _, ok := a.Get(ctx, "bar"); ok {
+		t.Errorf("dynamodb.Get() hit after Release")
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	client := newClientMock()
+	a, _ := NewAdapter(client)
+	ctx := context.Background()
+	keys := []string{"/web/1", "/web/12"}
+	for i := 0; i < 30; i++ {
+		keys = append(keys, "/api/"+string(rune('a'+i)))
+	}
+	for _, key := range keys {
+		a.Set(ctx, key, []byte(key), time.Time{})
+	}
+
+	if err := a.(*Adapter).InvalidatePrefix(ctx, "/api/"); err != nil {
+		t.Fatalf("dynamodb.InvalidatePrefix() error = %v", err)
+	}
+	if err := a.(*Adapter).InvalidatePattern(ctx, "/web/?"); err != nil {
+		t.Fatalf("dynamodb.InvalidatePattern() error = %v", err)
+	}
+	if len(client.items) != 1 || client.items["/web/12"] == nil {
+		t.Errorf("dynamodb items = %v, want /web/12", len(client.items))
+	}
+	if client.batches != 6 {
+		t.Errorf("dynamodb batches = %v, want 6", client.batches)
+	}
+}
+
+func TestInvalidateThrottled(t *testing.T) {
+	defer func(delay time.Duration) { retryDelay = delay }(retryDelay)
+	retryDelay = time.Millisecond
+
+	tests := []struct {
+		name         string
+		timeout      time.Duration
+		wantDeadline bool
+	}{
+		{"gives up after the retries", time.Minute, false},
+		{"stops when the context is done", 20 * time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			client := newClientMock()
+			client.throttled = true
+			a, _ := NewAdapter(client)
+			a.Set(context.Background(), "/api/1", []byte("1"), time.Time{})
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			err := a.(*Adapter).InvalidatePrefix(ctx, "/api/")
+			if err == nil {
+				t.Fatal("dynamodb.InvalidatePrefix() error = nil, want an error")
+			}
+			if got := errors.Is(err, context.DeadlineExceeded); got != tt.wantDeadline {
+				t.Errorf("dynamodb.InvalidatePrefix() error = %v, want deadline %v", err, tt.wantDeadline)
+			}
+			if tt.wantDeadline && client.batches > maxRetries {
+				t.Errorf("dynamodb batches = %v, want fewer than %v", client.batches, maxRetries+1)
+			}
+			if !tt.wantDeadline && client.batches != maxRetries+1 {
+				t.Errorf("dynamodb batches = %v, want %v", client.batches, maxRetries+1)
+			}
+		})
+	}
+}
@@ -0,0 +1,486 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package fs
+
+import (
+	"bufio"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+)
+
+// fileMagic starts every file, followed by the key and the expiration date
+// of the stored response, so that the index can be rebuilt from the files.
+const fileMagic = "\x00hcf"
+
+// maxKeySize is the largest key accepted when reading a file header.
+const maxKeySize = 1 << 20
+
+// Adapter is the filesystem adapter data structure, storing each response
+// in its own file under directories sharded by key hash, for responses too
+// large to be kept in memory.
+//
+// An in-memory index of the stored keys, rebuilt from the file headers by
+// NewAdapter, tracks their size, expiration date and recency, so that the
+// least recently used responses are removed beyond the max bytes.
+type Adapter struct {
+	dir             string
+	maxBytes        int64
+	cleanupInterval time.Duration
+
+	mutex sync.Mutex
+	index map[string]*list.Element
+	// recency lists files from the most to the least recently used.
+	recency *list.List
+	size    int64
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// file is an indexed file.
+type file struct {
+	key        string
+	path       string
+	size       int64
+	expiration int64
+}
+
+// AdapterOptions is used to set Adapter settings.
+type AdapterOptions func(a *Adapter) error
+
+var (
+	_ cache.Adapter             = (*Adapter)(nil)
+	_ cache.InvalidatingAdapter = (*Adapter)(nil)
+	_ cache.KeyScanner          = (*Adapter)(nil)
+)
+
+// Get implements the cache Adapter interface Get method. Entries past their
+// expiration date are removed instead of returned.
+func (a *Adapter) Get(ctx context.Context, key string) ([]byte, bool) {
+	a.mutex.Lock()
+	e, ok := a.index[key]
+	if !ok {
+		a.mutex.Unlock()
+		return nil, false
+	}
+	f := e.Value.(*file)
+	if f.expiration > 0 && f.expiration <= time.Now().UnixNano() {
+		a.remove(e)
+		a.mutex.Unlock()
+		return nil, false
+	}
+	a.recency.MoveToFront(e)
+	a.mutex.Unlock()
+
+	response, err := readFile(f.path, key)
+	if err != nil {
+		return nil, false
+	}
+
+	return response, true
+}
+
+// Set implements the cache Adapter interface Set method, writing the
+// response to a temporary file renamed once complete, then removing the
+// least recently used files beyond the max bytes. Responses already past
+// their expiration date release the stored one instead. The file is written
+// under the lock, so that the index always matches the files on disk.
+func (a *Adapter) Set(ctx context.Context, key string, response []byte, expiration time.Time) {
+	var nanos int64
+	if !expiration.IsZero() {
+		if nanos = expiration.UnixNano(); nanos <= time.Now().UnixNano() {
+			a.Release(ctx, key)
+			return
+		}
+	}
+	size := int64(len(response))
+	if a.maxBytes > 0 && size > a.maxBytes {
+		a.Release(ctx, key)
+		return
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	path := a.path(key)
+	if err := writeFile(path, key, nanos, response); err != nil {
+		if e, ok := a.index[key]; ok {
+			a.remove(e)
+		}
+		return
+	}
+
+	if e, ok := a.index[key]; ok {
+		// The file was replaced, only the index entry is removed.
+		a.recency.Remove(e)
+		a.size -= e.Value.(*file).size
+		delete(a.index, key)
+	}
+	a.add(&file{key: key, path: path, size: size, expiration: nanos})
+	for a.maxBytes > 0 && a.size > a.maxBytes {
+		a.remove(a.recency.Back())
+	}
+}
+
+// Release implements the cache Adapter interface Release method.
+func (a *Adapter) Release(ctx context.Context, key string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if e, ok := a.index[key]; ok {
+		a.remove(e)
+	}
+}
+
+// InvalidatePrefix implements the cache InvalidatingAdapter interface
+// InvalidatePrefix method.
+func (a *Adapter) InvalidatePrefix(ctx context.Context, prefix string) error {
+	return a.InvalidatePattern(ctx, cache.EscapePattern(prefix)+"*")
+}
+
+// InvalidatePattern implements the cache InvalidatingAdapter interface
+// InvalidatePattern method.
+func (a *Adapter) InvalidatePattern(ctx context.Context, pattern string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for key, e := range a.index {
+		if cache.MatchPattern(pattern, key) {
+			a.remove(e)
+		}
+	}
+
+	return nil
+}
+
+// ScanKeys implements the cache KeyScanner interface ScanKeys method,
+// skipping expired entries.
+func (a *Adapter) ScanKeys(ctx context.Context, prefix string, fn func(key string) error) error {
+	now := time.Now().UnixNano()
+	var keys []string
+	a.mutex.Lock()
+	for key, e := range a.index {
+		f := e.Value.(*file)
+		if strings.HasPrefix(key, prefix) && (f.expiration == 0 || f.expiration > now) {
+			keys = append(keys, key)
+		}
+	}
+	a.mutex.Unlock()
+
+	for _, key := range keys {
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close stops the cleanup goroutine started with AdapterWithCleanupInterval,
+// if any. The adapter returned by NewAdapter implements io.Closer.
+func (a *Adapter) Close() error {
+	a.closeOnce.Do(func() {
+		if a.done != nil {
+			close(a.done)
+		}
+	})
+
+	return nil
+}
+
+// add indexes a file as the most recently used one. The caller must hold
+// the lock.
+func (a *Adapter) add(f *file) {
+	a.index[f.key] = a.recency.PushFront(f)
+	a.size += f.size
+}
+
+// remove deletes an indexed file. The caller must hold the lock.
+func (a *Adapter) remove(e *list.Element) {
+	f := a.recency.Remove(e).(*file)
+	delete(a.index, f.key)
+	a.size -= f.size
+	os.Remove(f.path)
+}
+
+// path returns the path of the file of a key, sharded in two levels of
+// directories by key hash.
+func (a *Adapter) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+
+	return filepath.Join(a.dir, name[:2], name[2:4], name)
+}
+
+// owns reports whether a path is named as the files of the adapter, or as
+// their temporary files, within the sharded directories.
+func (a *Adapter) owns(path string) bool {
+	rel, err := filepath.Rel(a.dir, path)
+	if err != nil {
+		return false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) != 3 || !isHex(parts[0], 2) || !isHex(parts[1], 2) {
+		return false
+	}
+	if strings.HasPrefix(parts[2], ".tmp-") {
+		return true
+	}
+
+	return isHex(parts[2], sha256.Size*2) && parts[2][:2] == parts[0] && parts[2][2:4] == parts[1]
+}
+
+// isHex reports whether s is made of n lowercase hexadecimal digits.
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if (s[i] < '0' || s[i] > '9') && (s[i] < 'a' || s[i] > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (a *Adapter) cleanup() {
+	ticker := time.NewTicker(a.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now().UnixNano()
+			a.mutex.Lock()
+			for _, e := range a.index {
+				if f := e.Value.(*file); f.expiration > 0 && f.expiration <= now {
+					a.remove(e)
+				}
+			}
+			a.mutex.Unlock()
+		case <-a.done:
+			return
+		}
+	}
+}
+
+// load indexes the files of the directory, from the least to the most
+// recently modified, removing expired, corrupted and leftover temporary
+// files. Files not named as the adapter names them are left untouched.
+func (a *Adapter) load() error {
+	type loaded struct {
+		file
+		modified time.Time
+	}
+	var files []loaded
+	now := time.Now()
+	err := filepath.Walk(a.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		if !a.owns(path) {
+			return nil
+		}
+		key, expiration, err := readHeader(path)
+		if err != nil || path != a.path(key) || (expiration > 0 && expiration <= now.UnixNano()) {
+			os.Remove(path)
+			return nil
+		}
+		size := info.Size() - headerSize(key)
+		files = append(files, loaded{file{key: key, path: path, size: size, expiration: expiration}, info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modified.Before(files[j].modified) })
+	for i := range files {
+		f := files[i].file
+		a.add(&f)
+	}
+	for a.maxBytes > 0 && a.size > a.maxBytes {
+		a.remove(a.recency.Back())
+	}
+
+	return nil
+}
+
+// NewAdapter initializes a filesystem adapter storing responses under dir,
+// which is created if needed, indexing the files already stored there.
+func NewAdapter(dir string, opts ...AdapterOptions) (cache.Adapter, error) {
+	if dir == "" {
+		return nil, errors.New("fs adapter directory can not be empty")
+	}
+
+	a := &Adapter{
+		dir:     dir,
+		index:   map[string]*list.Element{},
+		recency: list.New(),
+	}
+
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("fs adapter directory: %w", err)
+	}
+	if err := a.load(); err != nil {
+		return nil, fmt.Errorf("fs adapter index: %w", err)
+	}
+
+	if a.cleanupInterval > 0 {
+		a.done = make(chan struct{})
+		go a.cleanup()
+	}
+
+	return a, nil
+}
+
+// AdapterWithMaxBytes sets the maximum total size of the stored responses,
+// removing the least recently used ones beyond it.
+func AdapterWithMaxBytes(n int64) AdapterOptions {
+	return func(a *Adapter) error {
+		if n <= 0 {
+			return fmt.Errorf("fs adapter max bytes %v is invalid", n)
+		}
+
+		a.maxBytes = n
+
+		return nil
+	}
+}
+
+// AdapterWithCleanupInterval starts a goroutine removing expired files
+// every interval, so that responses which are never requested again don't
+// hold disk space until evicted. Close stops it.
+func AdapterWithCleanupInterval(interval time.Duration) AdapterOptions {
+	return func(a *Adapter) error {
+		if interval <= 0 {
+			return fmt.Errorf("fs adapter cleanup interval %v is invalid", interval)
+		}
+
+		a.cleanupInterval = interval
+
+		return nil
+	}
+}
+
+// headerSize returns the size of the header of the file of a key.
+func headerSize(key string) int64 {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return int64(len(fileMagic) + binary.PutUvarint(buf, uint64(len(key))) + len(key) + 8)
+}
+
+// writeFile writes the file of a key to a temporary file renamed to path.
+func writeFile(path, key string, expiration int64, response []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, binary.MaxVarintLen64)
+	w := bufio.NewWriter(tmp)
+	w.WriteString(fileMagic)
+	w.Write(buf[:binary.PutUvarint(buf, uint64(len(key)))])
+	w.WriteString(key)
+	binary.BigEndian.PutUint64(buf, uint64(expiration))
+	w.Write(buf[:8])
+	w.Write(response)
+	err = w.Flush()
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+
+	return err
+}
+
+// readFile returns the response stored in the file of a key.
+func readFile(path, key string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	n := headerSize(key)
+	// The key is checked in case of a hash collision.
+	if int64(len(b)) < n || string(b[:len(fileMagic)]) != fileMagic || string(b[n-8-int64(len(key)):n-8]) != key {
+		return nil, errors.New("fs adapter file is corrupted")
+	}
+
+	return b[n:], nil
+}
+
+// readHeader returns the key and the expiration date of a file.
+func readHeader(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	magic := make([]byte, len(fileMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != fileMagic {
+		return "", 0, errors.New("fs adapter file is corrupted")
+	}
+	n, err := binary.ReadUvarint(r)
+	if err != nil || n > maxKeySize {
+		return "", 0, errors.New("fs adapter file is corrupted")
+	}
+	key := make([]byte, n+8)
+	if _, err := io.ReadFull(r, key); err != nil {
+		return "", 0, errors.New("fs adapter file is corrupted")
+	}
+
+	return string(key[:n]), int64(binary.BigEndian.Uint64(key[n:])), nil
+}
@@ -0,0 +1,190 @@
+package fs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewAdapter(t *testing.T) {
+	tests := []struct {
+		name    string
+		dir     string
+		opts    []AdapterOptions
+		wantErr bool
+	}{
+		{"returns new Adapter", t.TempDir(), []AdapterOptions{AdapterWithMaxBytes(1 << 20), AdapterWithCleanupInterval(time.Minute)}, false},
+		{"creates directory", filepath.Join(t.TempDir(), "cache"), nil, false},
+		{"returns directory error", "", nil, true},
+		{"returns max bytes error", t.TempDir(), []AdapterOptions{AdapterWithMaxBytes(0)}, true},
+		{"returns cleanup interval error", t.TempDir(), []AdapterOptions{AdapterWithCleanupInterval(-1)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewAdapter(tt.dir, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewAdapter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				a.(*Adapter).Close()
+			}
+		})
+	}
+}
+
+func TestAdapter(t *testing.T) {
+	dir := t.TempDir()
+	a, _ := NewAdapter(dir, AdapterWithMaxBytes(16))
+	ctx := context.Background()
+
+	a.Set(ctx, "foo", []byte("value 1"), time.Now().Add(time.Minute))
+	a.Set(ctx, "bar", []byte("value 2"), time.Time{})
+	if got, ok := a.Get(ctx, "foo"); !ok || string(got) != "value 1" {
+		t.Errorf("fs.Get(foo) = %q, %v, want %q", got, ok, "value 1")
+	}
+
+	// foo is now the most recently used, bar is removed beyond 16 bytes.
+	a.Set(ctx, "baz", []byte("value 3"), time.Time{})
+	if _, ok := a.Get(ctx, "bar"); ok {
+		t.Errorf("fs.Get(bar) hit, want it evicted")
+	}
+	if _, err := os.Stat(a.(*Adapter).path("bar")); !os.IsNotExist(err) {
+		t.Errorf("bar file error = %v, want it removed", err)
+	}
+	a.Set(ctx, "large", []byte("a response over 16 bytes"), time.Time{})
+	if _, ok := a.Get(ctx, "large"); ok {
+		t.Errorf("fs.Get(large) hit, want it rejected")
+	}
+
+	a.Set(ctx, "foo", []byte("value 4"), time.Now().Add(-time.Minute))
+	if _, ok := a.Get(ctx, "foo"); ok {
+		t.Errorf("fs.Get(foo) hit after an expired Set")
+	}
+
+	// A new adapter indexes the stored files, removing its corrupted and
+	// temporary files but leaving foreign files.
+	corrupted := a.(*Adapter).path("corrupted")
+	os.MkdirAll(filepath.Dir(corrupted), 0o755)
+	ioutil.WriteFile(corrupted, []byte("garbage"), 0o644)
+	tmp := filepath.Join(filepath.Dir(corrupted), ".tmp-123")
+	ioutil.WriteFile(tmp, []byte("garbage"), 0o644)
+	foreign := []string{filepath.Join(dir, "garbage"), filepath.Join(filepath.Dir(corrupted), "notes.txt")}
+	for _, path := range foreign {
+		ioutil.WriteFile(path, []byte("garbage"), 0o644)
+	}
+	a, _ = NewAdapter(dir)
+	if got, ok := a.Get(ctx, "baz"); !ok || string(got) != "value 3" {
+		t.Errorf("fs.Get(baz) after reload = %q, %v, want %q", got, ok, "value 3")
+	}
+	for _, path := range []string{corrupted, tmp} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%v file error = %v, want it removed", path, err)
+		}
+	}
+	for _, path := range foreign {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%v file error = %v, want it kept", path, err)
+		}
+	}
+	if size := a.(*Adapter).size; size != 7 {
+		t.Errorf("fs size after reload = %v, want 7", size)
+	}
+
+	a.Release(ctx, "baz")
+	if _, ok := a.Get(ctx, "baz"); ok {
+		t.Errorf("fs.Get(baz) hit after Release")
+	}
+}
+
+func TestConcurrentSetRelease(t *testing.T) {
+	a, _ := NewAdapter(t.TempDir())
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if (i+j)%2 == 0 {
+					a.Set(ctx, "foo", []byte("value"), time.Time{})
+				} else {
+					a.Release(ctx, "foo")
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// The index matches the file on disk.
+	fs := a.(*Adapter)
+	_, indexed := fs.index["foo"]
+	_, err := os.Stat(fs.path("foo"))
+	if indexed != (err == nil) {
+		t.Errorf("foo indexed = %v, file error = %v", indexed, err)
+	}
+	wantSize := int64(0)
+	if indexed {
+		wantSize = 5
+	}
+	if fs.size != wantSize {
+		t.Errorf("fs size = %v, want %v", fs.size, wantSize)
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	a, _ := NewAdapter(t.TempDir())
+	ctx := context.Background()
+	for _, key := range []string{"/api/1", "/api/2", "/web/1"} {
+		a.Set(ctx, key, []byte(key), time.Time{})
+	}
+
+	a.(*Adapter).InvalidatePrefix(ctx, "/api/")
+	a.(*Adapter).InvalidatePattern(ctx, "/web/?")
+	for _, key := range []string{"/api/1", "/api/2", "/web/1"} {
+		if _, ok := a.Get(ctx, key); ok {
+			t.Errorf("fs.Get(%v) hit after invalidation", key)
+		}
+	}
+}
+
+func TestScanKeys(t *testing.T) {
+	a, _ := NewAdapter(t.TempDir())
+	ctx := context.Background()
+	a.Set(ctx, "/api/1", []byte("1"), time.Time{})
+	a.Set(ctx, "/api/2", []byte("2"), time.Now().Add(time.Minute))
+	a.Set(ctx, "/web/1", []byte("3"), time.Time{})
+
+	var got []string
+	err := a.(*Adapter).ScanKeys(ctx, "/api/", func(key string) error {
+		got = append(got, key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("fs.ScanKeys() error = %v", err)
+	}
+	sort.Strings(got)
+	if want := []string{"/api/1", "/api/2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("fs.ScanKeys() = %v, want %v", got, want)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	a, _ := NewAdapter(t.TempDir(), AdapterWithCleanupInterval(time.Millisecond))
+	defer a.(*Adapter).Close()
+	a.Set(context.Background(), "foo", []byte("value 1"), time.Now().Add(5*time.Millisecond))
+
+	time.Sleep(50 * time.Millisecond)
+	f := a.(*Adapter)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if len(f.index) != 0 {
+		t.Errorf("fs index = %v, want expired files removed", len(f.index))
+	}
+}
@@ -0,0 +1,211 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package groupcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	"github.com/mailgun/groupcache/v2"
+)
+
+// errNotCached is returned by the getter of the group, as responses are
+// only cached with Set.
+var errNotCached = errors.New("groupcache adapter response is not cached")
+
+// Peers is the peer pool of the groupcache groups, implemented by
+// *groupcache.HTTPPool.
+type Peers interface {
+	// Set replaces the base URLs of the peers, including the current
+	// instance.
+	Set(peers ...string)
+}
+
+// Discovery returns the base URLs of the peers, including the current
+// instance, such as the pods of a Kubernetes service or the instances of a
+// Consul service.
+type Discovery func(ctx context.Context) ([]string, error)
+
+// Adapter is the groupcache adapter data structure, storing responses in a
+// groupcache group sharded between peers by key, so that a fleet of
+// instances forms a distributed in-process cache without duplicating
+// responses.
+//
+// Each response is stored by the peer owning its key, and lookups of a key
+// are deduplicated so that concurrent requests for a response wait for a
+// single round trip to its owner. Releases are broadcast to every peer.
+type Adapter struct {
+	name     string
+	group    *groupcache.Group
+	hotCache bool
+
+	peers     Peers
+	discover  Discovery
+	interval  time.Duration
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// AdapterOptions is used to set Adapter settings.
+type AdapterOptions func(a *Adapter) error
+
+var _ cache.Adapter = (*Adapter)(nil)
+
+// Get implements the cache Adapter interface Get method.
+func (a *Adapter) Get(ctx context.Context, key string) ([]byte, bool) {
+	var response []byte
+	if err := a.group.Get(ctx, key, groupcache.AllocatingByteSliceSink(&response)); err != nil {
+		return nil, false
+	}
+
+	return response, true
+}
+
+// Set implements the cache Adapter interface Set method, storing the
+// response on the peer owning its key.
+func (a *Adapter) Set(ctx context.Context, key string, response []byte, expiration time.Time) {
+	a.group.Set(ctx, key, response, expiration, a.hotCache)
+}
+
+// Release implements the cache Adapter interface Release method, releasing
+// the response from every peer.
+func (a *Adapter) Release(ctx context.Context, key string) {
+	a.group.Remove(ctx, key)
+}
+
+// Close stops the discovery goroutine started with AdapterWithDiscovery, if
+// any, and deregisters the group. The adapter returned by NewAdapter
+// implements io.Closer.
+func (a *Adapter) Close() error {
+	a.closeOnce.Do(func() {
+		if a.done != nil {
+			close(a.done)
+		}
+		groupcache.DeregisterGroup(a.name)
+	})
+
+	return nil
+}
+
+// refresh sets the discovered peers, keeping the current ones on errors.
+func (a *Adapter) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), a.interval)
+	defer cancel()
+
+	if peers, err := a.discover(ctx); err == nil && len(peers) > 0 {
+		a.peers.Set(peers...)
+	}
+}
+
+func (a *Adapter) discovery() {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.refresh()
+		case <-a.done:
+			return
+		}
+	}
+}
+
+// NewAdapter initializes a groupcache adapter with a new group, holding at
+// most cacheBytes of responses on each peer. Group names are unique within
+// a process, until the adapter is closed.
+//
+// Peers reach each other through the process-wide peer pool, such as the
+// one returned by groupcache.NewHTTPPoolOpts, served over HTTP at the base
+// URL of the current instance.
+func NewAdapter(name string, cacheBytes int64, opts ...AdapterOptions) (cache.Adapter, error) {
+	if name == "" {
+		return nil, errors.New("groupcache adapter group name can not be empty")
+	}
+	if cacheBytes <= 0 {
+		return nil, fmt.Errorf("groupcache adapter cache bytes %v is invalid", cacheBytes)
+	}
+	if groupcache.GetGroup(name) != nil {
+		return nil, fmt.Errorf("groupcache adapter group %v already exists", name)
+	}
+
+	a := &Adapter{
+		name: name,
+	}
+
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return nil, err
+		}
+	}
+
+	a.group = groupcache.NewGroup(name, cacheBytes, groupcache.GetterFunc(
+		func(ctx context.Context, key string, dest groupcache.Sink) error {
+			return errNotCached
+		},
+	))
+
+	if a.discover != nil {
+		a.refresh()
+		a.done = make(chan struct{})
+		go a.discovery()
+	}
+
+	return a, nil
+}
+
+// AdapterWithHotCache makes Set also store the responses owned by other
+// peers in the hot cache of the current instance, saving round trips for
+// the responses it requests most at the cost of duplicating them.
+func AdapterWithHotCache(enabled bool) AdapterOptions {
+	return func(a *Adapter) error {
+		a.hotCache = enabled
+		return nil
+	}
+}
+
+// AdapterWithDiscovery sets the peers of the pool to the ones returned by
+// discover when creating the adapter, then every interval, keeping the
+// current peers when it fails. Close stops it.
+func AdapterWithDiscovery(peers Peers, discover Discovery, interval time.Duration) AdapterOptions {
+	return func(a *Adapter) error {
+		if peers == nil || discover == nil {
+			return errors.New("groupcache adapter peers and discovery can not be nil")
+		}
+		if interval <= 0 {
+			return fmt.Errorf("groupcache adapter discovery interval %v is invalid", interval)
+		}
+
+		a.peers = peers
+		a.discover = discover
+		a.interval = interval
+
+		return nil
+	}
+}
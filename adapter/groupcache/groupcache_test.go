@@ -0,0 +1,103 @@
+package groupcache
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type peersMock struct {
+	sync.Mutex
+	peers [][]string
+}
+
+func (p *peersMock) Set(peers ...string) {
+	p.Lock()
+	defer p.Unlock()
+	p.peers = append(p.peers, peers)
+}
+
+func TestNewAdapter(t *testing.T) {
+	discover := func(ctx context.Context) ([]string, error) { return nil, nil }
+	tests := []struct {
+		name       string
+		group      string
+		cacheBytes int64
+		opts       []AdapterOptions
+		wantErr    bool
+	}{
+		{"returns new Adapter", "new", 1 << 20, []AdapterOptions{AdapterWithHotCache(true), AdapterWithDiscovery(&peersMock{}, discover, time.Minute)}, false},
+		{"returns name error", "", 1 << 20, nil, true},
+		{"returns cache bytes error", "bytes", 0, nil, true},
+		{"returns discovery error", "discovery", 1 << 20, []AdapterOptions{AdapterWithDiscovery(nil, discover, time.Minute)}, true},
+		{"returns discovery interval error", "interval", 1 << 20, []AdapterOptions{AdapterWithDiscovery(&peersMock{}, discover, 0)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewAdapter(tt.group, tt.cacheBytes, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewAdapter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				a.(*Adapter).Close()
+			}
+		})
+	}
+
+	a, _ := NewAdapter("duplicate", 1<<20)
+	if _, err := NewAdapter("duplicate", 1<<20); err == nil {
+		t.Errorf("NewAdapter() with a duplicate group error = nil")
+	}
+	a.(*Adapter).Close()
+	if _, err := NewAdapter("duplicate", 1<<20); err != nil {
+		t.Errorf("NewAdapter() after Close error = %v", err)
+	}
+}
+
+func TestAdapter(t *testing.T) {
+	a, _ := NewAdapter("responses", 1<<20)
+	defer a.(*Adapter).Close()
+	ctx := context.Background()
+
+	a.Set(ctx, "foo", []byte("value 1"), time.Now().Add(time.Minute))
+	a.Set(ctx, "bar", []byte("value 2"), time.Time{})
+	a.Set(ctx, "baz", []byte("value 3"), time.Now().Add(-time.Minute))
+	for key, want := range map[string]string{"foo": "value 1", "bar": "value 2", "baz": ""} {
+		got, ok := a.Get(ctx, key)
+		if ok != (want != "") || string(got) != want {
+			t.Errorf("groupcache.Get(%v) = %q, %v, want %q", key, got, ok, want)
+		}
+	}
+
+	a.Release(ctx, "foo")
+	if _, ok := a.Get(ctx, "foo"); ok {
+		t.Errorf("groupcache.Get() hit after Release")
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	peers := &peersMock{}
+	calls := 0
+	discover := func(ctx context.Context) ([]string, error) {
+		calls++
+		if calls == 2 {
+			return nil, errors.New("discovery failed")
+		}
+		return []string{"http://10.0.0.1:8080", "http://10.0.0.2:8080"}, nil
+	}
+	a, _ := NewAdapter("discovered", 1<<20, AdapterWithDiscovery(peers, discover, 10*time.Millisecond))
+
+	time.Sleep(35 * time.Millisecond)
+	a.(*Adapter).Close()
+	peers.Lock()
+	defer peers.Unlock()
+	if len(peers.peers) < 2 || len(peers.peers) != calls-1 {
+		t.Fatalf("peers set %v times for %v discoveries, want all but the failed one", len(peers.peers), calls)
+	}
+	if want := []string{"http://10.0.0.1:8080", "http://10.0.0.2:8080"}; !reflect.DeepEqual(peers.peers[0], want) {
+		t.Errorf("peers = %v, want %v", peers.peers[0], want)
+	}
+}
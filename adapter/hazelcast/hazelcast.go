@@ -0,0 +1,151 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package hazelcast
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	"github.com/hazelcast/hazelcast-go-client/predicate"
+)
+
+// keyAttribute is the attribute predicates match the keys of the map with.
+const keyAttribute = "__key"
+
+// Map is the part of the Hazelcast map API used by the adapter, implemented
+// by the *hazelcast.Map returned by the client GetMap method.
+type Map interface {
+	Get(ctx context.Context, key interface{}) (interface{}, error)
+	SetWithTTL(ctx context.Context, key interface{}, value interface{}, ttl time.Duration) error
+	Delete(ctx context.Context, key interface{}) error
+	GetKeySetWithPredicate(ctx context.Context, predicate predicate.Predicate) ([]interface{}, error)
+	RemoveAll(ctx context.Context, predicate predicate.Predicate) error
+}
+
+// Adapter is the Hazelcast adapter data structure, storing responses in
+// the entries of a distributed map, expired by the cluster with their TTL.
+type Adapter struct {
+	store Map
+}
+
+var (
+	_ cache.Adapter             = (*Adapter)(nil)
+	_ cache.InvalidatingAdapter = (*Adapter)(nil)
+)
+
+// Get implements the cache Adapter interface Get method.
+func (a *Adapter) Get(ctx context.Context, key string) ([]byte, bool) {
+	value, err := a.store.Get(ctx, key)
+	if err != nil {
+		return nil, false
+	}
+	response, ok := value.([]byte)
+
+	return response, ok
+}
+
+// Set implements the cache Adapter interface Set method. Responses already
+// past their expiration date release the stored one instead.
+func (a *Adapter) Set(ctx context.Context, key string, response []byte, expiration time.Time) {
+	// A zero TTL never expires, the default of the map config being only
+	// used by Map.Set.
+	var ttl time.Duration
+	if !expiration.IsZero() {
+		ttl = time.Until(expiration)
+		if ttl <= 0 {
+			a.Release(ctx, key)
+			return
+		}
+		// TTLs are sent in milliseconds, rounded up so as not to expire
+		// early, nor never expire when under a millisecond.
+		ttl = ttl.Truncate(time.Millisecond) + time.Millisecond
+	}
+
+	a.store.SetWithTTL(ctx, key, response, ttl)
+}
+
+// Release implements the cache Adapter interface Release method.
+func (a *Adapter) Release(ctx context.Context, key string) {
+	a.store.Delete(ctx, key)
+}
+
+// InvalidatePrefix implements the cache InvalidatingAdapter interface
+// InvalidatePrefix method, removing the entries with a like predicate
+// evaluated by the cluster.
+func (a *Adapter) InvalidatePrefix(ctx context.Context, prefix string) error {
+	if strings.Contains(prefix, `\`) {
+		return a.InvalidatePattern(ctx, cache.EscapePattern(prefix)+"*")
+	}
+
+	return a.store.RemoveAll(ctx, predicate.Like(keyAttribute, likePrefix(prefix)))
+}
+
+// InvalidatePattern implements the cache InvalidatingAdapter interface
+// InvalidatePattern method, matching the keys starting with the literal
+// prefix of the pattern, selected by the cluster.
+func (a *Adapter) InvalidatePattern(ctx context.Context, pattern string) error {
+	prefix := cache.PatternPrefix(pattern)
+	// Backslashes only escape wildcards in like expressions, so the keys
+	// are selected up to the first one.
+	if i := strings.IndexByte(prefix, '\\'); i >= 0 {
+		prefix = prefix[:i]
+	}
+
+	keys, err := a.store.GetKeySetWithPredicate(ctx, predicate.Like(keyAttribute, likePrefix(prefix)))
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if k, ok := key.(string); ok && cache.MatchPattern(pattern, k) {
+			if err := a.store.Delete(ctx, k); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// likePrefix returns a like expression matching the strings starting with
+// prefix, which must not contain backslashes.
+func likePrefix(prefix string) string {
+	r := strings.NewReplacer("%", `\%`, "_", `\_`)
+
+	return r.Replace(prefix) + "%"
+}
+
+// NewAdapter initializes a Hazelcast adapter storing responses in a map,
+// such as the one returned by client.GetMap(ctx, "http-cache"). Configuring
+// a near cache on the map keeps hot responses on the instance.
+func NewAdapter(m Map) (cache.Adapter, error) {
+	if m == nil {
+		return nil, errors.New("hazelcast adapter map can not be nil")
+	}
+
+	return &Adapter{store: m}, nil
+}
@@ -0,0 +1,214 @@
+package hazelcast
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hazelcast/hazelcast-go-client/predicate"
+)
+
+type mapMock struct {
+	sync.Mutex
+	store map[string][]byte
+	ttls  map[string]time.Duration
+	err   error
+}
+
+func newMapMock() *mapMock {
+	return &mapMock{store: map[string][]byte{}, ttls: map[string]time.Duration{}}
+}
+
+func (m *mapMock) Get(ctx context.Context, key interface{}) (interface{}, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	if value, ok := m.store[key.(string)]; ok {
+		return value, nil
+	}
+	return nil, nil
+}
+
+func (m *mapMock) SetWithTTL(ctx context.Context, key interface{}, value interface{}, ttl time.Duration) error {
+	m.Lock()
+	defer m.Unlock()
+	m.store[key.(string)] = value.([]byte)
+	m.ttls[key.(string)] = ttl
+	return nil
+}
+
+func (m *mapMock) Delete(ctx context.Context, key interface{}) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.store, key.(string))
+	return nil
+}
+
+// like matches the keys with the like expression of a Like(__key, ...)
+// predicate, as the cluster would.
+func (m *mapMock) like(p predicate.Predicate) []string {
+	expr := strings.TrimSuffix(strings.TrimPrefix(p.String(), "Like(__key, "), ")")
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(expr); i++ {
+		switch {
+		case expr[i] == '\\' && i+1 < len(expr):
+			i++
+			re.WriteString(regexp.QuoteMeta(expr[i : i+1]))
+		case expr[i] == '%':
+			re.WriteString(".*")
+		case expr[i] == '_':
+			re.WriteString(".")
+		default:
+			re.WriteString(regexp.QuoteMeta(expr[i : i+1]))
+		}
+	}
+	re.WriteString("$")
+
+	var keys []string
+	for key := range m.store {
+		if regexp.MustCompile(re.String()).MatchString(key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (m *mapMock) GetKeySetWithPredicate(ctx context.Context, p predicate.Predicate) ([]interface{}, error) {
+	m.Lock()
+	defer m.Unlock()
+	var keys []interface{}
+	for _, key := range m.like(p) {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (m *mapMock) RemoveAll(ctx context.Context, p predicate.Predicate) error {
+	m.Lock()
+	defer m.Unlock()
+	for _, key := range m.like(p) {
+		delete(m.store, key)
+	}
+	return nil
+}
+
+func (m *mapMock) keys() []string {
+	m.Lock()
+	defer m.Unlock()
+	var keys []string
+	for key := range m.store {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestNewAdapter(t *testing.T) {
+	if _, err := NewAdapter(newMapMock()); err != nil {
+		t.Errorf("NewAdapter() error = %v", err)
+	}
+	if _, err := NewAdapter(nil); err == nil {
+		t.Errorf("NewAdapter() with a nil map error = nil")
+	}
+}
+
+func TestAdapter(t *testing.T) {
+	m := newMapMock()
+	a, _ := NewAdapter(m)
+	ctx := context.Background()
+
+	a.Set(ctx, "foo", []byte("value 1"), time.Now().Add(time.Minute))
+	a.Set(ctx, "bar", []byte("value 2"), time.Time{})
+	a.Set(ctx, "baz", []byte("value 3"), time.Now().Add(100*time.Microsecond))
+	if ttl := m.ttls["foo"]; ttl <= 59*time.Second || ttl > time.Minute+time.Millisecond || ttl%time.Millisecond != 0 {
+		t.Errorf("foo TTL = %v, want about a minute in milliseconds", ttl)
+	}
+	if ttl := m.ttls["bar"]; ttl != 0 {
+		t.Errorf("bar TTL = %v, want 0", ttl)
+	}
+	if ttl := m.ttls["baz"]; ttl != time.Millisecond {
+		t.Errorf("baz TTL = %v, want %v", ttl, time.Millisecond)
+	}
+
+	if got, ok := a.Get(ctx, "foo"); !ok || string(got) != "value 1" {
+		t.Errorf("hazelcast.Get() = %q, %v, want %q", got, ok, "value 1")
+	}
+	if _, ok := a.Get(ctx, "qux"); ok {
+		t.Errorf("hazelcast.Get() hit a missing key")
+	}
+
+	a.Set(ctx, "foo", []byte("value 4"), time.Now().Add(-time.Minute))
+	if _, ok := a.Get(ctx, "foo"); ok {
+		t.Errorf("hazelcast.Get() hit an expired response")
+	}
+	a.Release(ctx, "bar")
+	if _, ok := a.Get(ctx, "bar"); ok {
+		t.Errorf("hazelcast.Get() hit a released response")
+	}
+
+	m.err = errors.New("cluster unreachable")
+	if _, ok := a.Get(ctx, "baz"); ok {
+		t.Errorf("hazelcast.Get() hit with an error")
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	keys := []string{"/api/a", "/api/b", "/api/50%_off", "/api/5x_off", "/api/50\\off", "/static/a"}
+	tests := []struct {
+		name   string
+		invoke func(a *Adapter) error
+		want   []string
+	}{
+		{
+			"invalidates prefix",
+			func(a *Adapter) error { return a.InvalidatePrefix(context.Background(), "/api/") },
+			[]string{"/static/a"},
+		},
+		{
+			"escapes like wildcards",
+			func(a *Adapter) error { return a.InvalidatePrefix(context.Background(), "/api/50%_") },
+			[]string{"/api/50\\off", "/api/5x_off", "/api/a", "/api/b", "/static/a"},
+		},
+		{
+			"invalidates prefix with a backslash",
+			func(a *Adapter) error { return a.InvalidatePrefix(context.Background(), "/api/50\\") },
+			[]string{"/api/50%_off", "/api/5x_off", "/api/a", "/api/b", "/static/a"},
+		},
+		{
+			"invalidates pattern",
+			func(a *Adapter) error { return a.InvalidatePattern(context.Background(), "/*/a") },
+			[]string{"/api/50%_off", "/api/50\\off", "/api/5x_off", "/api/b"},
+		},
+		{
+			"invalidates pattern with escapes",
+			func(a *Adapter) error { return a.InvalidatePattern(context.Background(), "/api/5?\\_off") },
+			[]string{"/api/50%_off", "/api/50\\off", "/api/a", "/api/b", "/static/a"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMapMock()
+			a, _ := NewAdapter(m)
+			for _, key := range keys {
+				a.Set(context.Background(), key, []byte(key), time.Time{})
+			}
+
+			if err := tt.invoke(a.(*Adapter)); err != nil {
+				t.Fatalf("invalidate error = %v", err)
+			}
+			if got := m.keys(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("keys = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package leveldb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	// responsePrefix prefixes the keys of the stored responses, and
+	// expirationPrefix the keys of the expiration date index, followed by
+	// the expiration date and the key.
+	responsePrefix   = "r:"
+	expirationPrefix = "e:"
+
+	// expirationSize is the size of the expiration date stored before each
+	// response, in Unix nanoseconds, zero for none.
+	expirationSize = 8
+
+	// batchSize is the number of keys deleted per write batch by Collect
+	// and invalidations.
+	batchSize = 1000
+)
+
+// Adapter is the LevelDB adapter data structure, storing responses in a
+// goleveldb database so that they survive restarts without an external
+// service.
+//
+// Responses are stored after their expiration date, and indexed by
+// expiration date so that Collect reads the expired keys in order, without
+// their responses, and deletes them in batches.
+type Adapter struct {
+	db              *leveldb.DB
+	cleanupInterval time.Duration
+
+	// mutex serializes writes, which read the expiration date index entry
+	// to replace.
+	mutex sync.Mutex
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// AdapterOptions is used to set Adapter settings.
+type AdapterOptions func(a *Adapter) error
+
+var (
+	_ cache.Adapter             = (*Adapter)(nil)
+	_ cache.InvalidatingAdapter = (*Adapter)(nil)
+)
+
+// Get implements the cache Adapter interface Get method. Entries past their
+// expiration date are released instead of returned.
+func (a *Adapter) Get(ctx context.Context, key string) ([]byte, bool) {
+	v, err := a.db.Get(responseKey(key), nil)
+	if err != nil || len(v) < expirationSize {
+		return nil, false
+	}
+	if expiration := int64(binary.BigEndian.Uint64(v)); expiration > 0 && expiration <= time.Now().UnixNano() {
+		a.Release(ctx, key)
+		return nil, false
+	}
+
+	return v[expirationSize:], true
+}
+
+// Set implements the cache Adapter interface Set method. Responses already
+// past their expiration date release the stored one instead.
+func (a *Adapter) Set(ctx context.Context, key string, response []byte, expiration time.Time) {
+	var nanos int64
+	if !expiration.IsZero() {
+		if nanos = expiration.UnixNano(); nanos <= time.Now().UnixNano() {
+			a.Release(ctx, key)
+			return
+		}
+	}
+
+	v := make([]byte, expirationSize+len(response))
+	binary.BigEndian.PutUint64(v, uint64(nanos))
+	copy(v[expirationSize:], response)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	batch := new(leveldb.Batch)
+	a.delete(batch, key, 0)
+	if nanos > 0 {
+		batch.Put(indexKey(v, key), nil)
+	}
+	batch.Put(responseKey(key), v)
+	a.db.Write(batch, nil)
+}
+
+// Release implements the cache Adapter interface Release method.
+func (a *Adapter) Release(ctx context.Context, key string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	batch := new(leveldb.Batch)
+	a.delete(batch, key, 0)
+	a.db.Write(batch, nil)
+}
+
+// InvalidatePrefix implements the cache InvalidatingAdapter interface
+// InvalidatePrefix method, iterating the keys in order.
+func (a *Adapter) InvalidatePrefix(ctx context.Context, prefix string) error {
+	return a.InvalidatePattern(ctx, cache.EscapePattern(prefix)+"*")
+}
+
+// InvalidatePattern implements the cache InvalidatingAdapter interface
+// InvalidatePattern method, iterating the keys starting with the literal
+// prefix of the pattern.
+func (a *Adapter) InvalidatePattern(ctx context.Context, pattern string) error {
+	var keys []string
+	it := a.db.NewIterator(util.BytesPrefix(responseKey(cache.PatternPrefix(pattern))), nil)
+	for it.Next() {
+		if key := string(it.Key()[len(responsePrefix):]); cache.MatchPattern(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	it.Release()
+	if err := it.Error(); err != nil {
+		return err
+	}
+
+	return a.deleteKeys(ctx, keys, 0)
+}
+
+// Collect removes the responses past their expiration date, iterating the
+// expiration date index.
+func (a *Adapter) Collect(ctx context.Context) error {
+	nanos := time.Now().UnixNano()
+	now := make([]byte, expirationSize)
+	binary.BigEndian.PutUint64(now, uint64(nanos))
+
+	var keys []string
+	it := a.db.NewIterator(util.BytesPrefix([]byte(expirationPrefix)), nil)
+	for it.Next() {
+		k := it.Key()[len(expirationPrefix):]
+		if bytes.Compare(k[:expirationSize], now) > 0 {
+			break
+		}
+		keys = append(keys, string(k[expirationSize:]))
+	}
+	it.Release()
+	if err := it.Error(); err != nil {
+		return err
+	}
+
+	return a.deleteKeys(ctx, keys, nanos)
+}
+
+// Close stops the cleanup goroutine started with AdapterWithCleanupInterval,
+// if any. It doesn't close the database. The adapter returned by NewAdapter
+// implements io.Closer.
+func (a *Adapter) Close() error {
+	a.closeOnce.Do(func() {
+		if a.done != nil {
+			close(a.done)
+		}
+	})
+
+	return nil
+}
+
+// deleteKeys deletes keys in batches, only the ones expired at until, if
+// not zero.
+func (a *Adapter) deleteKeys(ctx context.Context, keys []string, until int64) error {
+	for len(keys) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n := len(keys)
+		if n > batchSize {
+			n = batchSize
+		}
+
+		a.mutex.Lock()
+		batch := new(leveldb.Batch)
+		for _, key := range keys[:n] {
+			a.delete(batch, key, until)
+		}
+		err := a.db.Write(batch, nil)
+		a.mutex.Unlock()
+		if err != nil {
+			return err
+		}
+		keys = keys[n:]
+	}
+
+	return nil
+}
+
+// delete adds the deletion of a key and its expiration date index entry to
+// a batch, only if expired at until, if not zero, in case it was stored
+// again since it was found expired. The caller must hold the lock.
+func (a *Adapter) delete(batch *leveldb.Batch, key string, until int64) {
+	v, err := a.db.Get(responseKey(key), nil)
+	if err != nil {
+		return
+	}
+	if len(v) < expirationSize {
+		batch.Delete(responseKey(key))
+		return
+	}
+	expiration := int64(binary.BigEndian.Uint64(v))
+	if until > 0 && (expiration == 0 || expiration > until) {
+		return
+	}
+	if expiration > 0 {
+		batch.Delete(indexKey(v, key))
+	}
+	batch.Delete(responseKey(key))
+}
+
+// responseKey returns the database key of the response of a key.
+func responseKey(key string) []byte {
+	return append([]byte(responsePrefix), key...)
+}
+
+// indexKey returns the expiration date index key of a stored value.
+func indexKey(v []byte, key string) []byte {
+	return append(append([]byte(expirationPrefix), v[:expirationSize]...), key...)
+}
+
+func (a *Adapter) cleanup() {
+	ticker := time.NewTicker(a.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.Collect(context.Background())
+		case <-a.done:
+			return
+		}
+	}
+}
+
+// NewAdapter initializes a LevelDB adapter.
+func NewAdapter(db *leveldb.DB, opts ...AdapterOptions) (cache.Adapter, error) {
+	if db == nil {
+		return nil, errors.New("leveldb adapter database can not be nil")
+	}
+
+	a := &Adapter{
+		db: db,
+	}
+
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return nil, err
+		}
+	}
+
+	if a.cleanupInterval > 0 {
+		a.done = make(chan struct{})
+		go a.cleanup()
+	}
+
+	return a, nil
+}
+
+// AdapterWithCleanupInterval starts a goroutine calling Collect every
+// interval, so that responses which are never requested again don't hold
+// disk space. Close stops it.
+func AdapterWithCleanupInterval(interval time.Duration) AdapterOptions {
+	return func(a *Adapter) error {
+		if interval <= 0 {
+			return fmt.Errorf("leveldb adapter cleanup interval %v is invalid", interval)
+		}
+
+		a.cleanupInterval = interval
+
+		return nil
+	}
+}
@@ -0,0 +1,133 @@
+package leveldb
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+func openDB(t *testing.T) *leveldb.DB {
+	db, err := leveldb.OpenFile(filepath.Join(t.TempDir(), "cache"), nil)
+	if err != nil {
+		t.Fatalf("leveldb.OpenFile() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// countKeys returns the number of stored responses and expiration date
+// index entries.
+func countKeys(a *Adapter) (responses, indexed int) {
+	for prefix, n := range map[string]*int{responsePrefix: &responses, expirationPrefix: &indexed} {
+		it := a.db.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+		for it.Next() {
+			*n++
+		}
+		it.Release()
+	}
+
+	return responses, indexed
+}
+
+func TestNewAdapter(t *testing.T) {
+	tests := []struct {
+		name    string
+		nilDB   bool
+		opts    []AdapterOptions
+		wantErr bool
+	}{
+		{"returns new Adapter", false, []AdapterOptions{AdapterWithCleanupInterval(time.Minute)}, false},
+		{"returns database error", true, nil, true},
+		{"returns cleanup interval error", false, []AdapterOptions{AdapterWithCleanupInterval(0)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openDB(t)
+			if tt.nilDB {
+				db = nil
+			}
+			a, err := NewAdapter(db, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewAdapter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				a.(*Adapter).Close()
+			}
+		})
+	}
+}
+
+func TestAdapter(t *testing.T) {
+	a, _ := NewAdapter(openDB(t))
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		key        string
+		response   string
+		expiration time.Time
+		wantOk     bool
+	}{
+		{"stores response", "foo", "value 1", time.Now().Add(time.Minute), true},
+		{"stores response without expiration", "bar", "value 2", time.Time{}, true},
+		{"replaces response", "foo", "value 3", time.Now().Add(2 * time.Minute), true},
+		{"releases expired response", "bar", "value 4", time.Now().Add(-time.Minute), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a.Set(ctx, tt.key, []byte(tt.response), tt.expiration)
+			got, ok := a.Get(ctx, tt.key)
+			if ok != tt.wantOk || (ok && string(got) != tt.response) {
+				t.Errorf("leveldb.Get() = %q, %v, want %q, %v", got, ok, tt.response, tt.wantOk)
+			}
+		})
+	}
+	if responses, indexed := countKeys(a.(*Adapter)); responses != 1 || indexed != 1 {
+		t.Errorf("leveldb keys = %v responses and %v indexed, want 1 and 1", responses, indexed)
+	}
+
+	a.Release(ctx, "foo")
+	if responses, indexed := countKeys(a.(*Adapter)); responses != 0 || indexed != 0 {
+		t.Errorf("leveldb keys after Release = %v responses and %v indexed, want none", responses, indexed)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	a, _ := NewAdapter(openDB(t))
+	ctx := context.Background()
+	a.Set(ctx, "foo", []byte("value 1"), time.Now().Add(10*time.Millisecond))
+	a.Set(ctx, "bar", []byte("value 2"), time.Now().Add(time.Minute))
+	a.Set(ctx, "baz", []byte("value 3"), time.Time{})
+
+	time.Sleep(20 * time.Millisecond)
+	if err := a.(*Adapter).Collect(ctx); err != nil {
+		t.Fatalf("leveldb.Collect() error = %v", err)
+	}
+	if responses, indexed := countKeys(a.(*Adapter)); responses != 2 || indexed != 1 {
+		t.Errorf("leveldb keys = %v responses and %v indexed, want 2 and 1", responses, indexed)
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	a, _ := NewAdapter(openDB(t))
+	ctx := context.Background()
+	for _, key := range []string{"/api/1", "/api/2", "/web/1", "/web/12"} {
+		a.Set(ctx, key, []byte(key), time.Now().Add(time.Minute))
+	}
+
+	a.(*Adapter).InvalidatePrefix(ctx, "/api/")
+	a.(*Adapter).InvalidatePattern(ctx, "/web/?")
+	for key, want := range map[string]bool{"/api/1": false, "/api/2": false, "/web/1": false, "/web/12": true} {
+		if _, ok := a.Get(ctx, key); ok != want {
+			t.Errorf("leveldb.Get(%v) = %v after invalidation, want %v", key, ok, want)
+		}
+	}
+	if responses, indexed := countKeys(a.(*Adapter)); responses != 1 || indexed != 1 {
+		t.Errorf("leveldb keys = %v responses and %v indexed, want 1 and 1", responses, indexed)
+	}
+}
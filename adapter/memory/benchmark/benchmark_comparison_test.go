@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"testing"
 	"time"
@@ -147,3 +148,23 @@ func initBigCache(entriesInWindow int) *bigcache.BigCache {
 
 	return cache
 }
+
+func BenchmarkHTTPCacheMemoryAdapterGetLargeBody(b *testing.B) {
+	for _, copyOnRead := range []bool{false, true} {
+		adapter, _ := memory.NewAdapter(
+			memory.AdapterWithCapacity(2),
+			memory.AdapterWithAlgorithm(memory.LRU),
+			memory.AdapterWithCopyOnRead(copyOnRead),
+		)
+		response := cache.Response{Value: make([]byte, 1<<20)}
+		adapter.Set(context.Background(), "key", response.Bytes(), time.Now().Add(1*time.Minute))
+
+		b.Run(fmt.Sprintf("copy-on-read=%v", copyOnRead), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				v, _ := adapter.Get(context.Background(), "key")
+				ioutil.Discard.Write(cache.BytesToResponse(v).Value)
+			}
+		})
+	}
+}
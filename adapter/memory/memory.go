@@ -25,12 +25,18 @@ SOFTWARE.
 package memory
 
 import (
+	"container/list"
 	"context"
 	"errors"
 	"fmt"
+	"runtime"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/cespare/xxhash/v2"
 	cache "github.com/cludden/http-cache"
 )
 
@@ -49,99 +55,395 @@ const (
 
 	// MFU is the constant for Most Frequently Used.
 	MFU Algorithm = "MFU"
+
+	// ARC is the constant for Adaptive Replacement Cache, balancing recency
+	// and frequency according to the keys requested again after eviction.
+	ARC Algorithm = "ARC"
+
+	// CLOCK is the constant for the CLOCK approximation of LRU, giving
+	// entries requested since the clock hand last passed a second chance.
+	CLOCK Algorithm = "CLOCK"
+)
+
+const (
+	// minShardCapacity and minShardBytes are the smallest shares of the
+	// capacity and max bytes given to each shard by default, so that small
+	// adapters keep an exact eviction order and large responses fit.
+	minShardCapacity = 128
+	minShardBytes    = 16 << 20
 )
 
 // Adapter is the memory adapter data structure.
+//
+// By default Get returns the stored slice itself, so reads don't allocate and
+// the middleware writes cached bodies straight from memory. Callers must not
+// modify the returned bytes; use AdapterWithCopyOnRead when that can't be
+// guaranteed.
+//
+// Recency and frequency are tracked by the adapter itself on every Get, so
+// the middleware doesn't need to write entries back on cache hits, and
+// stored responses are kept as opaque bytes, never decoded to make eviction
+// decisions. Entries are kept ordered for the caching algorithm, in a
+// recency list for LRU and MRU, in frequency buckets for LFU and MFU, in
+// recency and frequency lists along with lists of evicted keys for ARC and
+// in a circular list for CLOCK, so that evictions take constant time,
+// amortized for CLOCK.
+//
+// The store is split into shards by key hash, each with its own lock, share
+// of the capacity and max bytes, and eviction order, so that concurrent
+// requests rarely contend. With the SyncMap storage, reads don't take the
+// shard lock at all, and evictions pick among a sample of entries instead.
 type Adapter struct {
-	mutex     sync.RWMutex
-	capacity  int
-	algorithm Algorithm
-	store     map[string][]byte
+	// counters is first to keep its atomic counters 64-bit aligned.
+	counters counters
+
+	capacity     int
+	maxBytes     int64
+	maxEntrySize int
+	onReject     func(key string, size int)
+	algorithm    Algorithm
+	storage      Storage
+	staleGrace   time.Duration
+	onStale      func(key string)
+	copyOnRead   bool
+	tinyLFU      bool
+	shardCount   int
+	shards       []*shard
+
+	cleanupInterval  time.Duration
+	snapshotPath     string
+	snapshotInterval time.Duration
+	done             chan struct{}
+	closeOnce        sync.Once
+}
+
+// entry is a stored response along with its access statistics and its
+// position for the caching algorithm.
+type entry struct {
+	// lastAccess and frequency are first to keep them 64-bit aligned, as
+	// they're updated atomically with the SyncMap storage.
+	lastAccess int64
+	frequency  int64
+
+	key        string
+	response   []byte
+	expiration int64
+	referenced bool
+	refreshing bool
+
+	// slab is the index plus one of the segment holding the response, if
+	// any, at offset.
+	slab   int
+	offset int
+
+	// element is the entry element in its queue, the recency or frequency
+	// list, or in the entries of its frequency bucket, and bucket the
+	// element of that bucket.
+	element *list.Element
+	queue   *list.List
+	bucket  *list.Element
+}
+
+// ghost is a key recently evicted from the recency or frequency list of the
+// ARC algorithm, remembered in the matching ghost list.
+type ghost struct {
+	key   string
+	queue *list.List
+}
+
+// bucket lists the entries accessed a number of times, from the most to the
+// least recently used.
+type bucket struct {
+	frequency int64
+	entries   *list.List
+}
+
+func newEntry(response []byte) *entry {
+	return &entry{response: response, lastAccess: time.Now().UnixNano(), frequency: 1}
+}
+
+// expired reports whether the entry expiration date, if any, is past.
+func (e *entry) expired(now int64) bool {
+	return e.expiration > 0 && e.expiration <= now
 }
 
 // AdapterOptions is used to set Adapter settings.
 type AdapterOptions func(a *Adapter) error
 
-// Get implements the cache Adapter interface Get method.
+var (
+	_ cache.Adapter             = (*Adapter)(nil)
+	_ cache.TaggingAdapter      = (*Adapter)(nil)
+	_ cache.InvalidatingAdapter = (*Adapter)(nil)
+)
+
+// Get implements the cache Adapter interface Get method. Entries past their
+// expiration date are not returned, nor any entry once ctx is done.
 func (a *Adapter) Get(ctx context.Context, key string) ([]byte, bool) {
-	a.mutex.RLock()
-	response, ok := a.store[key]
-	a.mutex.RUnlock()
+	response, _, ok := a.lookup(ctx, key, false)
+	return response, ok
+}
+
+// Lookup is like Get, but returns the entries past their expiration date by
+// less than the grace period of the stale handler, reporting them as stale
+// and calling the handler in the background to refresh them.
+func (a *Adapter) Lookup(ctx context.Context, key string) (response []byte, stale bool, ok bool) {
+	return a.lookup(ctx, key, true)
+}
+
+func (a *Adapter) lookup(ctx context.Context, key string, allowStale bool) ([]byte, bool, bool) {
+	if ctx.Err() != nil {
+		return nil, false, false
+	}
 
+	response, stale, ok := a.shard(key).lookup(key, allowStale)
 	if ok {
-		return response, true
+		atomic.AddUint64(&a.counters.hits, 1)
+	} else {
+		atomic.AddUint64(&a.counters.misses, 1)
+	}
+	if ok && a.copyOnRead && a.storage != Segmented {
+		response = append([]byte(nil), response...)
 	}
 
-	return nil, false
+	return response, stale, ok
 }
 
-// Set implements the cache Adapter interface Set method.
+// Set implements the cache Adapter interface Set method, evicting entries
+// until the response fits within the capacity and the max bytes of its
+// shard. Responses larger than the max bytes of a shard are not stored, nor
+// any response once ctx is done. Responses larger than the max entry size
+// are rejected, releasing the response previously stored for key.
 func (a *Adapter) Set(ctx context.Context, key string, response []byte, expiration time.Time) {
-	a.mutex.RLock()
-	length := len(a.store)
-	a.mutex.RUnlock()
-
-	if length > 0 && length == a.capacity {
-		a.evict()
+	if ctx.Err() != nil {
+		return
+	}
+	if a.maxEntrySize > 0 && len(response) > a.maxEntrySize {
+		a.shard(key).release(key)
+		atomic.AddUint64(&a.counters.rejections, 1)
+		if a.onReject != nil {
+			a.onReject(key, len(response))
+		}
+		return
 	}
 
-	a.mutex.Lock()
-	a.store[key] = response
-	a.mutex.Unlock()
+	a.shard(key).set(key, response, expiration)
 }
 
-// Release implements the Adapter interface Release method.
+// Release implements the Adapter interface Release method. Nothing is
+// released once ctx is done.
 func (a *Adapter) Release(ctx context.Context, key string) {
-	a.mutex.RLock()
-	_, ok := a.store[key]
-	a.mutex.RUnlock()
+	if ctx.Err() != nil {
+		return
+	}
 
-	if ok {
-		a.mutex.Lock()
-		delete(a.store, key)
-		a.mutex.Unlock()
+	a.shard(key).release(key)
+}
+
+// Tag implements the cache TaggingAdapter interface Tag method. Tags are
+// dropped along with the keys they're associated with.
+func (a *Adapter) Tag(ctx context.Context, key string, tags []string, expiration time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
+
+	a.shard(key).tag(key, tags)
+
+	return nil
 }
 
-func (a *Adapter) evict() {
-	var selectedKey string
-	lastAccess := time.Now()
-	frequency := 2147483647
+// InvalidateTag implements the cache TaggingAdapter interface InvalidateTag
+// method. It stops between shards once ctx is done, returning its error.
+func (a *Adapter) InvalidateTag(ctx context.Context, tag string) error {
+	for _, s := range a.shards {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s.invalidateTag(tag)
+	}
+	return nil
+}
 
-	if a.algorithm == MRU {
-		lastAccess = time.Time{}
-	} else if a.algorithm == MFU {
-		frequency = 0
+// InvalidatePrefix implements the cache InvalidatingAdapter interface
+// InvalidatePrefix method. It stops between shards once ctx is done,
+// returning its error.
+func (a *Adapter) InvalidatePrefix(ctx context.Context, prefix string) error {
+	for _, s := range a.shards {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s.invalidate(func(key string) bool {
+			return strings.HasPrefix(key, prefix)
+		})
 	}
+	return nil
+}
 
-	for k, v := range a.store {
-		r := cache.BytesToResponse(v)
-		switch a.algorithm {
-		case LRU:
-			if r.LastAccess.Before(lastAccess) {
-				selectedKey = k
-				lastAccess = r.LastAccess
-			}
-		case MRU:
-			if r.LastAccess.After(lastAccess) ||
-				r.LastAccess.Equal(lastAccess) {
-				selectedKey = k
-				lastAccess = r.LastAccess
-			}
-		case LFU:
-			if r.Frequency < frequency {
-				selectedKey = k
-				frequency = r.Frequency
+// InvalidatePattern implements the cache InvalidatingAdapter interface
+// InvalidatePattern method. It stops between shards once ctx is done,
+// returning its error.
+func (a *Adapter) InvalidatePattern(ctx context.Context, pattern string) error {
+	for _, s := range a.shards {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s.invalidate(func(key string) bool {
+			return cache.MatchPattern(pattern, key)
+		})
+	}
+	return nil
+}
+
+// Stats is a snapshot of the memory adapter usage.
+type Stats struct {
+	// Entries is the number of stored responses, and Bytes their size.
+	Entries int
+	Bytes   int64
+
+	// Hits and Misses count the Get calls which did or didn't return a
+	// response.
+	Hits   uint64
+	Misses uint64
+
+	// Evictions counts the responses evicted by the caching algorithm, and
+	// Expirations the ones removed past their expiration date.
+	Evictions   uint64
+	Expirations uint64
+
+	// Rejections counts the responses not stored for exceeding the max
+	// entry size.
+	Rejections uint64
+}
+
+// counters are the atomic counters of the adapter usage.
+type counters struct {
+	hits        uint64
+	misses      uint64
+	evictions   uint64
+	expirations uint64
+	rejections  uint64
+}
+
+// Stats returns the current usage of the adapter.
+func (a *Adapter) Stats() Stats {
+	stats := Stats{
+		Hits:        atomic.LoadUint64(&a.counters.hits),
+		Misses:      atomic.LoadUint64(&a.counters.misses),
+		Evictions:   atomic.LoadUint64(&a.counters.evictions),
+		Expirations: atomic.LoadUint64(&a.counters.expirations),
+		Rejections:  atomic.LoadUint64(&a.counters.rejections),
+	}
+	for _, s := range a.shards {
+		s.mutex.Lock()
+		stats.Entries += len(s.store)
+		stats.Bytes += s.size
+		s.mutex.Unlock()
+	}
+
+	return stats
+}
+
+// ResetStats resets the hits, misses, evictions, expirations and rejections
+// counters.
+func (a *Adapter) ResetStats() {
+	atomic.StoreUint64(&a.counters.hits, 0)
+	atomic.StoreUint64(&a.counters.misses, 0)
+	atomic.StoreUint64(&a.counters.evictions, 0)
+	atomic.StoreUint64(&a.counters.expirations, 0)
+	atomic.StoreUint64(&a.counters.rejections, 0)
+}
+
+// Len returns the number of stored responses, including expired ones not
+// removed yet.
+func (a *Adapter) Len() int {
+	n := 0
+	for _, s := range a.shards {
+		s.mutex.Lock()
+		n += len(s.store)
+		s.mutex.Unlock()
+	}
+
+	return n
+}
+
+// Keys returns the sorted keys of the stored responses starting with a
+// prefix, for debugging purposes.
+func (a *Adapter) Keys(prefix string) []string {
+	var keys []string
+	for _, s := range a.shards {
+		s.mutex.Lock()
+		for key := range s.store {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
 			}
-		case MFU:
-			if r.Frequency >= frequency {
-				selectedKey = k
-				frequency = r.Frequency
+		}
+		s.mutex.Unlock()
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Close stops the cleanup and snapshot goroutines started with
+// AdapterWithCleanupInterval and AdapterWithSnapshot, if any, then saves a
+// last snapshot when enabled. The adapter returned by NewAdapter implements
+// io.Closer.
+func (a *Adapter) Close() error {
+	var err error
+	a.closeOnce.Do(func() {
+		if a.done != nil {
+			close(a.done)
+		}
+		if a.snapshotPath != "" {
+			err = a.saveFile(a.snapshotPath)
+		}
+	})
+
+	return err
+}
+
+// cleanup removes expired entries every interval until the adapter is
+// closed.
+func (a *Adapter) cleanup() {
+	ticker := time.NewTicker(a.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now().UnixNano()
+			for _, s := range a.shards {
+				s.deleteExpired(now)
 			}
+		case <-a.done:
+			return
 		}
 	}
+}
 
-	a.Release(context.Background(), selectedKey)
+// shard returns the shard of a key.
+func (a *Adapter) shard(key string) *shard {
+	if len(a.shards) == 1 {
+		return a.shards[0]
+	}
+	return a.shards[xxhash.Sum64String(key)%uint64(len(a.shards))]
+}
+
+// defaultShardCount returns a shard count scaling with GOMAXPROCS, reduced
+// so that each shard gets at least minShardCapacity entries and
+// minShardBytes bytes.
+func defaultShardCount(capacity int, maxBytes int64) int {
+	n := 4 * runtime.GOMAXPROCS(0)
+	if capacity > 0 && capacity/minShardCapacity < n {
+		n = capacity / minShardCapacity
+	}
+	if maxBytes > 0 && int(maxBytes/minShardBytes) < n {
+		n = int(maxBytes / minShardBytes)
+	}
+	if n < 1 {
+		n = 1
+	}
+
+	return n
 }
 
 // NewAdapter initializes memory adapter.
@@ -154,7 +456,7 @@ func NewAdapter(opts ...AdapterOptions) (cache.Adapter, error) {
 		}
 	}
 
-	if a.capacity <= 1 {
+	if a.capacity <= 1 && a.maxBytes <= 0 {
 		return nil, errors.New("memory adapter capacity is not set")
 	}
 
@@ -162,8 +464,58 @@ func NewAdapter(opts ...AdapterOptions) (cache.Adapter, error) {
 		return nil, errors.New("memory adapter caching algorithm is not set")
 	}
 
-	a.mutex = sync.RWMutex{}
-	a.store = make(map[string][]byte, a.capacity)
+	if a.storage == SyncMap && (a.algorithm == ARC || a.algorithm == CLOCK) {
+		return nil, fmt.Errorf("memory adapter storage %v does not support the %v algorithm", a.storage, a.algorithm)
+	}
+	if a.storage == SyncMap && a.tinyLFU {
+		return nil, fmt.Errorf("memory adapter storage %v does not support TinyLFU", a.storage)
+	}
+
+	n := a.shardCount
+	if n == 0 {
+		n = defaultShardCount(a.capacity, a.maxBytes)
+	}
+	capacity := (a.capacity + n - 1) / n
+	maxBytes := a.maxBytes / int64(n)
+	if a.maxBytes > 0 && maxBytes == 0 {
+		maxBytes = 1
+	}
+	for i := 0; i < n; i++ {
+		s := newShard(capacity, maxBytes, a.algorithm)
+		s.counters = &a.counters
+		switch a.storage {
+		case Segmented:
+			s.segments = newSegments(maxBytes)
+		case SyncMap:
+			s.reads = &sync.Map{}
+		}
+		s.grace = int64(a.staleGrace)
+		s.onStale = a.onStale
+		if a.tinyLFU {
+			entries := capacity
+			if entries == 0 {
+				entries = int(maxBytes / averageEntrySize)
+			}
+			s.admission = newTinyLFU(entries)
+		}
+		a.shards = append(a.shards, s)
+	}
+
+	if a.snapshotPath != "" {
+		if err := a.loadFile(a.snapshotPath); err != nil {
+			return nil, fmt.Errorf("memory adapter snapshot load: %w", err)
+		}
+	}
+
+	if a.cleanupInterval > 0 || a.snapshotInterval > 0 {
+		a.done = make(chan struct{})
+	}
+	if a.cleanupInterval > 0 {
+		go a.cleanup()
+	}
+	if a.snapshotInterval > 0 {
+		go a.snapshots()
+	}
 
 	return a, nil
 }
@@ -177,6 +529,19 @@ func AdapterWithAlgorithm(alg Algorithm) AdapterOptions {
 	}
 }
 
+// AdapterWithStorage sets the way responses are stored, Heap by default.
+func AdapterWithStorage(storage Storage) AdapterOptions {
+	return func(a *Adapter) error {
+		if storage != Heap && storage != Segmented && storage != SyncMap {
+			return fmt.Errorf("memory adapter storage %v is invalid", storage)
+		}
+
+		a.storage = storage
+
+		return nil
+	}
+}
+
 // AdapterWithCapacity sets the maximum number of cached responses.
 func AdapterWithCapacity(cap int) AdapterOptions {
 	return func(a *Adapter) error {
@@ -189,3 +554,141 @@ func AdapterWithCapacity(cap int) AdapterOptions {
 		return nil
 	}
 }
+
+// AdapterWithMaxBytes sets the maximum total size of the cached responses,
+// evicting responses with the caching algorithm beyond it. It may replace
+// or complement AdapterWithCapacity.
+func AdapterWithMaxBytes(n int64) AdapterOptions {
+	return func(a *Adapter) error {
+		if n <= 0 {
+			return fmt.Errorf("memory adapter max bytes %v is invalid", n)
+		}
+
+		a.maxBytes = n
+
+		return nil
+	}
+}
+
+// AdapterWithMaxEntrySize sets the maximum size of a single response, so
+// that one very large response is rejected instead of evicting a large part
+// of the cache to fit. It applies to every writer, unlike the client
+// WithMaxBodySize option.
+func AdapterWithMaxEntrySize(n int) AdapterOptions {
+	return func(a *Adapter) error {
+		if n <= 0 {
+			return fmt.Errorf("memory adapter max entry size %v is invalid", n)
+		}
+
+		a.maxEntrySize = n
+
+		return nil
+	}
+}
+
+// AdapterWithRejectionHandler sets a function called with the key and the
+// size of every response rejected for exceeding the max entry size.
+func AdapterWithRejectionHandler(fn func(key string, size int)) AdapterOptions {
+	return func(a *Adapter) error {
+		if fn == nil {
+			return fmt.Errorf("memory adapter rejection handler can not be nil")
+		}
+
+		a.onReject = fn
+
+		return nil
+	}
+}
+
+// AdapterWithStaleHandler keeps entries for a grace period past their
+// expiration date, during which Lookup returns them as stale and calls fn
+// in the background with their key, once at a time, so that it refreshes
+// them with Set. Get still misses stale entries.
+func AdapterWithStaleHandler(grace time.Duration, fn func(key string)) AdapterOptions {
+	return func(a *Adapter) error {
+		if grace <= 0 {
+			return fmt.Errorf("memory adapter stale grace period %v is invalid", grace)
+		}
+		if fn == nil {
+			return fmt.Errorf("memory adapter stale handler can not be nil")
+		}
+
+		a.staleGrace = grace
+		a.onStale = fn
+
+		return nil
+	}
+}
+
+// AdapterWithCleanupInterval starts a goroutine removing expired entries
+// every interval, so that responses which are never requested again don't
+// hold memory until evicted. Close stops it.
+func AdapterWithCleanupInterval(interval time.Duration) AdapterOptions {
+	return func(a *Adapter) error {
+		if interval <= 0 {
+			return fmt.Errorf("memory adapter cleanup interval %v is invalid", interval)
+		}
+
+		a.cleanupInterval = interval
+
+		return nil
+	}
+}
+
+// AdapterWithShards sets the number of shards the store is split into,
+// trading the exactness of the eviction order for less lock contention.
+// The capacity and max bytes are split evenly between shards. It defaults
+// to a multiple of GOMAXPROCS, reduced for small capacities and max bytes.
+func AdapterWithShards(n int) AdapterOptions {
+	return func(a *Adapter) error {
+		if n < 1 {
+			return fmt.Errorf("memory adapter shards %v is invalid", n)
+		}
+
+		a.shardCount = n
+
+		return nil
+	}
+}
+
+// AdapterWithTinyLFU enables a TinyLFU admission filter in front of the
+// caching algorithm, estimating how often keys are requested so that a new
+// response is only stored when its key is requested more often than the
+// key it would evict. Scans requesting many URLs once, such as crawlers,
+// then don't evict hot responses.
+func AdapterWithTinyLFU(enabled bool) AdapterOptions {
+	return func(a *Adapter) error {
+		a.tinyLFU = enabled
+		return nil
+	}
+}
+
+// AdapterWithSnapshot loads the snapshot at path, if any, when creating the
+// adapter, and saves a snapshot there every interval and when the adapter is
+// closed, so that a restarted process comes back with a warm cache. A zero
+// interval only saves on Close.
+func AdapterWithSnapshot(path string, interval time.Duration) AdapterOptions {
+	return func(a *Adapter) error {
+		if path == "" {
+			return fmt.Errorf("memory adapter snapshot path can not be empty")
+		}
+		if interval < 0 {
+			return fmt.Errorf("memory adapter snapshot interval %v is invalid", interval)
+		}
+
+		a.snapshotPath = path
+		a.snapshotInterval = interval
+
+		return nil
+	}
+}
+
+// AdapterWithCopyOnRead makes Get return a copy of the stored response
+// instead of the stored slice itself, trading an allocation per read for
+// isolation from callers that modify the returned bytes.
+func AdapterWithCopyOnRead(enabled bool) AdapterOptions {
+	return func(a *Adapter) error {
+		a.copyOnRead = enabled
+		return nil
+	}
+}
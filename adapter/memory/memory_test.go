@@ -2,8 +2,9 @@ package memory
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"reflect"
-	"sync"
 	"testing"
 	"time"
 
@@ -11,19 +12,13 @@ import (
 )
 
 func TestGet(t *testing.T) {
-	a := &Adapter{
-		sync.RWMutex{},
-		2,
-		LRU,
-		map[string][]byte{
-			"https://example.com/foo": cache.Response{
-				Value:      []byte("value 1"),
-				Expiration: time.Now(),
-				LastAccess: time.Now(),
-				Frequency:  1,
-			}.Bytes(),
-		},
-	}
+	a, _ := NewAdapter(AdapterWithCapacity(2), AdapterWithAlgorithm(LRU))
+	a.Set(context.Background(), "https://example.com/foo", cache.Response{
+		Value:      []byte("value 1"),
+		Expiration: time.Now(),
+		LastAccess: time.Now(),
+		Frequency:  1,
+	}.Bytes(), time.Time{})
 
 	tests := []struct {
 		name string
@@ -61,10 +56,9 @@ func TestGet(t *testing.T) {
 
 func TestSet(t *testing.T) {
 	a := &Adapter{
-		sync.RWMutex{},
-		2,
-		LRU,
-		make(map[string][]byte),
+		capacity:  2,
+		algorithm: LRU,
+		shards:    []*shard{newShard(2, 0, LRU)},
 	}
 
 	tests := []struct {
@@ -100,7 +94,7 @@ func TestSet(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			a.Set(context.Background(), tt.key, tt.response.Bytes(), tt.response.Expiration)
-			if cache.BytesToResponse(a.store[tt.key]).Value == nil {
+			if cache.BytesToResponse(a.shards[0].store[tt.key].response).Value == nil {
 				t.Errorf(
 					"memory.Set() error = store[%v] response is not %s", tt.key, tt.response.Value,
 				)
@@ -111,24 +105,22 @@ func TestSet(t *testing.T) {
 
 func TestRelease(t *testing.T) {
 	a := &Adapter{
-		sync.RWMutex{},
-		2,
-		LRU,
-		map[string][]byte{
-			"https://example.com/foo": cache.Response{
-				Expiration: time.Now().Add(1 * time.Minute),
-				Value:      []byte("value 1"),
-			}.Bytes(),
-			"https://example.com/bar": cache.Response{
-				Expiration: time.Now(),
-				Value:      []byte("value 2"),
-			}.Bytes(),
-			"https://example.com/baz": cache.Response{
-				Expiration: time.Now(),
-				Value:      []byte("value 3"),
-			}.Bytes(),
-		},
+		capacity:  3,
+		algorithm: LRU,
+		shards:    []*shard{newShard(3, 0, LRU)},
 	}
+	a.Set(context.Background(), "https://example.com/foo", cache.Response{
+		Expiration: time.Now().Add(1 * time.Minute),
+		Value:      []byte("value 1"),
+	}.Bytes(), time.Time{})
+	a.Set(context.Background(), "https://example.com/bar", cache.Response{
+		Expiration: time.Now(),
+		Value:      []byte("value 2"),
+	}.Bytes(), time.Time{})
+	a.Set(context.Background(), "https://example.com/baz", cache.Response{
+		Expiration: time.Now(),
+		Value:      []byte("value 3"),
+	}.Bytes(), time.Time{})
 
 	tests := []struct {
 		name        string
@@ -152,8 +144,8 @@ func TestRelease(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			a.Release(context.Background(), tt.key)
-			if len(a.store) > tt.storeLength {
-				t.Errorf("memory.Release() error; store length = %v, want 0", len(a.store))
+			if len(a.shards[0].store) > tt.storeLength {
+				t.Errorf("memory.Release() error; store length = %v, want 0", len(a.shards[0].store))
 			}
 		})
 	}
@@ -173,10 +165,9 @@ func TestNewAdapter(t *testing.T) {
 				AdapterWithAlgorithm(LRU),
 			},
 			&Adapter{
-				sync.RWMutex{},
-				4,
-				LRU,
-				make(map[string][]byte),
+				capacity:  4,
+				algorithm: LRU,
+				shards:    []*shard{newShard(4, 0, LRU)},
 			},
 			false,
 		},
@@ -218,3 +209,526 @@ func TestNewAdapter(t *testing.T) {
 		})
 	}
 }
+
+func TestCopyOnRead(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		a, _ := NewAdapter(
+			AdapterWithCapacity(2),
+			AdapterWithAlgorithm(LRU),
+			AdapterWithCopyOnRead(enabled),
+		)
+		stored := cache.Response{Value: []byte("value 1")}.Bytes()
+		a.Set(context.Background(), "https://example.com/foo", stored, time.Now().Add(1*time.Minute))
+
+		b, _ := a.Get(context.Background(), "https://example.com/foo")
+		if shared := &b[0] == &stored[0]; shared == enabled {
+			t.Errorf("memory.Get() shares stored slice = %v with copy on read %v", shared, enabled)
+		}
+	}
+}
+
+func TestEvict(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm Algorithm
+		gets      []string
+		want      string
+	}{
+		{"LRU evicts least recently used", LRU, []string{"foo", "baz", "bar"}, "foo"},
+		{"MRU evicts most recently used", MRU, []string{"foo", "baz", "bar"}, "bar"},
+		{"LFU evicts least frequently used", LFU, []string{"foo", "foo", "bar"}, "baz"},
+		{"MFU evicts most frequently used", MFU, []string{"foo", "foo", "bar"}, "foo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := NewAdapter(AdapterWithCapacity(3), AdapterWithAlgorithm(tt.algorithm))
+			for _, key := range []string{"foo", "bar", "baz"} {
+				a.Set(context.Background(), key, cache.Response{Value: []byte(key)}.Bytes(), time.Now().Add(1*time.Minute))
+			}
+			for _, key := range tt.gets {
+				time.Sleep(1 * time.Millisecond)
+				a.Get(context.Background(), key)
+			}
+
+			a.Set(context.Background(), "qux", cache.Response{Value: []byte("qux")}.Bytes(), time.Now().Add(1*time.Minute))
+
+			if _, ok := a.Get(context.Background(), tt.want); ok {
+				t.Errorf("memory.Set() did not evict %v", tt.want)
+			}
+		})
+	}
+}
+
+func TestInvalidateTag(t *testing.T) {
+	a, _ := NewAdapter(AdapterWithCapacity(4), AdapterWithAlgorithm(LRU))
+	tagger := a.(cache.TaggingAdapter)
+	exp := time.Now().Add(1 * time.Minute)
+	for key, tags := range map[string][]string{
+		"https://example.com/foo": {"product-42", "catalog"},
+		"https://example.com/bar": {"catalog"},
+		"https://example.com/baz": {"product-43"},
+	} {
+		a.Set(context.Background(), key, cache.Response{Value: []byte(key)}.Bytes(), exp)
+		tagger.Tag(context.Background(), key, tags, exp)
+	}
+
+	tests := []struct {
+		name string
+		tag  string
+		want []string
+	}{
+		{"ignores unknown tag", "unknown", []string{"https://example.com/foo", "https://example.com/bar", "https://example.com/baz"}},
+		{"releases tagged keys", "product-42", []string{"https://example.com/bar", "https://example.com/baz"}},
+		{"releases remaining tagged keys", "catalog", []string{"https://example.com/baz"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tagger.InvalidateTag(context.Background(), tt.tag); err != nil {
+				t.Fatalf("memory.InvalidateTag() error = %v", err)
+			}
+			for _, key := range tt.want {
+				if _, ok := a.Get(context.Background(), key); !ok {
+					t.Errorf("memory.InvalidateTag() released %v", key)
+				}
+			}
+			if len(a.(*Adapter).shards[0].store) != len(tt.want) {
+				t.Errorf("memory.InvalidateTag() store length = %v, want %v", len(a.(*Adapter).shards[0].store), len(tt.want))
+			}
+		})
+	}
+
+	if len(a.(*Adapter).shards[0].tags) != 1 || len(a.(*Adapter).shards[0].keyTags) != 1 {
+		t.Errorf("memory.InvalidateTag() left tag index %v, %v", a.(*Adapter).shards[0].tags, a.(*Adapter).shards[0].keyTags)
+	}
+}
+
+func TestInvalidatePrefixAndPattern(t *testing.T) {
+	keys := []string{"/api/products/1", "/api/products/2", "/api/users/1"}
+	tests := []struct {
+		name       string
+		invalidate func(a cache.InvalidatingAdapter) error
+		want       []string
+	}{
+		{
+			"invalidates prefix",
+			func(a cache.InvalidatingAdapter) error {
+				return a.InvalidatePrefix(context.Background(), "/api/products/")
+			},
+			[]string{"/api/users/1"},
+		},
+		{
+			"invalidates pattern",
+			func(a cache.InvalidatingAdapter) error {
+				return a.InvalidatePattern(context.Background(), "/api/*/1")
+			},
+			[]string{"/api/products/2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := NewAdapter(AdapterWithCapacity(4), AdapterWithAlgorithm(LRU))
+			for _, key := range keys {
+				a.Set(context.Background(), key, cache.Response{Value: []byte(key)}.Bytes(), time.Now().Add(1*time.Minute))
+			}
+			if err := tt.invalidate(a.(cache.InvalidatingAdapter)); err != nil {
+				t.Fatalf("invalidate error = %v", err)
+			}
+			var got []string
+			for _, key := range keys {
+				if _, ok := a.Get(context.Background(), key); ok {
+					got = append(got, key)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("remaining keys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	a, err := NewAdapter(AdapterWithMaxBytes(10), AdapterWithAlgorithm(LRU))
+	if err != nil {
+		t.Fatalf("NewAdapter() error = %v", err)
+	}
+	exp := time.Now().Add(1 * time.Minute)
+
+	tests := []struct {
+		name     string
+		key      string
+		size     int
+		want     []string
+		wantSize int64
+	}{
+		{"stores within limit", "foo", 4, []string{"foo"}, 4},
+		{"stores up to limit", "bar", 6, []string{"foo", "bar"}, 10},
+		{"evicts least recently used", "baz", 3, []string{"bar", "baz"}, 9},
+		{"replaces existing key", "baz", 4, []string{"bar", "baz"}, 10},
+		{"evicts several", "qux", 9, []string{"qux"}, 9},
+		{"skips oversized", "quux", 11, []string{"qux"}, 9},
+		{"releases oversized existing key", "qux", 11, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			time.Sleep(1 * time.Millisecond)
+			a.Set(context.Background(), tt.key, make([]byte, tt.size), exp)
+			var got []string
+			for _, key := range []string{"foo", "bar", "baz", "qux", "quux"} {
+				if _, ok := a.(*Adapter).shards[0].store[key]; ok {
+					got = append(got, key)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("memory.Set() stored %v, want %v", got, tt.want)
+			}
+			if a.(*Adapter).shards[0].size != tt.wantSize {
+				t.Errorf("memory.Set() size = %v, want %v", a.(*Adapter).shards[0].size, tt.wantSize)
+			}
+		})
+	}
+
+	if _, err := NewAdapter(AdapterWithMaxBytes(0)); err == nil {
+		t.Errorf("NewAdapter() with invalid max bytes error = nil")
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	a, err := NewAdapter(
+		AdapterWithCapacity(4),
+		AdapterWithAlgorithm(LRU),
+		AdapterWithCleanupInterval(5*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("NewAdapter() error = %v", err)
+	}
+	defer a.(io.Closer).Close()
+
+	a.Set(context.Background(), "expiring", []byte("value 1"), time.Now().Add(10*time.Millisecond))
+	a.Set(context.Background(), "fresh", []byte("value 2"), time.Now().Add(1*time.Minute))
+	a.Set(context.Background(), "forever", []byte("value 3"), time.Time{})
+	if _, ok := a.Get(context.Background(), "expiring"); !ok {
+		t.Errorf("memory.Get() did not return unexpired entry")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	if _, ok := a.Get(context.Background(), "expiring"); ok {
+		t.Errorf("memory.Get() returned expired entry")
+	}
+	a.(*Adapter).shards[0].mutex.Lock()
+	length := len(a.(*Adapter).shards[0].store)
+	a.(*Adapter).shards[0].mutex.Unlock()
+	if length != 2 {
+		t.Errorf("memory cleanup left %v entries, want 2", length)
+	}
+
+	if err := a.(io.Closer).Close(); err != nil {
+		t.Errorf("memory.Close() error = %v", err)
+	}
+	if _, err := NewAdapter(AdapterWithCleanupInterval(0)); err == nil {
+		t.Errorf("NewAdapter() with invalid cleanup interval error = nil")
+	}
+}
+
+func TestEvictionOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm Algorithm
+		gets      []string
+		want      []string
+	}{
+		{"LRU", LRU, []string{"a", "b", "a"}, []string{"c", "b", "a"}},
+		{"MRU", MRU, []string{"a", "b", "a"}, []string{"a", "b", "c"}},
+		{"LFU breaks ties by recency", LFU, []string{"a", "a", "b", "c"}, []string{"b", "c", "a"}},
+		{"MFU breaks ties by recency", MFU, []string{"b", "a", "c", "c"}, []string{"c", "b", "a"}},
+		{"CLOCK gives referenced entries a second chance", CLOCK, []string{"a"}, []string{"b", "c", "a"}},
+		{"ARC evicts entries requested once first", ARC, []string{"a", "b"}, []string{"c", "a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter, _ := NewAdapter(AdapterWithCapacity(3), AdapterWithAlgorithm(tt.algorithm))
+			a := adapter.(*Adapter).shards[0]
+			for _, key := range []string{"a", "b", "c"} {
+				a.set(key, []byte(key), time.Time{})
+			}
+			for _, key := range tt.gets {
+				a.get(key)
+			}
+
+			var got []string
+			for len(a.store) > 0 {
+				before := map[string]bool{}
+				for key := range a.store {
+					before[key] = true
+				}
+				a.evict()
+				for key := range before {
+					if _, ok := a.store[key]; !ok {
+						got = append(got, key)
+					}
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("memory.evict() order = %v, want %v", got, tt.want)
+			}
+			if a.recency.Len() != 0 || a.buckets.Len() != 0 {
+				t.Errorf("memory.evict() left entries linked")
+			}
+		})
+	}
+}
+
+func TestShards(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         []AdapterOptions
+		wantShards   int
+		wantCapacity int
+		wantMaxBytes int64
+	}{
+		{"single shard for small capacity", []AdapterOptions{AdapterWithCapacity(100)}, 1, 100, 0},
+		{"single shard for small max bytes", []AdapterOptions{AdapterWithMaxBytes(1 << 20)}, 1, 0, 1 << 20},
+		{"splits capacity", []AdapterOptions{AdapterWithCapacity(10), AdapterWithShards(4)}, 4, 3, 0},
+		{"splits max bytes", []AdapterOptions{AdapterWithMaxBytes(100), AdapterWithShards(4)}, 4, 0, 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewAdapter(append([]AdapterOptions{AdapterWithAlgorithm(LRU)}, tt.opts...)...)
+			if err != nil {
+				t.Fatalf("NewAdapter() error = %v", err)
+			}
+			shards := a.(*Adapter).shards
+			if len(shards) != tt.wantShards || shards[0].capacity != tt.wantCapacity || shards[0].maxBytes != tt.wantMaxBytes {
+				t.Errorf("NewAdapter() shards = %v of capacity %v and max bytes %v, want %v of %v and %v",
+					len(shards), shards[0].capacity, shards[0].maxBytes, tt.wantShards, tt.wantCapacity, tt.wantMaxBytes)
+			}
+		})
+	}
+
+	a, _ := NewAdapter(AdapterWithCapacity(1000), AdapterWithAlgorithm(LRU), AdapterWithShards(8))
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("https://example.com/%d", i)
+		a.Set(context.Background(), key, []byte(key), time.Time{})
+	}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("https://example.com/%d", i)
+		if b, ok := a.Get(context.Background(), key); !ok || string(b) != key {
+			t.Errorf("memory.Get(%v) = %s, %v", key, b, ok)
+		}
+	}
+	used := 0
+	for _, s := range a.(*Adapter).shards {
+		if len(s.store) > 0 {
+			used++
+		}
+	}
+	if used < 2 {
+		t.Errorf("memory.Set() used %v shards, want keys spread over shards", used)
+	}
+
+	if _, err := NewAdapter(AdapterWithShards(0)); err == nil {
+		t.Errorf("NewAdapter() with invalid shards error = nil")
+	}
+}
+
+func TestARCGhosts(t *testing.T) {
+	s := newShard(2, 0, ARC)
+	s.set("a", []byte("a"), time.Time{})
+	s.set("b", []byte("b"), time.Time{})
+	s.set("c", []byte("c"), time.Time{})
+	if _, ok := s.ghosts["a"]; !ok || s.recentGhosts.Len() != 1 {
+		t.Fatalf("shard.set() did not remember evicted key a")
+	}
+
+	s.set("a", []byte("a"), time.Time{})
+	if s.target != 1 {
+		t.Errorf("shard.set() target = %v, want 1", s.target)
+	}
+	if e := s.store["a"]; e == nil || e.queue != s.frequent {
+		t.Errorf("shard.set() did not store requested ghost in frequent list")
+	}
+	if _, ok := s.ghosts["a"]; ok {
+		t.Errorf("shard.set() did not forget stored ghost")
+	}
+	if _, ok := s.ghosts["b"]; !ok {
+		t.Errorf("shard.set() did not remember evicted key b")
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	a, _ := NewAdapter(AdapterWithCapacity(4), AdapterWithAlgorithm(LRU), AdapterWithShards(2))
+	a.Set(context.Background(), "foo", []byte("value 1"), time.Time{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, ok := a.Get(ctx, "foo"); ok {
+		t.Errorf("memory.Get() with canceled context returned entry")
+	}
+	a.Set(ctx, "bar", []byte("value 2"), time.Time{})
+	a.Release(ctx, "foo")
+	if _, ok := a.Get(context.Background(), "bar"); ok {
+		t.Errorf("memory.Set() with canceled context stored entry")
+	}
+	if _, ok := a.Get(context.Background(), "foo"); !ok {
+		t.Errorf("memory.Release() with canceled context released entry")
+	}
+
+	tests := []struct {
+		name string
+		fn   func() error
+	}{
+		{"Tag", func() error { return a.(cache.TaggingAdapter).Tag(ctx, "foo", []string{"tag"}, time.Time{}) }},
+		{"InvalidateTag", func() error { return a.(cache.TaggingAdapter).InvalidateTag(ctx, "tag") }},
+		{"InvalidatePrefix", func() error { return a.(cache.InvalidatingAdapter).InvalidatePrefix(ctx, "") }},
+		{"InvalidatePattern", func() error { return a.(cache.InvalidatingAdapter).InvalidatePattern(ctx, "*") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fn(); err != context.Canceled {
+				t.Errorf("memory.%v() error = %v, want %v", tt.name, err, context.Canceled)
+			}
+		})
+	}
+	if _, ok := a.Get(context.Background(), "foo"); !ok {
+		t.Errorf("invalidation with canceled context released entry")
+	}
+}
+
+func TestStats(t *testing.T) {
+	ctx := context.Background()
+	adapter, _ := NewAdapter(AdapterWithCapacity(2), AdapterWithAlgorithm(LRU))
+	a := adapter.(*Adapter)
+
+	a.Set(ctx, "/api/foo", []byte("foo"), time.Time{})
+	a.Set(ctx, "/api/bar", []byte("bar"), time.Now().Add(10*time.Millisecond))
+	a.Get(ctx, "/api/foo")
+	a.Get(ctx, "/api/baz")
+	time.Sleep(20 * time.Millisecond)
+	a.Get(ctx, "/api/bar")
+	a.Set(ctx, "/api/bar", []byte("bar"), time.Time{})
+	a.Set(ctx, "/qux", []byte("quxx"), time.Time{})
+
+	want := Stats{Entries: 2, Bytes: 7, Hits: 1, Misses: 2, Evictions: 1, Expirations: 1}
+	if got := a.Stats(); got != want {
+		t.Errorf("memory.Stats() = %+v, want %+v", got, want)
+	}
+	if got := a.Len(); got != 2 {
+		t.Errorf("memory.Len() = %v, want 2", got)
+	}
+	if got := a.Keys("/api/"); !reflect.DeepEqual(got, []string{"/api/bar"}) {
+		t.Errorf("memory.Keys() = %v, want [/api/bar]", got)
+	}
+
+	a.ResetStats()
+	if got := a.Stats(); got != (Stats{Entries: 2, Bytes: 7}) {
+		t.Errorf("memory.Stats() after reset = %+v", got)
+	}
+}
+
+func TestEvictOpaqueResponses(t *testing.T) {
+	for _, algorithm := range []Algorithm{LRU, MRU, LFU, MFU, ARC, CLOCK} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			a, _ := NewAdapter(AdapterWithCapacity(2), AdapterWithAlgorithm(algorithm))
+			for _, key := range []string{"foo", "bar", "baz"} {
+				// Not encoded responses, which eviction must not decode.
+				a.Set(context.Background(), key, []byte{0xff, 0x00, byte(len(key))}, time.Time{})
+				a.Get(context.Background(), key)
+			}
+			if got := a.(*Adapter).Stats(); got.Entries != 2 || got.Evictions != 1 {
+				t.Errorf("memory.Set() stats = %+v, want 2 entries and 1 eviction", got)
+			}
+		})
+	}
+}
+
+func TestMaxEntrySize(t *testing.T) {
+	var rejected []string
+	a, err := NewAdapter(
+		AdapterWithCapacity(4),
+		AdapterWithAlgorithm(LRU),
+		AdapterWithMaxEntrySize(4),
+		AdapterWithRejectionHandler(func(key string, size int) {
+			rejected = append(rejected, fmt.Sprintf("%s:%d", key, size))
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewAdapter() error = %v", err)
+	}
+	ctx := context.Background()
+	a.Set(ctx, "foo", []byte("1234"), time.Time{})
+	a.Set(ctx, "bar", []byte("1234"), time.Time{})
+	a.Set(ctx, "foo", []byte("12345"), time.Time{})
+
+	if _, ok := a.Get(ctx, "foo"); ok {
+		t.Errorf("memory.Get(foo) found a response replaced by a rejected one")
+	}
+	if _, ok := a.Get(ctx, "bar"); !ok {
+		t.Errorf("memory.Get(bar) evicted by a rejected response")
+	}
+	if want := []string{"foo:5"}; !reflect.DeepEqual(rejected, want) {
+		t.Errorf("rejected = %v, want %v", rejected, want)
+	}
+	if got := a.(*Adapter).Stats().Rejections; got != 1 {
+		t.Errorf("memory.Stats().Rejections = %v, want 1", got)
+	}
+
+	if _, err := NewAdapter(AdapterWithCapacity(4), AdapterWithAlgorithm(LRU), AdapterWithMaxEntrySize(0)); err == nil {
+		t.Errorf("NewAdapter() with max entry size 0 error = nil")
+	}
+	if _, err := NewAdapter(AdapterWithCapacity(4), AdapterWithAlgorithm(LRU), AdapterWithRejectionHandler(nil)); err == nil {
+		t.Errorf("NewAdapter() with nil rejection handler error = nil")
+	}
+}
+
+func TestStaleHandler(t *testing.T) {
+	refreshed := make(chan string, 10)
+	release := make(chan struct{})
+	a, err := NewAdapter(
+		AdapterWithCapacity(4),
+		AdapterWithAlgorithm(LRU),
+		AdapterWithStaleHandler(time.Minute, func(key string) {
+			refreshed <- key
+			<-release
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewAdapter() error = %v", err)
+	}
+	m := a.(*Adapter)
+	ctx := context.Background()
+	a.Set(ctx, "fresh", []byte("value 1"), time.Now().Add(time.Minute))
+	a.Set(ctx, "stale", []byte("value 2"), time.Now().Add(-time.Second))
+	a.Set(ctx, "expired", []byte("value 3"), time.Now().Add(-2*time.Minute))
+
+	if _, stale, ok := m.Lookup(ctx, "fresh"); !ok || stale {
+		t.Errorf("memory.Lookup(fresh) = %v, %v, want fresh hit", stale, ok)
+	}
+	if _, stale, ok := m.Lookup(ctx, "expired"); ok || stale {
+		t.Errorf("memory.Lookup(expired) = %v, %v, want miss", stale, ok)
+	}
+	if _, ok := a.Get(ctx, "stale"); ok {
+		t.Errorf("memory.Get(stale) hit, want miss")
+	}
+	for i := 0; i < 2; i++ {
+		if response, stale, ok := m.Lookup(ctx, "stale"); !ok || !stale || string(response) != "value 2" {
+			t.Errorf("memory.Lookup(stale) = %q, %v, %v, want stale hit", response, stale, ok)
+		}
+	}
+	if key := <-refreshed; key != "stale" {
+		t.Errorf("stale handler key = %v, want stale", key)
+	}
+	close(release)
+	select {
+	case key := <-refreshed:
+		t.Errorf("stale handler called again for %v during a refresh", key)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	if _, err := NewAdapter(AdapterWithCapacity(4), AdapterWithAlgorithm(LRU), AdapterWithStaleHandler(0, func(string) {})); err == nil {
+		t.Errorf("NewAdapter() with grace period 0 error = nil")
+	}
+	if _, err := NewAdapter(AdapterWithCapacity(4), AdapterWithAlgorithm(LRU), AdapterWithStaleHandler(time.Minute, nil)); err == nil {
+		t.Errorf("NewAdapter() with nil stale handler error = nil")
+	}
+}
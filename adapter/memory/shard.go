@@ -0,0 +1,519 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package memory
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// shard is a lock-striped segment of the memory adapter store, with its
+// share of the capacity and max bytes and its own eviction order.
+type shard struct {
+	counters  *counters
+	mutex     sync.Mutex
+	capacity  int
+	maxBytes  int64
+	size      int64
+	algorithm Algorithm
+	store     map[string]*entry
+	tags      map[string]map[string]struct{}
+	keyTags   map[string]map[string]struct{}
+
+	// recency lists entries from the most to the least recently used, and
+	// buckets lists frequency buckets by increasing frequency.
+	recency *list.List
+	buckets *list.List
+
+	// frequent lists the entries requested more than once for ARC, which
+	// uses recency for the others, and the ghost lists the keys evicted from
+	// either, indexed by ghosts. target is the adaptive target length of
+	// recency.
+	frequent       *list.List
+	recentGhosts   *list.List
+	frequentGhosts *list.List
+	ghosts         map[string]*list.Element
+	target         int
+
+	// hand is the CLOCK hand, the next entry of recency considered for
+	// eviction.
+	hand *list.Element
+
+	// admission is the TinyLFU admission filter, if enabled.
+	admission *tinyLFU
+
+	// segments hold the responses with the Segmented storage.
+	segments *segments
+
+	// reads mirrors store with the SyncMap storage, for lookups without
+	// the lock.
+	reads *sync.Map
+
+	// grace is how long expired entries are kept as stale, calling onStale
+	// when looked up.
+	grace   int64
+	onStale func(key string)
+}
+
+func newShard(capacity int, maxBytes int64, algorithm Algorithm) *shard {
+	s := &shard{
+		counters:  &counters{},
+		capacity:  capacity,
+		maxBytes:  maxBytes,
+		algorithm: algorithm,
+		store:     make(map[string]*entry, capacity),
+		recency:   list.New(),
+		buckets:   list.New(),
+	}
+	if algorithm == ARC {
+		s.frequent = list.New()
+		s.recentGhosts = list.New()
+		s.frequentGhosts = list.New()
+		s.ghosts = map[string]*list.Element{}
+	}
+
+	return s
+}
+
+// get returns the response stored for a key, unless expired, recording the
+// access.
+func (s *shard) get(key string) ([]byte, bool) {
+	response, _, ok := s.lookup(key, false)
+	return response, ok
+}
+
+// lookup returns the response stored for a key, unless expired for longer
+// than the stale grace period, recording the access. Stale responses are
+// only returned when allowed, calling the stale handler unless a refresh
+// is already running.
+func (s *shard) lookup(key string, allowStale bool) ([]byte, bool, bool) {
+	if s.reads != nil {
+		return s.load(key, allowStale)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.admission != nil {
+		s.admission.record(xxhash.Sum64String(key))
+	}
+	e, ok := s.store[key]
+	if !ok {
+		return nil, false, false
+	}
+	now := time.Now().UnixNano()
+	if e.expired(now - s.grace) {
+		s.delete(key)
+		atomic.AddUint64(&s.counters.expirations, 1)
+		return nil, false, false
+	}
+	stale := e.expired(now)
+	if stale && !allowStale {
+		return nil, false, false
+	}
+	s.touch(e)
+	if stale && s.onStale != nil && !e.refreshing {
+		e.refreshing = true
+		go s.refresh(key, e)
+	}
+	if e.slab != 0 {
+		// The segment bytes are reused once the entry is removed.
+		return append([]byte(nil), e.response...), stale, true
+	}
+
+	return e.response, stale, true
+}
+
+// refresh calls the stale handler for an entry, allowing another refresh
+// once it returns.
+func (s *shard) refresh(key string, e *entry) {
+	defer func() {
+		s.mutex.Lock()
+		e.refreshing = false
+		s.mutex.Unlock()
+	}()
+
+	s.onStale(key)
+}
+
+// set stores a response, evicting entries until it fits within the capacity
+// and the max bytes. Responses larger than the max bytes are not stored, nor
+// new keys less frequently requested than the first eviction victim when
+// the admission filter is enabled.
+func (s *shard) set(key string, response []byte, expiration time.Time) {
+	size := int64(len(response))
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e, exists := s.store[key]
+	if s.maxBytes > 0 && size > s.maxBytes {
+		if exists {
+			s.delete(key)
+		}
+		return
+	}
+
+	if exists {
+		// The replaced entry keeps its tags.
+		s.unlink(e)
+		s.free(e)
+		s.size -= int64(len(e.response))
+		delete(s.store, key)
+	} else if s.admission != nil && s.full(size) {
+		if victim := s.victim(); victim != nil &&
+			s.admission.estimate(xxhash.Sum64String(key)) <= s.admission.estimate(xxhash.Sum64String(victim.key)) {
+			return
+		}
+	}
+	for s.full(size) {
+		if !s.evict() {
+			break
+		}
+	}
+
+	e = newEntry(response)
+	e.key = key
+	s.place(e, response)
+	if !expiration.IsZero() {
+		e.expiration = expiration.UnixNano()
+	}
+	s.link(e)
+	s.store[key] = e
+	s.size += size
+	if s.reads != nil {
+		s.reads.Store(key, e)
+	}
+}
+
+func (s *shard) release(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.delete(key)
+}
+
+// tag associates a stored key with tags.
+func (s *shard) tag(key string, tags []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.store[key]; !ok {
+		return
+	}
+	if s.tags == nil {
+		s.tags = map[string]map[string]struct{}{}
+		s.keyTags = map[string]map[string]struct{}{}
+	}
+	for _, tag := range tags {
+		if s.tags[tag] == nil {
+			s.tags[tag] = map[string]struct{}{}
+		}
+		s.tags[tag][key] = struct{}{}
+		if s.keyTags[key] == nil {
+			s.keyTags[key] = map[string]struct{}{}
+		}
+		s.keyTags[key][tag] = struct{}{}
+	}
+}
+
+func (s *shard) invalidateTag(tag string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for key := range s.tags[tag] {
+		s.delete(key)
+	}
+}
+
+// invalidate deletes the keys matching a function.
+func (s *shard) invalidate(match func(key string) bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for key := range s.store {
+		if match(key) {
+			s.delete(key)
+		}
+	}
+}
+
+func (s *shard) deleteExpired(now int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for key, e := range s.store {
+		if e.expired(now - s.grace) {
+			s.delete(key)
+			atomic.AddUint64(&s.counters.expirations, 1)
+		}
+	}
+}
+
+// delete removes a key from the store and the tag index. The caller must
+// hold the lock.
+func (s *shard) delete(key string) {
+	if e, ok := s.store[key]; ok {
+		s.unlink(e)
+		s.free(e)
+		s.size -= int64(len(e.response))
+	}
+	delete(s.store, key)
+	if s.reads != nil {
+		s.reads.Delete(key)
+	}
+	for tag := range s.keyTags[key] {
+		delete(s.tags[tag], key)
+		if len(s.tags[tag]) == 0 {
+			delete(s.tags, tag)
+		}
+	}
+	delete(s.keyTags, key)
+}
+
+// touch records an access to an entry, moving it for the caching
+// algorithm. The caller must hold the lock.
+func (s *shard) touch(e *entry) {
+	e.lastAccess = time.Now().UnixNano()
+	e.frequency++
+	switch s.algorithm {
+	case LRU, MRU:
+		s.recency.MoveToFront(e.element)
+	case CLOCK:
+		e.referenced = true
+	case ARC:
+		e.queue.Remove(e.element)
+		e.queue = s.frequent
+		e.element = s.frequent.PushFront(e)
+	case LFU, MFU:
+		current := e.bucket
+		next := current.Next()
+		if next == nil || next.Value.(*bucket).frequency != e.frequency {
+			next = s.buckets.InsertAfter(&bucket{frequency: e.frequency, entries: list.New()}, current)
+		}
+		s.unlink(e)
+		e.bucket = next
+		e.element = next.Value.(*bucket).entries.PushFront(e)
+	}
+}
+
+// link adds an entry as the most recently used one, in the bucket of its
+// frequency, or right behind the CLOCK hand. Entries aren't ordered with
+// the SyncMap storage. The caller must hold the lock.
+func (s *shard) link(e *entry) {
+	if s.reads != nil {
+		return
+	}
+	switch s.algorithm {
+	case LRU, MRU:
+		e.queue = s.recency
+		e.element = s.recency.PushFront(e)
+	case CLOCK:
+		e.queue = s.recency
+		if s.hand == nil {
+			e.element = s.recency.PushBack(e)
+		} else {
+			e.element = s.recency.InsertBefore(e, s.hand)
+		}
+	case ARC:
+		e.queue = s.recency
+		if g, ok := s.ghosts[e.key]; ok {
+			s.adapt(g)
+			e.queue = s.frequent
+		}
+		e.element = e.queue.PushFront(e)
+	case LFU, MFU:
+		b := s.buckets.Front()
+		for b != nil && b.Value.(*bucket).frequency < e.frequency {
+			b = b.Next()
+		}
+		if b == nil {
+			b = s.buckets.PushBack(&bucket{frequency: e.frequency, entries: list.New()})
+		} else if b.Value.(*bucket).frequency != e.frequency {
+			b = s.buckets.InsertBefore(&bucket{frequency: e.frequency, entries: list.New()}, b)
+		}
+		e.bucket = b
+		e.element = b.Value.(*bucket).entries.PushFront(e)
+	}
+}
+
+// unlink removes an entry from the recency list or its frequency bucket,
+// dropping the bucket once empty. The caller must hold the lock.
+func (s *shard) unlink(e *entry) {
+	if e.element == nil {
+		return
+	}
+
+	if e.bucket != nil {
+		entries := e.bucket.Value.(*bucket).entries
+		entries.Remove(e.element)
+		if entries.Len() == 0 {
+			s.buckets.Remove(e.bucket)
+		}
+		e.bucket = nil
+	} else {
+		if e.element == s.hand {
+			s.hand = e.element.Next()
+		}
+		e.queue.Remove(e.element)
+	}
+	e.element = nil
+	e.queue = nil
+}
+
+// adapt moves the ARC target length of recency toward the ghost list a
+// requested key was found in, and forgets the key. The caller must hold the
+// lock.
+func (s *shard) adapt(g *list.Element) {
+	ghost := g.Value.(*ghost)
+	if ghost.queue == s.recentGhosts {
+		delta := s.frequentGhosts.Len() / s.recentGhosts.Len()
+		if delta < 1 {
+			delta = 1
+		}
+		if s.target += delta; s.target > s.ghostCapacity() {
+			s.target = s.ghostCapacity()
+		}
+	} else {
+		delta := s.recentGhosts.Len() / s.frequentGhosts.Len()
+		if delta < 1 {
+			delta = 1
+		}
+		if s.target -= delta; s.target < 0 {
+			s.target = 0
+		}
+	}
+
+	ghost.queue.Remove(g)
+	delete(s.ghosts, ghost.key)
+}
+
+// remember adds the key of an entry evicted by ARC to the ghost list
+// matching its queue, forgetting the oldest keys beyond the ghost capacity.
+// The caller must hold the lock.
+func (s *shard) remember(e *entry) {
+	queue := s.recentGhosts
+	if e.queue == s.frequent {
+		queue = s.frequentGhosts
+	}
+	s.ghosts[e.key] = queue.PushFront(&ghost{key: e.key, queue: queue})
+
+	for queue.Len() > s.ghostCapacity() {
+		oldest := queue.Back()
+		delete(s.ghosts, oldest.Value.(*ghost).key)
+		queue.Remove(oldest)
+	}
+}
+
+// ghostCapacity returns the maximum length of each ARC ghost list, the
+// capacity, or the number of entries for shards bounded by max bytes only.
+func (s *shard) ghostCapacity() int {
+	if s.capacity > 0 {
+		return s.capacity
+	}
+	if len(s.store) > 0 {
+		return len(s.store)
+	}
+	return 1
+}
+
+// full reports whether storing a response of a size requires an eviction.
+// The caller must hold the lock.
+func (s *shard) full(size int64) bool {
+	return (s.capacity > 0 && len(s.store) >= s.capacity) ||
+		(s.maxBytes > 0 && s.size+size > s.maxBytes)
+}
+
+// evict removes the entry selected by the algorithm and reports whether
+// there was one. The caller must hold the lock.
+func (s *shard) evict() bool {
+	victim := s.victim()
+	if victim == nil {
+		return false
+	}
+
+	if s.algorithm == ARC {
+		s.remember(victim)
+	}
+	s.delete(victim.key)
+	atomic.AddUint64(&s.counters.evictions, 1)
+
+	return true
+}
+
+// victim returns the entry selected by the algorithm for eviction, the
+// least recently used one among the least or most frequently used ones for
+// LFU and MFU. With CLOCK, the hand moves to the victim, clearing the
+// reference bit of the entries it passes. With the SyncMap storage, the
+// victim is picked among a sample of entries. The caller must hold the lock.
+func (s *shard) victim() *entry {
+	if s.reads != nil {
+		return s.sample()
+	}
+
+	var victim *list.Element
+	switch s.algorithm {
+	case LRU:
+		victim = s.recency.Back()
+	case MRU:
+		victim = s.recency.Front()
+	case LFU:
+		if b := s.buckets.Front(); b != nil {
+			victim = b.Value.(*bucket).entries.Back()
+		}
+	case MFU:
+		if b := s.buckets.Back(); b != nil {
+			victim = b.Value.(*bucket).entries.Back()
+		}
+	case CLOCK:
+		for s.recency.Len() > 0 {
+			if s.hand == nil {
+				s.hand = s.recency.Front()
+			}
+			e := s.hand.Value.(*entry)
+			if !e.referenced {
+				victim = s.hand
+				break
+			}
+			e.referenced = false
+			s.hand = s.hand.Next()
+		}
+	case ARC:
+		if s.recency.Len() > 0 && (s.recency.Len() > s.target || s.frequent.Len() == 0) {
+			victim = s.recency.Back()
+		} else {
+			victim = s.frequent.Back()
+		}
+	}
+	if victim == nil {
+		return nil
+	}
+
+	return victim.Value.(*entry)
+}
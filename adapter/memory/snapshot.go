@@ -0,0 +1,234 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package memory
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotMagic and snapshotVersion start snapshots, identifying their
+// format.
+const (
+	snapshotMagic   = "\x00hcm"
+	snapshotVersion = 1
+)
+
+// maxSnapshotField is the largest key, tag or response read from a
+// snapshot, guarding against corrupt lengths.
+const maxSnapshotField = 1 << 30
+
+// snapshotEntry is an entry as written to a snapshot.
+type snapshotEntry struct {
+	key        string
+	response   []byte
+	expiration int64
+	tags       []string
+}
+
+// SaveTo writes a snapshot of the stored entries, along with their
+// expiration dates and tags, to w.
+func (a *Adapter) SaveTo(w io.Writer) error {
+	// Write errors are kept by the buffered writer and returned by Flush.
+	bw := bufio.NewWriter(w)
+	bw.WriteString(snapshotMagic)
+	bw.WriteByte(snapshotVersion)
+
+	buf := make([]byte, binary.MaxVarintLen64)
+	writeUvarint := func(v uint64) {
+		bw.Write(buf[:binary.PutUvarint(buf, v)])
+	}
+	writeBytes := func(b []byte) {
+		writeUvarint(uint64(len(b)))
+		bw.Write(b)
+	}
+
+	for _, s := range a.shards {
+		for _, e := range s.snapshot() {
+			writeBytes([]byte(e.key))
+			bw.Write(buf[:binary.PutVarint(buf, e.expiration)])
+			writeBytes(e.response)
+			writeUvarint(uint64(len(e.tags)))
+			for _, tag := range e.tags {
+				writeBytes([]byte(tag))
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+// LoadFrom stores the entries of a snapshot written by SaveTo, dropping the
+// ones past their expiration date.
+func (a *Adapter) LoadFrom(r io.Reader) error {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(snapshotMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return fmt.Errorf("memory adapter snapshot header: %w", err)
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return errors.New("memory adapter snapshot is invalid")
+	}
+	if header[len(snapshotMagic)] != snapshotVersion {
+		return fmt.Errorf("memory adapter snapshot version %d is unsupported", header[len(snapshotMagic)])
+	}
+
+	readBytes := func() ([]byte, error) {
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, err
+		}
+		if n > maxSnapshotField {
+			return nil, errors.New("field is too large")
+		}
+		b := make([]byte, n)
+		_, err = io.ReadFull(br, b)
+		return b, err
+	}
+
+	now := time.Now().UnixNano()
+	for {
+		key, err := readBytes()
+		if err == io.EOF {
+			return nil
+		}
+
+		var e snapshotEntry
+		if err == nil {
+			e.key = string(key)
+			e.expiration, err = binary.ReadVarint(br)
+		}
+		if err == nil {
+			e.response, err = readBytes()
+		}
+		var count uint64
+		if err == nil {
+			count, err = binary.ReadUvarint(br)
+		}
+		for i := uint64(0); err == nil && i < count; i++ {
+			var tag []byte
+			if tag, err = readBytes(); err == nil {
+				e.tags = append(e.tags, string(tag))
+			}
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("memory adapter snapshot entry: %w", err)
+		}
+
+		if e.expiration > 0 && e.expiration <= now {
+			continue
+		}
+		var expiration time.Time
+		if e.expiration > 0 {
+			expiration = time.Unix(0, e.expiration)
+		}
+		s := a.shard(e.key)
+		s.set(e.key, e.response, expiration)
+		s.tag(e.key, e.tags)
+	}
+}
+
+// saveFile writes a snapshot to a temporary file renamed to path, so that
+// an interrupted write doesn't corrupt the previous snapshot.
+func (a *Adapter) saveFile(path string) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := a.SaveTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// loadFile loads the snapshot at path, if it exists.
+func (a *Adapter) loadFile(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return a.LoadFrom(f)
+}
+
+// snapshots saves a snapshot every interval until the adapter is closed.
+func (a *Adapter) snapshots() {
+	ticker := time.NewTicker(a.snapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.saveFile(a.snapshotPath)
+		case <-a.done:
+			return
+		}
+	}
+}
+
+// snapshot returns the unexpired entries of the shard.
+func (s *shard) snapshot() []snapshotEntry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now().UnixNano()
+	entries := make([]snapshotEntry, 0, len(s.store))
+	for key, e := range s.store {
+		if e.expired(now) {
+			continue
+		}
+		entry := snapshotEntry{key: key, response: e.response, expiration: e.expiration}
+		if e.slab != 0 {
+			entry.response = append([]byte(nil), e.response...)
+		}
+		for tag := range s.keyTags[key] {
+			entry.tags = append(entry.tags, tag)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	a, _ := NewAdapter(AdapterWithCapacity(10), AdapterWithAlgorithm(LRU), AdapterWithShards(2))
+	a.Set(ctx, "fresh", []byte("value 1"), time.Now().Add(1*time.Minute))
+	a.Set(ctx, "forever", []byte("value 2"), time.Time{})
+	a.Set(ctx, "expiring", []byte("value 3"), time.Now().Add(10*time.Millisecond))
+	a.(*Adapter).Tag(ctx, "fresh", []string{"catalog"}, time.Time{})
+
+	var buf bytes.Buffer
+	if err := a.(*Adapter).SaveTo(&buf); err != nil {
+		t.Fatalf("memory.SaveTo() error = %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	loaded, _ := NewAdapter(AdapterWithCapacity(10), AdapterWithAlgorithm(LFU))
+	if err := loaded.(*Adapter).LoadFrom(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("memory.LoadFrom() error = %v", err)
+	}
+	for key, want := range map[string]string{"fresh": "value 1", "forever": "value 2"} {
+		if b, ok := loaded.Get(ctx, key); !ok || string(b) != want {
+			t.Errorf("memory.Get(%v) = %s, %v, want %v", key, b, ok, want)
+		}
+	}
+	if _, ok := loaded.Get(ctx, "expiring"); ok {
+		t.Errorf("memory.LoadFrom() loaded expired entry")
+	}
+	loaded.(*Adapter).InvalidateTag(ctx, "catalog")
+	if _, ok := loaded.Get(ctx, "fresh"); ok {
+		t.Errorf("memory.LoadFrom() did not load tags")
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"invalid magic", []byte("\x00xyz\x01")},
+		{"unsupported version", []byte(snapshotMagic + "\x02")},
+		{"truncated entry", buf.Bytes()[:buf.Len()-3]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := NewAdapter(AdapterWithCapacity(10), AdapterWithAlgorithm(LRU))
+			if err := a.(*Adapter).LoadFrom(bytes.NewReader(tt.data)); err == nil {
+				t.Errorf("memory.LoadFrom() error = nil")
+			}
+		})
+	}
+}
+
+func TestSnapshotFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	opts := []AdapterOptions{
+		AdapterWithCapacity(10),
+		AdapterWithAlgorithm(LRU),
+		AdapterWithSnapshot(path, 0),
+	}
+
+	a, err := NewAdapter(opts...)
+	if err != nil {
+		t.Fatalf("NewAdapter() without snapshot error = %v", err)
+	}
+	a.Set(context.Background(), "foo", []byte("value 1"), time.Time{})
+	if err := a.(io.Closer).Close(); err != nil {
+		t.Fatalf("memory.Close() error = %v", err)
+	}
+
+	restarted, err := NewAdapter(opts...)
+	if err != nil {
+		t.Fatalf("NewAdapter() with snapshot error = %v", err)
+	}
+	defer restarted.(io.Closer).Close()
+	if b, ok := restarted.Get(context.Background(), "foo"); !ok || !reflect.DeepEqual(b, []byte("value 1")) {
+		t.Errorf("memory.Get() after restart = %s, %v", b, ok)
+	}
+
+	if _, err := NewAdapter(AdapterWithSnapshot("", 0)); err == nil {
+		t.Errorf("NewAdapter() with empty snapshot path error = nil")
+	}
+}
@@ -0,0 +1,234 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package memory
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Storage is the way the memory adapter stores responses.
+type Storage string
+
+const (
+	// Heap stores each response in its own allocation, as given to Set.
+	Heap Storage = "heap"
+
+	// Segmented copies responses into large byte segments reused as
+	// responses are evicted, so that millions of small responses don't
+	// make as many allocations for the garbage collector to track. Get
+	// returns a copy of the stored response.
+	Segmented Storage = "segmented"
+
+	// SyncMap keeps each response in its own allocation, as Heap does, but
+	// indexes entries with a sync.Map read without locking, for read-mostly
+	// workloads where the shard locks contend. Evictions are approximate,
+	// picking the victim among a sample of entries, and only LRU, MRU, LFU
+	// and MFU are supported, without TinyLFU.
+	SyncMap Storage = "syncmap"
+)
+
+// evictionSamples is the number of entries an eviction victim is picked
+// among with the SyncMap storage.
+const evictionSamples = 5
+
+// maxSegmentSize is the size of the segments of a shard, unless reduced to
+// its max bytes.
+const maxSegmentSize = 1 << 20
+
+// segments are the byte segments holding the responses of a shard, with the
+// live bytes of each segment. Responses are appended to the active segment
+// at offset, and a new active segment is picked when full.
+type segments struct {
+	size   int
+	slabs  [][]byte
+	live   []int
+	active int
+	offset int
+}
+
+func newSegments(maxBytes int64) *segments {
+	size := maxSegmentSize
+	if maxBytes > 0 && maxBytes < int64(size) {
+		size = int(maxBytes)
+	}
+
+	return &segments{size: size, slabs: [][]byte{make([]byte, size)}, live: []int{0}}
+}
+
+// place stores the response of an entry, copying it in a segment unless it
+// is larger than a segment. The caller must hold the lock.
+func (s *shard) place(e *entry, response []byte) {
+	g := s.segments
+	if g == nil || len(response) > g.size {
+		e.response = response
+		return
+	}
+	if g.offset+len(response) > g.size {
+		s.roll(len(response))
+	}
+	e.slab = g.active + 1
+	e.offset = g.offset
+	e.response = g.slabs[g.active][g.offset : g.offset+len(response) : g.offset+len(response)]
+	copy(e.response, response)
+	g.offset += len(response)
+	g.live[g.active] += len(response)
+}
+
+// free releases the bytes of a removed entry. The caller must hold the
+// lock.
+func (s *shard) free(e *entry) {
+	if e.slab == 0 {
+		return
+	}
+	g := s.segments
+	g.live[e.slab-1] -= len(e.response)
+	if e.slab-1 == g.active && g.live[g.active] == 0 {
+		g.offset = 0
+	}
+	e.slab = 0
+}
+
+// roll picks a new active segment with room for n bytes: an empty segment,
+// else the sparsest segment once compacted if at least half of it is free,
+// else a new segment. The caller must hold the lock.
+func (s *shard) roll(n int) {
+	g := s.segments
+	sparsest := -1
+	for i, live := range g.live {
+		if i == g.active {
+			continue
+		}
+		if live == 0 {
+			g.active, g.offset = i, 0
+			return
+		}
+		if sparsest == -1 || live < g.live[sparsest] {
+			sparsest = i
+		}
+	}
+	if sparsest != -1 && g.live[sparsest] <= g.size/2 && g.size-g.live[sparsest] >= n {
+		s.compact(sparsest)
+		g.active, g.offset = sparsest, g.live[sparsest]
+		return
+	}
+
+	g.slabs = append(g.slabs, make([]byte, g.size))
+	g.live = append(g.live, 0)
+	g.active, g.offset = len(g.slabs)-1, 0
+}
+
+// compact moves the responses of a segment to its start, in order, so that
+// its free bytes follow them. The caller must hold the lock.
+func (s *shard) compact(i int) {
+	var entries []*entry
+	for _, e := range s.store {
+		if e.slab == i+1 {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(a, b int) bool { return entries[a].offset < entries[b].offset })
+
+	slab, offset := s.segments.slabs[i], 0
+	for _, e := range entries {
+		n := len(e.response)
+		copy(slab[offset:], e.response)
+		e.offset = offset
+		e.response = slab[offset : offset+n : offset+n]
+		offset += n
+	}
+}
+
+// load returns the response stored for a key with the SyncMap storage,
+// unless expired for longer than the stale grace period, recording the
+// access without the lock, which is only taken to remove expired entries
+// and start refreshing stale ones.
+func (s *shard) load(key string, allowStale bool) ([]byte, bool, bool) {
+	v, ok := s.reads.Load(key)
+	if !ok {
+		return nil, false, false
+	}
+	e := v.(*entry)
+	now := time.Now().UnixNano()
+	if e.expired(now - s.grace) {
+		s.mutex.Lock()
+		if s.store[key] == e {
+			s.delete(key)
+			atomic.AddUint64(&s.counters.expirations, 1)
+		}
+		s.mutex.Unlock()
+		return nil, false, false
+	}
+	stale := e.expired(now)
+	if stale && !allowStale {
+		return nil, false, false
+	}
+	atomic.StoreInt64(&e.lastAccess, now)
+	atomic.AddInt64(&e.frequency, 1)
+	if stale && s.onStale != nil {
+		s.mutex.Lock()
+		if !e.refreshing {
+			e.refreshing = true
+			go s.refresh(key, e)
+		}
+		s.mutex.Unlock()
+	}
+
+	return e.response, stale, true
+}
+
+// sample returns the eviction victim among the first entries of the store,
+// which are iterated from a random position: the least or most recently
+// used one for LRU and MRU, and the least or most frequently used one for
+// LFU and MFU, the least recently used one among them on ties. The caller
+// must hold the lock.
+func (s *shard) sample() *entry {
+	var victim *entry
+	var victimAccess, victimFrequency int64
+	n := 0
+	for _, e := range s.store {
+		access, frequency := atomic.LoadInt64(&e.lastAccess), atomic.LoadInt64(&e.frequency)
+		var better bool
+		switch s.algorithm {
+		case LRU:
+			better = access < victimAccess
+		case MRU:
+			better = access > victimAccess
+		case LFU:
+			better = frequency < victimFrequency || (frequency == victimFrequency && access < victimAccess)
+		case MFU:
+			better = frequency > victimFrequency || (frequency == victimFrequency && access < victimAccess)
+		}
+		if victim == nil || better {
+			victim, victimAccess, victimFrequency = e, access, frequency
+		}
+		if n++; n == evictionSamples {
+			break
+		}
+	}
+
+	return victim
+}
@@ -0,0 +1,229 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSegmentedStorage(t *testing.T) {
+	s := newShard(100, 0, LRU)
+	s.segments = newSegments(16)
+	want := map[string]string{}
+	set := func(key, value string) {
+		s.set(key, []byte(value), time.Time{})
+		want[key] = value
+	}
+	release := func(key string) {
+		s.release(key)
+		delete(want, key)
+	}
+	check := func(step string) {
+		t.Helper()
+		for key, value := range want {
+			if got, ok := s.get(key); !ok || string(got) != value {
+				t.Errorf("%s: get(%v) = %q, %v, want %q", step, key, got, ok, value)
+			}
+		}
+	}
+
+	set("a", "aaaaaaaa")
+	set("b", "bbbbbbbb")
+	release("a")
+	set("c", "cccccccc")
+	set("d", "dddd")
+	check("new segment")
+	if len(s.segments.slabs) != 2 {
+		t.Errorf("segments = %v, want 2", len(s.segments.slabs))
+	}
+
+	set("e", "eeeeeeee")
+	check("compacted segment")
+	if len(s.segments.slabs) != 2 || s.store["b"].offset != 0 {
+		t.Errorf("segments = %v, b offset = %v, want 2 and 0", len(s.segments.slabs), s.store["b"].offset)
+	}
+
+	release("c")
+	release("d")
+	set("f", "ffffffffffffffff")
+	set("g", "a response larger than a segment")
+	set("b", "bb")
+	check("reused segment")
+	if len(s.segments.slabs) != 2 {
+		t.Errorf("segments = %v, want 2", len(s.segments.slabs))
+	}
+
+	got, _ := s.get("f")
+	got[0] = 'x'
+	check("returned copy")
+}
+
+func TestSegmentedStorageRandom(t *testing.T) {
+	s := newShard(64, 512, LRU)
+	s.segments = newSegments(128)
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprint(rng.Intn(100))
+		if rng.Intn(4) == 0 {
+			s.release(key)
+			continue
+		}
+		value := make([]byte, rng.Intn(40))
+		for j := range value {
+			value[j] = key[0]
+		}
+		s.set(key, value, time.Time{})
+	}
+
+	live := make([]int, len(s.segments.slabs))
+	for key, e := range s.store {
+		for _, b := range e.response {
+			if b != key[0] {
+				t.Fatalf("get(%v) = %q, corrupted", key, e.response)
+			}
+		}
+		if e.slab != 0 {
+			live[e.slab-1] += len(e.response)
+		}
+	}
+	if fmt.Sprint(live) != fmt.Sprint(s.segments.live) {
+		t.Errorf("segments live = %v, want %v", s.segments.live, live)
+	}
+	if len(s.segments.slabs) > 512/128*2+2 {
+		t.Errorf("segments = %v, want at most %v", len(s.segments.slabs), 512/128*2+2)
+	}
+}
+
+func TestAdapterWithStorage(t *testing.T) {
+	a, err := NewAdapter(AdapterWithCapacity(4), AdapterWithAlgorithm(LRU), AdapterWithStorage(Segmented))
+	if err != nil {
+		t.Fatalf("NewAdapter() error = %v", err)
+	}
+	response := []byte("value 1")
+	a.Set(context.Background(), "foo", response, time.Time{})
+	response[0] = 'x'
+	if got, ok := a.Get(context.Background(), "foo"); !ok || string(got) != "value 1" {
+		t.Errorf("memory.Get() = %q, %v, want %q", got, ok, "value 1")
+	}
+
+	if _, err := NewAdapter(AdapterWithCapacity(4), AdapterWithAlgorithm(LRU), AdapterWithStorage("arena")); err == nil {
+		t.Errorf("NewAdapter() with storage arena error = nil")
+	}
+	for _, alg := range []Algorithm{ARC, CLOCK} {
+		if _, err := NewAdapter(AdapterWithCapacity(4), AdapterWithAlgorithm(alg), AdapterWithStorage(SyncMap)); err == nil {
+			t.Errorf("NewAdapter() with storage syncmap and %v error = nil", alg)
+		}
+	}
+	if _, err := NewAdapter(AdapterWithCapacity(4), AdapterWithAlgorithm(LRU), AdapterWithStorage(SyncMap), AdapterWithTinyLFU(true)); err == nil {
+		t.Errorf("NewAdapter() with storage syncmap and TinyLFU error = nil")
+	}
+}
+
+func TestSyncMapStorage(t *testing.T) {
+	tests := []struct {
+		alg     Algorithm
+		access  map[string]int
+		evicted string
+	}{
+		{LRU, map[string]int{"a": 1}, "b"},
+		{MRU, map[string]int{"a": 1}, "a"},
+		{LFU, map[string]int{"a": 2, "b": 1}, "b"},
+		{MFU, map[string]int{"a": 2, "b": 1}, "a"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(string(tt.alg), func(t *testing.T) {
+			ctx := context.Background()
+			a, err := NewAdapter(AdapterWithCapacity(2), AdapterWithAlgorithm(tt.alg), AdapterWithStorage(SyncMap))
+			if err != nil {
+				t.Fatalf("NewAdapter() error = %v", err)
+			}
+			a.Set(ctx, "a", []byte("value a"), time.Time{})
+			a.Set(ctx, "b", []byte("value b"), time.Time{})
+			for _, key := range []string{"b", "a"} {
+				for i := 0; i < tt.access[key]; i++ {
+					time.Sleep(time.Millisecond)
+					a.Get(ctx, key)
+				}
+			}
+			a.Set(ctx, "c", []byte("value c"), time.Time{})
+
+			if _, ok := a.Get(ctx, tt.evicted); ok {
+				t.Errorf("memory.Get(%v) hit an evicted response", tt.evicted)
+			}
+			if got := a.(*Adapter).Len(); got != 2 {
+				t.Errorf("Len() = %v, want 2", got)
+			}
+		})
+	}
+}
+
+func TestSyncMapStorageExpiration(t *testing.T) {
+	ctx := context.Background()
+	refreshed := make(chan string, 1)
+	a, _ := NewAdapter(
+		AdapterWithCapacity(4),
+		AdapterWithAlgorithm(LRU),
+		AdapterWithStorage(SyncMap),
+		AdapterWithStaleHandler(time.Minute, func(key string) { refreshed <- key }),
+	)
+	m := a.(*Adapter)
+	a.Set(ctx, "foo", []byte("value 1"), time.Now().Add(-time.Second))
+	a.Set(ctx, "bar", []byte("value 2"), time.Now().Add(-2*time.Minute))
+
+	if _, ok := a.Get(ctx, "foo"); ok {
+		t.Errorf("memory.Get() hit a stale response")
+	}
+	if got, stale, ok := m.Lookup(ctx, "foo"); !ok || !stale || string(got) != "value 1" {
+		t.Errorf("memory.Lookup() = %q, %v, %v, want a stale %q", got, stale, ok, "value 1")
+	}
+	if key := <-refreshed; key != "foo" {
+		t.Errorf("refreshed %v, want foo", key)
+	}
+
+	if _, _, ok := m.Lookup(ctx, "bar"); ok {
+		t.Errorf("memory.Lookup() hit an expired response")
+	}
+	if got := m.Stats(); got.Entries != 1 || got.Expirations != 1 {
+		t.Errorf("Stats() = %+v, want 1 entry and 1 expiration", got)
+	}
+
+	a.Release(ctx, "foo")
+	if _, _, ok := m.Lookup(ctx, "foo"); ok {
+		t.Errorf("memory.Lookup() hit a released response")
+	}
+}
+
+func TestSyncMapStorageConcurrency(t *testing.T) {
+	ctx := context.Background()
+	a, _ := NewAdapter(AdapterWithCapacity(64), AdapterWithAlgorithm(LFU), AdapterWithStorage(SyncMap), AdapterWithShards(2))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 2000; j++ {
+				key := fmt.Sprint((i * j) % 100)
+				switch {
+				case j%10 == 0:
+					a.Set(ctx, key, []byte(key), time.Now().Add(time.Duration(j%3-1)*time.Millisecond))
+				case j%25 == 0:
+					a.Release(ctx, key)
+				default:
+					if got, ok := a.Get(ctx, key); ok && string(got) != key {
+						t.Errorf("memory.Get(%v) = %q", key, got)
+					}
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := a.(*Adapter).Len(); got > 64 {
+		t.Errorf("Len() = %v, want at most 64", got)
+	}
+}
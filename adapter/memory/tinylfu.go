@@ -0,0 +1,135 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package memory
+
+import "math/bits"
+
+const (
+	// sketchDepth is the number of counters estimating each key frequency.
+	sketchDepth = 4
+
+	// maxCount is the saturation value of the 4-bit sketch counters.
+	maxCount = 15
+
+	// resetMask clears the bits shifted into the neighbour counter when
+	// halving the counters of a word.
+	resetMask = 0x7777777777777777
+
+	// averageEntrySize sizes the sketch of shards bounded by max bytes only.
+	averageEntrySize = 4 << 10
+)
+
+// tinyLFU is a TinyLFU admission filter, estimating recent key frequencies
+// with a count-min sketch of 4-bit counters. A doorkeeper bloom filter
+// absorbs the first access of each key, so that one-hit wonders don't
+// pollute the sketch. Counters are halved every sample period, so that
+// estimates reflect recent traffic.
+type tinyLFU struct {
+	counters   []uint64
+	mask       uint64
+	doorkeeper []uint64
+	samples    int
+	period     int
+}
+
+// newTinyLFU initializes a filter sized for a number of entries.
+func newTinyLFU(entries int) *tinyLFU {
+	if entries < 64 {
+		entries = 64
+	}
+	width := uint64(1) << bits.Len(uint(entries-1))
+
+	return &tinyLFU{
+		// 16 counters per word, for each of the sketch rows.
+		counters:   make([]uint64, width*sketchDepth/16),
+		mask:       width - 1,
+		doorkeeper: make([]uint64, width/8),
+		period:     10 * entries,
+	}
+}
+
+// record counts an access to the key with a hash.
+func (t *tinyLFU) record(h uint64) {
+	t.samples++
+	if t.samples >= t.period {
+		t.reset()
+	}
+
+	if !t.admitted(h) {
+		t.doorkeeper[(h>>6)%uint64(len(t.doorkeeper))] |= 1 << (h & 63)
+		h2 := h >> 32
+		t.doorkeeper[(h2>>6)%uint64(len(t.doorkeeper))] |= 1 << (h2 & 63)
+		return
+	}
+
+	for i := uint64(0); i < sketchDepth; i++ {
+		word, shift := t.position(h, i)
+		if (t.counters[word]>>shift)&maxCount < maxCount {
+			t.counters[word] += 1 << shift
+		}
+	}
+}
+
+// estimate returns the estimated recent frequency of the key with a hash.
+func (t *tinyLFU) estimate(h uint64) int {
+	if !t.admitted(h) {
+		return 0
+	}
+
+	min := uint64(maxCount)
+	for i := uint64(0); i < sketchDepth; i++ {
+		word, shift := t.position(h, i)
+		if count := (t.counters[word] >> shift) & maxCount; count < min {
+			min = count
+		}
+	}
+
+	return int(min) + 1
+}
+
+// admitted reports whether the doorkeeper has seen the key with a hash.
+func (t *tinyLFU) admitted(h uint64) bool {
+	h2 := h >> 32
+	return t.doorkeeper[(h>>6)%uint64(len(t.doorkeeper))]&(1<<(h&63)) != 0 &&
+		t.doorkeeper[(h2>>6)%uint64(len(t.doorkeeper))]&(1<<(h2&63)) != 0
+}
+
+// position returns the word and bit shift of the counter of a key hash in
+// a sketch row.
+func (t *tinyLFU) position(h, row uint64) (int, uint64) {
+	index := ((h + row*(h>>32|1)*0x9e3779b97f4a7c15) & t.mask) + row*(t.mask+1)
+	return int(index / 16), (index % 16) * 4
+}
+
+// reset halves the counters and clears the doorkeeper.
+func (t *tinyLFU) reset() {
+	t.samples /= 2
+	for i := range t.counters {
+		t.counters[i] = (t.counters[i] >> 1) & resetMask
+	}
+	for i := range t.doorkeeper {
+		t.doorkeeper[i] = 0
+	}
+}
@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+func TestTinyLFU(t *testing.T) {
+	f := newTinyLFU(100)
+	hot, cold := xxhash.Sum64String("hot"), xxhash.Sum64String("cold")
+
+	if got := f.estimate(hot); got != 0 {
+		t.Errorf("tinyLFU.estimate() unseen = %v, want 0", got)
+	}
+	f.record(cold)
+	if got := f.estimate(cold); got != 1 {
+		t.Errorf("tinyLFU.estimate() after doorkeeper = %v, want 1", got)
+	}
+	for i := 0; i < 20; i++ {
+		f.record(hot)
+	}
+	if got := f.estimate(hot); got != maxCount+1 {
+		t.Errorf("tinyLFU.estimate() saturated = %v, want %v", got, maxCount+1)
+	}
+
+	f.reset()
+	if got := f.estimate(hot); got != 0 {
+		t.Errorf("tinyLFU.estimate() after reset clears doorkeeper = %v, want 0", got)
+	}
+	f.record(hot)
+	if got := f.estimate(hot); got != maxCount/2+1 {
+		t.Errorf("tinyLFU.estimate() after reset = %v, want %v", got, maxCount/2+1)
+	}
+}
+
+func TestTinyLFUAdmission(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		wantHot int
+	}{
+		{"scan evicts hot keys without admission", false, 0},
+		{"admission keeps hot keys", true, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := NewAdapter(AdapterWithCapacity(20), AdapterWithAlgorithm(LRU), AdapterWithTinyLFU(tt.enabled))
+			ctx := context.Background()
+			get := func(key string) {
+				if _, ok := a.Get(ctx, key); !ok {
+					a.Set(ctx, key, []byte(key), time.Time{})
+				}
+			}
+
+			for i := 0; i < 5; i++ {
+				for j := 0; j < 10; j++ {
+					get(fmt.Sprintf("hot-%d", j))
+				}
+			}
+			for i := 0; i < 100; i++ {
+				get(fmt.Sprintf("scan-%d", i))
+			}
+
+			hot := 0
+			for j := 0; j < 10; j++ {
+				if _, ok := a.(*Adapter).shards[0].store[fmt.Sprintf("hot-%d", j)]; ok {
+					hot++
+				}
+			}
+			if hot != tt.wantHot {
+				t.Errorf("hot keys kept = %v, want %v", hot, tt.wantHot)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+const (
+	// DefaultDatabase is the database storing responses by default.
+	DefaultDatabase = "http_cache"
+
+	// DefaultCollection is the collection storing responses by default.
+	DefaultCollection = "responses"
+)
+
+// document is a stored response, keyed by its cache key.
+type document struct {
+	Key       string     `bson:"_id"`
+	Response  []byte     `bson:"response"`
+	ExpiresAt *time.Time `bson:"expires_at,omitempty"`
+}
+
+// Adapter is the MongoDB adapter data structure, storing responses in
+// documents of a collection, deleted past their expiration date by a TTL
+// index on expires_at.
+//
+// MongoDB deletes expired documents up to a minute after their expiration
+// date, so the adapter doesn't return responses past it either.
+type Adapter struct {
+	database   string
+	collection string
+	majority   bool
+	store      *mongo.Collection
+}
+
+// AdapterOptions is used to set Adapter settings.
+type AdapterOptions func(a *Adapter) error
+
+var (
+	_ cache.Adapter             = (*Adapter)(nil)
+	_ cache.InvalidatingAdapter = (*Adapter)(nil)
+)
+
+// Get implements the cache Adapter interface Get method. Entries past their
+// expiration date are not returned.
+func (a *Adapter) Get(ctx context.Context, key string) ([]byte, bool) {
+	var doc document
+	err := a.store.FindOne(ctx, bson.M{
+		"_id": key,
+		"$or": bson.A{
+			bson.M{"expires_at": bson.M{"$exists": false}},
+			bson.M{"expires_at": bson.M{"$gt": time.Now()}},
+		},
+	}).Decode(&doc)
+	if err != nil {
+		return nil, false
+	}
+
+	return doc.Response, true
+}
+
+// Set implements the cache Adapter interface Set method, upserting the
+// response. Responses already past their expiration date release the
+// stored one instead.
+func (a *Adapter) Set(ctx context.Context, key string, response []byte, expiration time.Time) {
+	doc := document{Key: key, Response: response}
+	if !expiration.IsZero() {
+		if !expiration.After(time.Now()) {
+			a.Release(ctx, key)
+			return
+		}
+		doc.ExpiresAt = &expiration
+	}
+
+	a.store.ReplaceOne(ctx, bson.M{"_id": key}, doc, options.Replace().SetUpsert(true))
+}
+
+// Release implements the cache Adapter interface Release method.
+func (a *Adapter) Release(ctx context.Context, key string) {
+	a.store.DeleteOne(ctx, bson.M{"_id": key})
+}
+
+// Ping implements the cache HealthChecker interface Ping method, pinging the
+// primary.
+func (a *Adapter) Ping(ctx context.Context) error {
+	return a.store.Database().Client().Ping(ctx, readpref.Primary())
+}
+
+// InvalidatePrefix implements the cache InvalidatingAdapter interface
+// InvalidatePrefix method, with an anchored regular expression using the
+// _id index.
+func (a *Adapter) InvalidatePrefix(ctx context.Context, prefix string) error {
+	_, err := a.store.DeleteMany(ctx, bson.M{"_id": prefixRegex(prefix)})
+	return err
+}
+
+// InvalidatePattern implements the cache InvalidatingAdapter interface
+// InvalidatePattern method, matching the keys starting with the literal
+// prefix of the pattern.
+func (a *Adapter) InvalidatePattern(ctx context.Context, pattern string) error {
+	cursor, err := a.store.Find(ctx, bson.M{"_id": prefixRegex(cache.PatternPrefix(pattern))},
+		options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	keys := bson.A{}
+	for cursor.Next(ctx) {
+		var doc document
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		if cache.MatchPattern(pattern, doc.Key) {
+			keys = append(keys, doc.Key)
+		}
+	}
+	if err := cursor.Err(); err != nil || len(keys) == 0 {
+		return err
+	}
+
+	_, err = a.store.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": keys}})
+	return err
+}
+
+// prefixRegex returns a regular expression matching the strings starting
+// with prefix.
+func prefixRegex(prefix string) bson.M {
+	return bson.M{"$regex": "^" + regexp.QuoteMeta(prefix)}
+}
+
+// NewAdapter initializes a MongoDB adapter, creating the TTL index of its
+// collection if needed.
+func NewAdapter(ctx context.Context, client *mongo.Client, opts ...AdapterOptions) (cache.Adapter, error) {
+	if client == nil {
+		return nil, errors.New("mongo adapter client can not be nil")
+	}
+
+	a := &Adapter{
+		database:   DefaultDatabase,
+		collection: DefaultCollection,
+	}
+
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return nil, err
+		}
+	}
+
+	collectionOpts := options.Collection()
+	if a.majority {
+		collectionOpts.SetWriteConcern(writeconcern.New(writeconcern.WMajority()))
+	}
+	a.store = client.Database(a.database).Collection(a.collection, collectionOpts)
+
+	_, err := a.store.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"expires_at": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("mongo adapter ttl index: %w", err)
+	}
+
+	return a, nil
+}
+
+// AdapterWithDatabase sets the database storing responses, and defaults to
+// DefaultDatabase.
+func AdapterWithDatabase(name string) AdapterOptions {
+	return func(a *Adapter) error {
+		if name == "" {
+			return errors.New("mongo adapter database can not be empty")
+		}
+
+		a.database = name
+
+		return nil
+	}
+}
+
+// AdapterWithCollection sets the collection storing responses, and
+// defaults to DefaultCollection.
+func AdapterWithCollection(name string) AdapterOptions {
+	return func(a *Adapter) error {
+		if name == "" {
+			return errors.New("mongo adapter collection can not be empty")
+		}
+
+		a.collection = name
+
+		return nil
+	}
+}
+
+// AdapterWithMajorityWriteConcern makes writes wait for their
+// acknowledgement by a majority of the replica set, so that responses
+// written or released are not rolled back on failover, for slower writes.
+func AdapterWithMajorityWriteConcern(enabled bool) AdapterOptions {
+	return func(a *Adapter) error {
+		a.majority = enabled
+		return nil
+	}
+}
@@ -0,0 +1,137 @@
+package mongo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestNewAdapter(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("creates ttl index", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		_, err := NewAdapter(context.Background(), mt.Client,
+			AdapterWithDatabase("cache"), AdapterWithCollection("pages"), AdapterWithMajorityWriteConcern(true))
+		if err != nil {
+			mt.Fatalf("NewAdapter() error = %v", err)
+		}
+		cmd := mt.GetStartedEvent().Command
+		if got := cmd.Lookup("createIndexes").StringValue(); got != "pages" {
+			mt.Errorf("createIndexes collection = %v, want pages", got)
+		}
+		index := cmd.Lookup("indexes").Array().Index(0).Value().Document()
+		if index.Lookup("expireAfterSeconds").Int32() != 0 || index.Lookup("key", "expires_at").Int32() != 1 {
+			mt.Errorf("createIndexes index = %v, want a ttl index on expires_at", index)
+		}
+		if w := cmd.Lookup("writeConcern", "w").StringValue(); w != "majority" {
+			mt.Errorf("createIndexes write concern = %v, want majority", w)
+		}
+	})
+
+	mt.Run("returns index error", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 13, Message: "unauthorized"}))
+		if _, err := NewAdapter(context.Background(), mt.Client); err == nil {
+			mt.Errorf("NewAdapter() error = nil")
+		}
+	})
+
+	mt.Run("returns options error", func(mt *mtest.T) {
+		for _, opt := range []AdapterOptions{AdapterWithDatabase(""), AdapterWithCollection("")} {
+			if _, err := NewAdapter(context.Background(), mt.Client, opt); err == nil {
+				mt.Errorf("NewAdapter() error = nil")
+			}
+		}
+	})
+
+	if _, err := NewAdapter(context.Background(), nil); err == nil {
+		t.Errorf("NewAdapter() with nil client error = nil")
+	}
+}
+
+func TestAdapter(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	ctx := context.Background()
+
+	mt.Run("gets response", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		a, _ := NewAdapter(ctx, mt.Client)
+		mt.ClearEvents()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "http_cache.responses", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: "foo"}, {Key: "response", Value: []byte("value 1")}}))
+
+		if got, ok := a.Get(ctx, "foo"); !ok || string(got) != "value 1" {
+			mt.Errorf("mongo.Get() = %q, %v, want %q", got, ok, "value 1")
+		}
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		if _, err := filter.LookupErr("$or"); err != nil {
+			mt.Errorf("mongo.Get() filter = %v, want expired documents excluded", filter)
+		}
+	})
+
+	mt.Run("misses response", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		a, _ := NewAdapter(ctx, mt.Client)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "http_cache.responses", mtest.FirstBatch))
+
+		if _, ok := a.Get(ctx, "foo"); ok {
+			mt.Errorf("mongo.Get() hit, want miss")
+		}
+	})
+
+	mt.Run("upserts response", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		a, _ := NewAdapter(ctx, mt.Client)
+		mt.ClearEvents()
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		expiration := time.Now().Add(time.Minute).Truncate(time.Millisecond)
+		a.Set(ctx, "foo", []byte("value 1"), expiration)
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		if !update.Lookup("upsert").Boolean() {
+			mt.Errorf("mongo.Set() update = %v, want upsert", update)
+		}
+		if got := update.Lookup("u", "expires_at").Time(); !got.Equal(expiration) {
+			mt.Errorf("mongo.Set() expires_at = %v, want %v", got, expiration)
+		}
+	})
+
+	mt.Run("releases expired response", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		a, _ := NewAdapter(ctx, mt.Client)
+		mt.ClearEvents()
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		a.Set(ctx, "foo", []byte("value 1"), time.Now().Add(-time.Minute))
+		if name := mt.GetStartedEvent().CommandName; name != "delete" {
+			mt.Errorf("mongo.Set() command = %v, want delete", name)
+		}
+	})
+
+	mt.Run("invalidates pattern", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		a, _ := NewAdapter(ctx, mt.Client)
+		mt.ClearEvents()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "http_cache.responses", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: "/web/1"}}, bson.D{{Key: "_id", Value: "/web/12"}}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		if err := a.(*Adapter).InvalidatePattern(ctx, "/web/?"); err != nil {
+			mt.Fatalf("mongo.InvalidatePattern() error = %v", err)
+		}
+		if regex := mt.GetStartedEvent().Command.Lookup("filter", "_id", "$regex").StringValue(); regex != `^/web/` {
+			mt.Errorf("mongo.InvalidatePattern() regex = %v, want ^/web/", regex)
+		}
+		ids := mt.GetStartedEvent().Command.Lookup("deletes").Array().Index(0).Value().Document().Lookup("q", "_id", "$in").Array()
+		if values, _ := ids.Values(); len(values) != 1 || values[0].StringValue() != "/web/1" {
+			mt.Errorf("mongo.InvalidatePattern() deleted = %v, want /web/1", ids)
+		}
+	})
+}
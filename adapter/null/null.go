@@ -0,0 +1,84 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package null
+
+import (
+	"context"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+)
+
+// Adapter is the null adapter data structure, which stores nothing: every
+// Get misses and writes are discarded. It lets tests run the middleware
+// without a cache, and disables caching without removing the middleware.
+type Adapter struct{}
+
+var (
+	_ cache.Adapter             = (*Adapter)(nil)
+	_ cache.TaggingAdapter      = (*Adapter)(nil)
+	_ cache.InvalidatingAdapter = (*Adapter)(nil)
+)
+
+// Get implements the cache Adapter interface Get method, always missing.
+func (a *Adapter) Get(ctx context.Context, key string) ([]byte, bool) {
+	return nil, false
+}
+
+// Set implements the cache Adapter interface Set method, discarding the
+// response.
+func (a *Adapter) Set(ctx context.Context, key string, response []byte, expiration time.Time) {}
+
+// Release implements the cache Adapter interface Release method.
+func (a *Adapter) Release(ctx context.Context, key string) {}
+
+// Tag implements the cache TaggingAdapter interface Tag method, discarding
+// the tags.
+func (a *Adapter) Tag(ctx context.Context, key string, tags []string, expiration time.Time) error {
+	return nil
+}
+
+// InvalidateTag implements the cache TaggingAdapter interface InvalidateTag
+// method.
+func (a *Adapter) InvalidateTag(ctx context.Context, tag string) error {
+	return nil
+}
+
+// InvalidatePrefix implements the cache InvalidatingAdapter interface
+// InvalidatePrefix method.
+func (a *Adapter) InvalidatePrefix(ctx context.Context, prefix string) error {
+	return nil
+}
+
+// InvalidatePattern implements the cache InvalidatingAdapter interface
+// InvalidatePattern method.
+func (a *Adapter) InvalidatePattern(ctx context.Context, pattern string) error {
+	return nil
+}
+
+// NewAdapter initializes a null adapter.
+func NewAdapter() cache.Adapter {
+	return &Adapter{}
+}
@@ -0,0 +1,30 @@
+package null
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAdapter(t *testing.T) {
+	ctx := context.Background()
+	a := NewAdapter()
+
+	a.Set(ctx, "foo", []byte("value 1"), time.Now().Add(time.Minute))
+	if _, ok := a.Get(ctx, "foo"); ok {
+		t.Errorf("null.Get() hit a discarded response")
+	}
+	a.Release(ctx, "foo")
+
+	n := a.(*Adapter)
+	for name, err := range map[string]error{
+		"Tag":               n.Tag(ctx, "foo", []string{"products"}, time.Time{}),
+		"InvalidateTag":     n.InvalidateTag(ctx, "products"),
+		"InvalidatePrefix":  n.InvalidatePrefix(ctx, "/api/"),
+		"InvalidatePattern": n.InvalidatePattern(ctx, "/api/*"),
+	} {
+		if err != nil {
+			t.Errorf("null.%s() error = %v", name, err)
+		}
+	}
+}
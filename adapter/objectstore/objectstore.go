@@ -0,0 +1,282 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package objectstore
+
+import (
+	"container/list"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+)
+
+// DefaultIndexCapacity is the number of keys indexed in memory by default.
+const DefaultIndexCapacity = 10000
+
+// expirationSize is the size of the expiration date stored before each
+// response, in Unix nanoseconds, zero for none.
+const expirationSize = 8
+
+// ErrNotExist is returned by Bucket.Get for objects that don't exist.
+var ErrNotExist = errors.New("object does not exist")
+
+// Bucket is the blob store of the object store adapter, such as an S3 or
+// GCS bucket.
+type Bucket interface {
+	// Get returns the data of an object, or ErrNotExist.
+	Get(ctx context.Context, name string) ([]byte, error)
+
+	// Put writes the data of an object.
+	Put(ctx context.Context, name string, data []byte) error
+
+	// Delete deletes an object, if it exists.
+	Delete(ctx context.Context, name string) error
+
+	// List calls fn with the names of the objects starting with prefix.
+	List(ctx context.Context, prefix string, fn func(name string) error) error
+}
+
+// Adapter is the object store adapter data structure, storing each response
+// in an object of a bucket, for very large responses such as generated
+// exports.
+//
+// Objects are named after the path escaped keys, under a prefix, and store
+// the expiration date of their response. A lifecycle rule of the bucket
+// should delete them after the longest TTL, as the adapter only deletes
+// expired objects when requested.
+//
+// The expiration dates of the most recently used keys are indexed in
+// memory, so that Get misses responses known to be expired without
+// downloading them.
+type Adapter struct {
+	bucket        Bucket
+	prefix        string
+	indexCapacity int
+
+	mutex sync.Mutex
+	index map[string]*list.Element
+	// recency lists the indexed keys from the most to the least recently
+	// used.
+	recency *list.List
+}
+
+// indexed is the expiration date of a key, in Unix nanoseconds.
+type indexed struct {
+	key        string
+	expiration int64
+}
+
+// AdapterOptions is used to set Adapter settings.
+type AdapterOptions func(a *Adapter) error
+
+var (
+	_ cache.Adapter             = (*Adapter)(nil)
+	_ cache.InvalidatingAdapter = (*Adapter)(nil)
+)
+
+// Get implements the cache Adapter interface Get method. Entries past their
+// expiration date are released instead of returned.
+func (a *Adapter) Get(ctx context.Context, key string) ([]byte, bool) {
+	now := time.Now().UnixNano()
+	if expiration, ok := a.expiration(key); ok && expiration > 0 && expiration <= now {
+		// Another instance may have stored a new response since, which
+		// the miss is about to replace anyway.
+		return nil, false
+	}
+
+	data, err := a.bucket.Get(ctx, a.name(key))
+	if err != nil || len(data) < expirationSize {
+		if errors.Is(err, ErrNotExist) {
+			a.unindex(key)
+		}
+		return nil, false
+	}
+	expiration := int64(binary.BigEndian.Uint64(data))
+	if expiration > 0 && expiration <= now {
+		a.Release(ctx, key)
+		return nil, false
+	}
+	a.indexKey(key, expiration)
+
+	return data[expirationSize:], true
+}
+
+// Set implements the cache Adapter interface Set method. Responses already
+// past their expiration date release the stored one instead.
+func (a *Adapter) Set(ctx context.Context, key string, response []byte, expiration time.Time) {
+	var nanos int64
+	if !expiration.IsZero() {
+		if nanos = expiration.UnixNano(); nanos <= time.Now().UnixNano() {
+			a.Release(ctx, key)
+			return
+		}
+	}
+
+	data := make([]byte, expirationSize+len(response))
+	binary.BigEndian.PutUint64(data, uint64(nanos))
+	copy(data[expirationSize:], response)
+	if err := a.bucket.Put(ctx, a.name(key), data); err != nil {
+		// The previous object may still be stored.
+		a.unindex(key)
+		return
+	}
+	a.indexKey(key, nanos)
+}
+
+// Release implements the cache Adapter interface Release method.
+func (a *Adapter) Release(ctx context.Context, key string) {
+	a.unindex(key)
+	a.bucket.Delete(ctx, a.name(key))
+}
+
+// InvalidatePrefix implements the cache InvalidatingAdapter interface
+// InvalidatePrefix method, listing objects.
+func (a *Adapter) InvalidatePrefix(ctx context.Context, prefix string) error {
+	return a.InvalidatePattern(ctx, cache.EscapePattern(prefix)+"*")
+}
+
+// InvalidatePattern implements the cache InvalidatingAdapter interface
+// InvalidatePattern method, listing the objects of the keys starting with
+// the literal prefix of the pattern.
+func (a *Adapter) InvalidatePattern(ctx context.Context, pattern string) error {
+	var keys []string
+	err := a.bucket.List(ctx, a.name(cache.PatternPrefix(pattern)), func(name string) error {
+		key, err := url.PathUnescape(name[len(a.prefix):])
+		if err == nil && cache.MatchPattern(pattern, key) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		a.unindex(key)
+		if err := a.bucket.Delete(ctx, a.name(key)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// name returns the object name of a key. Keys are escaped character by
+// character, so the names of the keys starting with a prefix start with
+// the name of the prefix.
+func (a *Adapter) name(key string) string {
+	return a.prefix + url.PathEscape(key)
+}
+
+// expiration returns the indexed expiration date of a key.
+func (a *Adapter) expiration(key string) (int64, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	e, ok := a.index[key]
+	if !ok {
+		return 0, false
+	}
+	a.recency.MoveToFront(e)
+
+	return e.Value.(*indexed).expiration, true
+}
+
+// indexKey indexes the expiration date of a key, dropping the least
+// recently used key beyond the index capacity.
+func (a *Adapter) indexKey(key string, expiration int64) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if e, ok := a.index[key]; ok {
+		e.Value.(*indexed).expiration = expiration
+		a.recency.MoveToFront(e)
+		return
+	}
+	a.index[key] = a.recency.PushFront(&indexed{key: key, expiration: expiration})
+	if a.recency.Len() > a.indexCapacity {
+		delete(a.index, a.recency.Remove(a.recency.Back()).(*indexed).key)
+	}
+}
+
+func (a *Adapter) unindex(key string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if e, ok := a.index[key]; ok {
+		a.recency.Remove(e)
+		delete(a.index, key)
+	}
+}
+
+// NewAdapter initializes an object store adapter.
+func NewAdapter(bucket Bucket, opts ...AdapterOptions) (cache.Adapter, error) {
+	if bucket == nil {
+		return nil, errors.New("object store adapter bucket can not be nil")
+	}
+
+	a := &Adapter{
+		bucket:        bucket,
+		indexCapacity: DefaultIndexCapacity,
+		index:         map[string]*list.Element{},
+		recency:       list.New(),
+	}
+
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+// AdapterWithPrefix sets the prefix of the object names, such as
+// "http-cache/", to share a bucket or to scope its lifecycle rule.
+func AdapterWithPrefix(prefix string) AdapterOptions {
+	return func(a *Adapter) error {
+		a.prefix = prefix
+		return nil
+	}
+}
+
+// AdapterWithIndexCapacity sets the number of keys whose expiration date
+// is indexed in memory, and defaults to DefaultIndexCapacity.
+func AdapterWithIndexCapacity(n int) AdapterOptions {
+	return func(a *Adapter) error {
+		if n <= 0 {
+			return fmt.Errorf("object store adapter index capacity %v is invalid", n)
+		}
+
+		a.indexCapacity = n
+
+		return nil
+	}
+}
@@ -0,0 +1,150 @@
+package objectstore
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type bucketMock struct {
+	sync.Mutex
+	objects map[string][]byte
+	gets    int
+}
+
+func newBucketMock() *bucketMock {
+	return &bucketMock{objects: map[string][]byte{}}
+}
+
+func (b *bucketMock) Get(ctx context.Context, name string) ([]byte, error) {
+	b.Lock()
+	defer b.Unlock()
+	b.gets++
+	data, ok := b.objects[name]
+	if !ok {
+		return nil, ErrNotExist
+	}
+	return data, nil
+}
+
+func (b *bucketMock) Put(ctx context.Context, name string, data []byte) error {
+	b.Lock()
+	defer b.Unlock()
+	b.objects[name] = data
+	return nil
+}
+
+func (b *bucketMock) Delete(ctx context.Context, name string) error {
+	b.Lock()
+	defer b.Unlock()
+	delete(b.objects, name)
+	return nil
+}
+
+func (b *bucketMock) List(ctx context.Context, prefix string, fn func(name string) error) error {
+	b.Lock()
+	var names []string
+	for name := range b.objects {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	b.Unlock()
+	sort.Strings(names)
+	for _, name := range names {
+		if err := fn(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestNewAdapter(t *testing.T) {
+	tests := []struct {
+		name    string
+		bucket  Bucket
+		opts    []AdapterOptions
+		wantErr bool
+	}{
+		{"returns new Adapter", newBucketMock(), []AdapterOptions{AdapterWithPrefix("cache/"), AdapterWithIndexCapacity(10)}, false},
+		{"returns bucket error", nil, nil, true},
+		{"returns index capacity error", newBucketMock(), []AdapterOptions{AdapterWithIndexCapacity(0)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewAdapter(tt.bucket, tt.opts...); (err != nil) != tt.wantErr {
+				t.Errorf("NewAdapter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAdapter(t *testing.T) {
+	bucket := newBucketMock()
+	a, _ := NewAdapter(bucket, AdapterWithPrefix("cache/"), AdapterWithIndexCapacity(2))
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		key        string
+		response   string
+		expiration time.Time
+		wantOk     bool
+	}{
+		{"stores response", "http://foo.bar/export?id=1", "value 1", time.Now().Add(time.Minute), true},
+		{"stores response without expiration", "http://foo.bar/export?id=2", "value 2", time.Time{}, true},
+		{"releases expired response", "http://foo.bar/export?id=1", "value 3", time.Now().Add(-time.Minute), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a.Set(ctx, tt.key, []byte(tt.response), tt.expiration)
+			got, ok := a.Get(ctx, tt.key)
+			if ok != tt.wantOk || (ok && string(got) != tt.response) {
+				t.Errorf("objectstore.Get() = %q, %v, want %q, %v", got, ok, tt.response, tt.wantOk)
+			}
+		})
+	}
+	if _, ok := bucket.objects["cache/http:%2F%2Ffoo.bar%2Fexport%3Fid=2"]; !ok {
+		t.Errorf("objectstore objects = %v, want the escaped key under the prefix", bucket.objects)
+	}
+
+	a.Set(ctx, "expiring", []byte("value 4"), time.Now().Add(10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	gets := bucket.gets
+	if _, ok := a.Get(ctx, "expiring"); ok || bucket.gets != gets {
+		t.Errorf("objectstore.Get() = %v with %v downloads, want an indexed miss", ok, bucket.gets-gets)
+	}
+
+	a.Release(ctx, "http://foo.bar/export?id=2")
+	if _, ok := a.Get(ctx, "http://foo.bar/export?id=2"); ok {
+		t.Errorf("objectstore.Get() hit after Release")
+	}
+	if n := a.(*Adapter).recency.Len(); n > 2 {
+		t.Errorf("objectstore indexed keys = %v, want at most 2", n)
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	bucket := newBucketMock()
+	a, _ := NewAdapter(bucket)
+	ctx := context.Background()
+	for _, key := range []string{"/api/1", "/api/2?x=1", "/web/1", "/web/12"} {
+		a.Set(ctx, key, []byte(key), time.Time{})
+	}
+
+	if err := a.(*Adapter).InvalidatePrefix(ctx, "/api/"); err != nil {
+		t.Fatalf("objectstore.InvalidatePrefix() error = %v", err)
+	}
+	if err := a.(*Adapter).InvalidatePattern(ctx, "/web/?"); err != nil {
+		t.Fatalf("objectstore.InvalidatePattern() error = %v", err)
+	}
+	if len(bucket.objects) != 1 {
+		t.Errorf("objectstore objects = %v, want /web/12 only", bucket.objects)
+	}
+	if _, ok := a.Get(ctx, "/web/12"); !ok {
+		t.Errorf("objectstore.Get(/web/12) miss after invalidation")
+	}
+}
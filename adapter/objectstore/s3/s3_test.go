@@ -0,0 +1,86 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/cludden/http-cache/adapter/objectstore"
+)
+
+// clientMock is an in-memory bucket listed by pages of one object.
+type clientMock struct {
+	objects map[string][]byte
+}
+
+func (c *clientMock) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	data, ok := c.objects[*params.Key]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func (c *clientMock) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := ioutil.ReadAll(params.Body)
+	c.objects[*params.Key] = data
+	return &s3.PutObjectOutput{}, err
+}
+
+func (c *clientMock) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	delete(c.objects, *params.Key)
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func (c *clientMock) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	var keys []string
+	for key := range c.objects {
+		if strings.HasPrefix(key, *params.Prefix) && (params.ContinuationToken == nil || key > *params.ContinuationToken) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	out := &s3.ListObjectsV2Output{}
+	if len(keys) > 0 {
+		out.Contents = []types.Object{{Key: &keys[0]}}
+		out.IsTruncated = len(keys) > 1
+		out.NextContinuationToken = &keys[0]
+	}
+	return out, nil
+}
+
+func TestBucket(t *testing.T) {
+	b := NewBucket(&clientMock{objects: map[string][]byte{}}, "cache")
+	ctx := context.Background()
+
+	for _, name := range []string{"a/1", "a/2", "a/3", "b/1"} {
+		if err := b.Put(ctx, name, []byte(name)); err != nil {
+			t.Fatalf("s3.Put() error = %v", err)
+		}
+	}
+	if got, err := b.Get(ctx, "a/1"); err != nil || string(got) != "a/1" {
+		t.Errorf("s3.Get() = %q, %v, want %q", got, err, "a/1")
+	}
+	if err := b.Delete(ctx, "a/1"); err != nil {
+		t.Errorf("s3.Delete() error = %v", err)
+	}
+	if _, err := b.Get(ctx, "a/1"); !errors.Is(err, objectstore.ErrNotExist) {
+		t.Errorf("s3.Get() error = %v, want %v", err, objectstore.ErrNotExist)
+	}
+
+	var names []string
+	err := b.List(ctx, "a/", func(name string) error {
+		names = append(names, name)
+		return nil
+	})
+	if want := []string{"a/2", "a/3"}; err != nil || !reflect.DeepEqual(names, want) {
+		t.Errorf("s3.List() = %v, %v, want %v", names, err, want)
+	}
+}
@@ -0,0 +1,275 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	cache "github.com/cludden/http-cache"
+)
+
+// DefaultTable is the table storing responses by default.
+const DefaultTable = "http_cache"
+
+// tableName matches the table names accepted by AdapterWithTable, optionally
+// qualified by a schema.
+var tableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// Adapter is the PostgreSQL adapter data structure, storing responses in an
+// UNLOGGED table, which skips the write-ahead log for faster writes at the
+// cost of being emptied after a crash and not replicated.
+//
+// It works with any database/sql PostgreSQL driver, such as pgx or pq.
+type Adapter struct {
+	db              *sql.DB
+	table           string
+	lockID          int64
+	cleanupInterval time.Duration
+
+	get, set, release, collect string
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// AdapterOptions is used to set Adapter settings.
+type AdapterOptions func(a *Adapter) error
+
+var (
+	_ cache.Adapter             = (*Adapter)(nil)
+	_ cache.InvalidatingAdapter = (*Adapter)(nil)
+)
+
+// Get implements the cache Adapter interface Get method. Entries past their
+// expiration date are not returned.
+func (a *Adapter) Get(ctx context.Context, key string) ([]byte, bool) {
+	var response []byte
+	if err := a.db.QueryRowContext(ctx, a.get, key).Scan(&response); err != nil {
+		return nil, false
+	}
+
+	return response, true
+}
+
+// Set implements the cache Adapter interface Set method, upserting the
+// response.
+func (a *Adapter) Set(ctx context.Context, key string, response []byte, expiration time.Time) {
+	a.db.ExecContext(ctx, a.set, key, response, sql.NullTime{Time: expiration, Valid: !expiration.IsZero()})
+}
+
+// Release implements the cache Adapter interface Release method.
+func (a *Adapter) Release(ctx context.Context, key string) {
+	a.db.ExecContext(ctx, a.release, key)
+}
+
+// InvalidatePrefix implements the cache InvalidatingAdapter interface
+// InvalidatePrefix method.
+func (a *Adapter) InvalidatePrefix(ctx context.Context, prefix string) error {
+	_, err := a.db.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE key LIKE $1 ESCAPE '\'`, a.table), likePrefix(prefix))
+	return err
+}
+
+// InvalidatePattern implements the cache InvalidatingAdapter interface
+// InvalidatePattern method, matching the keys starting with the literal
+// prefix of the pattern.
+func (a *Adapter) InvalidatePattern(ctx context.Context, pattern string) error {
+	rows, err := a.db.QueryContext(ctx, fmt.Sprintf(`SELECT key FROM %s WHERE key LIKE $1 ESCAPE '\'`, a.table), likePrefix(cache.PatternPrefix(pattern)))
+	if err != nil {
+		return err
+	}
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return err
+		}
+		if cache.MatchPattern(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if _, err := a.db.ExecContext(ctx, a.release, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Collect deletes the responses past their expiration date, unless another
+// replica holds the advisory lock of the table, reporting whether it did.
+func (a *Adapter) Collect(ctx context.Context) (bool, error) {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// The transaction level lock is released on commit or rollback.
+	var locked bool
+	if err := tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1)`, a.lockID).Scan(&locked); err != nil || !locked {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, a.collect); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// Ping implements the cache HealthChecker interface Ping method.
+func (a *Adapter) Ping(ctx context.Context) error {
+	return a.db.PingContext(ctx)
+}
+
+// Close stops the cleanup goroutine started with AdapterWithCleanupInterval,
+// if any. It doesn't close the database. The adapter returned by NewAdapter
+// implements io.Closer.
+func (a *Adapter) Close() error {
+	a.closeOnce.Do(func() {
+		if a.done != nil {
+			close(a.done)
+		}
+	})
+
+	return nil
+}
+
+func (a *Adapter) cleanup() {
+	ticker := time.NewTicker(a.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.Collect(context.Background())
+		case <-a.done:
+			return
+		}
+	}
+}
+
+// NewAdapter initializes a PostgreSQL adapter, creating its table and the
+// index of its expiration dates in db if needed.
+func NewAdapter(db *sql.DB, opts ...AdapterOptions) (cache.Adapter, error) {
+	if db == nil {
+		return nil, errors.New("postgres adapter database can not be nil")
+	}
+
+	a := &Adapter{
+		db:    db,
+		table: DefaultTable,
+	}
+
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return nil, err
+		}
+	}
+	if a.lockID == 0 {
+		a.lockID = int64(xxhash.Sum64String(a.table))
+	}
+
+	a.get = fmt.Sprintf(`SELECT response FROM %s WHERE key = $1 AND (expiration IS NULL OR expiration > now())`, a.table)
+	a.set = fmt.Sprintf(`INSERT INTO %s (key, response, expiration) VALUES ($1, $2, $3) `+
+		`ON CONFLICT (key) DO UPDATE SET response = EXCLUDED.response, expiration = EXCLUDED.expiration`, a.table)
+	a.release = fmt.Sprintf(`DELETE FROM %s WHERE key = $1`, a.table)
+	a.collect = fmt.Sprintf(`DELETE FROM %s WHERE expiration <= now()`, a.table)
+
+	index := a.table[strings.LastIndexByte(a.table, '.')+1:] + "_expiration"
+	for _, query := range []string{
+		fmt.Sprintf(`CREATE UNLOGGED TABLE IF NOT EXISTS %s (key TEXT PRIMARY KEY, response BYTEA NOT NULL, expiration TIMESTAMPTZ)`, a.table),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (expiration)`, index, a.table),
+	} {
+		if _, err := db.Exec(query); err != nil {
+			return nil, fmt.Errorf("postgres adapter table: %w", err)
+		}
+	}
+
+	if a.cleanupInterval > 0 {
+		a.done = make(chan struct{})
+		go a.cleanup()
+	}
+
+	return a, nil
+}
+
+// AdapterWithTable sets the table storing responses, optionally qualified
+// by a schema, and defaults to DefaultTable.
+func AdapterWithTable(name string) AdapterOptions {
+	return func(a *Adapter) error {
+		if !tableName.MatchString(name) {
+			return fmt.Errorf("postgres adapter table %v is invalid", name)
+		}
+
+		a.table = name
+
+		return nil
+	}
+}
+
+// AdapterWithLockID sets the key of the advisory lock taken by Collect,
+// and defaults to a hash of the table name.
+func AdapterWithLockID(id int64) AdapterOptions {
+	return func(a *Adapter) error {
+		a.lockID = id
+		return nil
+	}
+}
+
+// AdapterWithCleanupInterval starts a goroutine calling Collect every
+// interval. Replicas sharing the table skip the cleanup while another one
+// runs it. Close stops it.
+func AdapterWithCleanupInterval(interval time.Duration) AdapterOptions {
+	return func(a *Adapter) error {
+		if interval <= 0 {
+			return fmt.Errorf("postgres adapter cleanup interval %v is invalid", interval)
+		}
+
+		a.cleanupInterval = interval
+
+		return nil
+	}
+}
+
+// likePrefix returns a LIKE pattern matching the strings starting with
+// prefix.
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+}
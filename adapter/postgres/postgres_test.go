@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func newMock(t *testing.T, table string) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	mock.ExpectExec(`CREATE UNLOGGED TABLE IF NOT EXISTS ` + table).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE INDEX IF NOT EXISTS \w+ ON ` + table).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	return db, mock
+}
+
+func TestNewAdapter(t *testing.T) {
+	tests := []struct {
+		name    string
+		table   string
+		opts    []AdapterOptions
+		wantErr bool
+	}{
+		{"returns new Adapter", "http_cache", []AdapterOptions{AdapterWithLockID(42), AdapterWithCleanupInterval(time.Minute)}, false},
+		{"sets table", `cache\.responses`, []AdapterOptions{AdapterWithTable("cache.responses")}, false},
+		{"returns table error", "", []AdapterOptions{AdapterWithTable("responses; DROP TABLE users")}, true},
+		{"returns cleanup interval error", "", []AdapterOptions{AdapterWithCleanupInterval(0)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMock(t, tt.table)
+			a, err := NewAdapter(db, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewAdapter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			a.(*Adapter).Close()
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("NewAdapter() queries: %v", err)
+			}
+		})
+	}
+
+	if _, err := NewAdapter(nil); err == nil {
+		t.Errorf("NewAdapter() with nil database error = nil")
+	}
+}
+
+func TestAdapter(t *testing.T) {
+	db, mock := newMock(t, "http_cache")
+	a, _ := NewAdapter(db)
+	ctx := context.Background()
+	expiration := time.Now().Add(time.Minute)
+
+	mock.ExpectExec(`INSERT INTO http_cache .* ON CONFLICT \(key\) DO UPDATE`).
+		WithArgs("foo", []byte("value 1"), sql.NullTime{Time: expiration, Valid: true}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT response FROM http_cache WHERE key = \$1 AND \(expiration IS NULL OR expiration > now\(\)\)`).
+		WithArgs("foo").
+		WillReturnRows(sqlmock.NewRows([]string{"response"}).AddRow([]byte("value 1")))
+	mock.ExpectExec(`DELETE FROM http_cache WHERE key = \$1`).WithArgs("foo").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT response FROM http_cache`).WithArgs("foo").WillReturnError(sql.ErrNoRows)
+
+	a.Set(ctx, "foo", []byte("value 1"), expiration)
+	if got, ok := a.Get(ctx, "foo"); !ok || string(got) != "value 1" {
+		t.Errorf("postgres.Get() = %q, %v, want %q", got, ok, "value 1")
+	}
+	a.Release(ctx, "foo")
+	if _, ok := a.Get(ctx, "foo"); ok {
+		t.Errorf("postgres.Get() hit after Release")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("postgres adapter queries: %v", err)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	tests := []struct {
+		name    string
+		locked  bool
+		lockErr error
+		want    bool
+		wantErr bool
+	}{
+		{"deletes expired responses", true, nil, true, false},
+		{"skips when locked by another replica", false, nil, false, false},
+		{"returns lock error", false, errors.New("connection lost"), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMock(t, "http_cache")
+			a, _ := NewAdapter(db, AdapterWithLockID(42))
+
+			mock.ExpectBegin()
+			lock := mock.ExpectQuery(`SELECT pg_try_advisory_xact_lock\(\$1\)`).WithArgs(int64(42))
+			if tt.lockErr != nil {
+				lock.WillReturnError(tt.lockErr)
+			} else {
+				lock.WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(tt.locked))
+			}
+			if tt.locked {
+				mock.ExpectExec(`DELETE FROM http_cache WHERE expiration <= now\(\)`).WillReturnResult(sqlmock.NewResult(0, 3))
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			got, err := a.(*Adapter).Collect(context.Background())
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("postgres.Collect() = %v, %v, want %v, wantErr %v", got, err, tt.want, tt.wantErr)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("postgres.Collect() queries: %v", err)
+			}
+		})
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	db, mock := newMock(t, "http_cache")
+	a, _ := NewAdapter(db)
+	ctx := context.Background()
+
+	mock.ExpectExec(`DELETE FROM http_cache WHERE key LIKE \$1`).WithArgs(`/api/100\%\_%`).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(`SELECT key FROM http_cache WHERE key LIKE \$1`).WithArgs(`/web/%`).
+		WillReturnRows(sqlmock.NewRows([]string{"key"}).AddRow("/web/1").AddRow("/web/12"))
+	mock.ExpectExec(`DELETE FROM http_cache WHERE key = \$1`).WithArgs("/web/1").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := a.(*Adapter).InvalidatePrefix(ctx, "/api/100%_"); err != nil {
+		t.Errorf("postgres.InvalidatePrefix() error = %v", err)
+	}
+	if err := a.(*Adapter).InvalidatePattern(ctx, "/web/?"); err != nil {
+		t.Errorf("postgres.InvalidatePattern() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("postgres adapter queries: %v", err)
+	}
+}
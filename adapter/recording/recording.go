@@ -0,0 +1,255 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package recording
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+)
+
+// Op is the string type for the recorded adapter operations.
+type Op string
+
+const (
+	// Get is the constant for Get operations.
+	Get Op = "get"
+
+	// Set is the constant for Set operations.
+	Set Op = "set"
+
+	// Release is the constant for Release operations.
+	Release Op = "release"
+
+	// Tag is the constant for Tag operations.
+	Tag Op = "tag"
+
+	// InvalidateTag is the constant for InvalidateTag operations.
+	InvalidateTag Op = "invalidate_tag"
+
+	// InvalidatePrefix is the constant for InvalidatePrefix operations.
+	InvalidatePrefix Op = "invalidate_prefix"
+
+	// InvalidatePattern is the constant for InvalidatePattern operations.
+	InvalidatePattern Op = "invalidate_pattern"
+)
+
+// Operation is a recorded adapter operation.
+type Operation struct {
+	Op Op
+
+	// Key is the key of the operation, or the tag, prefix or pattern of
+	// invalidations.
+	Key string
+
+	// Hit reports whether a Get returned a response, and Size is the size
+	// of the response returned by Get or given to Set.
+	Hit  bool
+	Size int
+
+	// Expiration is the expiration date given to Set and Tag, and Tags the
+	// tags given to Tag.
+	Expiration time.Time
+	Tags       []string
+
+	// Err is the error returned by the wrapped adapter, if any.
+	Err error
+
+	// Start is when the operation started, and Duration how long it took.
+	Start    time.Time
+	Duration time.Duration
+}
+
+// Adapter is the recording adapter data structure, wrapping another adapter
+// and recording every operation, so that tests can assert on the cache
+// interactions of the middleware. Wrapping a null adapter records the
+// operations of a cache that never hits.
+//
+// It implements tagging and invalidation whether the wrapped adapter does
+// or not, returning cache.ErrTaggingUnsupported and
+// cache.ErrInvalidationUnsupported in the latter case.
+type Adapter struct {
+	adapter    cache.AdapterV2
+	mutex      sync.Mutex
+	operations []Operation
+}
+
+var (
+	_ cache.AdapterV2           = (*Adapter)(nil)
+	_ cache.TaggingAdapter      = (*Adapter)(nil)
+	_ cache.InvalidatingAdapter = (*Adapter)(nil)
+)
+
+// Get implements the cache AdapterV2 interface Get method.
+func (a *Adapter) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	op := newOperation(Get, key)
+	response, ok, err := a.adapter.Get(ctx, key)
+	op.Hit, op.Size = ok, len(response)
+	a.record(op, err)
+
+	return response, ok, err
+}
+
+// Set implements the cache AdapterV2 interface Set method.
+func (a *Adapter) Set(ctx context.Context, key string, response []byte, expiration time.Time) error {
+	op := newOperation(Set, key)
+	op.Size, op.Expiration = len(response), expiration
+	err := a.adapter.Set(ctx, key, response, expiration)
+	a.record(op, err)
+
+	return err
+}
+
+// Release implements the cache AdapterV2 interface Release method.
+func (a *Adapter) Release(ctx context.Context, key string) error {
+	op := newOperation(Release, key)
+	err := a.adapter.Release(ctx, key)
+	a.record(op, err)
+
+	return err
+}
+
+// Tag implements the cache TaggingAdapter interface Tag method.
+func (a *Adapter) Tag(ctx context.Context, key string, tags []string, expiration time.Time) error {
+	op := newOperation(Tag, key)
+	op.Tags, op.Expiration = append([]string(nil), tags...), expiration
+	err := cache.ErrTaggingUnsupported
+	if tagger, ok := a.wrapped().(cache.TaggingAdapter); ok {
+		err = tagger.Tag(ctx, key, tags, expiration)
+	}
+	a.record(op, err)
+
+	return err
+}
+
+// InvalidateTag implements the cache TaggingAdapter interface InvalidateTag
+// method.
+func (a *Adapter) InvalidateTag(ctx context.Context, tag string) error {
+	op := newOperation(InvalidateTag, tag)
+	err := cache.ErrTaggingUnsupported
+	if tagger, ok := a.wrapped().(cache.TaggingAdapter); ok {
+		err = tagger.InvalidateTag(ctx, tag)
+	}
+	a.record(op, err)
+
+	return err
+}
+
+// InvalidatePrefix implements the cache InvalidatingAdapter interface
+// InvalidatePrefix method.
+func (a *Adapter) InvalidatePrefix(ctx context.Context, prefix string) error {
+	op := newOperation(InvalidatePrefix, prefix)
+	err := cache.ErrInvalidationUnsupported
+	if invalidator, ok := a.wrapped().(cache.InvalidatingAdapter); ok {
+		err = invalidator.InvalidatePrefix(ctx, prefix)
+	}
+	a.record(op, err)
+
+	return err
+}
+
+// InvalidatePattern implements the cache InvalidatingAdapter interface
+// InvalidatePattern method.
+func (a *Adapter) InvalidatePattern(ctx context.Context, pattern string) error {
+	op := newOperation(InvalidatePattern, pattern)
+	err := cache.ErrInvalidationUnsupported
+	if invalidator, ok := a.wrapped().(cache.InvalidatingAdapter); ok {
+		err = invalidator.InvalidatePattern(ctx, pattern)
+	}
+	a.record(op, err)
+
+	return err
+}
+
+// Operations returns the recorded operations, in the order they completed.
+func (a *Adapter) Operations() []Operation {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return append([]Operation(nil), a.operations...)
+}
+
+// Count returns the number of recorded operations of a kind, for a key
+// unless empty.
+func (a *Adapter) Count(op Op, key string) int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	n := 0
+	for _, o := range a.operations {
+		if o.Op == op && (key == "" || o.Key == key) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Reset forgets the recorded operations.
+func (a *Adapter) Reset() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.operations = nil
+}
+
+func newOperation(op Op, key string) Operation {
+	return Operation{Op: op, Key: key, Start: time.Now()}
+}
+
+func (a *Adapter) record(op Operation, err error) {
+	op.Err = err
+	op.Duration = time.Since(op.Start)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.operations = append(a.operations, op)
+}
+
+// wrapped returns the wrapped adapter, unwrapping the shim of a
+// cache.Adapter.
+func (a *Adapter) wrapped() interface{} {
+	return cache.UnwrapAdapterV2(a.adapter)
+}
+
+// NewAdapter initializes a recording adapter wrapping an adapter
+// implementing either cache.Adapter or cache.AdapterV2.
+func NewAdapter(adapter interface{}) (*Adapter, error) {
+	a := &Adapter{}
+	switch adapter := adapter.(type) {
+	case cache.AdapterV2:
+		a.adapter = adapter
+	case cache.Adapter:
+		a.adapter = cache.AdapterV2Of(adapter)
+	default:
+		return nil, fmt.Errorf("recording adapter adapter type %T is not supported", adapter)
+	}
+
+	return a, nil
+}
@@ -0,0 +1,151 @@
+package recording
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	"github.com/cludden/http-cache/adapter/memory"
+	"github.com/cludden/http-cache/adapter/null"
+)
+
+type failingAdapter struct{}
+
+var errFailing = errors.New("adapter unavailable")
+
+func (failingAdapter) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return nil, false, errFailing
+}
+
+func (failingAdapter) Set(ctx context.Context, key string, response []byte, expiration time.Time) error {
+	return errFailing
+}
+
+func (failingAdapter) Release(ctx context.Context, key string) error {
+	return errFailing
+}
+
+func TestNewAdapter(t *testing.T) {
+	tests := []struct {
+		name    string
+		adapter interface{}
+		wantErr bool
+	}{
+		{"wraps Adapter", null.NewAdapter(), false},
+		{"wraps AdapterV2", failingAdapter{}, false},
+		{"returns type error", "memory", true},
+		{"returns nil error", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewAdapter(tt.adapter); (err != nil) != tt.wantErr {
+				t.Errorf("NewAdapter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	m, _ := memory.NewAdapter(memory.AdapterWithAlgorithm(memory.LRU), memory.AdapterWithCapacity(10))
+	a, _ := NewAdapter(m)
+	client, _ := cache.NewClient(cache.WithAdapterV2(a), cache.WithTTL(time.Minute))
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("value 1"))
+	}))
+
+	for i := 0; i < 2; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/products", nil))
+	}
+
+	ops := a.Operations()
+	if len(ops) != 3 {
+		t.Fatalf("operations = %+v, want get, set and get", ops)
+	}
+	if ops[0].Op != Get || ops[0].Hit {
+		t.Errorf("operation 0 = %+v, want a get miss", ops[0])
+	}
+	if ops[1].Op != Set || ops[1].Key != ops[0].Key || ops[1].Size == 0 || ops[1].Expiration.IsZero() {
+		t.Errorf("operation 1 = %+v, want a set of %v", ops[1], ops[0].Key)
+	}
+	if ops[2].Op != Get || !ops[2].Hit || ops[2].Size != ops[1].Size {
+		t.Errorf("operation 2 = %+v, want a get hit of %v bytes", ops[2], ops[1].Size)
+	}
+	if got := a.Count(Get, ops[0].Key); got != 2 {
+		t.Errorf("Count() = %v, want 2", got)
+	}
+
+	a.Reset()
+	if got := a.Operations(); len(got) != 0 {
+		t.Errorf("operations after Reset = %+v, want none", got)
+	}
+}
+
+func TestAdapter(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		adapter interface{}
+		invoke  func(a *Adapter) error
+		want    Operation
+	}{
+		{
+			"records get error",
+			failingAdapter{},
+			func(a *Adapter) error { _, _, err := a.Get(ctx, "foo"); return err },
+			Operation{Op: Get, Key: "foo", Err: errFailing},
+		},
+		{
+			"records release",
+			null.NewAdapter(),
+			func(a *Adapter) error { return a.Release(ctx, "foo") },
+			Operation{Op: Release, Key: "foo"},
+		},
+		{
+			"records tag",
+			null.NewAdapter(),
+			func(a *Adapter) error { return a.Tag(ctx, "foo", []string{"products"}, time.Time{}) },
+			Operation{Op: Tag, Key: "foo", Tags: []string{"products"}},
+		},
+		{
+			"records unsupported tag invalidation",
+			failingAdapter{},
+			func(a *Adapter) error { return a.InvalidateTag(ctx, "products") },
+			Operation{Op: InvalidateTag, Key: "products", Err: cache.ErrTaggingUnsupported},
+		},
+		{
+			"records prefix invalidation",
+			null.NewAdapter(),
+			func(a *Adapter) error { return a.InvalidatePrefix(ctx, "/api/") },
+			Operation{Op: InvalidatePrefix, Key: "/api/"},
+		},
+		{
+			"records unsupported pattern invalidation",
+			failingAdapter{},
+			func(a *Adapter) error { return a.InvalidatePattern(ctx, "/api/*") },
+			Operation{Op: InvalidatePattern, Key: "/api/*", Err: cache.ErrInvalidationUnsupported},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := NewAdapter(tt.adapter)
+			if err := tt.invoke(a); err != tt.want.Err {
+				t.Errorf("error = %v, want %v", err, tt.want.Err)
+			}
+
+			ops := a.Operations()
+			if len(ops) != 1 {
+				t.Fatalf("operations = %+v, want 1", ops)
+			}
+			got := ops[0]
+			if got.Op != tt.want.Op || got.Key != tt.want.Key || got.Err != tt.want.Err ||
+				len(got.Tags) != len(tt.want.Tags) || got.Start.IsZero() {
+				t.Errorf("operation = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package redis
+
+import (
+	"context"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// Item is a response stored by SetMulti.
+type Item struct {
+	Key        string
+	Response   []byte
+	Expiration time.Time
+}
+
+// GetMulti returns the responses stored for several keys, by key, omitting
+// the missing ones. Without go-redis/cache, the keys are read in a single
+// pipeline, split by node with a cluster client, instead of a round-trip
+// per key.
+func (a *Adapter) GetMulti(ctx context.Context, keys []string) map[string][]byte {
+	ctx, cancel := withTimeout(ctx, a.readTimeout)
+	defer cancel()
+
+	responses := make(map[string][]byte, len(keys))
+	if a.store != nil {
+		for _, key := range keys {
+			if response, err := a.get(ctx, a.key(key)); a.count(key, err) {
+				responses[key] = response
+			}
+		}
+		return responses
+	}
+
+	// Responses cached client-side are served from memory, the others are
+	// read without tracking.
+	missing := keys
+	if a.tracking != nil {
+		missing = nil
+		for _, key := range keys {
+			if response, ok := a.tracking.local.Get(ctx, a.key(key)); ok {
+				if response, err := a.decode(response); a.count(key, err) {
+					responses[key] = response
+				}
+			} else {
+				missing = append(missing, key)
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return responses
+	}
+
+	cmds := make([]*goredis.StringCmd, len(missing))
+	a.client.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for i, key := range missing {
+			cmds[i] = pipe.Get(ctx, a.key(key))
+		}
+		return nil
+	})
+	for i, cmd := range cmds {
+		response, err := cmd.Bytes()
+		if err == nil {
+			response, err = a.decode(response)
+		}
+		if a.count(missing[i], err) {
+			responses[missing[i]] = response
+		}
+	}
+
+	return responses
+}
+
+// SetMulti stores several responses like Set. Without go-redis/cache, they
+// are written in a single pipeline, split by node with a cluster client,
+// instead of a round-trip per response.
+func (a *Adapter) SetMulti(ctx context.Context, items []Item) {
+	if a.store != nil {
+		for _, item := range items {
+			a.Set(ctx, item.Key, item.Response, item.Expiration)
+		}
+		return
+	}
+
+	ctx, cancel := withTimeout(ctx, a.writeTimeout)
+	defer cancel()
+
+	cmds := make([]goredis.Cmder, len(items))
+	a.client.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for i, item := range items {
+			response, err := a.encode(item.Response)
+			a.fail("set", item.Key, err)
+			if ttl, ok := ttl(a.retain(item.Expiration)); ok && err == nil {
+				cmds[i] = pipe.Set(ctx, a.key(item.Key), response, ttl)
+			} else {
+				cmds[i] = pipe.Del(ctx, a.key(item.Key))
+			}
+		}
+		return nil
+	})
+	for i, cmd := range cmds {
+		a.fail("set", items[i].Key, cmd.Err())
+	}
+	if a.tracking != nil {
+		for _, item := range items {
+			a.tracking.release(ctx, a.key(item.Key))
+		}
+	}
+}
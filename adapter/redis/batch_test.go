@@ -0,0 +1,93 @@
+package redis
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	redisCache "github.com/go-redis/cache/v8"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestMulti(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr: ":6379",
+	})
+	tests := []struct {
+		name    string
+		adapter cache.Adapter
+		items   []Item
+		want    map[string][]byte
+	}{
+		{
+			"pipelines commands",
+			NewUniversalAdapter(client),
+			[]Item{
+				{"/multi/foo", []byte("value 1"), time.Now().Add(time.Minute)},
+				{"/multi/bar", []byte("value 2"), time.Now().Add(time.Minute)},
+				{"/multi/baz", []byte("value 3"), time.Now().Add(-time.Minute)},
+			},
+			map[string][]byte{
+				"/multi/foo": []byte("value 1"),
+				"/multi/bar": []byte("value 2"),
+			},
+		},
+		{
+			"uses go-redis/cache",
+			NewAdapter(redisCache.New(&redisCache.Options{Redis: client})),
+			[]Item{
+				{"/multi/foo", []byte("value 1"), time.Now().Add(time.Minute)},
+				{"/multi/bar", []byte("value 2"), time.Now().Add(time.Minute)},
+			},
+			map[string][]byte{
+				"/multi/foo": []byte("value 1"),
+				"/multi/bar": []byte("value 2"),
+				"/multi/baz": []byte("value 0"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			a := tt.adapter.(*Adapter)
+			a.Release(ctx, "/multi/baz")
+			a.Set(ctx, "/multi/baz", []byte("value 0"), time.Now().Add(time.Minute))
+			a.SetMulti(ctx, tt.items)
+			got := a.GetMulti(ctx, []string{"/multi/foo", "/multi/bar", "/multi/baz", "/multi/qux"})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("redis.GetMulti() = %q, want %q", got, tt.want)
+			}
+
+			for key := range got {
+				a.Release(ctx, key)
+			}
+		})
+	}
+}
+
+func TestMultiClientSideCaching(t *testing.T) {
+	ctx := context.Background()
+	server := newTrackingServer(t)
+	client := redis.NewClient(&redis.Options{Addr: server.listener.Addr().String()})
+	defer client.Close()
+	a := NewUniversalAdapter(client, AdapterWithClientSideCaching(100, time.Minute)).(*Adapter)
+	defer a.Close()
+
+	a.SetMulti(ctx, []Item{
+		{"foo", []byte("value 1"), time.Time{}},
+		{"bar", []byte("value 2"), time.Time{}},
+	})
+	a.Get(ctx, "foo")
+	gets := server.getCount()
+
+	got := a.GetMulti(ctx, []string{"foo", "bar"})
+	if string(got["foo"]) != "value 1" || string(got["bar"]) != "value 2" {
+		t.Errorf("redis.GetMulti() = %q", got)
+	}
+	if n := server.getCount() - gets; n != 1 {
+		t.Errorf("GET commands = %v, want 1 for the key not cached client-side", n)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package redis
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	cache "github.com/cludden/http-cache"
+)
+
+// compressionMagic prefixes compressed values, followed by the length and
+// the name of their compression algorithm. Values the middleware encodes
+// never start with it.
+const compressionMagic = "\x00hcz"
+
+// ErrValueTooLarge is reported to the error handler when a value exceeds the
+// size set with AdapterWithMaxValueSize, which is then released instead.
+var ErrValueTooLarge = errors.New("redis adapter value is too large")
+
+// encode compresses a value when compression is enabled and it is large
+// enough, and enforces the max value size.
+func (a *Adapter) encode(value []byte) ([]byte, error) {
+	if a.compression != "" && len(value) >= a.compressionMinSize {
+		compressed, err := cache.Compress(a.compression, value)
+		if err != nil {
+			return nil, err
+		}
+		if len(compressed)+len(compressionMagic)+1+len(a.compression) < len(value) {
+			b := make([]byte, 0, len(compressionMagic)+1+len(a.compression)+len(compressed))
+			b = append(b, compressionMagic...)
+			b = append(b, byte(len(a.compression)))
+			b = append(b, a.compression...)
+			value = append(b, compressed...)
+		}
+	}
+	if a.maxValueSize > 0 && len(value) > a.maxValueSize {
+		return nil, ErrValueTooLarge
+	}
+
+	return value, nil
+}
+
+// decode decompresses a value stored compressed, returning other values
+// unchanged.
+func (a *Adapter) decode(value []byte) ([]byte, error) {
+	if !bytes.HasPrefix(value, []byte(compressionMagic)) {
+		return value, nil
+	}
+
+	value = value[len(compressionMagic):]
+	if len(value) == 0 || len(value) < 1+int(value[0]) {
+		return nil, errors.New("redis adapter compressed value is truncated")
+	}
+	compression := cache.Compression(value[1 : 1+value[0]])
+	b, err := cache.Decompress(compression, value[1+value[0]:])
+	if err != nil {
+		return nil, fmt.Errorf("decompressing value: %w", err)
+	}
+
+	return b, nil
+}
@@ -0,0 +1,105 @@
+package redis
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	redisCache "github.com/go-redis/cache/v8"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestCompressionAndMaxValueSize(t *testing.T) {
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{
+		Addr: ":6379",
+	})
+	large := []byte(strings.Repeat("value 1 ", 1000))
+	tests := []struct {
+		name      string
+		adapter   func(opts ...AdapterOptions) cache.Adapter
+		opts      []AdapterOptions
+		value     []byte
+		wantSize  int
+		wantFound bool
+	}{
+		{
+			"compresses large values",
+			func(opts ...AdapterOptions) cache.Adapter { return NewUniversalAdapter(client, opts...) },
+			[]AdapterOptions{AdapterWithCompression(cache.Zstd, 100)},
+			large,
+			len(large) / 10,
+			true,
+		},
+		{
+			"stores small values as is",
+			func(opts ...AdapterOptions) cache.Adapter { return NewUniversalAdapter(client, opts...) },
+			[]AdapterOptions{AdapterWithCompression(cache.Gzip, 100)},
+			[]byte("value 1"),
+			len("value 1"),
+			true,
+		},
+		{
+			"stores compressed values under the max size",
+			func(opts ...AdapterOptions) cache.Adapter { return NewUniversalAdapter(client, opts...) },
+			[]AdapterOptions{AdapterWithCompression(cache.Snappy, 0), AdapterWithMaxValueSize(1000)},
+			large,
+			1000,
+			true,
+		},
+		{
+			"releases values over the max size",
+			func(opts ...AdapterOptions) cache.Adapter { return NewUniversalAdapter(client, opts...) },
+			[]AdapterOptions{AdapterWithMaxValueSize(1000)},
+			large,
+			0,
+			false,
+		},
+		{
+			"compresses with go-redis/cache",
+			func(opts ...AdapterOptions) cache.Adapter {
+				return NewAdapter(redisCache.New(&redisCache.Options{Redis: client}), opts...)
+			},
+			[]AdapterOptions{AdapterWithCompression(cache.Gzip, 0)},
+			large,
+			len(large) / 10,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var errs []error
+			opts := append(tt.opts, AdapterWithErrorHandler(func(op, key string, err error) {
+				errs = append(errs, err)
+			}))
+			adapter := tt.adapter(opts...)
+			client.Set(ctx, "/compression/foo", "previous", time.Minute)
+			defer client.Del(ctx, "/compression/foo")
+
+			adapter.Set(ctx, "/compression/foo", tt.value, time.Now().Add(time.Minute))
+			got, ok := adapter.Get(ctx, "/compression/foo")
+			if ok != tt.wantFound {
+				t.Fatalf("redis.Get() ok = %v, want %v", ok, tt.wantFound)
+			}
+			if !tt.wantFound {
+				if len(errs) != 1 || errs[0] != ErrValueTooLarge {
+					t.Errorf("errors = %v, want %v", errs, ErrValueTooLarge)
+				}
+				return
+			}
+			if !bytes.Equal(got, tt.value) {
+				t.Errorf("redis.Get() = %q, want %q", got, tt.value)
+			}
+			if size := len(client.Get(ctx, "/compression/foo").Val()); size > tt.wantSize {
+				t.Errorf("stored size = %v, want at most %v", size, tt.wantSize)
+			}
+			if len(errs) != 0 {
+				t.Errorf("errors = %v, want none", errs)
+			}
+		})
+	}
+}
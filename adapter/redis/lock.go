@@ -0,0 +1,97 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// lockPrefix prefixes the keys of the fill locks.
+const lockPrefix = internalPrefix + "lock:"
+
+// unlockScript deletes a lock only while it holds the token of its holder.
+var unlockScript = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Lock implements the cache LockingAdapter interface Lock method with SET NX,
+// so that a single instance fills a missing response. The lock is stored
+// under a random token, so that unlocking after it expired doesn't release
+// the lock of another holder. It requires AdapterWithClient.
+func (a *Adapter) Lock(ctx context.Context, key string, ttl time.Duration) (func(context.Context) error, bool, error) {
+	unlock, ok, err := a.lock(ctx, key, ttl)
+	a.fail("lock", key, err)
+
+	return unlock, ok, err
+}
+
+func (a *Adapter) lock(ctx context.Context, key string, ttl time.Duration) (func(context.Context) error, bool, error) {
+	if a.client == nil {
+		return nil, false, errNoClient
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, false, err
+	}
+	token := hex.EncodeToString(b)
+
+	ctx, cancel := withTimeout(ctx, a.writeTimeout)
+	defer cancel()
+
+	lockKey := a.lockKey(key)
+	ok, err := a.client.SetNX(ctx, lockKey, token, ttl).Result()
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	return func(ctx context.Context) error {
+		ctx, cancel := withTimeout(ctx, a.writeTimeout)
+		defer cancel()
+
+		err := unlockScript.Run(ctx, a.client, []string{lockKey}, token).Err()
+		a.fail("unlock", key, err)
+
+		return err
+	}, true, nil
+}
+
+// lockKey returns the Redis key of the fill lock of a cache key, in the slot
+// of the key.
+func (a *Adapter) lockKey(key string) string {
+	if tag := a.hashTagOf(key); tag != "" {
+		return a.prefix + lockPrefix + "{" + tag + "}" + key
+	}
+
+	return a.prefix + lockPrefix + key
+}
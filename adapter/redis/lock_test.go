@@ -0,0 +1,58 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	redisCache "github.com/go-redis/cache/v8"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestLock(t *testing.T) {
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{
+		Addr: ":6379",
+	})
+	locker := NewUniversalAdapter(client, AdapterWithKeyPrefix("lock-test:")).(cache.LockingAdapter)
+
+	unlock, ok, err := locker.Lock(ctx, "/lock/foo", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("redis.Lock() = %v, %v, want lock", ok, err)
+	}
+	if ttl := client.PTTL(ctx, "lock-test:http-cache:lock:/lock/foo").Val(); ttl <= 59*time.Second || ttl > time.Minute {
+		t.Errorf("PTTL() = %v, want about a minute", ttl)
+	}
+	if _, ok, err := locker.Lock(ctx, "/lock/foo", time.Minute); err != nil || ok {
+		t.Errorf("redis.Lock() = %v, %v, want lock held", ok, err)
+	}
+
+	// Once the lock expired and was acquired again, unlocking must not
+	// release the lock of the new holder.
+	client.Del(ctx, "lock-test:http-cache:lock:/lock/foo")
+	unlock2, ok, err := locker.Lock(ctx, "/lock/foo", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("redis.Lock() = %v, %v, want lock", ok, err)
+	}
+	if err := unlock(ctx); err != nil {
+		t.Errorf("unlock() error = %v", err)
+	}
+	if _, ok, _ := locker.Lock(ctx, "/lock/foo", time.Minute); ok {
+		t.Error("unlock() released the lock of another holder")
+	}
+
+	if err := unlock2(ctx); err != nil {
+		t.Errorf("unlock() error = %v", err)
+	}
+	unlock, ok, err = locker.Lock(ctx, "/lock/foo", time.Minute)
+	if err != nil || !ok {
+		t.Errorf("redis.Lock() = %v, %v, want lock released", ok, err)
+	}
+	unlock(ctx)
+
+	noClient := NewAdapter(redisCache.New(&redisCache.Options{Redis: client})).(cache.LockingAdapter)
+	if _, _, err := noClient.Lock(ctx, "/lock/foo", time.Minute); err != errNoClient {
+		t.Errorf("redis.Lock() error = %v, want %v", err, errNoClient)
+	}
+}
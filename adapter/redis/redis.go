@@ -26,44 +26,736 @@ package redis
 
 import (
 	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	cache "github.com/cludden/http-cache"
 	redis "github.com/go-redis/cache/v8"
+	goredis "github.com/go-redis/redis/v8"
 )
 
-// Adapter is the memory adapter data structure.
+// internalPrefix prefixes the keys the adapter stores besides responses.
+const internalPrefix = "http-cache:"
+
+// tagPrefix prefixes the keys of the sets indexing keys by tag.
+const tagPrefix = internalPrefix + "tag:"
+
+// pingKey is the key read by Ping without a Redis client.
+const pingKey = internalPrefix + "ping"
+
+// errNoClient is returned by tagging and invalidation methods when no Redis
+// client is configured.
+var errNoClient = errors.New("redis adapter client is not configured")
+
+// scanCount is the number of keys requested per SCAN iteration.
+const scanCount = 1000
+
+// tagScript adds a key to tag sets, extending their TTL to cover the key.
+var tagScript = goredis.NewScript(`
+for _, tag in ipairs(KEYS) do
+	redis.call("SADD", tag, ARGV[1])
+	if redis.call("PTTL", tag) < tonumber(ARGV[2]) then
+		redis.call("PEXPIRE", tag, ARGV[2])
+	end
+end
+return 0
+`)
+
+// Adapter is the Redis adapter data structure, storing responses through
+// go-redis/cache when created with NewAdapter, or as plain Redis strings
+// expiring with their TTL when created with NewUniversalAdapter or
+// NewAdapterFromOptions. The two don't encode responses the same way, so
+// they can't share keys.
 type Adapter struct {
-	store *redis.Cache
+	// counters is first to keep its atomic counters 64-bit aligned.
+	counters counters
+	onError  func(op, key string, err error)
+
+	// prefix and hashTag make the Redis keys of cache keys and tags.
+	prefix  string
+	hashTag func(string) string
+
+	store  *redis.Cache
+	client goredis.Cmdable
+
+	// compression compresses values of at least compressionMinSize bytes,
+	// and values over maxValueSize bytes aren't stored.
+	compression        cache.Compression
+	compressionMinSize int
+	maxValueSize       int
+
+	// margin is how long responses are kept past their expiration, or
+	// forever when negative.
+	margin time.Duration
+
+	// readTimeout and writeTimeout bound each Get, and each Set, Release
+	// and Tag.
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+
+	// options, addrs, masterName and replicaReads configure the client
+	// created by NewAdapterFromOptions, which the adapter closes.
+	options      goredis.UniversalOptions
+	addrs        []string
+	masterName   string
+	replicaReads bool
+	closer       io.Closer
+
+	// tracking is the client-side cache enabled with
+	// AdapterWithClientSideCaching, of localCapacity responses for up to
+	// localTTL.
+	tracking      *tracking
+	localCapacity int
+	localTTL      time.Duration
 }
 
+// AdapterOptions is used to set Adapter settings.
+type AdapterOptions func(a *Adapter)
+
 // Get implements the cache Adapter interface Get method.
 func (a *Adapter) Get(ctx context.Context, key string) ([]byte, bool) {
-	var c []byte
-	if err := a.store.Get(ctx, key, &c); err == nil {
-		return c, true
+	ctx, cancel := withTimeout(ctx, a.readTimeout)
+	defer cancel()
+
+	response, err := a.get(ctx, a.key(key))
+	if !a.count(key, err) {
+		return nil, false
 	}
 
-	return nil, false
+	return response, true
 }
 
-// Set implements the cache Adapter interface Set method.
+// get reads the response stored at a Redis key.
+func (a *Adapter) get(ctx context.Context, key string) ([]byte, error) {
+	var response []byte
+	var err error
+	if a.tracking != nil {
+		response, err = a.tracking.get(ctx, key)
+	}
+	if a.tracking == nil || err == errNotSubscribed {
+		if a.store == nil {
+			response, err = a.client.Get(ctx, key).Bytes()
+		} else {
+			err = a.store.Get(ctx, key, &response)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return a.decode(response)
+}
+
+// Set implements the cache Adapter interface Set method. Without
+// go-redis/cache, responses already past their expiration date release the
+// stored one instead, and responses without one don't expire.
 func (a *Adapter) Set(ctx context.Context, key string, response []byte, expiration time.Time) {
-	a.store.Set(&redis.Item{
-		Key:   key,
+	ctx, cancel := withTimeout(ctx, a.writeTimeout)
+	defer cancel()
+
+	rk := a.key(key)
+	response, err := a.encode(response)
+	if err != nil {
+		a.fail("set", key, err)
+		a.fail("release", key, a.release(ctx, rk))
+		return
+	}
+	if a.store == nil {
+		ttl, ok := ttl(a.retain(expiration))
+		if !ok {
+			a.fail("set", key, a.release(ctx, rk))
+			return
+		}
+		a.fail("set", key, a.client.Set(ctx, rk, response, ttl).Err())
+		if a.tracking != nil {
+			a.tracking.release(ctx, rk)
+		}
+		return
+	}
+
+	a.fail("set", key, a.store.Set(&redis.Item{
+		Ctx:   ctx,
+		Key:   rk,
 		Value: response,
-		TTL:   time.Until(expiration),
-	})
+		TTL:   time.Until(a.retain(expiration)),
+	}))
+}
+
+// ttl returns the TTL of a response expiring at a date, zero for no
+// expiration, or false when already expired.
+func ttl(expiration time.Time) (time.Duration, bool) {
+	if expiration.IsZero() {
+		return 0, true
+	}
+	ttl := time.Until(expiration)
+	if ttl <= 0 {
+		return 0, false
+	}
+
+	// TTLs are sent in milliseconds, rounded up so as not to expire early,
+	// nor never expire when under a millisecond.
+	return ttl.Truncate(time.Millisecond) + time.Millisecond, true
+}
+
+// retain returns until when a response expiring at a date is kept, extended
+// by the TTL margin, or the zero time for ever.
+func (a *Adapter) retain(expiration time.Time) time.Time {
+	if expiration.IsZero() || a.margin < 0 {
+		return time.Time{}
+	}
+
+	return expiration.Add(a.margin)
 }
 
 // Release implements the cache Adapter interface Release method.
 func (a *Adapter) Release(ctx context.Context, key string) {
-	a.store.Delete(ctx, key)
+	ctx, cancel := withTimeout(ctx, a.writeTimeout)
+	defer cancel()
+
+	a.fail("release", key, a.release(ctx, a.key(key)))
+}
+
+// release deletes a Redis key.
+func (a *Adapter) release(ctx context.Context, key string) error {
+	if a.tracking != nil {
+		defer a.tracking.release(ctx, key)
+	}
+	if a.store == nil {
+		return a.client.Del(ctx, key).Err()
+	}
+
+	return a.store.Delete(ctx, key)
+}
+
+// Tag implements the cache TaggingAdapter interface Tag method, indexing the
+// key in a set per tag. It requires AdapterWithClient.
+func (a *Adapter) Tag(ctx context.Context, key string, tags []string, expiration time.Time) error {
+	err := a.tag(ctx, key, tags, expiration)
+	a.fail("tag", key, err)
+
+	return err
+}
+
+func (a *Adapter) tag(ctx context.Context, key string, tags []string, expiration time.Time) error {
+	if a.client == nil {
+		return errNoClient
+	}
+
+	ctx, cancel := withTimeout(ctx, a.writeTimeout)
+	defer cancel()
+
+	// Tag sets are updated by a script per hash tag, as scripts can only
+	// access keys of a single cluster slot.
+	groups := map[string][]string{}
+	var order []string
+	for _, tag := range tags {
+		group := a.hashTagOf(tag)
+		if _, ok := groups[group]; !ok {
+			order = append(order, group)
+		}
+		groups[group] = append(groups[group], a.tagKey(tag))
+	}
+	for _, group := range order {
+		if err := tagScript.Run(ctx, a.client, groups[group], key, time.Until(a.retain(expiration)).Milliseconds()).Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// InvalidateTag implements the cache TaggingAdapter interface InvalidateTag
+// method. It requires AdapterWithClient.
+func (a *Adapter) InvalidateTag(ctx context.Context, tag string) error {
+	err := a.invalidateTag(ctx, tag)
+	a.fail("invalidate_tag", tag, err)
+
+	return err
+}
+
+func (a *Adapter) invalidateTag(ctx context.Context, tag string) error {
+	if a.client == nil {
+		return errNoClient
+	}
+
+	keys, err := a.client.SMembers(ctx, a.tagKey(tag)).Result()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := a.release(ctx, a.key(key)); err != nil {
+			return err
+		}
+	}
+
+	return a.client.Del(ctx, a.tagKey(tag)).Err()
+}
+
+// InvalidatePrefix implements the cache InvalidatingAdapter interface
+// InvalidatePrefix method, scanning keys. It requires AdapterWithClient.
+func (a *Adapter) InvalidatePrefix(ctx context.Context, prefix string) error {
+	return a.InvalidatePattern(ctx, cache.EscapePattern(prefix)+"*")
+}
+
+// InvalidatePattern implements the cache InvalidatingAdapter interface
+// InvalidatePattern method, scanning keys, on every master with a cluster
+// client. It requires AdapterWithClient.
+func (a *Adapter) InvalidatePattern(ctx context.Context, pattern string) error {
+	err := a.invalidatePattern(ctx, pattern)
+	a.fail("invalidate_pattern", pattern, err)
+
+	return err
+}
+
+func (a *Adapter) invalidatePattern(ctx context.Context, pattern string) error {
+	if a.client == nil {
+		return errNoClient
+	}
+
+	// Hash tags come between the prefix and the key, so keys are matched
+	// once stripped of both.
+	match, filter := cache.EscapePattern(a.prefix)+pattern, ""
+	if a.hashTag != nil {
+		match, filter = cache.EscapePattern(a.prefix)+"*", pattern
+	}
+
+	if cluster, ok := a.client.(*goredis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, client *goredis.Client) error {
+			return a.scan(ctx, client, match, filter, func(key string) error {
+				return a.release(ctx, key)
+			})
+		})
+	}
+
+	return a.scan(ctx, a.client, match, filter, func(key string) error {
+		return a.release(ctx, key)
+	})
+}
+
+// ScanKeys implements the cache KeyScanner interface ScanKeys method,
+// scanning keys on every master with a cluster client, and skipping the
+// keys of tags and locks. It requires AdapterWithClient.
+func (a *Adapter) ScanKeys(ctx context.Context, prefix string, fn func(key string) error) error {
+	err := a.scanKeys(ctx, prefix, fn)
+	a.fail("scan_keys", prefix, err)
+
+	return err
+}
+
+func (a *Adapter) scanKeys(ctx context.Context, prefix string, fn func(key string) error) error {
+	if a.client == nil {
+		return errNoClient
+	}
+
+	match, filter := cache.EscapePattern(a.prefix+prefix)+"*", ""
+	if a.hashTag != nil {
+		match, filter = cache.EscapePattern(a.prefix)+"*", cache.EscapePattern(prefix)+"*"
+	}
+	each := func(ctx context.Context, client goredis.Cmdable) error {
+		return a.scan(ctx, client, match, filter, func(key string) error {
+			if key = a.cacheKey(key); strings.HasPrefix(key, internalPrefix) {
+				return nil
+			}
+			return fn(key)
+		})
+	}
+
+	if cluster, ok := a.client.(*goredis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, client *goredis.Client) error {
+			return each(ctx, client)
+		})
+	}
+
+	return each(ctx, a.client)
+}
+
+// scan calls fn with the Redis keys of a node matching a pattern, and whose
+// cache key matches filter unless empty.
+func (a *Adapter) scan(ctx context.Context, client goredis.Cmdable, match, filter string, fn func(key string) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := client.Scan(ctx, cursor, match, scanCount).Result()
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if filter != "" && !cache.MatchPattern(filter, a.cacheKey(key)) {
+				continue
+			}
+			if err := fn(key); err != nil {
+				return err
+			}
+		}
+		if cursor = next; cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// key returns the Redis key of a cache key, with the key prefix and the hash
+// tag, if any.
+func (a *Adapter) key(key string) string {
+	if tag := a.hashTagOf(key); tag != "" {
+		return a.prefix + "{" + tag + "}" + key
+	}
+
+	return a.prefix + key
+}
+
+// tagKey returns the Redis key of the set indexing the keys of a tag.
+func (a *Adapter) tagKey(tag string) string {
+	if hashTag := a.hashTagOf(tag); hashTag != "" {
+		return a.prefix + tagPrefix + "{" + hashTag + "}" + tag
+	}
+
+	return a.prefix + tagPrefix + tag
+}
+
+// cacheKey returns the cache key of a Redis key.
+func (a *Adapter) cacheKey(key string) string {
+	key = strings.TrimPrefix(key, a.prefix)
+	if a.hashTag != nil && strings.HasPrefix(key, "{") {
+		if i := strings.IndexByte(key, '}'); i > 0 {
+			key = key[i+1:]
+		}
+	}
+
+	return key
+}
+
+// hashTagOf returns the hash tag of a cache key or tag, if any.
+func (a *Adapter) hashTagOf(s string) string {
+	if a.hashTag == nil {
+		return ""
+	}
+
+	return a.hashTag(s)
+}
+
+// Stats is a snapshot of the Redis adapter usage.
+type Stats struct {
+	// Hits and Misses count the keys which were or weren't found by Get
+	// and GetMulti.
+	Hits   uint64
+	Misses uint64
+
+	// Errors counts the failed operations, including reads, which aren't
+	// counted as misses.
+	Errors uint64
+}
+
+// counters are the atomic counters of the adapter usage.
+type counters struct {
+	hits   uint64
+	misses uint64
+	errors uint64
+}
+
+// Stats returns the current usage of the adapter.
+func (a *Adapter) Stats() Stats {
+	return Stats{
+		Hits:   atomic.LoadUint64(&a.counters.hits),
+		Misses: atomic.LoadUint64(&a.counters.misses),
+		Errors: atomic.LoadUint64(&a.counters.errors),
+	}
+}
+
+// ResetStats resets the hits, misses and errors counters.
+func (a *Adapter) ResetStats() {
+	atomic.StoreUint64(&a.counters.hits, 0)
+	atomic.StoreUint64(&a.counters.misses, 0)
+	atomic.StoreUint64(&a.counters.errors, 0)
+}
+
+// count records the outcome of reading a key, and reports whether it was
+// found.
+func (a *Adapter) count(key string, err error) bool {
+	switch {
+	case err == nil:
+		atomic.AddUint64(&a.counters.hits, 1)
+		return true
+	case err == goredis.Nil || err == redis.ErrCacheMiss:
+		atomic.AddUint64(&a.counters.misses, 1)
+	default:
+		a.fail("get", key, err)
+	}
+
+	return false
+}
+
+// fail records the error of an operation on a key, tag or pattern, if any,
+// calling the error handler.
+func (a *Adapter) fail(op, key string, err error) {
+	if err == nil {
+		return
+	}
+
+	atomic.AddUint64(&a.counters.errors, 1)
+	if a.onError != nil {
+		a.onError(op, key, err)
+	}
+}
+
+// Ping implements the cache HealthChecker interface Ping method, sending a
+// PING, or reading a key with go-redis/cache alone.
+func (a *Adapter) Ping(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, a.readTimeout)
+	defer cancel()
+
+	if a.client != nil {
+		return a.client.Ping(ctx).Err()
+	}
+	var c []byte
+	if err := a.store.Get(ctx, a.prefix+pingKey, &c); err != nil && err != redis.ErrCacheMiss {
+		return err
+	}
+
+	return nil
+}
+
+// Close stops the client-side cache, if any, and closes the client created
+// by NewAdapterFromOptions. The clients given to the other constructors are
+// left open.
+func (a *Adapter) Close() error {
+	if a.tracking != nil {
+		a.tracking.Close()
+	}
+	if a.closer == nil {
+		return nil
+	}
+
+	return a.closer.Close()
+}
+
+// track starts the client-side cache when enabled, for single node and
+// sentinel clients.
+func (a *Adapter) track() {
+	client, ok := a.client.(*goredis.Client)
+	if a.localCapacity <= 1 || !ok || a.store != nil {
+		return
+	}
+
+	if t, err := newTracking(client, a.localCapacity, a.localTTL); err == nil {
+		a.tracking = t
+	}
+}
+
+// withTimeout returns ctx bounded by a timeout, unless zero.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, timeout)
 }
 
 // NewAdapter initializes Redis adapter.
-func NewAdapter(c *redis.Cache) cache.Adapter {
-	return &Adapter{
+func NewAdapter(c *redis.Cache, opts ...AdapterOptions) cache.Adapter {
+	a := &Adapter{
 		store: c,
 	}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+// NewUniversalAdapter initializes a Redis adapter issuing plain GET, SET PX
+// and DEL commands with a single node, sentinel or cluster client, without
+// go-redis/cache and its local cache. Tag, prefix and pattern invalidation
+// use the same client.
+func NewUniversalAdapter(client goredis.UniversalClient, opts ...AdapterOptions) cache.Adapter {
+	a := &Adapter{
+		client: client,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	a.track()
+
+	return a
+}
+
+// AdapterWithClient sets the Redis client used to index keys by tag and to
+// scan keys, which must connect to the same Redis as the cache, enabling tag,
+// prefix and pattern invalidation.
+func AdapterWithClient(client goredis.Cmdable) AdapterOptions {
+	return func(a *Adapter) {
+		a.client = client
+	}
+}
+
+// NewAdapterFromOptions initializes a Redis adapter like NewUniversalAdapter,
+// with a client created from the options: a cluster client for several
+// addresses, a sentinel client with AdapterWithSentinel, else a single node
+// client. Close closes the client.
+func NewAdapterFromOptions(opts ...AdapterOptions) cache.Adapter {
+	a := &Adapter{}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	// The options are applied over the universal options whatever their
+	// order.
+	options := a.options
+	if len(a.addrs) > 0 {
+		options.Addrs = a.addrs
+	}
+	if a.masterName != "" {
+		options.MasterName = a.masterName
+	}
+
+	var client goredis.UniversalClient
+	switch {
+	case options.MasterName != "" && a.replicaReads:
+		// Failover clients only talk to the master, so reading from
+		// replicas takes a cluster client of the master and the replicas
+		// known to the sentinels.
+		failover := options.Failover()
+		failover.RouteRandomly = true
+		client = goredis.NewFailoverClusterClient(failover)
+	default:
+		options.ReadOnly = options.ReadOnly || a.replicaReads
+		client = goredis.NewUniversalClient(&options)
+	}
+	a.client, a.closer = client, client
+	a.track()
+
+	return a
+}
+
+// AdapterWithUniversalOptions sets the options of the client created by
+// NewAdapterFromOptions, such as credentials, TLS and pool settings,
+// overridden by the other options.
+func AdapterWithUniversalOptions(options goredis.UniversalOptions) AdapterOptions {
+	return func(a *Adapter) {
+		a.options = options
+	}
+}
+
+// AdapterWithAddrs sets the addresses of the client created by
+// NewAdapterFromOptions: a single node, cluster nodes when several, or
+// sentinels with AdapterWithSentinel.
+func AdapterWithAddrs(addrs ...string) AdapterOptions {
+	return func(a *Adapter) {
+		a.addrs = addrs
+	}
+}
+
+// AdapterWithSentinel sets the name of the master monitored by the sentinels
+// the client created by NewAdapterFromOptions connects to.
+func AdapterWithSentinel(masterName string) AdapterOptions {
+	return func(a *Adapter) {
+		a.masterName = masterName
+	}
+}
+
+// AdapterWithReplicaReads makes the client created by NewAdapterFromOptions
+// send Get commands to replicas while writes go to the primary: to the
+// replicas of each shard with a cluster, and randomly to the master or a
+// replica with sentinels. Responses written recently may not have reached
+// the replicas yet.
+func AdapterWithReplicaReads(enabled bool) AdapterOptions {
+	return func(a *Adapter) {
+		a.replicaReads = enabled
+	}
+}
+
+// AdapterWithTimeouts bounds each Get command by the read timeout, and each
+// Set, Release and Tag command by the write timeout, on top of the deadline
+// of their context. Zero timeouts don't bound commands.
+func AdapterWithTimeouts(read, write time.Duration) AdapterOptions {
+	return func(a *Adapter) {
+		a.readTimeout = read
+		a.writeTimeout = write
+	}
+}
+
+// AdapterWithClientSideCaching keeps up to capacity responses read from
+// Redis in memory, for up to maxTTL unless zero, until Redis reports their
+// keys as modified, expired or evicted with CLIENT TRACKING, so that hot
+// responses don't take a round-trip. It requires Redis 6 and a single node
+// or sentinel client given to NewUniversalAdapter or NewAdapterFromOptions,
+// and is ignored otherwise. Close stops it.
+//
+// Invalidations may be lost while reconnecting to Redis, so maxTTL bounds
+// how long a response modified meanwhile may be served.
+func AdapterWithClientSideCaching(capacity int, maxTTL time.Duration) AdapterOptions {
+	return func(a *Adapter) {
+		a.localCapacity = capacity
+		a.localTTL = maxTTL
+	}
+}
+
+// AdapterWithErrorHandler sets a function called with the operation, "get",
+// "set", "release", "tag", "invalidate_tag", "invalidate_pattern", "lock"
+// or "unlock", the key, tag or pattern, and the error of every failed
+// operation, which the cache Adapter interface doesn't return, such as to
+// log them.
+func AdapterWithErrorHandler(fn func(op, key string, err error)) AdapterOptions {
+	return func(a *Adapter) {
+		a.onError = fn
+	}
+}
+
+// AdapterWithKeyPrefix prefixes the Redis keys of responses and tag sets, so
+// that several caches or applications can share a Redis. Prefix and pattern
+// invalidation only release keys with the prefix.
+func AdapterWithKeyPrefix(prefix string) AdapterOptions {
+	return func(a *Adapter) {
+		a.prefix = prefix
+	}
+}
+
+// AdapterWithTTLMargin keeps responses in Redis for a margin past their
+// expiration date, which remains the one encoded in the responses, so that
+// the middleware can still serve them stale, such as with
+// stale-while-revalidate or stale-if-error windows it doesn't know of. With
+// a negative margin, responses are stored without TTL and only leave Redis
+// when released or evicted under its maxmemory policy.
+func AdapterWithTTLMargin(margin time.Duration) AdapterOptions {
+	return func(a *Adapter) {
+		a.margin = margin
+	}
+}
+
+// AdapterWithCompression compresses the values of at least minSize bytes
+// stored by the adapter with the given algorithm, cache.Gzip, cache.Snappy
+// or cache.Zstd, unless compressing doesn't make them smaller. Values are
+// decompressed when read whatever the compression setting, so it can be
+// changed without releasing the stored ones.
+func AdapterWithCompression(compression cache.Compression, minSize int) AdapterOptions {
+	return func(a *Adapter) {
+		a.compression = compression
+		a.compressionMinSize = minSize
+	}
+}
+
+// AdapterWithMaxValueSize sets the size in bytes of the largest value stored
+// by the adapter, after compression. Larger values release the stored one
+// instead, reporting ErrValueTooLarge to the error handler.
+func AdapterWithMaxValueSize(size int) AdapterOptions {
+	return func(a *Adapter) {
+		a.maxValueSize = size
+	}
+}
+
+// AdapterWithHashTag sets a function returning the stable portion of a cache
+// key or tag, such as a tenant or a host, wrapped in a {hash tag} in its
+// Redis key so that Redis Cluster stores the keys and tags with the same
+// portion in the same slot, or an empty string for none. The sets of the
+// tags of a key are updated by a script per hash tag, so that tagging works
+// under Redis Cluster, and with the same hash tag as the key, the entry and
+// its tag index are co-located.
+//
+// Pattern invalidation then scans every key with the key prefix, matching
+// the patterns against the keys stripped of their hash tag.
+func AdapterWithHashTag(fn func(s string) string) AdapterOptions {
+	return func(a *Adapter) {
+		a.hashTag = fn
+	}
 }
@@ -3,6 +3,8 @@ package redis
 import (
 	"context"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -128,3 +130,459 @@ func TestRelease(t *testing.T) {
 		})
 	}
 }
+
+func TestInvalidateTag(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr: ":6379",
+	})
+	tagged := NewAdapter(redisCache.New(&redisCache.Options{Redis: client}), AdapterWithClient(client))
+	tagger := tagged.(cache.TaggingAdapter)
+	exp := time.Now().Add(1 * time.Minute)
+	for key, tags := range map[string][]string{
+		"https://example.com/tagged-foo": {"product-42", "catalog"},
+		"https://example.com/tagged-bar": {"catalog"},
+		"https://example.com/tagged-baz": {"product-43"},
+	} {
+		tagged.Set(context.Background(), key, cache.Response{Value: []byte(key)}.Bytes(), exp)
+		if err := tagger.Tag(context.Background(), key, tags, exp); err != nil {
+			t.Fatalf("redis.Tag() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		tag      string
+		released []string
+		kept     []string
+	}{
+		{
+			"releases tagged keys",
+			"product-42",
+			[]string{"https://example.com/tagged-foo"},
+			[]string{"https://example.com/tagged-bar", "https://example.com/tagged-baz"},
+		},
+		{
+			"releases remaining tagged keys",
+			"catalog",
+			[]string{"https://example.com/tagged-bar"},
+			[]string{"https://example.com/tagged-baz"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tagger.InvalidateTag(context.Background(), tt.tag); err != nil {
+				t.Fatalf("redis.InvalidateTag() error = %v", err)
+			}
+			for _, key := range tt.released {
+				if _, ok := tagged.Get(context.Background(), key); ok {
+					t.Errorf("redis.InvalidateTag() kept %v", key)
+				}
+			}
+			for _, key := range tt.kept {
+				if _, ok := tagged.Get(context.Background(), key); !ok {
+					t.Errorf("redis.InvalidateTag() released %v", key)
+				}
+			}
+		})
+	}
+
+	if err := a.(cache.TaggingAdapter).InvalidateTag(context.Background(), "catalog"); err == nil {
+		t.Errorf("redis.InvalidateTag() error = nil, want an error without client")
+	}
+}
+
+func TestInvalidatePrefixAndPattern(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr: ":6379",
+	})
+	adapter := NewAdapter(redisCache.New(&redisCache.Options{Redis: client}), AdapterWithClient(client))
+	keys := []string{"/api/products/1", "/api/products/2", "/api/users/1"}
+
+	tests := []struct {
+		name       string
+		invalidate func(a cache.InvalidatingAdapter) error
+		want       []string
+	}{
+		{
+			"invalidates prefix",
+			func(a cache.InvalidatingAdapter) error {
+				return a.InvalidatePrefix(context.Background(), "/api/products/")
+			},
+			[]string{"/api/users/1"},
+		},
+		{
+			"invalidates pattern",
+			func(a cache.InvalidatingAdapter) error {
+				return a.InvalidatePattern(context.Background(), "/api/*/1")
+			},
+			[]string{"/api/products/2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range keys {
+				adapter.Set(context.Background(), key, cache.Response{Value: []byte(key)}.Bytes(), time.Now().Add(1*time.Minute))
+			}
+			if err := tt.invalidate(adapter.(cache.InvalidatingAdapter)); err != nil {
+				t.Fatalf("invalidate error = %v", err)
+			}
+			var got []string
+			for _, key := range keys {
+				if _, ok := adapter.Get(context.Background(), key); ok {
+					got = append(got, key)
+				}
+				adapter.Release(context.Background(), key)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("remaining keys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanKeys(t *testing.T) {
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{
+		Addr: ":6379",
+	})
+	tenant := func(s string) string {
+		if i := strings.IndexByte(s, ':'); i > 0 {
+			return s[:i]
+		}
+		return ""
+	}
+	tests := []struct {
+		name string
+		opts []AdapterOptions
+	}{
+		{"scans keys", []AdapterOptions{AdapterWithKeyPrefix("scan:")}},
+		{"scans hash tagged keys", []AdapterOptions{AdapterWithKeyPrefix("scan:"), AdapterWithHashTag(tenant)}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewUniversalAdapter(client, tt.opts...).(*Adapter)
+			keys := []string{"t1:/products/1", "t1:/products/2", "t2:/products/1"}
+			exp := time.Now().Add(1 * time.Minute)
+			for _, key := range keys {
+				a.Set(ctx, key, []byte(key), exp)
+			}
+			a.Tag(ctx, "t1:/products/1", []string{"t1:catalog"}, exp)
+			defer func() {
+				a.InvalidatePrefix(ctx, "")
+				a.InvalidateTag(ctx, "t1:catalog")
+			}()
+
+			var got []string
+			err := a.ScanKeys(ctx, "t1:", func(key string) error {
+				got = append(got, key)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("redis.ScanKeys() error = %v", err)
+			}
+			sort.Strings(got)
+			if want := keys[:2]; !reflect.DeepEqual(got, want) {
+				t.Errorf("redis.ScanKeys() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestUniversalAdapter(t *testing.T) {
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{
+		Addr: ":6379",
+	})
+	adapter := NewUniversalAdapter(client)
+
+	adapter.Set(ctx, "/universal/foo", []byte("value 1"), time.Now().Add(1*time.Minute))
+	adapter.Set(ctx, "/universal/bar", []byte("value 2"), time.Time{})
+	adapter.Set(ctx, "/universal/baz", []byte("value 3"), time.Now().Add(-1*time.Minute))
+	for key, want := range map[string]string{
+		"/universal/foo": "value 1",
+		"/universal/bar": "value 2",
+		"/universal/baz": "",
+	} {
+		got, ok := adapter.Get(ctx, key)
+		if ok != (want != "") || string(got) != want {
+			t.Errorf("redis.Get(%v) = %q, %v, want %q", key, got, ok, want)
+		}
+	}
+	if ttl := client.PTTL(ctx, "/universal/foo").Val(); ttl <= 59*time.Second || ttl > time.Minute+time.Millisecond {
+		t.Errorf("PTTL() = %v, want about a minute", ttl)
+	}
+	if ttl := client.PTTL(ctx, "/universal/bar").Val(); ttl >= 0 {
+		t.Errorf("PTTL() = %v, want no expiration", ttl)
+	}
+
+	if err := adapter.(cache.InvalidatingAdapter).InvalidatePrefix(ctx, "/universal/f"); err != nil {
+		t.Fatalf("redis.InvalidatePrefix() error = %v", err)
+	}
+	if _, ok := adapter.Get(ctx, "/universal/foo"); ok {
+		t.Errorf("redis.InvalidatePrefix() kept /universal/foo")
+	}
+
+	adapter.Release(ctx, "/universal/bar")
+	if _, ok := adapter.Get(ctx, "/universal/bar"); ok {
+		t.Errorf("redis.Release() kept /universal/bar")
+	}
+}
+
+func TestNewAdapterFromOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []AdapterOptions
+		want interface{}
+	}{
+		{
+			"creates single node client",
+			[]AdapterOptions{AdapterWithAddrs(":6379")},
+			&redis.Client{},
+		},
+		{
+			"creates cluster client",
+			[]AdapterOptions{AdapterWithAddrs(":7000", ":7001"), AdapterWithReplicaReads(true)},
+			&redis.ClusterClient{},
+		},
+		{
+			"creates sentinel client",
+			[]AdapterOptions{AdapterWithSentinel("mymaster"), AdapterWithAddrs(":26379")},
+			&redis.Client{},
+		},
+		{
+			"creates sentinel client reading from replicas",
+			[]AdapterOptions{AdapterWithAddrs(":26379"), AdapterWithSentinel("mymaster"), AdapterWithReplicaReads(true)},
+			&redis.ClusterClient{},
+		},
+		{
+			"applies options over universal options",
+			[]AdapterOptions{AdapterWithAddrs(":6379"), AdapterWithUniversalOptions(redis.UniversalOptions{Addrs: []string{":7000", ":7001"}})},
+			&redis.Client{},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAdapterFromOptions(tt.opts...).(*Adapter)
+			defer a.Close()
+			if got, want := reflect.TypeOf(a.client), reflect.TypeOf(tt.want); got != want {
+				t.Errorf("client type = %v, want %v", got, want)
+			}
+		})
+	}
+
+	ctx := context.Background()
+	a := NewAdapterFromOptions(AdapterWithAddrs(":6379"), AdapterWithTimeouts(time.Second, time.Second))
+	a.Set(ctx, "/options/foo", []byte("value 1"), time.Now().Add(1*time.Minute))
+	if got, ok := a.Get(ctx, "/options/foo"); !ok || string(got) != "value 1" {
+		t.Errorf("redis.Get() = %q, %v, want %q", got, ok, "value 1")
+	}
+	a.Release(ctx, "/options/foo")
+	if err := a.(*Adapter).Close(); err != nil {
+		t.Errorf("redis.Close() error = %v", err)
+	}
+	if _, ok := a.Get(ctx, "/options/foo"); ok {
+		t.Errorf("redis.Get() hit after Close")
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{
+		Addr: ":6379",
+	})
+	adapter := NewUniversalAdapter(client, AdapterWithTimeouts(time.Nanosecond, time.Nanosecond))
+
+	adapter.Set(ctx, "/timeouts/foo", []byte("value 1"), time.Now().Add(1*time.Minute))
+	if _, err := client.Get(ctx, "/timeouts/foo").Result(); err != redis.Nil {
+		t.Errorf("Get() error = %v, want a Set timing out", err)
+	}
+	client.Set(ctx, "/timeouts/foo", "value 1", time.Minute)
+	if _, ok := adapter.Get(ctx, "/timeouts/foo"); ok {
+		t.Errorf("redis.Get() hit, want a Get timing out")
+	}
+	client.Del(ctx, "/timeouts/foo")
+}
+
+func TestStatsAndErrorHandler(t *testing.T) {
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{
+		Addr: ":6379",
+	})
+	adapter := NewUniversalAdapter(client).(*Adapter)
+	adapter.Set(ctx, "/stats/foo", []byte("value 1"), time.Now().Add(1*time.Minute))
+	adapter.Get(ctx, "/stats/foo")
+	adapter.Get(ctx, "/stats/bar")
+	adapter.GetMulti(ctx, []string{"/stats/foo", "/stats/bar"})
+	adapter.Release(ctx, "/stats/foo")
+	if got, want := adapter.Stats(), (Stats{Hits: 2, Misses: 2}); got != want {
+		t.Errorf("redis.Stats() = %+v, want %+v", got, want)
+	}
+	adapter.ResetStats()
+	if got := adapter.Stats(); got != (Stats{}) {
+		t.Errorf("redis.Stats() after ResetStats = %+v", got)
+	}
+
+	var ops []string
+	unreachable := redis.NewClient(&redis.Options{
+		Addr:       "127.0.0.1:1",
+		MaxRetries: -1,
+	})
+	defer unreachable.Close()
+	failing := NewUniversalAdapter(unreachable, AdapterWithErrorHandler(func(op, key string, err error) {
+		if err == nil {
+			t.Errorf("error handler called without error for %v %v", op, key)
+		}
+		ops = append(ops, op+" "+key)
+	})).(*Adapter)
+	failing.Set(ctx, "/stats/foo", []byte("value 1"), time.Now().Add(1*time.Minute))
+	if _, ok := failing.Get(ctx, "/stats/foo"); ok {
+		t.Errorf("redis.Get() hit with an unreachable Redis")
+	}
+	failing.Release(ctx, "/stats/foo")
+	failing.InvalidatePrefix(ctx, "/stats/")
+
+	want := []string{"set /stats/foo", "get /stats/foo", "release /stats/foo", "invalidate_pattern /stats/*"}
+	if !reflect.DeepEqual(ops, want) {
+		t.Errorf("handled errors = %q, want %q", ops, want)
+	}
+	if got, want := failing.Stats(), (Stats{Errors: 4}); got != want {
+		t.Errorf("redis.Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestKeyPrefixAndHashTag(t *testing.T) {
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{
+		Addr: ":6379",
+	})
+	tenant := func(s string) string {
+		if i := strings.IndexByte(s, ':'); i > 0 {
+			return s[:i]
+		}
+		return ""
+	}
+	tests := []struct {
+		name      string
+		opts      []AdapterOptions
+		redisKeys []string
+	}{
+		{
+			"prefixes keys",
+			[]AdapterOptions{AdapterWithKeyPrefix("app:")},
+			[]string{"app:t1:/products/1", "app:t2:/products/1", "app:/products/2", "app:http-cache:tag:t1:catalog"},
+		},
+		{
+			"adds hash tags",
+			[]AdapterOptions{AdapterWithKeyPrefix("app:"), AdapterWithHashTag(tenant)},
+			[]string{"app:{t1}t1:/products/1", "app:{t2}t2:/products/1", "app:/products/2", "app:http-cache:tag:{t1}t1:catalog"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewUniversalAdapter(client, tt.opts...).(*Adapter)
+			keys := []string{"t1:/products/1", "t2:/products/1", "/products/2"}
+			exp := time.Now().Add(1 * time.Minute)
+			for _, key := range keys {
+				a.Set(ctx, key, []byte(key), exp)
+			}
+			client.Set(ctx, "t1:/products/1", "unprefixed", time.Minute)
+			defer client.Del(ctx, "t1:/products/1")
+			if err := a.Tag(ctx, "t1:/products/1", []string{"t1:catalog", "t2:catalog"}, exp); err != nil {
+				t.Fatalf("redis.Tag() error = %v", err)
+			}
+
+			for _, key := range tt.redisKeys {
+				if n := client.Exists(ctx, key).Val(); n != 1 {
+					t.Errorf("Redis key %v not found", key)
+				}
+			}
+
+			if err := a.InvalidatePattern(ctx, "t?:/products/*"); err != nil {
+				t.Fatalf("redis.InvalidatePattern() error = %v", err)
+			}
+			if _, ok := a.Get(ctx, "t2:/products/1"); ok {
+				t.Errorf("redis.InvalidatePattern() kept t2:/products/1")
+			}
+			if _, ok := a.Get(ctx, "/products/2"); !ok {
+				t.Errorf("redis.InvalidatePattern() released /products/2")
+			}
+			if got := client.Get(ctx, "t1:/products/1").Val(); got != "unprefixed" {
+				t.Errorf("redis.InvalidatePattern() released a key without prefix")
+			}
+
+			a.Set(ctx, "t1:/products/1", []byte("value"), exp)
+			if err := a.InvalidateTag(ctx, "t2:catalog"); err != nil {
+				t.Fatalf("redis.InvalidateTag() error = %v", err)
+			}
+			if _, ok := a.Get(ctx, "t1:/products/1"); ok {
+				t.Errorf("redis.InvalidateTag() kept t1:/products/1")
+			}
+			a.InvalidateTag(ctx, "t1:catalog")
+			a.Release(ctx, "/products/2")
+		})
+	}
+}
+
+func TestTTLMargin(t *testing.T) {
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{
+		Addr: ":6379",
+	})
+	tests := []struct {
+		name       string
+		margin     time.Duration
+		expiration time.Time
+		wantTTL    time.Duration
+		wantStored bool
+	}{
+		{"extends the TTL", time.Hour, time.Now().Add(time.Minute), time.Hour + time.Minute, true},
+		{"keeps expired responses within the margin", time.Hour, time.Now().Add(-time.Minute), time.Hour - time.Minute, true},
+		{"releases expired responses past the margin", time.Minute, time.Now().Add(-time.Hour), 0, false},
+		{"stores without TTL", -1, time.Now().Add(time.Minute), -1, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := NewUniversalAdapter(client, AdapterWithTTLMargin(tt.margin))
+			defer client.Del(ctx, "/margin/foo")
+
+			adapter.Set(ctx, "/margin/foo", []byte("value 1"), tt.expiration)
+			if _, ok := adapter.Get(ctx, "/margin/foo"); ok != tt.wantStored {
+				t.Fatalf("redis.Get() ok = %v, want %v", ok, tt.wantStored)
+			}
+			if !tt.wantStored {
+				return
+			}
+			ttl := client.PTTL(ctx, "/margin/foo").Val()
+			if tt.wantTTL < 0 && ttl >= 0 || tt.wantTTL >= 0 && (ttl <= tt.wantTTL-time.Second || ttl > tt.wantTTL+time.Millisecond) {
+				t.Errorf("PTTL() = %v, want about %v", ttl, tt.wantTTL)
+			}
+		})
+	}
+}
+
+func TestPing(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		adapter cache.Adapter
+		wantErr bool
+	}{
+		{"client", NewUniversalAdapter(redis.NewClient(&redis.Options{Addr: ":6379"})), false},
+		{"go-redis/cache", NewAdapter(redisCache.New(&redisCache.Options{
+			Redis: redis.NewClient(&redis.Options{Addr: ":6379"}),
+		})), false},
+		{"unreachable", NewUniversalAdapter(redis.NewClient(&redis.Options{Addr: ":1", MaxRetries: -1})), true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.adapter.(cache.HealthChecker).Ping(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("redis.Ping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
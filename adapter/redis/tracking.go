@@ -0,0 +1,202 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	"github.com/cludden/http-cache/adapter/memory"
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// invalidationChannel is the channel Redis publishes the keys invalidated
+// for tracking clients on, with the RESP2 protocol.
+const invalidationChannel = "__redis__:invalidate"
+
+// errNotSubscribed is returned by tracked reads until the subscriber is
+// connected, so that the adapter reads without tracking instead.
+var errNotSubscribed = errors.New("redis adapter tracking subscriber is not connected")
+
+// tracking is the server-assisted client-side cache of the adapter, keeping
+// responses read from Redis in memory until Redis reports their keys as
+// modified, expired or evicted.
+//
+// Reads go through a dedicated client whose connections enable CLIENT
+// TRACKING, redirecting invalidations to the connection of a subscriber
+// client, as go-redis v8 doesn't support RESP3 push messages.
+type tracking struct {
+	options goredis.Options
+	local   cache.Adapter
+	maxTTL  time.Duration
+
+	// generation changes with every invalidation, so that responses read
+	// while one is received aren't cached. redirect is the client ID of the
+	// subscriber connection, and zero until connected.
+	generation uint64
+	redirect   int64
+
+	mutex      sync.Mutex
+	reader     *goredis.Client
+	subscriber *goredis.Client
+	pubsub     *goredis.PubSub
+}
+
+// newTracking starts the client-side cache of the Redis server of a client.
+func newTracking(client *goredis.Client, capacity int, maxTTL time.Duration) (*tracking, error) {
+	local, err := memory.NewAdapter(memory.AdapterWithAlgorithm(memory.LRU), memory.AdapterWithCapacity(capacity))
+	if err != nil {
+		return nil, err
+	}
+
+	t := &tracking{options: *client.Options(), local: local, maxTTL: maxTTL}
+	options := t.options
+	options.OnConnect = t.subscribed
+	t.subscriber = goredis.NewClient(&options)
+	t.reader = t.newReader()
+	t.pubsub = t.subscriber.Subscribe(context.Background(), invalidationChannel)
+	go t.receive(t.pubsub.Channel())
+
+	return t, nil
+}
+
+// get returns the response cached for a key, else reads it from Redis,
+// caching it unless invalidated meanwhile. It returns errNotSubscribed until
+// the subscriber is connected.
+func (t *tracking) get(ctx context.Context, key string) ([]byte, error) {
+	if response, ok := t.local.Get(ctx, key); ok {
+		return response, nil
+	}
+	if atomic.LoadInt64(&t.redirect) == 0 {
+		return nil, errNotSubscribed
+	}
+
+	generation := atomic.LoadUint64(&t.generation)
+	t.mutex.Lock()
+	reader := t.reader
+	t.mutex.Unlock()
+	response, err := reader.Get(ctx, key).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if atomic.LoadUint64(&t.generation) == generation {
+		var expiration time.Time
+		if t.maxTTL > 0 {
+			expiration = time.Now().Add(t.maxTTL)
+		}
+		t.local.Set(ctx, key, response, expiration)
+	}
+
+	return response, nil
+}
+
+// release drops the response cached for a key, written by this instance.
+func (t *tracking) release(ctx context.Context, key string) {
+	atomic.AddUint64(&t.generation, 1)
+	t.local.Release(ctx, key)
+}
+
+// newReader returns a client enabling tracking on its connections.
+func (t *tracking) newReader() *goredis.Client {
+	options := t.options
+	options.OnConnect = func(ctx context.Context, cn *goredis.Conn) error {
+		if t.options.OnConnect != nil {
+			if err := t.options.OnConnect(ctx, cn); err != nil {
+				return err
+			}
+		}
+		redirect := atomic.LoadInt64(&t.redirect)
+		if redirect == 0 {
+			return errNotSubscribed
+		}
+
+		return cn.Process(ctx, goredis.NewStatusCmd(ctx, "client", "tracking", "on", "redirect", redirect))
+	}
+
+	return goredis.NewClient(&options)
+}
+
+// subscribed records the client ID of a new subscriber connection. When the
+// subscriber reconnects, the invalidations sent meanwhile are lost and the
+// reader connections redirect to the previous one, so the cached responses
+// are dropped and the reader replaced.
+func (t *tracking) subscribed(ctx context.Context, cn *goredis.Conn) error {
+	if t.options.OnConnect != nil {
+		if err := t.options.OnConnect(ctx, cn); err != nil {
+			return err
+		}
+	}
+	id, err := cn.ClientID(ctx).Result()
+	if err != nil {
+		return err
+	}
+
+	if atomic.SwapInt64(&t.redirect, id) != 0 {
+		t.flush(ctx)
+		t.mutex.Lock()
+		previous := t.reader
+		t.reader = t.newReader()
+		t.mutex.Unlock()
+		previous.Close()
+	}
+
+	return nil
+}
+
+// receive drops the cached responses of the keys invalidated by Redis until
+// the subscription is closed. Invalidations of every key, sent on FLUSHALL,
+// have a nil payload go-redis fails to parse, reconnecting the subscriber
+// which flushes the cache.
+func (t *tracking) receive(messages <-chan *goredis.Message) {
+	for msg := range messages {
+		atomic.AddUint64(&t.generation, 1)
+		for _, key := range msg.PayloadSlice {
+			t.local.Release(context.Background(), key)
+		}
+		if msg.Payload != "" {
+			t.local.Release(context.Background(), msg.Payload)
+		}
+	}
+}
+
+// flush drops every cached response.
+func (t *tracking) flush(ctx context.Context) {
+	atomic.AddUint64(&t.generation, 1)
+	t.local.(cache.InvalidatingAdapter).InvalidatePrefix(ctx, "")
+}
+
+// Close stops the subscription and closes the clients.
+func (t *tracking) Close() error {
+	t.pubsub.Close()
+	t.subscriber.Close()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.reader.Close()
+}
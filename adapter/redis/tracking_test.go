@@ -0,0 +1,258 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// trackingServer is a Redis server implementing the commands used by the
+// client-side cache, with CLIENT TRACKING redirected invalidations, which
+// miniredis and other test servers lack.
+type trackingServer struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	data     map[string]string
+	conns    map[int64]*trackingConn
+	tracked  map[string]map[int64]bool
+	nextID   int64
+	gets     int
+}
+
+type trackingConn struct {
+	id       int64
+	conn     net.Conn
+	writer   *bufio.Writer
+	redirect int64
+}
+
+func newTrackingServer(t *testing.T) *trackingServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	s := &trackingServer{
+		listener: listener,
+		data:     map[string]string{},
+		conns:    map[int64]*trackingConn{},
+		tracked:  map[string]map[int64]bool{},
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+
+	return s
+}
+
+func (s *trackingServer) serve(conn net.Conn) {
+	s.mutex.Lock()
+	s.nextID++
+	c := &trackingConn{id: s.nextID, conn: conn, writer: bufio.NewWriter(conn)}
+	s.conns[c.id] = c
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.conns, c.id)
+		s.mutex.Unlock()
+		conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		s.mutex.Lock()
+		s.handle(c, args)
+		c.writer.Flush()
+		s.mutex.Unlock()
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		b := make([]byte, size+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+	return args, nil
+}
+
+func writeBulk(w *bufio.Writer, s string) {
+	fmt.Fprintf(w, "$%d\r\n%s\r\n", len(s), s)
+}
+
+// handle runs a command. The caller must hold the lock.
+func (s *trackingServer) handle(c *trackingConn, args []string) {
+	switch strings.ToUpper(args[0]) {
+	case "CLIENT":
+		if strings.EqualFold(args[1], "id") {
+			fmt.Fprintf(c.writer, ":%d\r\n", c.id)
+			return
+		}
+		c.redirect, _ = strconv.ParseInt(args[len(args)-1], 10, 64)
+		c.writer.WriteString("+OK\r\n")
+	case "SUBSCRIBE":
+		c.writer.WriteString("*3\r\n")
+		writeBulk(c.writer, "subscribe")
+		writeBulk(c.writer, args[1])
+		c.writer.WriteString(":1\r\n")
+	case "GET":
+		s.gets++
+		if c.redirect != 0 {
+			if s.tracked[args[1]] == nil {
+				s.tracked[args[1]] = map[int64]bool{}
+			}
+			s.tracked[args[1]][c.redirect] = true
+		}
+		if value, ok := s.data[args[1]]; ok {
+			writeBulk(c.writer, value)
+		} else {
+			c.writer.WriteString("$-1\r\n")
+		}
+	case "SET":
+		s.data[args[1]] = args[2]
+		s.invalidate(args[1])
+		c.writer.WriteString("+OK\r\n")
+	case "DEL":
+		delete(s.data, args[1])
+		s.invalidate(args[1])
+		c.writer.WriteString(":1\r\n")
+	case "PING":
+		c.writer.WriteString("+PONG\r\n")
+	default:
+		fmt.Fprintf(c.writer, "-ERR unknown command %s\r\n", args[0])
+	}
+}
+
+// invalidate sends the invalidation of a key to the connections tracking
+// it. The caller must hold the lock.
+func (s *trackingServer) invalidate(key string) {
+	for id := range s.tracked[key] {
+		if c, ok := s.conns[id]; ok {
+			c.writer.WriteString("*3\r\n")
+			writeBulk(c.writer, "message")
+			writeBulk(c.writer, invalidationChannel)
+			c.writer.WriteString("*1\r\n")
+			writeBulk(c.writer, key)
+			c.writer.Flush()
+		}
+	}
+	delete(s.tracked, key)
+}
+
+// set modifies a key without sending invalidations.
+func (s *trackingServer) set(key, value string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.data[key] = value
+}
+
+// disconnect closes the connections redirected to by tracking connections.
+func (s *trackingServer) disconnect() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, c := range s.conns {
+		if target, ok := s.conns[c.redirect]; ok {
+			target.conn.Close()
+		}
+	}
+}
+
+func (s *trackingServer) getCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.gets
+}
+
+// eventually polls cond for up to a second.
+func eventually(cond func() bool) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return true
+		}
+	}
+	return cond()
+}
+
+func TestClientSideCaching(t *testing.T) {
+	ctx := context.Background()
+	server := newTrackingServer(t)
+	client := redis.NewClient(&redis.Options{Addr: server.listener.Addr().String()})
+	defer client.Close()
+	a := NewUniversalAdapter(client, AdapterWithClientSideCaching(100, time.Minute))
+	defer a.(*Adapter).Close()
+	if a.(*Adapter).tracking == nil {
+		t.Fatalf("client-side cache not started")
+	}
+
+	get := func(want string) bool {
+		got, ok := a.Get(ctx, "foo")
+		return ok && string(got) == want
+	}
+
+	a.Set(ctx, "foo", []byte("value 1"), time.Now().Add(time.Minute))
+	if !get("value 1") || !get("value 1") {
+		t.Fatalf("redis.Get() missed value 1")
+	}
+	if gets := server.getCount(); gets != 1 {
+		t.Errorf("GET commands = %v, want 1 served from Redis", gets)
+	}
+
+	client.Set(ctx, "foo", "value 2", time.Minute)
+	if !eventually(func() bool { return get("value 2") }) {
+		t.Errorf("redis.Get() kept serving an invalidated response")
+	}
+
+	a.Release(ctx, "foo")
+	if _, ok := a.Get(ctx, "foo"); ok {
+		t.Errorf("redis.Get() hit a released response")
+	}
+
+	a.Set(ctx, "foo", []byte("value 3"), time.Now().Add(time.Minute))
+	if !get("value 3") {
+		t.Fatalf("redis.Get() missed value 3")
+	}
+	server.set("foo", "value 4")
+	server.disconnect()
+	if !eventually(func() bool { return get("value 4") }) {
+		t.Errorf("redis.Get() kept serving a response cached before reconnecting")
+	}
+}
+
+func TestClientSideCachingUnsupported(t *testing.T) {
+	cluster := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{":7000"}})
+	defer cluster.Close()
+	if a := NewUniversalAdapter(cluster, AdapterWithClientSideCaching(100, time.Minute)); a.(*Adapter).tracking != nil {
+		t.Errorf("client-side cache started with a cluster client")
+	}
+}
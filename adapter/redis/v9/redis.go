@@ -0,0 +1,172 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package redis is the Redis adapter for github.com/redis/go-redis/v9
+// clients. Responses are stored as plain Redis strings, as with the
+// NewUniversalAdapter adapter of adapter/redis, so that both can share keys
+// while migrating from go-redis v8.
+package redis
+
+import (
+	"context"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	"github.com/redis/go-redis/v9"
+)
+
+// tagPrefix prefixes the keys of the sets indexing keys by tag.
+const tagPrefix = "http-cache:tag:"
+
+// scanCount is the number of keys requested per SCAN iteration.
+const scanCount = 1000
+
+// tagScript adds a key to tag sets, extending their TTL to cover the key.
+var tagScript = redis.NewScript(`
+for _, tag in ipairs(KEYS) do
+	redis.call("SADD", tag, ARGV[1])
+	if redis.call("PTTL", tag) < tonumber(ARGV[2]) then
+		redis.call("PEXPIRE", tag, ARGV[2])
+	end
+end
+return 0
+`)
+
+// Adapter is the Redis adapter data structure, storing responses as plain
+// Redis strings expiring with their TTL.
+type Adapter struct {
+	client redis.UniversalClient
+}
+
+var (
+	_ cache.Adapter             = (*Adapter)(nil)
+	_ cache.TaggingAdapter      = (*Adapter)(nil)
+	_ cache.InvalidatingAdapter = (*Adapter)(nil)
+)
+
+// Get implements the cache Adapter interface Get method.
+func (a *Adapter) Get(ctx context.Context, key string) ([]byte, bool) {
+	response, err := a.client.Get(ctx, key).Bytes()
+	return response, err == nil
+}
+
+// Set implements the cache Adapter interface Set method. Responses already
+// past their expiration date release the stored one instead, and responses
+// without one don't expire.
+func (a *Adapter) Set(ctx context.Context, key string, response []byte, expiration time.Time) {
+	var ttl time.Duration
+	if !expiration.IsZero() {
+		ttl = time.Until(expiration)
+		if ttl <= 0 {
+			a.Release(ctx, key)
+			return
+		}
+		// TTLs are sent in milliseconds, rounded up so as not to expire
+		// early, nor never expire when under a millisecond.
+		ttl = ttl.Truncate(time.Millisecond) + time.Millisecond
+	}
+
+	a.client.Set(ctx, key, response, ttl)
+}
+
+// Ping implements the cache HealthChecker interface Ping method.
+func (a *Adapter) Ping(ctx context.Context) error {
+	return a.client.Ping(ctx).Err()
+}
+
+// Release implements the cache Adapter interface Release method.
+func (a *Adapter) Release(ctx context.Context, key string) {
+	a.client.Del(ctx, key)
+}
+
+// Tag implements the cache TaggingAdapter interface Tag method, indexing the
+// key in a set per tag.
+func (a *Adapter) Tag(ctx context.Context, key string, tags []string, expiration time.Time) error {
+	keys := make([]string, len(tags))
+	for i, tag := range tags {
+		keys[i] = tagPrefix + tag
+	}
+
+	return tagScript.Run(ctx, a.client, keys, key, time.Until(expiration).Milliseconds()).Err()
+}
+
+// InvalidateTag implements the cache TaggingAdapter interface InvalidateTag
+// method.
+func (a *Adapter) InvalidateTag(ctx context.Context, tag string) error {
+	keys, err := a.client.SMembers(ctx, tagPrefix+tag).Result()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := a.client.Del(ctx, key).Err(); err != nil {
+			return err
+		}
+	}
+
+	return a.client.Del(ctx, tagPrefix+tag).Err()
+}
+
+// InvalidatePrefix implements the cache InvalidatingAdapter interface
+// InvalidatePrefix method, scanning keys.
+func (a *Adapter) InvalidatePrefix(ctx context.Context, prefix string) error {
+	return a.InvalidatePattern(ctx, cache.EscapePattern(prefix)+"*")
+}
+
+// InvalidatePattern implements the cache InvalidatingAdapter interface
+// InvalidatePattern method, scanning keys, on every master with a cluster
+// client.
+func (a *Adapter) InvalidatePattern(ctx context.Context, pattern string) error {
+	if cluster, ok := a.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return a.scan(ctx, client, pattern)
+		})
+	}
+
+	return a.scan(ctx, a.client, pattern)
+}
+
+// scan releases the keys of a node matching a pattern.
+func (a *Adapter) scan(ctx context.Context, client redis.Cmdable, pattern string) error {
+	var cursor uint64
+	for {
+		keys, next, err := client.Scan(ctx, cursor, pattern, scanCount).Result()
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := a.client.Del(ctx, key).Err(); err != nil {
+				return err
+			}
+		}
+		if cursor = next; cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// NewAdapter initializes a Redis adapter issuing plain GET, SET PX and DEL
+// commands with a single node, sentinel or cluster client.
+func NewAdapter(client redis.UniversalClient) cache.Adapter {
+	return &Adapter{client: client}
+}
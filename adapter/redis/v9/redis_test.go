@@ -0,0 +1,123 @@
+package redis
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	"github.com/redis/go-redis/v9"
+)
+
+func newAdapter() (cache.Adapter, *redis.Client) {
+	client := redis.NewClient(&redis.Options{
+		Addr: ":6379",
+	})
+	return NewAdapter(client), client
+}
+
+func TestAdapter(t *testing.T) {
+	ctx := context.Background()
+	adapter, client := newAdapter()
+
+	adapter.Set(ctx, "/v9/foo", []byte("value 1"), time.Now().Add(1*time.Minute))
+	adapter.Set(ctx, "/v9/bar", []byte("value 2"), time.Time{})
+	adapter.Set(ctx, "/v9/baz", []byte("value 3"), time.Now().Add(-1*time.Minute))
+	for key, want := range map[string]string{
+		"/v9/foo": "value 1",
+		"/v9/bar": "value 2",
+		"/v9/baz": "",
+	} {
+		got, ok := adapter.Get(ctx, key)
+		if ok != (want != "") || string(got) != want {
+			t.Errorf("redis.Get(%v) = %q, %v, want %q", key, got, ok, want)
+		}
+	}
+	if ttl := client.PTTL(ctx, "/v9/foo").Val(); ttl <= 59*time.Second || ttl > time.Minute+time.Millisecond {
+		t.Errorf("PTTL() = %v, want about a minute", ttl)
+	}
+
+	adapter.Release(ctx, "/v9/foo")
+	adapter.Release(ctx, "/v9/bar")
+	for _, key := range []string{"/v9/foo", "/v9/bar"} {
+		if _, ok := adapter.Get(ctx, key); ok {
+			t.Errorf("redis.Release() kept %v", key)
+		}
+	}
+}
+
+func TestInvalidateTag(t *testing.T) {
+	ctx := context.Background()
+	adapter, _ := newAdapter()
+	tagger := adapter.(cache.TaggingAdapter)
+	exp := time.Now().Add(1 * time.Minute)
+	for key, tags := range map[string][]string{
+		"/v9/tagged-foo": {"v9-product-42", "v9-catalog"},
+		"/v9/tagged-bar": {"v9-catalog"},
+	} {
+		adapter.Set(ctx, key, []byte(key), exp)
+		if err := tagger.Tag(ctx, key, tags, exp); err != nil {
+			t.Fatalf("redis.Tag() error = %v", err)
+		}
+	}
+
+	if err := tagger.InvalidateTag(ctx, "v9-product-42"); err != nil {
+		t.Fatalf("redis.InvalidateTag() error = %v", err)
+	}
+	if _, ok := adapter.Get(ctx, "/v9/tagged-foo"); ok {
+		t.Errorf("redis.InvalidateTag() kept /v9/tagged-foo")
+	}
+	if _, ok := adapter.Get(ctx, "/v9/tagged-bar"); !ok {
+		t.Errorf("redis.InvalidateTag() released /v9/tagged-bar")
+	}
+	tagger.InvalidateTag(ctx, "v9-catalog")
+}
+
+func TestInvalidatePrefixAndPattern(t *testing.T) {
+	ctx := context.Background()
+	adapter, _ := newAdapter()
+	keys := []string{"/v9/products/1", "/v9/products/2", "/v9/users/1"}
+
+	tests := []struct {
+		name       string
+		invalidate func(a cache.InvalidatingAdapter) error
+		want       []string
+	}{
+		{
+			"invalidates prefix",
+			func(a cache.InvalidatingAdapter) error {
+				return a.InvalidatePrefix(ctx, "/v9/products/")
+			},
+			[]string{"/v9/users/1"},
+		},
+		{
+			"invalidates pattern",
+			func(a cache.InvalidatingAdapter) error {
+				return a.InvalidatePattern(ctx, "/v9/*/1")
+			},
+			[]string{"/v9/products/2"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range keys {
+				adapter.Set(ctx, key, []byte(key), time.Now().Add(1*time.Minute))
+			}
+			if err := tt.invalidate(adapter.(cache.InvalidatingAdapter)); err != nil {
+				t.Fatalf("invalidate error = %v", err)
+			}
+			var got []string
+			for _, key := range keys {
+				if _, ok := adapter.Get(ctx, key); ok {
+					got = append(got, key)
+				}
+				adapter.Release(ctx, key)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("remaining keys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
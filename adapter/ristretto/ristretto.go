@@ -0,0 +1,154 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package ristretto
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	"github.com/dgraph-io/ristretto"
+)
+
+const (
+	// DefaultMaxCost is the total size in bytes of the cached responses by
+	// default.
+	DefaultMaxCost = 64 << 20
+
+	// DefaultNumCounters is the number of keys whose access frequency is
+	// tracked by default, about ten times the number of responses expected
+	// to fit within DefaultMaxCost.
+	DefaultNumCounters = 1e6
+)
+
+// Adapter is the Ristretto adapter data structure, storing responses in a
+// Ristretto cache which admits them by cost, their size in bytes, and by
+// the estimated access frequency of their key.
+//
+// Ristretto buffers writes, so a response may not be returned by Get right
+// after Set, and may be dropped under contention.
+type Adapter struct {
+	store       *ristretto.Cache
+	maxCost     int64
+	numCounters int64
+}
+
+// AdapterOptions is used to set Adapter settings.
+type AdapterOptions func(a *Adapter) error
+
+var _ cache.Adapter = (*Adapter)(nil)
+
+// Get implements the cache Adapter interface Get method.
+func (a *Adapter) Get(ctx context.Context, key string) ([]byte, bool) {
+	if value, ok := a.store.Get(key); ok {
+		return value.([]byte), true
+	}
+
+	return nil, false
+}
+
+// Set implements the cache Adapter interface Set method. Responses already
+// past their expiration date release the stored one instead.
+func (a *Adapter) Set(ctx context.Context, key string, response []byte, expiration time.Time) {
+	var ttl time.Duration
+	if !expiration.IsZero() {
+		if ttl = time.Until(expiration); ttl <= 0 {
+			a.store.Del(key)
+			return
+		}
+	}
+
+	a.store.SetWithTTL(key, response, int64(len(response)), ttl)
+}
+
+// Release implements the cache Adapter interface Release method.
+func (a *Adapter) Release(ctx context.Context, key string) {
+	a.store.Del(key)
+}
+
+// Close stops the goroutines of the Ristretto cache. The adapter returned by
+// NewAdapter implements io.Closer.
+func (a *Adapter) Close() error {
+	a.store.Close()
+	return nil
+}
+
+// NewAdapter initializes a Ristretto adapter.
+func NewAdapter(opts ...AdapterOptions) (cache.Adapter, error) {
+	a := &Adapter{
+		maxCost:     DefaultMaxCost,
+		numCounters: DefaultNumCounters,
+	}
+
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return nil, err
+		}
+	}
+
+	store, err := ristretto.NewCache(&ristretto.Config{
+		NumCounters: a.numCounters,
+		MaxCost:     a.maxCost,
+		BufferItems: 64,
+		// Costs are response sizes, not including Ristretto's own overhead.
+		IgnoreInternalCost: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ristretto adapter: %w", err)
+	}
+	a.store = store
+
+	return a, nil
+}
+
+// AdapterWithMaxCost sets the maximum total size in bytes of the cached
+// responses, and defaults to DefaultMaxCost.
+func AdapterWithMaxCost(n int64) AdapterOptions {
+	return func(a *Adapter) error {
+		if n <= 0 {
+			return fmt.Errorf("ristretto adapter max cost %v is invalid", n)
+		}
+
+		a.maxCost = n
+
+		return nil
+	}
+}
+
+// AdapterWithNumCounters sets the number of keys whose access frequency is
+// tracked for admission, ideally ten times the number of responses
+// expected to fit within the max cost, and defaults to DefaultNumCounters.
+func AdapterWithNumCounters(n int64) AdapterOptions {
+	return func(a *Adapter) error {
+		if n <= 0 {
+			return fmt.Errorf("ristretto adapter num counters %v is invalid", n)
+		}
+
+		a.numCounters = n
+
+		return nil
+	}
+}
@@ -0,0 +1,58 @@
+package ristretto
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNewAdapter(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []AdapterOptions
+		wantErr bool
+	}{
+		{"returns new Adapter", nil, false},
+		{"sets options", []AdapterOptions{AdapterWithMaxCost(1 << 10), AdapterWithNumCounters(100)}, false},
+		{"returns max cost error", []AdapterOptions{AdapterWithMaxCost(0)}, true},
+		{"returns num counters error", []AdapterOptions{AdapterWithNumCounters(-1)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewAdapter(tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewAdapter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				a.(*Adapter).Close()
+			}
+		})
+	}
+}
+
+func TestAdapter(t *testing.T) {
+	a, _ := NewAdapter(AdapterWithMaxCost(1<<10), AdapterWithNumCounters(100))
+	defer a.(*Adapter).Close()
+	store := a.(*Adapter).store
+	ctx := context.Background()
+
+	a.Set(ctx, "foo", []byte("value 1"), time.Now().Add(time.Minute))
+	a.Set(ctx, "bar", []byte("value 2"), time.Time{})
+	store.Wait()
+	if got, ok := a.Get(ctx, "foo"); !ok || string(got) != "value 1" {
+		t.Errorf("ristretto.Get(foo) = %q, %v, want %q", got, ok, "value 1")
+	}
+	if got, ok := a.Get(ctx, "bar"); !ok || string(got) != "value 2" {
+		t.Errorf("ristretto.Get(bar) = %q, %v, want %q", got, ok, "value 2")
+	}
+
+	a.Set(ctx, "foo", []byte("value 3"), time.Now().Add(-time.Minute))
+	a.Release(ctx, "bar")
+	store.Wait()
+	if _, ok := a.Get(ctx, "foo"); ok {
+		t.Errorf("ristretto.Get(foo) hit after an expired Set")
+	}
+	if _, ok := a.Get(ctx, "bar"); ok {
+		t.Errorf("ristretto.Get(bar) hit after Release")
+	}
+}
@@ -0,0 +1,211 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tiered
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+)
+
+// DefaultL1TTL is how long responses are cached in the first tier by default.
+const DefaultL1TTL = 1 * time.Minute
+
+// Adapter is the tiered adapter data structure, reading from a fast first
+// tier, typically an in-process memory adapter, before falling back to a
+// shared second tier, such as Redis.
+//
+// Responses are written through to both tiers and backfilled into the first
+// tier on second tier hits. They are kept in the first tier for a shorter
+// TTL, bounding how long an instance serves a response released from the
+// second tier by another one, which requires a first tier adapter releasing
+// entries at their expiration date. An invalidation bus shortens that delay
+// to the time it takes to broadcast releases.
+type Adapter struct {
+	l1    cache.Adapter
+	l2    cache.Adapter
+	l1TTL time.Duration
+}
+
+// AdapterOptions is used to set Adapter settings.
+type AdapterOptions func(a *Adapter) error
+
+// NewAdapter initializes a tiered adapter with its first and second tiers.
+func NewAdapter(l1, l2 cache.Adapter, opts ...AdapterOptions) (cache.Adapter, error) {
+	if l1 == nil || l2 == nil {
+		return nil, errors.New("tiered adapter tiers can not be nil")
+	}
+
+	a := &Adapter{
+		l1:    l1,
+		l2:    l2,
+		l1TTL: DefaultL1TTL,
+	}
+
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+// AdapterWithL1TTL sets how long responses are cached in the first tier, at
+// most, and defaults to DefaultL1TTL.
+func AdapterWithL1TTL(ttl time.Duration) AdapterOptions {
+	return func(a *Adapter) error {
+		if ttl <= 0 {
+			return fmt.Errorf("tiered adapter l1 ttl %v is invalid", ttl)
+		}
+
+		a.l1TTL = ttl
+
+		return nil
+	}
+}
+
+// Get implements the cache Adapter interface Get method, backfilling the
+// first tier on second tier hits.
+func (a *Adapter) Get(ctx context.Context, key string) ([]byte, bool) {
+	if response, ok := a.l1.Get(ctx, key); ok {
+		return response, true
+	}
+
+	response, ok := a.l2.Get(ctx, key)
+	if ok {
+		a.l1.Set(ctx, key, response, time.Now().Add(a.l1TTL))
+	}
+
+	return response, ok
+}
+
+// Set implements the cache Adapter interface Set method, writing through to
+// both tiers.
+func (a *Adapter) Set(ctx context.Context, key string, response []byte, expiration time.Time) {
+	a.l2.Set(ctx, key, response, expiration)
+	a.l1.Set(ctx, key, response, a.l1Expiration(expiration))
+}
+
+// Release implements the cache Adapter interface Release method.
+func (a *Adapter) Release(ctx context.Context, key string) {
+	a.l2.Release(ctx, key)
+	a.l1.Release(ctx, key)
+}
+
+// Tag implements the cache.TaggingAdapter interface Tag method, tagging the
+// key in the tiers supporting it.
+func (a *Adapter) Tag(ctx context.Context, key string, tags []string, expiration time.Time) error {
+	return a.each(cache.ErrTaggingUnsupported, func(tier cache.Adapter) (bool, error) {
+		tagger, ok := tier.(cache.TaggingAdapter)
+		if !ok {
+			return false, nil
+		}
+		if tier == a.l1 {
+			return true, tagger.Tag(ctx, key, tags, a.l1Expiration(expiration))
+		}
+		return true, tagger.Tag(ctx, key, tags, expiration)
+	})
+}
+
+// InvalidateTag implements the cache.TaggingAdapter interface InvalidateTag
+// method. A first tier without tagging support keeps serving the responses
+// it holds until their first tier TTL elapses.
+func (a *Adapter) InvalidateTag(ctx context.Context, tag string) error {
+	return a.each(cache.ErrTaggingUnsupported, func(tier cache.Adapter) (bool, error) {
+		tagger, ok := tier.(cache.TaggingAdapter)
+		if !ok {
+			return false, nil
+		}
+		return true, tagger.InvalidateTag(ctx, tag)
+	})
+}
+
+// InvalidatePrefix implements the cache.InvalidatingAdapter interface
+// InvalidatePrefix method, invalidating the tiers supporting it.
+func (a *Adapter) InvalidatePrefix(ctx context.Context, prefix string) error {
+	return a.each(cache.ErrInvalidationUnsupported, func(tier cache.Adapter) (bool, error) {
+		invalidator, ok := tier.(cache.InvalidatingAdapter)
+		if !ok {
+			return false, nil
+		}
+		return true, invalidator.InvalidatePrefix(ctx, prefix)
+	})
+}
+
+// InvalidatePattern implements the cache.InvalidatingAdapter interface
+// InvalidatePattern method, invalidating the tiers supporting it.
+func (a *Adapter) InvalidatePattern(ctx context.Context, pattern string) error {
+	return a.each(cache.ErrInvalidationUnsupported, func(tier cache.Adapter) (bool, error) {
+		invalidator, ok := tier.(cache.InvalidatingAdapter)
+		if !ok {
+			return false, nil
+		}
+		return true, invalidator.InvalidatePattern(ctx, pattern)
+	})
+}
+
+// Ping implements the cache.HealthChecker interface Ping method, pinging the
+// tiers implementing it.
+func (a *Adapter) Ping(ctx context.Context) error {
+	for _, tier := range []cache.Adapter{a.l2, a.l1} {
+		if checker, ok := tier.(cache.HealthChecker); ok {
+			if err := checker.Ping(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// each calls fn with the second then the first tier, returning unsupported
+// when neither supports the operation, or the first error.
+func (a *Adapter) each(unsupported error, fn func(tier cache.Adapter) (bool, error)) error {
+	supported := false
+	for _, tier := range []cache.Adapter{a.l2, a.l1} {
+		ok, err := fn(tier)
+		if err != nil {
+			return err
+		}
+		supported = supported || ok
+	}
+	if !supported {
+		return unsupported
+	}
+
+	return nil
+}
+
+// l1Expiration caps an expiration date to the first tier TTL.
+func (a *Adapter) l1Expiration(expiration time.Time) time.Time {
+	if max := time.Now().Add(a.l1TTL); expiration.After(max) {
+		return max
+	}
+	return expiration
+}
@@ -0,0 +1,128 @@
+package tiered
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+)
+
+type adapterMock struct {
+	sync.Mutex
+	store       map[string][]byte
+	expirations map[string]time.Time
+}
+
+func newAdapterMock() *adapterMock {
+	return &adapterMock{store: map[string][]byte{}, expirations: map[string]time.Time{}}
+}
+
+func (a *adapterMock) Get(ctx context.Context, key string) ([]byte, bool) {
+	a.Lock()
+	defer a.Unlock()
+	b, ok := a.store[key]
+	return b, ok
+}
+
+func (a *adapterMock) Set(ctx context.Context, key string, response []byte, expiration time.Time) {
+	a.Lock()
+	defer a.Unlock()
+	a.store[key] = response
+	a.expirations[key] = expiration
+}
+
+func (a *adapterMock) Release(ctx context.Context, key string) {
+	a.Lock()
+	defer a.Unlock()
+	delete(a.store, key)
+	delete(a.expirations, key)
+}
+
+type invalidatingAdapterMock struct {
+	*adapterMock
+	prefixes []string
+}
+
+func (a *invalidatingAdapterMock) InvalidatePrefix(ctx context.Context, prefix string) error {
+	a.prefixes = append(a.prefixes, prefix)
+	return nil
+}
+
+func (a *invalidatingAdapterMock) InvalidatePattern(ctx context.Context, pattern string) error {
+	return nil
+}
+
+func TestNewAdapter(t *testing.T) {
+	tests := []struct {
+		name    string
+		l1      cache.Adapter
+		l2      cache.Adapter
+		opts    []AdapterOptions
+		wantErr bool
+	}{
+		{"returns new Adapter", newAdapterMock(), newAdapterMock(), []AdapterOptions{AdapterWithL1TTL(10 * time.Second)}, false},
+		{"returns error on nil tier", newAdapterMock(), nil, nil, true},
+		{"returns error on invalid l1 ttl", newAdapterMock(), newAdapterMock(), []AdapterOptions{AdapterWithL1TTL(0)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAdapter(tt.l1, tt.l2, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewAdapter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAdapter(t *testing.T) {
+	l1, l2 := newAdapterMock(), newAdapterMock()
+	a, _ := NewAdapter(l1, l2, AdapterWithL1TTL(10*time.Second))
+	ctx := context.Background()
+	value := []byte("value 1")
+
+	a.Set(ctx, "foo", value, time.Now().Add(1*time.Hour))
+	if !reflect.DeepEqual(l1.store["foo"], value) || !reflect.DeepEqual(l2.store["foo"], value) {
+		t.Errorf("tiered.Set() did not write through to both tiers")
+	}
+	if l1.expirations["foo"].After(time.Now().Add(10*time.Second)) || !l2.expirations["foo"].After(time.Now().Add(59*time.Minute)) {
+		t.Errorf("tiered.Set() expirations = %v, %v", l1.expirations["foo"], l2.expirations["foo"])
+	}
+
+	l1.Release(ctx, "foo")
+	if b, ok := a.Get(ctx, "foo"); !ok || !reflect.DeepEqual(b, value) {
+		t.Errorf("tiered.Get() = %s, %v, want second tier response", b, ok)
+	}
+	if _, ok := l1.store["foo"]; !ok {
+		t.Errorf("tiered.Get() did not backfill the first tier")
+	}
+
+	l2.Release(ctx, "foo")
+	if _, ok := a.Get(ctx, "foo"); !ok {
+		t.Errorf("tiered.Get() did not read from the first tier")
+	}
+
+	a.Release(ctx, "foo")
+	if _, ok := a.Get(ctx, "foo"); ok {
+		t.Errorf("tiered.Release() did not release both tiers")
+	}
+}
+
+func TestAdapterInvalidation(t *testing.T) {
+	l1 := newAdapterMock()
+	l2 := &invalidatingAdapterMock{adapterMock: newAdapterMock()}
+	a, _ := NewAdapter(l1, l2)
+	ctx := context.Background()
+
+	if err := a.(cache.InvalidatingAdapter).InvalidatePrefix(ctx, "/api/"); err != nil {
+		t.Errorf("tiered.InvalidatePrefix() error = %v", err)
+	}
+	if !reflect.DeepEqual(l2.prefixes, []string{"/api/"}) {
+		t.Errorf("tiered.InvalidatePrefix() invalidated %v", l2.prefixes)
+	}
+	if err := a.(cache.TaggingAdapter).InvalidateTag(ctx, "catalog"); err != cache.ErrTaggingUnsupported {
+		t.Errorf("tiered.InvalidateTag() error = %v, want %v", err, cache.ErrTaggingUnsupported)
+	}
+}
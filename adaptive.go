@@ -0,0 +1,158 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// adaptiveCapacity is the number of keys whose request frequency is
+	// tracked by the adaptive TTL mode.
+	adaptiveCapacity = 10000
+
+	// adaptiveHotRequests is the number of requests during the lifetime of
+	// a response from which its key is hot.
+	adaptiveHotRequests = 10
+)
+
+// adaptiveTTL scales the TTL of each key by its request frequency, within
+// bounds, forgetting the least recently observed keys beyond its capacity.
+type adaptiveTTL struct {
+	min, max time.Duration
+	capacity int
+	mutex    sync.Mutex
+	keys     map[string]*list.Element
+	// recency lists keys from the most to the least recently observed.
+	recency *list.List
+}
+
+// adaptiveKey is the request frequency of a key.
+type adaptiveKey struct {
+	key string
+	// requests is the number of requests since the key was last cached.
+	requests int
+	// scale is the factor applied to the TTL of the key.
+	scale float64
+	// cached is whether the key was cached once already.
+	cached bool
+}
+
+// WithAdaptiveTTL is an experimental mode scaling the TTL of each response
+// by the request frequency of its key, between min and max: a key requested
+// at least 10 times over the lifetime of its previous response has its TTL
+// doubled, while one not requested again has it halved. The first response
+// of a key, without history yet, keeps its TTL as is. The frequency of up
+// to 10000 keys is tracked, in memory, so each instance adapts on its own.
+func WithAdaptiveTTL(min, max time.Duration) ClientOption {
+	return func(c *Client) error {
+		if int64(min) < 1 || max < min {
+			return fmt.Errorf("cache client adaptive ttl bounds %v and %v are invalid", min, max)
+		}
+
+		c.adaptive = newAdaptiveTTL(min, max, adaptiveCapacity)
+
+		return nil
+	}
+}
+
+// newAdaptiveTTL returns an adaptive TTL tracking up to capacity keys.
+func newAdaptiveTTL(min, max time.Duration, capacity int) *adaptiveTTL {
+	return &adaptiveTTL{
+		min:      min,
+		max:      max,
+		capacity: capacity,
+		keys:     map[string]*list.Element{},
+		recency:  list.New(),
+	}
+}
+
+// touch counts a request for a key.
+func (a *adaptiveTTL) touch(key string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	e, ok := a.keys[key]
+	if ok {
+		a.recency.MoveToFront(e)
+	} else {
+		if len(a.keys) >= a.capacity {
+			evicted := a.recency.Remove(a.recency.Back()).(*adaptiveKey)
+			delete(a.keys, evicted.key)
+		}
+		e = a.recency.PushFront(&adaptiveKey{key: key, scale: 1})
+		a.keys[key] = e
+	}
+	e.Value.(*adaptiveKey).requests++
+}
+
+// ttl returns the TTL of a response cached under a key, scaling the base
+// TTL by the requests of the key since it was last cached. The base TTL of
+// a key without such history is returned as is.
+func (a *adaptiveTTL) ttl(key string, base time.Duration) time.Duration {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	e, ok := a.keys[key]
+	if !ok {
+		return base
+	}
+	a.recency.MoveToFront(e)
+	k := e.Value.(*adaptiveKey)
+	requests := k.requests
+	k.requests = 0
+	if !k.cached {
+		k.cached = true
+		return base
+	}
+
+	switch {
+	case requests >= adaptiveHotRequests:
+		k.scale *= 2
+	case requests <= 1:
+		k.scale /= 2
+	}
+	// The scale is kept within the bounds, so that a key changing
+	// temperature adapts right away.
+	if max := float64(a.max) / float64(base); k.scale > max {
+		k.scale = max
+	}
+	if min := float64(a.min) / float64(base); k.scale < min {
+		k.scale = min
+	}
+
+	ttl := time.Duration(float64(base) * k.scale)
+	if ttl > a.max {
+		return a.max
+	}
+	if ttl < a.min {
+		return a.min
+	}
+
+	return ttl
+}
@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveTTL(t *testing.T) {
+	tests := []struct {
+		name     string
+		requests []int
+		want     []time.Duration
+	}{
+		{"keeps the first ttl", []int{1}, []time.Duration{time.Minute}},
+		{"extends hot keys up to max", []int{1, 10, 20, 50}, []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute}},
+		{"shortens cold keys down to min", []int{1, 1, 1, 0}, []time.Duration{time.Minute, 30 * time.Second, 15 * time.Second, 10 * time.Second}},
+		{"keeps warm keys", []int{1, 5, 5}, []time.Duration{time.Minute, time.Minute, time.Minute}},
+		{"adapts right away past the bounds", []int{1, 10, 10, 10, 1}, []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 150 * time.Second}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAdaptiveTTL(10*time.Second, 5*time.Minute, adaptiveCapacity)
+			for i, requests := range tt.requests {
+				for j := 0; j < requests; j++ {
+					a.touch("http://foo.bar/test-1")
+				}
+				if got := a.ttl("http://foo.bar/test-1", time.Minute); got != tt.want[i] {
+					t.Errorf("adaptiveTTL.ttl() #%d = %v, want %v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAdaptiveTTLWithoutHistory(t *testing.T) {
+	tests := []struct {
+		name    string
+		touched bool
+	}{
+		{"untracked key", false},
+		{"first seen key", true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAdaptiveTTL(time.Minute, 5*time.Minute, adaptiveCapacity)
+			if tt.touched {
+				a.touch("http://foo.bar/test-1")
+			}
+			if got := a.ttl("http://foo.bar/test-1", 5*time.Second); got != 5*time.Second {
+				t.Errorf("adaptiveTTL.ttl() = %v, want %v", got, 5*time.Second)
+			}
+		})
+	}
+}
+
+func TestAdaptiveTTLEviction(t *testing.T) {
+	a := newAdaptiveTTL(10*time.Second, 5*time.Minute, 3)
+	a.touch("hot")
+	a.ttl("hot", time.Minute)
+	for i := 0; i < 10; i++ {
+		// The hot key keeps being requested while cold keys come and go.
+		a.touch("hot")
+		a.touch(fmt.Sprintf("cold-%d", i))
+	}
+
+	if _, ok := a.keys["hot"]; !ok {
+		t.Fatal("hot key evicted, want the least recently observed keys evicted")
+	}
+	if len(a.keys) != 3 {
+		t.Errorf("tracked keys = %d, want 3", len(a.keys))
+	}
+	if got := a.ttl("hot", time.Minute); got != 2*time.Minute {
+		t.Errorf("adaptiveTTL.ttl(hot) = %v, want %v", got, 2*time.Minute)
+	}
+}
+
+func TestMiddlewareAdaptiveTTL(t *testing.T) {
+	adapter := &adapterMock{store: map[string][]byte{}}
+	client, err := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithRefreshKey("rk"),
+		WithAdaptiveTTL(10*time.Second, 10*time.Minute),
+	)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("value 1"))
+	}))
+	serve := func(url string) {
+		r, _ := http.NewRequest(http.MethodGet, url, nil)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	serve("http://foo.bar/test-1")
+	for i := 0; i < 20; i++ {
+		serve("http://foo.bar/test-1")
+	}
+	serve("http://foo.bar/test-1?rk=true")
+
+	ttl := time.Until(BytesToResponse(adapter.store["http://foo.bar/test-1"]).Expiration)
+	if ttl <= 119*time.Second || ttl > 2*time.Minute {
+		t.Errorf("hot response ttl = %v, want about 2m", ttl)
+	}
+
+	for _, bounds := range [][2]time.Duration{{0, time.Minute}, {time.Minute, time.Second}} {
+		if _, err := NewClient(WithAdapter(adapter), WithTTL(1*time.Minute), WithAdaptiveTTL(bounds[0], bounds[1])); err == nil {
+			t.Errorf("NewClient() error = nil, want an error for bounds %v", bounds)
+		}
+	}
+}
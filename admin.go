@@ -0,0 +1,158 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// adminEntry is the JSON representation of a cached response served by the
+// admin handler.
+type adminEntry struct {
+	Key        string       `json:"key"`
+	StatusCode int          `json:"status_code,omitempty"`
+	Header     http.Header  `json:"header,omitempty"`
+	Size       int          `json:"size"`
+	Expiration time.Time    `json:"expiration"`
+	StoredAt   time.Time    `json:"stored_at"`
+	Vary       []string     `json:"vary,omitempty"`
+	Request    *RequestInfo `json:"request,omitempty"`
+}
+
+// AdminHandler returns an HTTP handler to manage the cache, meant to be
+// mounted under a path of an internal server with http.StripPrefix. Requests
+// must be allowed by the function set with WithAdminAuthorizer, all being
+// denied otherwise. It serves the following endpoints:
+//
+//	GET /entry?key=KEY          shows the response cached under a key
+//	DELETE /entry?key=KEY       releases the response cached under a key
+//	POST /invalidate?prefix=P   releases responses by key prefix
+//	POST /invalidate?pattern=P  releases responses by key pattern
+//	POST /invalidate?tag=T      releases responses by tag
+//	GET /stats                  shows the client stats
+//	POST /flush                 releases all the responses of the cache version
+func (c *Client) AdminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.adminAuthorizer == nil || !c.adminAuthorizer(r) {
+			adminError(w, http.StatusForbidden, errors.New("forbidden"))
+			return
+		}
+
+		switch route := strings.Trim(r.URL.Path, "/"); {
+		case route == "entry" && r.Method == http.MethodGet:
+			c.adminGetEntry(w, r)
+		case route == "entry" && r.Method == http.MethodDelete:
+			key := r.URL.Query().Get("key")
+			if key == "" {
+				adminError(w, http.StatusBadRequest, errors.New("key is required"))
+				return
+			}
+			c.invalidate(r, key)
+			w.WriteHeader(http.StatusNoContent)
+		case route == "invalidate" && r.Method == http.MethodPost:
+			c.adminInvalidate(w, r)
+		case route == "stats" && r.Method == http.MethodGet:
+			adminJSON(w, http.StatusOK, c.Stats())
+		case route == "flush" && r.Method == http.MethodPost:
+			adminResult(w, c.InvalidatePrefix(r.Context(), ""))
+		case route == "entry" || route == "invalidate" || route == "stats" || route == "flush":
+			adminError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		default:
+			adminError(w, http.StatusNotFound, errors.New("not found"))
+		}
+	})
+}
+
+func (c *Client) adminGetEntry(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		adminError(w, http.StatusBadRequest, errors.New("key is required"))
+		return
+	}
+
+	req := r.Clone(r.Context())
+	req.Header = http.Header{}
+	b, ok := c.get(req, key)
+	if !ok {
+		adminError(w, http.StatusNotFound, errors.New("entry not found"))
+		return
+	}
+	response, ok := c.decode(req, key, b)
+	if !ok {
+		adminError(w, http.StatusNotFound, errors.New("entry not found"))
+		return
+	}
+
+	adminJSON(w, http.StatusOK, adminEntry{
+		Key:        key,
+		StatusCode: response.StatusCode,
+		Header:     response.Header,
+		Size:       len(response.Value),
+		Expiration: response.Expiration,
+		StoredAt:   response.StoredAt,
+		Vary:       response.Vary,
+		Request:    response.Request,
+	})
+}
+
+func (c *Client) adminInvalidate(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	switch {
+	case params.Get("prefix") != "":
+		adminResult(w, c.InvalidatePrefix(r.Context(), params.Get("prefix")))
+	case params.Get("pattern") != "":
+		adminResult(w, c.InvalidatePattern(r.Context(), params.Get("pattern")))
+	case params.Get("tag") != "":
+		adminResult(w, c.InvalidateTag(r.Context(), params.Get("tag")))
+	default:
+		adminError(w, http.StatusBadRequest, errors.New("prefix, pattern or tag is required"))
+	}
+}
+
+// adminResult writes the outcome of an invalidation.
+func adminResult(w http.ResponseWriter, err error) {
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, ErrTaggingUnsupported), errors.Is(err, ErrInvalidationUnsupported):
+		adminError(w, http.StatusNotImplemented, err)
+	default:
+		adminError(w, http.StatusInternalServerError, err)
+	}
+}
+
+func adminError(w http.ResponseWriter, statusCode int, err error) {
+	adminJSON(w, statusCode, map[string]string{"error": err.Error()})
+}
+
+func adminJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
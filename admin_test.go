@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAdminHandler(t *testing.T) {
+	allow := func(r *http.Request) bool { return r.Header.Get("Authorization") == "Bearer admin" }
+
+	tests := []struct {
+		name         string
+		adapter      Adapter
+		method       string
+		target       string
+		authorized   bool
+		wantCode     int
+		wantBody     map[string]interface{}
+		wantStore    int
+		wantPrefixes []string
+		wantPatterns []string
+	}{
+		{"denies unauthorized", nil, http.MethodGet, "/stats", false, http.StatusForbidden, map[string]interface{}{"error": "forbidden"}, 1, nil, nil},
+		{"shows stats", nil, http.MethodGet, "/stats", true, http.StatusOK, map[string]interface{}{"hits": 0.0, "misses": 0.0, "hit_ratio": 0.0, "stores": 0.0, "stored_bytes": 0.0, "keys": -1.0, "dropped_writes": 0.0}, 1, nil, nil},
+		{"shows entry", nil, http.MethodGet, "/entry?key=http://foo.bar/test-1", true, http.StatusOK, map[string]interface{}{
+			"key": "http://foo.bar/test-1", "status_code": 200.0, "size": 7.0,
+			"expiration": "2030-01-01T00:00:00Z", "stored_at": "2029-01-01T00:00:00Z",
+		}, 1, nil, nil},
+		{"entry not found", nil, http.MethodGet, "/entry?key=http://foo.bar/test-2", true, http.StatusNotFound, map[string]interface{}{"error": "entry not found"}, 1, nil, nil},
+		{"entry requires key", nil, http.MethodGet, "/entry", true, http.StatusBadRequest, map[string]interface{}{"error": "key is required"}, 1, nil, nil},
+		{"deletes entry", nil, http.MethodDelete, "/entry?key=http://foo.bar/test-1", true, http.StatusNoContent, nil, 0, nil, nil},
+		{"invalidates prefix", nil, http.MethodPost, "/invalidate?prefix=/api/", true, http.StatusNoContent, nil, 1, []string{"/api/"}, nil},
+		{"invalidates pattern", nil, http.MethodPost, "/invalidate?pattern=/api/*", true, http.StatusNoContent, nil, 1, nil, []string{"/api/*"}},
+		{"invalidate requires target", nil, http.MethodPost, "/invalidate", true, http.StatusBadRequest, map[string]interface{}{"error": "prefix, pattern or tag is required"}, 1, nil, nil},
+		{"tagging unsupported", nil, http.MethodPost, "/invalidate?tag=catalog", true, http.StatusNotImplemented, map[string]interface{}{"error": ErrTaggingUnsupported.Error()}, 1, nil, nil},
+		{"flushes", nil, http.MethodPost, "/flush", true, http.StatusNoContent, nil, 1, []string{""}, nil},
+		{"flush unsupported", &adapterMock{}, http.MethodPost, "/flush", true, http.StatusNotImplemented, map[string]interface{}{"error": ErrInvalidationUnsupported.Error()}, 1, nil, nil},
+		{"method not allowed", nil, http.MethodPut, "/stats", true, http.StatusMethodNotAllowed, map[string]interface{}{"error": "method not allowed"}, 1, nil, nil},
+		{"not found", nil, http.MethodGet, "/unknown", true, http.StatusNotFound, map[string]interface{}{"error": "not found"}, 1, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := map[string][]byte{
+				"http://foo.bar/test-1": Response{
+					Value:      []byte("value 1"),
+					StatusCode: http.StatusOK,
+					Expiration: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+					StoredAt:   time.Date(2029, 1, 1, 0, 0, 0, 0, time.UTC),
+				}.Bytes(),
+			}
+			mock := &invalidatingAdapterMock{adapterMock: adapterMock{store: store}}
+			adapter := tt.adapter
+			if adapter == nil {
+				adapter = mock
+			} else {
+				adapter.(*adapterMock).store = store
+			}
+			client, _ := NewClient(
+				WithAdapter(adapter),
+				WithTTL(1*time.Minute),
+				WithAdminAuthorizer(allow),
+			)
+
+			r, _ := http.NewRequest(tt.method, tt.target, nil)
+			if tt.authorized {
+				r.Header.Set("Authorization", "Bearer admin")
+			}
+			w := httptest.NewRecorder()
+			client.AdminHandler().ServeHTTP(w, r)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("*Client.AdminHandler() code = %v, want %v", w.Code, tt.wantCode)
+			}
+			var body map[string]interface{}
+			json.Unmarshal(w.Body.Bytes(), &body)
+			if !reflect.DeepEqual(body, tt.wantBody) {
+				t.Errorf("*Client.AdminHandler() body = %v, want %v", body, tt.wantBody)
+			}
+			if len(store) != tt.wantStore {
+				t.Errorf("*Client.AdminHandler() store length = %v, want %v", len(store), tt.wantStore)
+			}
+			if !reflect.DeepEqual(mock.prefixes, tt.wantPrefixes) || !reflect.DeepEqual(mock.patterns, tt.wantPatterns) {
+				t.Errorf("*Client.AdminHandler() invalidated %v %v, want %v %v", mock.prefixes, mock.patterns, tt.wantPrefixes, tt.wantPatterns)
+			}
+		})
+	}
+}
+
+func TestAdminHandlerDeniedByDefault(t *testing.T) {
+	client, _ := NewClient(WithAdapter(&adapterMock{}), WithTTL(1*time.Minute))
+	r, _ := http.NewRequest(http.MethodGet, "/stats", nil)
+	w := httptest.NewRecorder()
+	client.AdminHandler().ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("*Client.AdminHandler() code = %v, want %v", w.Code, http.StatusForbidden)
+	}
+}
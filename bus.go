@@ -0,0 +1,130 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// InvalidationKind is the kind of an Invalidation.
+type InvalidationKind string
+
+const (
+	// InvalidationKey releases a key.
+	InvalidationKey InvalidationKind = "key"
+
+	// InvalidationTag releases the keys associated with a tag.
+	InvalidationTag InvalidationKind = "tag"
+
+	// InvalidationPrefix releases the keys starting with a prefix.
+	InvalidationPrefix InvalidationKind = "prefix"
+
+	// InvalidationPattern releases the keys matching a glob pattern.
+	InvalidationPattern InvalidationKind = "pattern"
+)
+
+// Invalidation is an invalidation event broadcast between client instances
+// sharing an InvalidationBus. Values are cache keys, tags, prefixes or
+// patterns as passed to the client, before the cache version is applied.
+type Invalidation struct {
+	Source string           `json:"source"`
+	Kind   InvalidationKind `json:"kind"`
+	Value  string           `json:"value"`
+}
+
+// InvalidationBus broadcasts invalidations between client instances, so
+// that a response refreshed, purged or invalidated on one instance is also
+// released from the local adapters of the others.
+type InvalidationBus interface {
+	// Publish broadcasts an invalidation to every subscriber.
+	Publish(ctx context.Context, invalidation Invalidation) error
+
+	// Subscribe calls fn with each invalidation published until ctx is
+	// done, returning once the subscription is active.
+	Subscribe(ctx context.Context, fn func(Invalidation)) error
+}
+
+// subscribe subscribes the client to its invalidation bus, identifying it
+// to ignore its own invalidations.
+func (c *Client) subscribe() error {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Errorf("cache client invalidation bus id: %w", err)
+	}
+	c.busID = hex.EncodeToString(id)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := c.bus.Subscribe(ctx, c.receive); err != nil {
+		cancel()
+		return fmt.Errorf("cache client invalidation bus subscribe: %w", err)
+	}
+	c.busCancel = cancel
+
+	return nil
+}
+
+// publish broadcasts an invalidation applied locally, reporting failures to
+// the OnInvalidationError hook.
+func (c *Client) publish(ctx context.Context, kind InvalidationKind, value string) {
+	if c.bus == nil {
+		return
+	}
+
+	invalidation := Invalidation{Source: c.busID, Kind: kind, Value: value}
+	if err := c.bus.Publish(ctx, invalidation); err != nil {
+		c.hooks.invalidationError(invalidation, fmt.Errorf("invalidation bus publish: %w", err))
+	}
+}
+
+// receive applies an invalidation published by another instance to the
+// local adapter.
+func (c *Client) receive(invalidation Invalidation) {
+	if invalidation.Source == c.busID {
+		return
+	}
+
+	ctx := context.Background()
+	var err error
+	switch invalidation.Kind {
+	case InvalidationKey:
+		err = c.call(ctx, "release", func(ctx context.Context) error {
+			return c.adapter.Release(ctx, c.adapterKey(invalidation.Value))
+		})
+	case InvalidationTag:
+		err = c.invalidateTag(ctx, invalidation.Value)
+	case InvalidationPrefix:
+		err = c.invalidatePrefix(ctx, invalidation.Value)
+	case InvalidationPattern:
+		err = c.invalidatePattern(ctx, invalidation.Value)
+	default:
+		err = fmt.Errorf("invalidation kind %q is unknown", invalidation.Kind)
+	}
+	if err != nil {
+		c.hooks.invalidationError(invalidation, err)
+	}
+}
@@ -0,0 +1,102 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package nats
+
+import (
+	"context"
+	"encoding/json"
+
+	cache "github.com/cludden/http-cache"
+	"github.com/nats-io/nats.go"
+)
+
+// DefaultSubject is the subject invalidations are broadcast on by default.
+const DefaultSubject = "http-cache.invalidations"
+
+// Bus is the NATS invalidation bus.
+type Bus struct {
+	conn    *nats.Conn
+	subject string
+}
+
+// BusOptions is used to set Bus settings.
+type BusOptions func(b *Bus)
+
+// NewBus initializes a NATS invalidation bus.
+func NewBus(conn *nats.Conn, opts ...BusOptions) cache.InvalidationBus {
+	b := &Bus{
+		conn:    conn,
+		subject: DefaultSubject,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+// BusWithSubject sets the subject invalidations are broadcast on, separating
+// the caches of different services sharing a NATS server.
+func BusWithSubject(subject string) BusOptions {
+	return func(b *Bus) {
+		b.subject = subject
+	}
+}
+
+// Publish implements the cache.InvalidationBus interface Publish method.
+func (b *Bus) Publish(ctx context.Context, invalidation cache.Invalidation) error {
+	data, err := json.Marshal(invalidation)
+	if err != nil {
+		return err
+	}
+
+	return b.conn.Publish(b.subject, data)
+}
+
+// Subscribe implements the cache.InvalidationBus interface Subscribe method.
+// Malformed messages are ignored.
+func (b *Bus) Subscribe(ctx context.Context, fn func(cache.Invalidation)) error {
+	sub, err := b.conn.Subscribe(b.subject, func(msg *nats.Msg) {
+		var invalidation cache.Invalidation
+		if err := json.Unmarshal(msg.Data, &invalidation); err == nil {
+			fn(invalidation)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if err := b.conn.Flush(); err != nil {
+		sub.Unsubscribe()
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		sub.Unsubscribe()
+	}()
+
+	return nil
+}
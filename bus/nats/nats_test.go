@@ -0,0 +1,63 @@
+package nats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestBus(t *testing.T) {
+	s, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1})
+	if err != nil {
+		t.Fatalf("server.NewServer() error = %v", err)
+	}
+	go s.Start()
+	defer s.Shutdown()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatalf("nats server is not ready")
+	}
+	conn, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatalf("nats.Connect() error = %v", err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		name string
+		opts []BusOptions
+	}{
+		{"default subject", nil},
+		{"custom subject", []BusOptions{BusWithSubject("http-cache.test")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := NewBus(conn, tt.opts...)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			received := make(chan cache.Invalidation, 1)
+			if err := bus.Subscribe(ctx, func(invalidation cache.Invalidation) {
+				received <- invalidation
+			}); err != nil {
+				t.Fatalf("Bus.Subscribe() error = %v", err)
+			}
+
+			want := cache.Invalidation{Source: "a", Kind: cache.InvalidationKey, Value: "http://foo.bar/test-1"}
+			if err := bus.Publish(context.Background(), want); err != nil {
+				t.Fatalf("Bus.Publish() error = %v", err)
+			}
+			select {
+			case got := <-received:
+				if got != want {
+					t.Errorf("Bus.Subscribe() received %v, want %v", got, want)
+				}
+			case <-time.After(1 * time.Second):
+				t.Errorf("Bus.Subscribe() received nothing")
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package redis
+
+import (
+	"context"
+	"encoding/json"
+
+	cache "github.com/cludden/http-cache"
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// DefaultChannel is the Pub/Sub channel invalidations are broadcast on by
+// default.
+const DefaultChannel = "http-cache:invalidations"
+
+// Bus is the Redis Pub/Sub invalidation bus.
+type Bus struct {
+	client  goredis.UniversalClient
+	channel string
+}
+
+// BusOptions is used to set Bus settings.
+type BusOptions func(b *Bus)
+
+// NewBus initializes a Redis Pub/Sub invalidation bus.
+func NewBus(client goredis.UniversalClient, opts ...BusOptions) cache.InvalidationBus {
+	b := &Bus{
+		client:  client,
+		channel: DefaultChannel,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+// BusWithChannel sets the Pub/Sub channel invalidations are broadcast on,
+// separating the caches of different services sharing a Redis server.
+func BusWithChannel(channel string) BusOptions {
+	return func(b *Bus) {
+		b.channel = channel
+	}
+}
+
+// Publish implements the cache.InvalidationBus interface Publish method.
+func (b *Bus) Publish(ctx context.Context, invalidation cache.Invalidation) error {
+	data, err := json.Marshal(invalidation)
+	if err != nil {
+		return err
+	}
+
+	return b.client.Publish(ctx, b.channel, data).Err()
+}
+
+// Subscribe implements the cache.InvalidationBus interface Subscribe method.
+// Malformed messages are ignored.
+func (b *Bus) Subscribe(ctx context.Context, fn func(cache.Invalidation)) error {
+	sub := b.client.Subscribe(ctx, b.channel)
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return err
+	}
+
+	go func() {
+		defer sub.Close()
+		messages := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				var invalidation cache.Invalidation
+				if err := json.Unmarshal([]byte(msg.Payload), &invalidation); err == nil {
+					fn(invalidation)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	goredis "github.com/go-redis/redis/v8"
+)
+
+func TestBus(t *testing.T) {
+	client := goredis.NewClient(&goredis.Options{Addr: ":6379"})
+	defer client.Close()
+
+	tests := []struct {
+		name string
+		opts []BusOptions
+	}{
+		{"default channel", nil},
+		{"custom channel", []BusOptions{BusWithChannel("http-cache:test")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := NewBus(client, tt.opts...)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			received := make(chan cache.Invalidation, 1)
+			if err := bus.Subscribe(ctx, func(invalidation cache.Invalidation) {
+				received <- invalidation
+			}); err != nil {
+				t.Fatalf("Bus.Subscribe() error = %v", err)
+			}
+
+			want := cache.Invalidation{Source: "a", Kind: cache.InvalidationTag, Value: "catalog"}
+			if err := bus.Publish(context.Background(), want); err != nil {
+				t.Fatalf("Bus.Publish() error = %v", err)
+			}
+			select {
+			case got := <-received:
+				if got != want {
+					t.Errorf("Bus.Subscribe() received %v, want %v", got, want)
+				}
+			case <-time.After(1 * time.Second):
+				t.Errorf("Bus.Subscribe() received nothing")
+			}
+		})
+	}
+}
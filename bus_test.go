@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type busMock struct {
+	sync.Mutex
+	subscribers []func(Invalidation)
+	published   []Invalidation
+}
+
+func (b *busMock) Publish(ctx context.Context, invalidation Invalidation) error {
+	b.Lock()
+	b.published = append(b.published, invalidation)
+	subscribers := b.subscribers
+	b.Unlock()
+	for _, fn := range subscribers {
+		fn(invalidation)
+	}
+	return nil
+}
+
+func (b *busMock) Subscribe(ctx context.Context, fn func(Invalidation)) error {
+	b.Lock()
+	defer b.Unlock()
+	b.subscribers = append(b.subscribers, fn)
+	return nil
+}
+
+type failingBus struct{}
+
+func (failingBus) Publish(ctx context.Context, invalidation Invalidation) error {
+	return errors.New("publish error")
+}
+
+func (failingBus) Subscribe(ctx context.Context, fn func(Invalidation)) error {
+	return errors.New("subscribe error")
+}
+
+func TestInvalidationBus(t *testing.T) {
+	bus := &busMock{}
+	var adapters []*invalidatingAdapterMock
+	var clients []*Client
+	var errs []error
+	for i := 0; i < 2; i++ {
+		adapter := &invalidatingAdapterMock{adapterMock: adapterMock{store: map[string][]byte{}}}
+		client, err := NewClient(
+			WithAdapter(adapter),
+			WithTTL(1*time.Minute),
+			WithCacheVersion("v1"),
+			WithRefreshKey("refresh"),
+			WithInvalidationBus(bus),
+			WithHooks(Hooks{OnInvalidationError: func(invalidation Invalidation, err error) {
+				errs = append(errs, err)
+			}}),
+		)
+		if err != nil {
+			t.Fatalf("NewClient() error = %v", err)
+		}
+		defer client.Close()
+		adapters = append(adapters, adapter)
+		clients = append(clients, client)
+	}
+	handler := clients[0].Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("value"))
+	}))
+
+	adapters[1].store["v1:http://foo.bar/test-1"] = Response{Value: []byte("value")}.Bytes()
+	r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1?refresh=true", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if _, ok := adapters[1].store["v1:http://foo.bar/test-1"]; ok {
+		t.Errorf("refresh was not applied to other instance")
+	}
+	if _, ok := adapters[0].store["v1:http://foo.bar/test-1"]; !ok {
+		t.Errorf("refresh released the refreshed response")
+	}
+
+	clients[0].InvalidatePrefix(context.Background(), "/api/")
+	clients[1].InvalidatePattern(context.Background(), "/api/*")
+	for i, adapter := range adapters {
+		if !reflect.DeepEqual(adapter.prefixes, []string{"v1:/api/"}) || !reflect.DeepEqual(adapter.patterns, []string{`v1:/api/*`}) {
+			t.Errorf("adapter %v invalidated %v %v, want each once", i, adapter.prefixes, adapter.patterns)
+		}
+	}
+
+	if err := clients[0].InvalidateTag(context.Background(), "catalog"); err != ErrTaggingUnsupported {
+		t.Errorf("*Client.InvalidateTag() error = %v, want %v", err, ErrTaggingUnsupported)
+	}
+	if len(bus.published) != 3 {
+		t.Errorf("published %v, want 3 invalidations", bus.published)
+	}
+
+	bus.Publish(context.Background(), Invalidation{Source: "other", Kind: "unknown"})
+	if len(errs) != 2 {
+		t.Errorf("OnInvalidationError called %v times, want 2", len(errs))
+	}
+}
+
+func TestWithInvalidationBus(t *testing.T) {
+	if _, err := NewClient(WithAdapter(&adapterMock{}), WithTTL(1*time.Minute), WithInvalidationBus(nil)); err == nil {
+		t.Errorf("NewClient() with nil bus error = nil")
+	}
+	if _, err := NewClient(WithAdapter(&adapterMock{}), WithTTL(1*time.Minute), WithInvalidationBus(failingBus{})); err == nil {
+		t.Errorf("NewClient() with failing bus error = nil")
+	}
+}
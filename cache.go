@@ -27,19 +27,32 @@ package cache
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/gob"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
-	"net/http/httptest"
 	"net/url"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"golang.org/x/sync/singleflight"
 )
 
 // Adapter interface for HTTP cache middleware client.
+//
+// Byte slices handed to Set and returned by Get are treated as immutable:
+// adapters may keep the slice passed to Set and return it from Get without
+// copying, and callers must not modify either.
 type Adapter interface {
 	// Get retrieves the cached response by a given key. It also
 	// returns true or false, whether it exists or not.
@@ -52,48 +65,247 @@ type Adapter interface {
 	Release(context.Context, string)
 }
 
+// AdapterV2 interface for HTTP cache middleware client, surfacing the
+// failures Adapter implementations have to swallow. It follows the same
+// contract as Adapter otherwise.
+type AdapterV2 interface {
+	// Get retrieves the cached response by a given key. It also
+	// returns true or false, whether it exists or not.
+	Get(context.Context, string) ([]byte, bool, error)
+
+	// Set caches a response for a given key until an expiration date.
+	Set(context.Context, string, []byte, time.Time) error
+
+	// Release frees cache for a given key.
+	Release(context.Context, string) error
+}
+
+// AdapterV2Of adapts an Adapter to the AdapterV2 interface, reporting no
+// failures, for packages wrapping adapters of either kind.
+func AdapterV2Of(a Adapter) AdapterV2 {
+	return adapterShim{a}
+}
+
+// UnwrapAdapterV2 returns the Adapter adapted by AdapterV2Of, or a itself,
+// to look for the optional interfaces the adapter implements.
+func UnwrapAdapterV2(a AdapterV2) interface{} {
+	if shim, ok := a.(adapterShim); ok {
+		return shim.Adapter
+	}
+
+	return a
+}
+
+// adapterShim adapts an Adapter to the AdapterV2 interface.
+type adapterShim struct {
+	Adapter
+}
+
+func (a adapterShim) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	b, ok := a.Adapter.Get(ctx, key)
+	return b, ok, nil
+}
+
+func (a adapterShim) Set(ctx context.Context, key string, response []byte, expiration time.Time) error {
+	a.Adapter.Set(ctx, key, response, expiration)
+	return nil
+}
+
+func (a adapterShim) Release(ctx context.Context, key string) error {
+	a.Adapter.Release(ctx, key)
+	return nil
+}
+
 // =============================================================================
 
 // Response is the cached response data structure.
 type Response struct {
 	// Value is the cached response value.
-	Value []byte
+	Value []byte `json:"value,omitempty"`
+
+	// StatusCode is the cached response status code.
+	StatusCode int `json:"status_code,omitempty"`
 
 	// Header is the cached response header.
-	Header http.Header
+	Header http.Header `json:"header,omitempty"`
 
 	// Expiration is the cached response expiration date.
-	Expiration time.Time
+	Expiration time.Time `json:"expiration"`
+
+	// StoredAt is the date the response was cached, used to compute its age.
+	StoredAt time.Time `json:"stored_at"`
 
 	// LastAccess is the last date a cached response was accessed.
-	// Used by LRU and MRU algorithms.
-	LastAccess time.Time
+	// Only updated on cache hits when access tracking is enabled.
+	LastAccess time.Time `json:"last_access"`
 
 	// Frequency is the count of times a cached response is accessed.
-	// Used for LFU and MFU algorithms.
-	Frequency int
+	// Only updated on cache hits when access tracking is enabled.
+	Frequency int `json:"frequency,omitempty"`
+
+	// Vary is the list of request headers named by the response Vary header.
+	// A response cached under its primary key with Vary set and no Value is
+	// a marker pointing to the secondary keys its representations are
+	// cached under.
+	Vary []string `json:"vary,omitempty"`
+
+	// Encoding is the compression applied to Value, if any.
+	Encoding Compression `json:"encoding,omitempty"`
+
+	// Key is the key the response is cached under before hashing, kept to
+	// detect hash collisions when key verification is enabled.
+	Key string `json:"key,omitempty"`
+
+	// Request describes the request the response was generated from. Only
+	// set when enabled with WithRequestMetadata.
+	Request *RequestInfo `json:"request,omitempty"`
+}
+
+// RequestInfo is the metadata of the request a cached response was
+// generated from.
+type RequestInfo struct {
+	// Method is the request method.
+	Method string `json:"method,omitempty"`
+
+	// URL is the absolute request URL.
+	URL string `json:"url,omitempty"`
+
+	// Header holds the request headers selected with WithRequestMetadata.
+	Header http.Header `json:"header,omitempty"`
+}
+
+// NewRequest returns a request equivalent to the one described, to
+// regenerate the response outside of the original request.
+func (i *RequestInfo) NewRequest(ctx context.Context) (*http.Request, error) {
+	r, err := http.NewRequestWithContext(ctx, i.Method, i.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range i.Header {
+		r.Header[name] = append([]string(nil), values...)
+	}
+
+	return r, nil
 }
 
-// BytesToResponse converts bytes array into Response data structure.
+// responseMagic prefixes the framed Response encoding. A gob stream never
+// starts with a zero byte, so it can't be mistaken for a legacy payload.
+const responseMagic = "\x00hc"
+
+// responseVersion is the current version of the framed Response encoding.
+// Version 2 added StatusCode; older payloads decode as 200 OK responses.
+const responseVersion = 2
+
+// responseMeta is the gob encoded part of a Response. Having no methods, it
+// keeps gob from calling the Response binary marshaling methods recursively.
+type responseMeta Response
+
+// BytesToResponse converts bytes array into Response data structure,
+// returning a zero Response when b can't be decoded.
+//
+// The returned Value references b directly instead of copying the body, so
+// it must be treated as read-only, in line with the Adapter contract.
 func BytesToResponse(b []byte) Response {
 	var r Response
-	dec := gob.NewDecoder(bytes.NewReader(b))
-	dec.Decode(&r)
+	r.UnmarshalBinary(b)
 
 	return r
 }
 
-// Bytes converts Response data structure into bytes array.
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface,
+// decoding bytes produced by MarshalBinary or by older versions of Bytes.
+// The decoded Value references b directly.
+func (r *Response) UnmarshalBinary(b []byte) error {
+	var meta responseMeta
+	if !bytes.HasPrefix(b, []byte(responseMagic)) {
+		dec := gob.NewDecoder(bytes.NewReader(b))
+		if err := dec.Decode(&meta); err != nil {
+			return fmt.Errorf("decoding response: %w", err)
+		}
+		meta.StatusCode = http.StatusOK
+		*r = Response(meta)
+
+		return nil
+	}
+
+	if len(b) == len(responseMagic) {
+		return errors.New("decoding response: missing version")
+	}
+	version := b[len(responseMagic)]
+	if version > responseVersion {
+		return fmt.Errorf("decoding response: version %v is not supported", version)
+	}
+
+	b = b[len(responseMagic)+1:]
+	n, size := binary.Uvarint(b)
+	if size <= 0 || uint64(len(b)-size) < n {
+		return errors.New("decoding response: invalid metadata length")
+	}
+	b = b[size:]
+
+	dec := gob.NewDecoder(bytes.NewReader(b[:n]))
+	if err := dec.Decode(&meta); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	if body := b[n:]; len(body) > 0 {
+		meta.Value = body
+	}
+	if version < 2 {
+		meta.StatusCode = http.StatusOK
+	}
+	*r = Response(meta)
+
+	return nil
+}
+
+// Bytes converts Response data structure into bytes array, returning nil if
+// it can't be encoded.
+//
+// The body is appended raw after the gob encoded metadata, which lets
+// BytesToResponse hand it back without another copy.
 func (r Response) Bytes() []byte {
-	var b bytes.Buffer
-	enc := gob.NewEncoder(&b)
-	enc.Encode(&r)
+	b, _ := r.MarshalBinary()
+	return b
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (r Response) MarshalBinary() ([]byte, error) {
+	meta := responseMeta(r)
+	meta.Value = nil
+
+	var m bytes.Buffer
+	enc := gob.NewEncoder(&m)
+	if err := enc.Encode(&meta); err != nil {
+		return nil, fmt.Errorf("encoding response: %w", err)
+	}
+
+	var size [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(size[:], uint64(m.Len()))
 
-	return b.Bytes()
+	b := make([]byte, 0, len(responseMagic)+1+n+m.Len()+len(r.Value))
+	b = append(b, responseMagic...)
+	b = append(b, responseVersion)
+	b = append(b, size[:n]...)
+	b = append(b, m.Bytes()...)
+	b = append(b, r.Value...)
+
+	return b, nil
 }
 
 // =============================================================================
 
+// Cache statuses reported by the X-Cache header.
+const (
+	cacheHit    = "HIT"
+	cacheMiss   = "MISS"
+	cacheBypass = "BYPASS"
+	cacheStale  = "STALE"
+)
+
+// ErrWriteDropped is reported to the OnError hook when an async write is
+// dropped.
+var ErrWriteDropped = errors.New("cache write dropped")
+
 // ClientOption is used to set Client settings.
 type ClientOption func(c *Client) error
 
@@ -101,7 +313,77 @@ type ClientOption func(c *Client) error
 // middleware client.
 func WithAdapter(a Adapter) ClientOption {
 	return func(c *Client) error {
-		c.adapter = a
+		c.setAdapter(a)
+		if a != nil {
+			c.adapter = AdapterV2Of(a)
+		}
+		return nil
+	}
+}
+
+// WithAdapterV2 sets an AdapterV2 as the adapter of the HTTP cache
+// middleware client. Its failures are reported to the OnError hook and
+// handled as cache misses.
+func WithAdapterV2(a AdapterV2) ClientOption {
+	return func(c *Client) error {
+		c.setAdapter(a)
+		if a != nil {
+			c.adapter = a
+		}
+		return nil
+	}
+}
+
+// setAdapter resets the adapter of the client, along with the optional
+// interfaces it implements.
+func (c *Client) setAdapter(a interface{}) {
+	c.adapter = nil
+	c.tagger, _ = a.(TaggingAdapter)
+	c.invalidator, _ = a.(InvalidatingAdapter)
+	c.locker, _ = a.(LockingAdapter)
+}
+
+// WithTagHeader sets the response header handlers list the tags of their
+// responses in, separated by commas or spaces, such as X-Cache-Tags or
+// Surrogate-Key. Tagged responses can be invalidated with
+// Client.InvalidateTag when the adapter implements TaggingAdapter. The
+// header is removed from responses written by the middleware.
+func WithTagHeader(name string) ClientOption {
+	return func(c *Client) error {
+		if name == "" {
+			return fmt.Errorf("cache client tag header can not be empty")
+		}
+
+		c.tagHeader = http.CanonicalHeaderKey(name)
+
+		return nil
+	}
+}
+
+// WithTagger sets a function returning the tags of a response, in addition
+// to those listed by the tag header, given the request and response header.
+func WithTagger(fn func(*http.Request, http.Header) []string) ClientOption {
+	return func(c *Client) error {
+		if fn == nil {
+			return fmt.Errorf("tagger function can not be nil")
+		}
+
+		c.taggerFn = fn
+
+		return nil
+	}
+}
+
+// WithAdminAuthorizer sets the function deciding whether a request may use
+// the handler returned by AdminHandler, which denies all requests otherwise.
+func WithAdminAuthorizer(fn func(*http.Request) bool) ClientOption {
+	return func(c *Client) error {
+		if fn == nil {
+			return fmt.Errorf("admin authorizer function can not be nil")
+		}
+
+		c.adminAuthorizer = fn
+
 		return nil
 	}
 }
@@ -117,167 +399,1860 @@ func WithCacheable(fn func(*http.Request) bool) ClientOption {
 	}
 }
 
-// WithKey configues the key generation function
-func WithKey(fn func(*http.Request) (string, error)) ClientOption {
+// WithMethods sets the request methods cached by the default cacheable
+// function. Only GET requests are cached by default.
+func WithMethods(methods ...string) ClientOption {
 	return func(c *Client) error {
-		if fn == nil {
-			return fmt.Errorf("key function can not be nil")
+		if len(methods) == 0 {
+			return fmt.Errorf("cache client methods can not be empty")
 		}
-		c.keygenFn = fn
+		c.methods = methods
 		return nil
 	}
 }
 
-// WithRefreshKey sets the parameter key used to free a request
-// cached response. Optional setting.
-func WithRefreshKey(refreshKey string) ClientOption {
+// WithVaryHeaders adds the values of the given request headers to cache
+// keys, caching responses separately per locale or tenant for instance,
+// whatever the key function.
+func WithVaryHeaders(names ...string) ClientOption {
 	return func(c *Client) error {
-		c.refreshKey = refreshKey
+		if len(names) == 0 {
+			return fmt.Errorf("cache client vary headers can not be empty")
+		}
+
+		c.varyRequestHeaders = nil
+		for _, name := range names {
+			c.varyRequestHeaders = append(c.varyRequestHeaders, http.CanonicalHeaderKey(name))
+		}
+
 		return nil
 	}
 }
 
-// WithTTL sets how long each response is going to be cached.
-func WithTTL(ttl time.Duration) ClientOption {
+// WithVaryCookies adds the values of the given cookies to cache keys,
+// whatever the key function. As requests carrying cookies aren't cacheable
+// by default, it requires WithPrivateCaching or a cacheable function set
+// with WithCacheable.
+func WithVaryCookies(names ...string) ClientOption {
 	return func(c *Client) error {
-		if int64(ttl) < 1 {
-			return fmt.Errorf("cache client ttl %v is invalid", ttl)
+		if len(names) == 0 {
+			return fmt.Errorf("cache client vary cookies can not be empty")
 		}
 
-		c.ttl = ttl
+		c.varyCookies = names
 
 		return nil
 	}
 }
 
-// =============================================================================
+// WithKeyContext adds a component derived from the request context to cache
+// keys, whatever the key function, such as the tenant ID set by an
+// authentication middleware, so that the same URL is cached separately per
+// tenant.
+func WithKeyContext(fn func(ctx context.Context) string) ClientOption {
+	return func(c *Client) error {
+		if fn == nil {
+			return fmt.Errorf("key context function can not be nil")
+		}
 
-// Client data structure for HTTP cache middleware.
-type Client struct {
-	adapter     Adapter
-	cacheableFn func(*http.Request) bool
-	keygenFn    func(*http.Request) (string, error)
-	ttl         time.Duration
-	refreshKey  string
-	methods     []string
-}
+		c.keyContextFn = fn
 
-// NewClient initializes the cache HTTP middleware client with the given
-// options.
-func NewClient(opts ...ClientOption) (*Client, error) {
-	c := &Client{}
+		return nil
+	}
+}
 
-	for _, opt := range opts {
-		if err := opt(c); err != nil {
-			return nil, err
+// WithKey configues the key generation function
+func WithKey(fn func(*http.Request) (string, error)) ClientOption {
+	return func(c *Client) error {
+		if fn == nil {
+			return fmt.Errorf("key function can not be nil")
 		}
+		c.keygenFn = fn
+		return nil
 	}
+}
 
-	if c.adapter == nil {
-		return nil, errors.New("cache client adapter is not set")
+// WithETag makes the middleware add a strong ETag, the SHA-256 of the body,
+// to cached responses that don't carry one, and answer conditional requests
+// whose If-None-Match or If-Modified-Since match a cached response with 304
+// Not Modified. As cache misses are streamed to the client, a generated ETag
+// is only sent along with responses served from the cache.
+func WithETag(enabled bool) ClientOption {
+	return func(c *Client) error {
+		c.etag = enabled
+		return nil
 	}
-	if c.cacheableFn == nil {
-		c.cacheableFn = isCacheable
+}
+
+// WithRequestDirectives makes the middleware honor the Cache-Control
+// directives sent by clients. Requests carrying no-cache (or Pragma:
+// no-cache) skip the cache lookup, and cached responses older than the
+// request max-age, or expiring sooner than its min-fresh, are fetched again
+// from the handler. In both cases the fresh response is cached.
+func WithRequestDirectives(enabled bool) ClientOption {
+	return func(c *Client) error {
+		c.requestDirectives = enabled
+		return nil
 	}
-	if c.keygenFn == nil {
-		c.keygenFn = generateKey
+}
+
+// WithResponseDirectives sets whether the middleware honors the
+// Cache-Control directives of the handler responses. It is enabled by
+// default, so responses marked no-store, private or no-cache are never
+// cached; disable it for internal-only deployments that cache regardless.
+func WithResponseDirectives(enabled bool) ClientOption {
+	return func(c *Client) error {
+		c.ignoreResponseDirectives = !enabled
+		return nil
 	}
-	if int64(c.ttl) < 1 {
-		return nil, errors.New("cache client ttl is not set")
+}
+
+// WithTTLFunc sets a function deciding how long each response is cached,
+// given the request and the response written by the handler. A non-positive
+// duration falls back to the TTL set with WithTTL.
+func WithTTLFunc(fn func(*http.Request, *http.Response) time.Duration) ClientOption {
+	return func(c *Client) error {
+		if fn == nil {
+			return fmt.Errorf("ttl function can not be nil")
+		}
+		c.ttlFn = fn
+		return nil
 	}
-	if c.methods == nil {
-		c.methods = []string{http.MethodGet}
+}
+
+// WithTTLJitter randomizes how long each response is cached by up to plus
+// or minus the given fraction of its TTL, so responses cached at the same
+// time don't all expire together.
+func WithTTLJitter(fraction float64) ClientOption {
+	return func(c *Client) error {
+		if fraction < 0 || fraction >= 1 {
+			return fmt.Errorf("cache client ttl jitter %v is invalid", fraction)
+		}
+
+		c.ttlJitter = fraction
+
+		return nil
 	}
+}
 
-	return c, nil
+// WithTTLFromHeaders makes the middleware derive how long each response is
+// cached from its Cache-Control s-maxage or max-age directive, or from its
+// Expires header, falling back to the TTL set with WithTTL when none is
+// present.
+func WithTTLFromHeaders(enabled bool) ClientOption {
+	return func(c *Client) error {
+		c.ttlFromHeaders = enabled
+		return nil
+	}
 }
 
-// Middleware is the HTTP cache middleware handler.
-func (c *Client) Middleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if c.cacheableFn(r) {
-			ctx := r.Context()
-			params := r.URL.Query()
-			_, isRefresh := params[c.refreshKey]
-			if isRefresh {
-				delete(params, c.refreshKey)
-				r.URL.RawQuery = params.Encode()
-			}
-			sortURLParams(r.URL)
+// WithNegativeTTL sets how long 404 Not Found and 410 Gone responses are
+// cached, whatever the rules and the TTL function. They aren't cached by
+// default.
+func WithNegativeTTL(ttl time.Duration) ClientOption {
+	return func(c *Client) error {
+		if int64(ttl) < 1 {
+			return fmt.Errorf("cache client negative ttl %v is invalid", ttl)
+		}
 
-			key, err := c.keygenFn(r)
-			if err != nil {
-				next.ServeHTTP(w, r)
-				return
-			}
+		c.negativeTTL = ttl
 
-			if isRefresh {
-				c.adapter.Release(ctx, key)
-			} else {
-				b, ok := c.adapter.Get(ctx, key)
-				response := BytesToResponse(b)
-				if ok {
-					if response.Expiration.After(time.Now()) {
-						response.LastAccess = time.Now()
-						response.Frequency++
-						c.adapter.Set(ctx, key, response.Bytes(), response.Expiration)
-
-						//w.WriteHeader(http.StatusNotModified)
-						for k, v := range response.Header {
-							w.Header().Set(k, strings.Join(v, ","))
-						}
-						w.Write(response.Value)
-						return
-					}
+		return nil
+	}
+}
 
-					c.adapter.Release(ctx, key)
-				}
+// WithStatusCodes restricts the cached responses to the given status codes.
+// Any response below 400 is cached by default, along with 404 Not Found and
+// 410 Gone ones when WithNegativeTTL is set, which the status codes further
+// restrict rather than extend.
+func WithStatusCodes(codes ...int) ClientOption {
+	return func(c *Client) error {
+		if len(codes) == 0 {
+			return fmt.Errorf("cache client status codes can not be empty")
+		}
+		for _, code := range codes {
+			if code < 100 || code > 599 {
+				return fmt.Errorf("cache client status code %v is invalid", code)
 			}
+		}
+		c.statusCodes = codes
+		return nil
+	}
+}
 
-			rec := httptest.NewRecorder()
-			next.ServeHTTP(rec, r)
-			result := rec.Result()
-
-			statusCode := result.StatusCode
-			value := rec.Body.Bytes()
-			if statusCode < 400 {
-				now := time.Now()
-
-				response := Response{
-					Value:      value,
-					Header:     result.Header,
-					Expiration: now.Add(c.ttl),
-					LastAccess: now,
-					Frequency:  1,
-				}
-				c.adapter.Set(ctx, key, response.Bytes(), response.Expiration)
-			}
-			for k, v := range result.Header {
-				w.Header().Set(k, strings.Join(v, ","))
-			}
-			w.WriteHeader(statusCode)
-			w.Write(value)
-			return
+// WithStaleWhileRevalidate sets how long after expiring a cached response
+// may still be served while it is refreshed in the background.
+func WithStaleWhileRevalidate(d time.Duration) ClientOption {
+	return func(c *Client) error {
+		if d < 0 {
+			return fmt.Errorf("stale while revalidate window %v is invalid", d)
 		}
-		next.ServeHTTP(w, r)
-	})
+
+		c.staleWhileRevalidate = d
+
+		return nil
+	}
 }
 
-// =============================================================================
+// WithStatusHeaders makes the middleware report how each response was served
+// with an X-Cache header, one of HIT, MISS, BYPASS or STALE, and set the Age
+// header of responses served from the cache.
+func WithStatusHeaders(enabled bool) ClientOption {
+	return func(c *Client) error {
+		c.statusHeaders = enabled
+		return nil
+	}
+}
 
-func generateKey(r *http.Request) (string, error) {
-	if r.Method == http.MethodPost {
-		body, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			return "", fmt.Errorf("error reading body: %v", err)
+// WithDebugHeaders makes the middleware set debug headers on responses: the
+// X-Cache-Key header to the SHA-256 hash of the cache key, or to the hashed
+// key with WithKeyHashing, and for cached responses, the
+// X-Cache-TTL-Remaining header to the seconds left until expiration,
+// negative for stale responses, and the X-Cache-Stored-At header to the date
+// they were cached. They are set on every response unless a function is set
+// with WithDebugAuthorizer.
+func WithDebugHeaders(enabled bool) ClientOption {
+	return func(c *Client) error {
+		c.debugHeaders = enabled
+		return nil
+	}
+}
+
+// WithDebugAuthorizer sets the function deciding whether a response gets the
+// debug headers enabled with WithDebugHeaders, checking a request header and
+// its remote address for instance.
+func WithDebugAuthorizer(fn func(*http.Request) bool) ClientOption {
+	return func(c *Client) error {
+		if fn == nil {
+			return fmt.Errorf("debug authorizer function can not be nil")
+		}
+
+		c.debugAuthorizer = fn
+
+		return nil
+	}
+}
+
+// WithStaleIfError sets how long after expiring a cached response may still
+// be served in place of a handler failure, that is a 5xx response or a
+// panic. Failures are surfaced as usual when no such response is cached.
+func WithStaleIfError(d time.Duration) ClientOption {
+	return func(c *Client) error {
+		if d < 0 {
+			return fmt.Errorf("stale if error window %v is invalid", d)
+		}
+
+		c.staleIfError = d
+
+		return nil
+	}
+}
+
+// WithHeaderTransform sets a function to filter or rewrite response headers,
+// for instance stripping Set-Cookie. It is applied to the headers of
+// responses before they are cached and again before cached responses are
+// served, and receives a copy it is free to modify.
+func WithHeaderTransform(fn func(http.Header) http.Header) ClientOption {
+	return func(c *Client) error {
+		if fn == nil {
+			return fmt.Errorf("header transform function can not be nil")
 		}
-		return fmt.Sprintf("%s%s", r.URL.String(), string(body)), nil
+		c.headerTransformFn = fn
+		return nil
 	}
-	return r.URL.String(), nil
 }
 
-func isCacheable(r *http.Request) bool {
-	return r.Method == http.MethodGet
+// WithPrivateCaching sets whether responses meant for a single user may be
+// cached. It is disabled by default, so responses setting cookies are never
+// cached, and unless a cacheable function is set with WithCacheable, neither
+// are requests carrying Authorization or Cookie headers.
+func WithPrivateCaching(enabled bool) ClientOption {
+	return func(c *Client) error {
+		c.privateCaching = enabled
+		return nil
+	}
+}
+
+// WithRequestMetadata stores the method, URL and the given headers of the
+// originating request with each cached response, for background
+// revalidation or inspection through the admin handler. It's disabled by
+// default since it grows entries, and headers may hold personal data.
+func WithRequestMetadata(headers ...string) ClientOption {
+	return func(c *Client) error {
+		c.requestMetadata = true
+		c.requestMetadataHeaders = nil
+		for _, name := range headers {
+			c.requestMetadataHeaders = append(c.requestMetadataHeaders, http.CanonicalHeaderKey(name))
+		}
+
+		return nil
+	}
+}
+
+// WithRequestCoalescing makes concurrent cache misses on the same key invoke
+// the handler only once, the other requests being served the response it
+// caches. Requests fall back to invoking the handler themselves when that
+// response isn't cacheable.
+func WithRequestCoalescing(enabled bool) ClientOption {
+	return func(c *Client) error {
+		c.coalescing = enabled
+		return nil
+	}
+}
+
+// WithMaxBodySize sets the size in bytes of the largest response body that
+// is cached. Larger responses are streamed to the client without being
+// cached.
+func WithMaxBodySize(size int64) ClientOption {
+	return func(c *Client) error {
+		if size < 1 {
+			return fmt.Errorf("max body size %v is invalid", size)
+		}
+
+		c.maxBodySize = size
+
+		return nil
+	}
+}
+
+// WithHooks sets the functions invoked at each stage of the middleware.
+func WithHooks(hooks Hooks) ClientOption {
+	return func(c *Client) error {
+		hooks.logger = c.hooks.logger
+		hooks.metrics = c.hooks.metrics
+		hooks.metricTags = c.hooks.metricTags
+		c.hooks = hooks
+		return nil
+	}
+}
+
+// WithCodec sets the Codec used to encode cached responses, GobCodec by
+// default.
+func WithCodec(codec Codec) ClientOption {
+	return func(c *Client) error {
+		if codec == nil {
+			return fmt.Errorf("cache client codec can not be nil")
+		}
+
+		c.codec = codec
+
+		return nil
+	}
+}
+
+// WithCompression compresses cached bodies of at least minSize bytes with
+// the given algorithm. Compressed bodies are served as is, with an ETag
+// derived for the coding, to clients accepting the algorithm as their
+// content coding, and decompressed for the others, which is why they gain a
+// Vary: Accept-Encoding header. Responses already having a Content-Encoding
+// are stored unchanged.
+func WithCompression(compression Compression, minSize int) ClientOption {
+	return func(c *Client) error {
+		switch compression {
+		case Gzip, Snappy, Zstd:
+		default:
+			return fmt.Errorf("cache client compression %q is invalid", compression)
+		}
+		if minSize < 0 {
+			return fmt.Errorf("cache client compression min size %v is invalid", minSize)
+		}
+
+		c.compression = compression
+		c.compressionMinSize = minSize
+
+		return nil
+	}
+}
+
+// WithEncryption encrypts cached entries with AES-GCM, using a 16, 24 or 32
+// bytes key. Entries are tagged with the ID of the key they're encrypted
+// with, so keys can be rotated by passing the keys previously in use, which
+// are only used to decrypt existing entries. Entries failing to decrypt,
+// including entries cached without encryption, are released and treated as
+// misses.
+func WithEncryption(key []byte, previous ...[]byte) ClientOption {
+	return func(c *Client) error {
+		e, err := newEncryption(key, previous...)
+		if err != nil {
+			return fmt.Errorf("cache client encryption key is invalid: %w", err)
+		}
+
+		c.encryption = e
+
+		return nil
+	}
+}
+
+// WithSigning signs cached entries with HMAC-SHA256, using a key of at least
+// 16 bytes, so that responses injected into a shared or compromised store
+// aren't served. Like with WithEncryption, keys can be rotated by passing
+// the keys previously in use. Entries failing verification, including
+// entries cached without signing, are reported to the OnVerifyFailure hook,
+// released and treated as misses.
+func WithSigning(key []byte, previous ...[]byte) ClientOption {
+	return func(c *Client) error {
+		s, err := newSigning(key, previous...)
+		if err != nil {
+			return fmt.Errorf("cache client signing key is invalid: %w", err)
+		}
+
+		c.signing = s
+
+		return nil
+	}
+}
+
+// WithKeyHashing makes the middleware pass hashed keys to the adapter, such
+// as SHA256Key or XXHashKey, keeping keys short whatever the length of URLs
+// and request bodies. With verify, the original key is stored in each entry
+// and entries cached under a colliding key are treated as misses.
+func WithKeyHashing(hash func([]byte) string, verify bool) ClientOption {
+	return func(c *Client) error {
+		if hash == nil {
+			return fmt.Errorf("key hashing function can not be nil")
+		}
+
+		c.keyHashFn = hash
+		c.keyVerification = verify
+
+		return nil
+	}
+}
+
+// SHA256Key hashes a key with SHA-256, for use with WithKeyHashing.
+func SHA256Key(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:])
+}
+
+// XXHashKey hashes a key with the faster, non cryptographic, xxHash
+// algorithm, for use with WithKeyHashing. Its 64 bits hashes make
+// collisions unlikely but not impossible, which verification guards
+// against.
+func XXHashKey(key []byte) string {
+	return strconv.FormatUint(xxhash.Sum64(key), 16)
+}
+
+// WithCacheVersion mixes a version into every key passed to the adapter,
+// before any hashing, so that changing the version, for instance to the
+// commit deployed, invalidates all the responses cached under the previous
+// one without flushing the store. Keys seen by hooks are left unchanged.
+func WithCacheVersion(version string) ClientOption {
+	return func(c *Client) error {
+		if version == "" {
+			return fmt.Errorf("cache client version can not be empty")
+		}
+
+		c.version = version
+
+		return nil
+	}
+}
+
+// WithAccessTracking makes the middleware update the LastAccess and
+// Frequency of cached responses on every hit by writing them back to the
+// adapter. It's disabled by default since it costs an extra write per hit;
+// adapters that evict by recency or frequency, like the memory adapter,
+// track accesses themselves.
+func WithAccessTracking(enabled bool) ClientOption {
+	return func(c *Client) error {
+		c.accessTracking = enabled
+		return nil
+	}
+}
+
+// WithAdapterTimeout sets how long each adapter operation may take. Slower
+// operations are abandoned, and reported to the OnError hook, with the
+// middleware falling back to the handler as on a cache miss.
+func WithAdapterTimeout(d time.Duration) ClientOption {
+	return func(c *Client) error {
+		if int64(d) < 1 {
+			return fmt.Errorf("cache client adapter timeout %v is invalid", d)
+		}
+
+		c.adapterTimeout = d
+
+		return nil
+	}
+}
+
+// WithAsyncWrites makes the middleware cache responses in the background,
+// off the request path, using the given number of workers. Up to queueSize
+// writes wait for a worker; further writes are dropped rather than slowing
+// requests down, as counted by DroppedWrites. Close stops the workers.
+func WithAsyncWrites(workers, queueSize int) ClientOption {
+	return func(c *Client) error {
+		if workers < 1 {
+			return fmt.Errorf("cache client async write workers %v is invalid", workers)
+		}
+		if queueSize < 1 {
+			return fmt.Errorf("cache client async write queue size %v is invalid", queueSize)
+		}
+
+		c.writeWorkers = workers
+		c.writes = make(chan func(), queueSize)
+
+		return nil
+	}
+}
+
+// WithRefreshKey sets the parameter key used to free a request
+// cached response. Optional setting.
+func WithRefreshKey(refreshKey string) ClientOption {
+	return func(c *Client) error {
+		c.refreshKey = refreshKey
+		return nil
+	}
+}
+
+// WithRefreshHeader sets the request header used to free a request cached
+// response, which unlike the refresh key parameter doesn't show in URLs and
+// access logs. Any non empty value requests a refresh. Optional setting.
+func WithRefreshHeader(name string) ClientOption {
+	return func(c *Client) error {
+		if name == "" {
+			return fmt.Errorf("cache client refresh header can not be empty")
+		}
+
+		c.refreshHeader = http.CanonicalHeaderKey(name)
+
+		return nil
+	}
+}
+
+// WithPurgeKey sets the parameter key used to free a request cached response
+// without invoking the handler, responding with 204 No Content instead, so
+// that entries can be removed without generating load. Optional setting.
+func WithPurgeKey(purgeKey string) ClientOption {
+	return func(c *Client) error {
+		if purgeKey == "" {
+			return fmt.Errorf("cache client purge key can not be empty")
+		}
+
+		c.purgeKey = purgeKey
+
+		return nil
+	}
+}
+
+// WithPurgeHeader sets the request header used to purge a request cached
+// response, as WithPurgeKey does. Any non empty value requests a purge.
+// Optional setting.
+func WithPurgeHeader(name string) ClientOption {
+	return func(c *Client) error {
+		if name == "" {
+			return fmt.Errorf("cache client purge header can not be empty")
+		}
+
+		c.purgeHeader = http.CanonicalHeaderKey(name)
+
+		return nil
+	}
+}
+
+// WithWriteThroughInvalidation makes the middleware release the cached GET
+// response of the URL of POST, PUT, PATCH and DELETE requests it doesn't
+// cache once they succeed with a 2xx status, as well as the cached GET
+// responses of the given related paths, such as the collection a resource
+// belongs to.
+func WithWriteThroughInvalidation(related ...string) ClientOption {
+	return func(c *Client) error {
+		for _, path := range related {
+			if !strings.HasPrefix(path, "/") {
+				return fmt.Errorf("cache client write through path %v is invalid", path)
+			}
+		}
+
+		c.writeThrough = true
+		c.writeThroughPaths = related
+
+		return nil
+	}
+}
+
+// WithInvalidationBus sets the bus broadcasting the invalidations of the
+// client, whether refreshes, purges or tag, prefix and pattern
+// invalidations, to the other instances sharing it, and applying theirs to
+// the local adapter. The client subscribes to the bus when created, until it
+// is closed.
+func WithInvalidationBus(bus InvalidationBus) ClientOption {
+	return func(c *Client) error {
+		if bus == nil {
+			return fmt.Errorf("cache client invalidation bus can not be nil")
+		}
+
+		c.bus = bus
+
+		return nil
+	}
+}
+
+// WithRefreshAuthorizer sets the function deciding whether a request may
+// refresh or purge its cached response, checking its remote address or a
+// shared secret for instance. Unauthorized refresh and purge requests are
+// handled as regular requests.
+func WithRefreshAuthorizer(fn func(*http.Request) bool) ClientOption {
+	return func(c *Client) error {
+		if fn == nil {
+			return fmt.Errorf("refresh authorizer function can not be nil")
+		}
+
+		c.refreshAuthorizer = fn
+
+		return nil
+	}
+}
+
+// WithTTL sets how long each response is going to be cached.
+func WithTTL(ttl time.Duration) ClientOption {
+	return func(c *Client) error {
+		if int64(ttl) < 1 {
+			return fmt.Errorf("cache client ttl %v is invalid", ttl)
+		}
+
+		c.ttl = ttl
+
+		return nil
+	}
+}
+
+// =============================================================================
+
+// Client data structure for HTTP cache middleware.
+type Client struct {
+	adapter                  AdapterV2
+	cacheableFn              func(*http.Request) bool
+	keygenFn                 func(*http.Request) (string, error)
+	ttl                      time.Duration
+	ttlFn                    func(*http.Request, *http.Response) time.Duration
+	adaptive                 *adaptiveTTL
+	refresher                *refresher
+	ttlJitter                float64
+	refreshKey               string
+	refreshHeader            string
+	refreshAuthorizer        func(*http.Request) bool
+	purgeKey                 string
+	purgeHeader              string
+	tagger                   TaggingAdapter
+	invalidator              InvalidatingAdapter
+	tagHeader                string
+	taggerFn                 func(*http.Request, http.Header) []string
+	adminAuthorizer          func(*http.Request) bool
+	writeThrough             bool
+	writeThroughPaths        []string
+	bus                      InvalidationBus
+	busID                    string
+	busCancel                context.CancelFunc
+	methods                  []string
+	requestDirectives        bool
+	ignoreResponseDirectives bool
+	ttlFromHeaders           bool
+	negativeTTL              time.Duration
+	statusCodes              []int
+	rules                    []Rule
+	routeFn                  func(*http.Request) string
+	etag                     bool
+	staleWhileRevalidate     time.Duration
+	staleIfError             time.Duration
+	statusHeaders            bool
+	debugHeaders             bool
+	debugAuthorizer          func(*http.Request) bool
+	headerTransformFn        func(http.Header) http.Header
+	privateCaching           bool
+	requestMetadata          bool
+	requestMetadataHeaders   []string
+	coalescing               bool
+	maxBodySize              int64
+	flights                  singleflight.Group
+	locker                   LockingAdapter
+	fillLockTTL              time.Duration
+	fillLockWait             time.Duration
+	hooks                    Hooks
+	adapterTimeout           time.Duration
+	accessTracking           bool
+	codec                    Codec
+	compression              Compression
+	compressionMinSize       int
+	encryption               *encryption
+	signing                  *signing
+	keyHashFn                func([]byte) string
+	keyVerification          bool
+	version                  string
+	varyRequestHeaders       []string
+	varyCookies              []string
+	keyContextFn             func(context.Context) string
+	writeWorkers             int
+	writes                   chan func()
+	writesMutex              sync.RWMutex
+	workers                  sync.WaitGroup
+	closed                   bool
+	droppedWrites            uint64
+	hits                     uint64
+	misses                   uint64
+	stores                   uint64
+	storedBytes              uint64
+	expvarName               string
+	revalidating             sync.Map
+}
+
+// NewClient initializes the cache HTTP middleware client with the given
+// options.
+func NewClient(opts ...ClientOption) (*Client, error) {
+	c := &Client{}
+
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.adapter == nil {
+		return nil, errors.New("cache client adapter is not set")
+	}
+	if c.cacheableFn == nil {
+		c.cacheableFn = c.isCacheable
+	}
+	if c.rules != nil {
+		c.cacheableFn = c.ruleCacheable(c.cacheableFn)
+	}
+	if c.keygenFn == nil {
+		c.keygenFn = generateKey
+	}
+	if int64(c.ttl) < 1 {
+		return nil, errors.New("cache client ttl is not set")
+	}
+	if c.methods == nil {
+		c.methods = []string{http.MethodGet}
+	}
+	if c.codec == nil {
+		c.codec = GobCodec{}
+	}
+	if c.fillLockTTL > 0 && c.locker == nil {
+		return nil, errors.New("cache client adapter does not support fill locks")
+	}
+	if c.bus != nil {
+		if err := c.subscribe(); err != nil {
+			return nil, err
+		}
+	}
+	if c.expvarName != "" {
+		c.publishExpvar()
+	}
+	if c.refresher != nil {
+		c.refresher.start(c)
+	}
+	if c.writes != nil {
+		for i := 0; i < c.writeWorkers; i++ {
+			c.workers.Add(1)
+			go func() {
+				defer c.workers.Done()
+				for write := range c.writes {
+					write()
+				}
+			}()
+		}
+	}
+
+	return c, nil
+}
+
+// Middleware is the HTTP cache middleware handler.
+func (c *Client) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.hooks.metrics != nil {
+			defer c.hooks.requestTiming(r, time.Now())
+		}
+		if r.Method == http.MethodHead {
+			c.head(w, r, next)
+			return
+		}
+		if c.cacheableFn(r) {
+			isPurge := c.purge(r)
+			isRefresh := c.refresh(r)
+			sortURLParams(r.URL)
+
+			key, err := c.key(r)
+			if err != nil {
+				c.hooks.error(r, "", err)
+				c.annotate(r, w.Header(), "", cacheBypass, nil)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if isPurge {
+				c.invalidate(r, key)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			var fallback *Response
+			status := cacheMiss
+			if isRefresh {
+				status = cacheBypass
+				c.invalidate(r, key)
+				c.hooks.refresh(r, key)
+			} else if c.bypasses(r) {
+				status = cacheBypass
+			} else {
+				if c.adaptive != nil {
+					c.adaptive.touch(key)
+				}
+				entryKey, response, ok := c.lookup(key, r)
+				if ok {
+					now := time.Now()
+					fresh := response.Expiration.After(now)
+					revalidating := !fresh && response.Expiration.Add(c.staleWhileRevalidate).After(now)
+					if (fresh || revalidating) && c.satisfies(r, response, now) {
+						status = cacheHit
+						if fresh && c.accessTracking {
+							response.LastAccess = now
+							response.Frequency++
+							c.write(r, entryKey, func(r *http.Request) {
+								if b, ok := c.encode(r, entryKey, c.compress(r, entryKey, response)); ok {
+									c.set(r, entryKey, b, c.retain(response.Expiration))
+								}
+							})
+						} else if !fresh {
+							status = cacheStale
+							c.revalidate(next, r, key)
+						}
+						if c.refresher != nil {
+							c.refresher.touch(key, r, next, response.Expiration)
+						}
+
+						c.serve(w, r, key, response, status)
+						return
+					}
+
+					if c.staleIfError > 0 && response.Expiration.Add(c.staleIfError).After(now) {
+						fallback = &response
+					} else if !fresh && !revalidating {
+						c.release(r, entryKey)
+					}
+				}
+			}
+
+			if status == cacheMiss {
+				if c.refresher != nil {
+					c.refresher.touch(key, r, next, time.Time{})
+				}
+				atomic.AddUint64(&c.misses, 1)
+				c.hooks.miss(r, key)
+			}
+
+			fetch := func() {
+				rw := newResponseWriter(w, func(h http.Header) {
+					if c.tagHeader != "" {
+						h.Del(c.tagHeader)
+					}
+					c.annotate(r, h, key, status, nil)
+				})
+				rw.limit = c.maxBodySize
+				if fallback == nil {
+					next.ServeHTTP(rw, r)
+				} else {
+					rw.hold = func(statusCode int) bool {
+						return statusCode >= 500
+					}
+					p := try(next, rw, r)
+					if p != nil {
+						c.hooks.error(r, key, fmt.Errorf("handler panic: %v", p))
+					}
+					if p != nil && rw.committed && !rw.held {
+						panic(p)
+					} else if p != nil || rw.held {
+						c.serve(w, r, key, *fallback, cacheStale)
+						return
+					}
+				}
+
+				if rw.finish() {
+					c.save(key, r, rw.status, rw.stored, rw.body.Bytes())
+				}
+			}
+			if c.fillLockTTL > 0 && status == cacheMiss {
+				handle := fetch
+				fetch = func() {
+					if !c.fill(w, r, key, fallback, handle) {
+						handle()
+					}
+				}
+			}
+			if !c.coalescing || !c.coalesce(w, r, key, fetch) {
+				fetch()
+			}
+			return
+		}
+		c.annotate(r, w.Header(), "", cacheBypass, nil)
+		if c.writeThrough && isUnsafe(r.Method) {
+			rw := newResponseWriter(w, nil)
+			rw.discard = true
+			next.ServeHTTP(rw, r)
+			if rw.finish() && rw.status >= 200 && rw.status < 300 {
+				c.invalidateWrite(r)
+			}
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// invalidateWrite releases the cached GET responses of the URL of a
+// successful unsafe request and of the write through related paths.
+func (c *Client) invalidateWrite(r *http.Request) {
+	urls := []*url.URL{r.URL}
+	for _, path := range c.writeThroughPaths {
+		u := *r.URL
+		u.Path, u.RawPath, u.RawQuery = path, "", ""
+		urls = append(urls, &u)
+	}
+
+	for _, u := range urls {
+		get := r.Clone(r.Context())
+		get.Method = http.MethodGet
+		get.Body = http.NoBody
+		get.URL = u
+		if !c.cacheableFn(get) {
+			continue
+		}
+
+		sortURLParams(get.URL)
+		key, err := c.key(get)
+		if err != nil {
+			c.hooks.error(get, "", err)
+			continue
+		}
+		c.invalidate(get, key)
+	}
+}
+
+// isUnsafe reports whether a request method modifies resources.
+func isUnsafe(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// coalesce runs fetch for the first of concurrent cache misses on a key,
+// while the others wait to be served the response it caches. It reports
+// whether the request was served, which isn't the case for waiters when no
+// fresh response was cached.
+func (c *Client) coalesce(w http.ResponseWriter, r *http.Request, key string, fetch func()) bool {
+	leader := false
+	var p interface{}
+	c.flights.Do(key, func() (interface{}, error) {
+		leader = true
+		defer func() {
+			p = recover()
+		}()
+		fetch()
+		return nil, nil
+	})
+	if p != nil {
+		panic(p)
+	}
+	if leader {
+		return true
+	}
+
+	_, response, ok := c.lookup(key, r)
+	if !ok || !response.Expiration.After(time.Now()) {
+		return false
+	}
+	c.serve(w, r, key, response, cacheHit)
+
+	return true
+}
+
+// serve writes a response cached under key.
+func (c *Client) serve(w http.ResponseWriter, r *http.Request, key string, response Response, status string) {
+	atomic.AddUint64(&c.hits, 1)
+	c.hooks.hit(r, key, response)
+	if response.Encoding != "" {
+		response.Header = response.Header.Clone()
+		if tag := response.Header.Get("ETag"); tag != "" {
+			response.Header.Set("ETag", encodedETag(tag, response.Encoding))
+		}
+		response.Header.Set("Content-Encoding", string(response.Encoding))
+		response.Header.Set("Content-Length", strconv.Itoa(len(response.Value)))
+	}
+	copyHeader(w.Header(), c.transform(response.Header))
+	c.annotate(r, w.Header(), key, status, &response)
+	if c.notModified(r, response) {
+		w.Header().Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if response.StatusCode != 0 {
+		w.WriteHeader(response.StatusCode)
+	}
+	if r.Method != http.MethodHead {
+		w.Write(response.Value)
+	}
+}
+
+// refresh reports whether a request asks, and is authorized, to refresh its
+// cached response.
+func (c *Client) refresh(r *http.Request) bool {
+	return c.requests(r, c.refreshKey, c.refreshHeader)
+}
+
+// purge reports whether a request asks, and is authorized, to purge its
+// cached response.
+func (c *Client) purge(r *http.Request) bool {
+	return c.requests(r, c.purgeKey, c.purgeHeader)
+}
+
+// requests reports whether a request asks, and is authorized, for an
+// operation on its cached response, with the given parameter or header. The
+// parameter is removed from the request URL, so that it doesn't change the
+// request key, whether the operation is authorized or not.
+func (c *Client) requests(r *http.Request, param, header string) bool {
+	requested := false
+	if param != "" {
+		params := r.URL.Query()
+		if _, ok := params[param]; ok {
+			requested = true
+			delete(params, param)
+			r.URL.RawQuery = params.Encode()
+		}
+	}
+	if header != "" && r.Header.Get(header) != "" {
+		requested = true
+	}
+
+	return requested && (c.refreshAuthorizer == nil || c.refreshAuthorizer(r))
+}
+
+// head handles a HEAD request, serving the headers of the response cached
+// for the equivalent GET request, if any. Responses to HEAD requests are
+// never cached, as they lack the body a GET request expects. Purges and
+// refreshes apply to the response of the GET request, a refresh only
+// releasing it before passing the request through.
+func (c *Client) head(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	get := r.Clone(r.Context())
+	get.Method = http.MethodGet
+	get.Body = http.NoBody
+	isPurge := c.purge(get)
+	isRefresh := c.refresh(get)
+	if !c.cacheableFn(get) || (!isPurge && !isRefresh && c.bypasses(r)) {
+		c.annotate(r, w.Header(), "", cacheBypass, nil)
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	sortURLParams(get.URL)
+	key, err := c.key(get)
+	if err != nil {
+		c.hooks.error(r, "", err)
+		c.annotate(r, w.Header(), "", cacheBypass, nil)
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	if isPurge {
+		c.invalidate(get, key)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if isRefresh {
+		c.invalidate(get, key)
+		c.hooks.refresh(r, key)
+		c.annotate(r, w.Header(), key, cacheBypass, nil)
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	_, response, ok := c.lookup(key, get)
+	if now := time.Now(); ok && response.Expiration.After(now) && c.satisfies(r, response, now) {
+		c.serve(w, r, key, response, cacheHit)
+		return
+	}
+
+	atomic.AddUint64(&c.misses, 1)
+	c.hooks.miss(r, key)
+	c.annotate(r, w.Header(), key, cacheMiss, nil)
+	next.ServeHTTP(w, r)
+}
+
+// transform applies the header transform function, if any, to a copy of h.
+func (c *Client) transform(h http.Header) http.Header {
+	if c.headerTransformFn == nil {
+		return h
+	}
+	return c.headerTransformFn(h.Clone())
+}
+
+// annotate sets the X-Cache header to the cache status of a response, and
+// the Age header of cached responses, when cache status headers are enabled,
+// then the debug headers, when enabled for the request. The key is empty
+// when unknown.
+func (c *Client) annotate(r *http.Request, h http.Header, key, status string, response *Response) {
+	if c.statusHeaders {
+		h.Set("X-Cache", status)
+		if response != nil {
+			age := time.Since(response.StoredAt)
+			if age < 0 || response.StoredAt.IsZero() {
+				age = 0
+			}
+			h.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+		}
+	}
+
+	if c.debugHeaders && (c.debugAuthorizer == nil || c.debugAuthorizer(r)) {
+		c.debug(h, key, response)
+	}
+}
+
+// debug sets the debug headers of a response: the hashed key, and the time
+// left until expiration and the storage date of cached responses.
+func (c *Client) debug(h http.Header, key string, response *Response) {
+	if key != "" {
+		hashed := c.adapterKey(key)
+		if c.keyHashFn == nil {
+			hashed = SHA256Key([]byte(hashed))
+		}
+		h.Set("X-Cache-Key", hashed)
+	}
+	if response != nil {
+		if !response.Expiration.IsZero() {
+			ttl := time.Until(response.Expiration)
+			h.Set("X-Cache-TTL-Remaining", strconv.FormatInt(int64(ttl/time.Second), 10))
+		}
+		if !response.StoredAt.IsZero() {
+			h.Set("X-Cache-Stored-At", response.StoredAt.UTC().Format(http.TimeFormat))
+		}
+	}
+}
+
+// save caches a response written by the handler, when cacheable. The header
+// is modified in place with any header the cached response gains, such as a
+// generated ETag, so it must not be the one already sent to the client.
+func (c *Client) save(key string, r *http.Request, statusCode int, header http.Header, value []byte) {
+	negative := statusCode == http.StatusNotFound || statusCode == http.StatusGone
+	if (statusCode >= 400 && !(negative && c.negativeTTL > 0)) || !c.storable(header) || !c.cachesStatus(statusCode) {
+		return
+	}
+
+	now := time.Now()
+	// Negative responses are cached for the negative TTL only, whatever the
+	// rules and the TTL function.
+	var ttl time.Duration
+	if negative {
+		ttl = c.negativeTTL
+	} else if rule := c.rule(r); rule != nil {
+		ttl = rule.TTL
+	}
+	if ttl <= 0 && c.ttlFn != nil {
+		ttl = c.ttlFn(r, &http.Response{
+			Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+			StatusCode:    statusCode,
+			Proto:         r.Proto,
+			ProtoMajor:    r.ProtoMajor,
+			ProtoMinor:    r.ProtoMinor,
+			Header:        header,
+			Body:          ioutil.NopCloser(bytes.NewReader(value)),
+			ContentLength: int64(len(value)),
+			Request:       r,
+		})
+	}
+	if ttl <= 0 {
+		ttl = c.lifetime(header, now)
+	}
+	if ttl <= 0 {
+		return
+	}
+	if c.adaptive != nil && !negative {
+		ttl = c.adaptive.ttl(key, ttl)
+	}
+	if c.ttlJitter > 0 {
+		ttl += time.Duration(float64(ttl) * c.ttlJitter * (2*rand.Float64() - 1))
+	}
+	if c.etag && header.Get("ETag") == "" {
+		header.Set("ETag", etag(value))
+	}
+
+	tags := c.tags(r, header)
+	stored := c.transform(header)
+	if !c.privateCaching && stored.Get("Set-Cookie") != "" {
+		return
+	}
+
+	response := Response{
+		Value:      value,
+		StatusCode: statusCode,
+		Header:     stored,
+		Expiration: now.Add(ttl),
+		StoredAt:   now,
+		LastAccess: now,
+		Frequency:  1,
+		Vary:       varyHeaders(header),
+	}
+	if c.requestMetadata {
+		response.Request = c.requestInfo(r)
+	}
+	c.store(key, r, c.compress(r, key, response), tags)
+}
+
+// requestInfo returns the metadata of a request stored with the responses
+// it generates.
+func (c *Client) requestInfo(r *http.Request) *RequestInfo {
+	info := &RequestInfo{Method: r.Method, URL: absoluteURL(r)}
+	for _, name := range c.requestMetadataHeaders {
+		if values := r.Header.Values(name); len(values) > 0 {
+			if info.Header == nil {
+				info.Header = http.Header{}
+			}
+			info.Header[name] = append([]string(nil), values...)
+		}
+	}
+
+	return info
+}
+
+// absoluteURL returns the URL of a request, with the host and scheme it was
+// received with when relative.
+func absoluteURL(r *http.Request) string {
+	u := *r.URL
+	if u.Host == "" {
+		u.Host = r.Host
+	}
+	if u.Scheme == "" {
+		u.Scheme = "http"
+		if r.TLS != nil {
+			u.Scheme = "https"
+		}
+	}
+
+	return u.String()
+}
+
+// revalidate refreshes the cached response for a key in the background,
+// invoking the handler with a clone of the request. Only one revalidation
+// per key runs at a time.
+func (c *Client) revalidate(next http.Handler, r *http.Request, key string) {
+	if _, running := c.revalidating.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+
+	req := r.Clone(context.WithValue(detachedContext{r.Context()}, revalidationKey{}, true))
+	req.Body = http.NoBody
+	go func() {
+		defer c.revalidating.Delete(key)
+
+		rw := newResponseWriter(nil, nil)
+		rw.limit = c.maxBodySize
+		if p := try(next, rw, req); p != nil || !rw.finish() {
+			return
+		}
+		c.save(key, req, rw.status, rw.stored, rw.body.Bytes())
+	}()
+}
+
+// retain returns until when the adapter keeps a response expiring at the
+// given date, which includes the windows it may be served stale.
+func (c *Client) retain(expiration time.Time) time.Time {
+	if c.staleIfError > c.staleWhileRevalidate {
+		return expiration.Add(c.staleIfError)
+	}
+	return expiration.Add(c.staleWhileRevalidate)
+}
+
+// get retrieves the cached bytes for a key, reporting adapter failures and
+// treating them as misses.
+func (c *Client) get(r *http.Request, key string) ([]byte, bool) {
+	var b []byte
+	var ok bool
+	err := c.call(r.Context(), "get", func(ctx context.Context) (err error) {
+		b, ok, err = c.adapter.Get(ctx, c.adapterKey(key))
+		return err
+	})
+	if err != nil {
+		c.hooks.error(r, key, fmt.Errorf("adapter get: %w", err))
+		return nil, false
+	}
+
+	return b, ok
+}
+
+// set caches bytes for a key, reporting whether the adapter succeeded.
+func (c *Client) set(r *http.Request, key string, b []byte, expiration time.Time) bool {
+	err := c.call(r.Context(), "set", func(ctx context.Context) error {
+		return c.adapter.Set(ctx, c.adapterKey(key), b, expiration)
+	})
+	if err != nil {
+		c.hooks.error(r, key, fmt.Errorf("adapter set: %w", err))
+		return false
+	}
+
+	return true
+}
+
+// release frees the cache for a key, reporting adapter failures.
+func (c *Client) release(r *http.Request, key string) {
+	err := c.call(r.Context(), "release", func(ctx context.Context) error {
+		return c.adapter.Release(ctx, c.adapterKey(key))
+	})
+	if err != nil {
+		c.hooks.error(r, key, fmt.Errorf("adapter release: %w", err))
+	}
+}
+
+// invalidate releases a key on purpose, unlike expired or corrupt entries,
+// publishing the invalidation to the other instances.
+func (c *Client) invalidate(r *http.Request, key string) {
+	c.release(r, key)
+	c.publish(r.Context(), InvalidationKey, key)
+}
+
+// adapterKey returns the key passed to the adapter for a cache key, which is
+// prefixed with the cache version, if any, then hashed when key hashing is
+// enabled.
+func (c *Client) adapterKey(key string) string {
+	if c.version != "" {
+		key = c.version + ":" + key
+	}
+	if c.keyHashFn == nil {
+		return key
+	}
+	return c.keyHashFn([]byte(key))
+}
+
+// call runs an adapter operation with the given context, timing it when a
+// metrics sink is set. When an adapter timeout is set, the context gets a
+// deadline and the operation is given up on once it passes, even if the
+// adapter doesn't honor its context.
+func (c *Client) call(ctx context.Context, op string, fn func(context.Context) error) error {
+	if c.hooks.metrics != nil {
+		defer c.hooks.timing("adapter.latency", time.Now(), "op:"+op)
+	}
+	if c.adapterTimeout <= 0 {
+		return fn(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.adapterTimeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// lookup retrieves the cached response for a key, following the marker of
+// responses cached with a Vary header to the secondary key matching the
+// request. It also returns the key the response was found under.
+func (c *Client) lookup(key string, r *http.Request) (string, Response, bool) {
+	b, ok := c.get(r, key)
+	if !ok {
+		return key, Response{}, false
+	}
+
+	response, ok := c.decode(r, key, b)
+	if ok && len(response.Vary) > 0 {
+		key = varyKey(key, response.Vary, r.Header)
+		if b, ok = c.get(r, key); !ok {
+			return key, Response{}, false
+		}
+		response, ok = c.decode(r, key, b)
+	}
+
+	return key, response, ok
+}
+
+// encode marshals a response with the client codec, encrypting and signing
+// it when enabled, and reports failures to the OnError hook.
+func (c *Client) encode(r *http.Request, key string, response Response) ([]byte, bool) {
+	if c.keyVerification {
+		response.Key = key
+	}
+	b, err := c.codec.Marshal(response)
+	if err != nil {
+		c.hooks.error(r, key, fmt.Errorf("codec marshal: %w", err))
+		return nil, false
+	}
+	if c.encryption != nil {
+		if b, err = c.encryption.seal(key, b); err != nil {
+			c.hooks.error(r, key, fmt.Errorf("encrypt: %w", err))
+			return nil, false
+		}
+	}
+	if c.signing != nil {
+		b = c.signing.sign(key, b)
+	}
+
+	return b, true
+}
+
+// decode verifies and decrypts, when enabled, and unmarshals a response with
+// the client codec. Failures are reported to the OnVerifyFailure or OnError
+// hook and the corrupt entry is released.
+func (c *Client) decode(r *http.Request, key string, b []byte) (Response, bool) {
+	if c.signing != nil {
+		var err error
+		if b, err = c.signing.verify(key, b); err != nil {
+			c.hooks.verifyFailure(r, key, err)
+			c.release(r, key)
+			return Response{}, false
+		}
+	}
+	if c.encryption != nil {
+		var err error
+		if b, err = c.encryption.open(key, b); err != nil {
+			c.hooks.decodeFailure(r, key, fmt.Errorf("decrypt: %w", err))
+			c.release(r, key)
+			return Response{}, false
+		}
+	}
+
+	response, err := c.codec.Unmarshal(b)
+	if err != nil {
+		c.hooks.decodeFailure(r, key, fmt.Errorf("codec unmarshal: %w", err))
+		c.release(r, key)
+		return Response{}, false
+	}
+	if c.keyVerification && response.Key != key {
+		c.hooks.error(r, key, fmt.Errorf("key hash collides with %q", response.Key))
+		return Response{}, false
+	}
+
+	if response.Encoding != "" && !accepts(r, response.Encoding) {
+		value, err := Decompress(response.Encoding, response.Value)
+		if err != nil {
+			c.hooks.decodeFailure(r, key, fmt.Errorf("decompress: %w", err))
+			c.release(r, key)
+			return Response{}, false
+		}
+		response.Value = value
+		response.Encoding = ""
+	}
+
+	return response, true
+}
+
+// compress compresses the body of a response when compression is enabled
+// and the body is large enough and not already encoded, reporting failures
+// to the OnError hook.
+func (c *Client) compress(r *http.Request, key string, response Response) Response {
+	if c.compression == "" || response.Encoding != "" || len(response.Value) == 0 ||
+		len(response.Value) < c.compressionMinSize || response.Header.Get("Content-Encoding") != "" {
+		return response
+	}
+
+	value, err := Compress(c.compression, response.Value)
+	if err != nil {
+		c.hooks.error(r, key, fmt.Errorf("compress: %w", err))
+		return response
+	}
+	response.Value = value
+	response.Encoding = c.compression
+	if c.compression != Snappy {
+		if response.Header == nil {
+			response.Header = http.Header{}
+		} else {
+			response.Header = response.Header.Clone()
+		}
+		addVary(response.Header, "Accept-Encoding")
+	}
+
+	return response
+}
+
+// store caches a response under key. A response with a Vary header is cached
+// under the secondary key matching the request instead, with key holding a
+// marker that lists the headers it varies on.
+func (c *Client) store(key string, r *http.Request, response Response, tags []string) {
+	if len(response.Vary) > 0 {
+		for _, name := range response.Vary {
+			if name == "*" {
+				return
+			}
+		}
+
+		primaryKey := key
+		marker := Response{
+			Expiration: response.Expiration,
+			StoredAt:   response.StoredAt,
+			Vary:       response.Vary,
+		}
+		c.write(r, primaryKey, func(r *http.Request) {
+			b, ok := c.encode(r, primaryKey, marker)
+			if ok && c.set(r, primaryKey, b, c.retain(marker.Expiration)) {
+				c.tag(r, primaryKey, tags, c.retain(marker.Expiration))
+			}
+		})
+		key = varyKey(key, response.Vary, r.Header)
+	}
+
+	c.write(r, key, func(r *http.Request) {
+		b, ok := c.encode(r, key, response)
+		if ok && c.set(r, key, b, c.retain(response.Expiration)) {
+			c.tag(r, key, tags, c.retain(response.Expiration))
+			atomic.AddUint64(&c.stores, 1)
+			atomic.AddUint64(&c.storedBytes, uint64(len(b)))
+			c.hooks.store(r, key, response)
+		}
+	})
+}
+
+// write runs a cache write for a key, queueing it for the async write
+// workers when enabled. Writes are dropped, and reported to the OnError hook
+// with ErrWriteDropped, when the queue is full or the client is closed.
+func (c *Client) write(r *http.Request, key string, fn func(*http.Request)) {
+	if c.writes == nil {
+		fn(r)
+		return
+	}
+
+	r = r.WithContext(detachedContext{r.Context()})
+
+	c.writesMutex.RLock()
+	queued := false
+	if !c.closed {
+		select {
+		case c.writes <- func() { fn(r) }:
+			queued = true
+		default:
+		}
+	}
+	c.writesMutex.RUnlock()
+
+	if !queued {
+		atomic.AddUint64(&c.droppedWrites, 1)
+		c.hooks.error(r, key, ErrWriteDropped)
+	}
+}
+
+// DroppedWrites returns the number of async writes dropped so far.
+func (c *Client) DroppedWrites() uint64 {
+	return atomic.LoadUint64(&c.droppedWrites)
+}
+
+// Close unsubscribes from the invalidation bus, stops the refresher and the
+// async write workers, if any, once the queued writes are done. Writes
+// issued afterwards are dropped.
+func (c *Client) Close() error {
+	if c.busCancel != nil {
+		c.busCancel()
+	}
+	if c.refresher != nil {
+		c.refresher.stop()
+	}
+	if c.writes == nil {
+		return nil
+	}
+
+	c.writesMutex.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.writes)
+	}
+	c.writesMutex.Unlock()
+
+	c.workers.Wait()
+
+	return nil
+}
+
+// bypasses reports whether the request asks to skip the cache lookup, when
+// request directives are honored.
+func (c *Client) bypasses(r *http.Request) bool {
+	if !c.requestDirectives {
+		return false
+	}
+	if parseCacheControl(r.Header).has("no-cache") {
+		return true
+	}
+	for _, v := range r.Header.Values("Pragma") {
+		if strings.EqualFold(strings.TrimSpace(v), "no-cache") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// storable reports whether the Cache-Control directives of a response allow
+// caching it, unless response directives are ignored.
+func (c *Client) storable(h http.Header) bool {
+	if c.ignoreResponseDirectives {
+		return true
+	}
+
+	cc := parseCacheControl(h)
+	return !cc.has("no-store") && !cc.has("private") && !cc.has("no-cache")
+}
+
+// notModified reports whether a conditional request matches the validators
+// of a cached response, when ETags are enabled. If-None-Match takes
+// precedence over If-Modified-Since, which is compared against the
+// Last-Modified header or, when absent, the date the response was cached.
+func (c *Client) notModified(r *http.Request, response Response) bool {
+	if !c.etag || response.StatusCode != http.StatusOK {
+		return false
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		tag := strings.TrimPrefix(response.Header.Get("ETag"), "W/")
+		if tag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+				return true
+			}
+		}
+		return false
+	}
+
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified := response.StoredAt
+	if lm, err := http.ParseTime(response.Header.Get("Last-Modified")); err == nil {
+		modified = lm
+	}
+
+	return !modified.Truncate(time.Second).After(ims)
+}
+
+// lifetime returns how long a response with the given header is cached.
+func (c *Client) lifetime(h http.Header, now time.Time) time.Duration {
+	if !c.ttlFromHeaders {
+		return c.ttl
+	}
+
+	cc := parseCacheControl(h)
+	if ttl, ok := cc.duration("s-maxage"); ok {
+		return ttl
+	}
+	if ttl, ok := cc.duration("max-age"); ok {
+		return ttl
+	}
+	if v := h.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return 0
+		}
+		if date, err := http.ParseTime(h.Get("Date")); err == nil {
+			return expires.Sub(date)
+		}
+		return expires.Sub(now)
+	}
+
+	return c.ttl
+}
+
+// satisfies reports whether a fresh cached response meets the max-age and
+// min-fresh directives of the request, when request directives are honored.
+func (c *Client) satisfies(r *http.Request, response Response, now time.Time) bool {
+	if !c.requestDirectives {
+		return true
+	}
+
+	cc := parseCacheControl(r.Header)
+	if maxAge, ok := cc.duration("max-age"); ok && now.Sub(response.StoredAt) > maxAge {
+		return false
+	}
+	if minFresh, ok := cc.duration("min-fresh"); ok && response.Expiration.Sub(now) < minFresh {
+		return false
+	}
+
+	return true
+}
+
+// =============================================================================
+
+// copyHeader replaces the values in dst of every header in src, keeping
+// multiple values of a header apart.
+func copyHeader(dst, src http.Header) {
+	for k, vv := range src {
+		dst.Del(k)
+		for _, v := range vv {
+			dst.Add(k, v)
+		}
+	}
+}
+
+// try invokes the handler, recovering from and returning its panic value,
+// unless it panicked with http.ErrAbortHandler.
+func try(next http.Handler, w http.ResponseWriter, r *http.Request) (p interface{}) {
+	defer func() {
+		if p = recover(); p == http.ErrAbortHandler {
+			panic(p)
+		}
+	}()
+
+	next.ServeHTTP(w, r)
+
+	return nil
+}
+
+// revalidationKey is the context key marking the requests of background
+// revalidations.
+type revalidationKey struct{}
+
+// IsRevalidation reports whether a request context is the one of a background
+// revalidation, which the middleware runs after the request it was triggered
+// by has been answered, so that handlers can tell them apart.
+func IsRevalidation(ctx context.Context) bool {
+	revalidation, _ := ctx.Value(revalidationKey{}).(bool)
+	return revalidation
+}
+
+// detachedContext keeps the values of a request context without its deadline
+// and cancellation, for work that outlives the request.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func generateKey(r *http.Request) (string, error) {
+	key := r.URL.String()
+	if r.Method != http.MethodGet {
+		key = r.Method + " " + key
+	}
+	if r.Method == http.MethodPost && r.Body != nil {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return "", fmt.Errorf("error reading body: %v", err)
+		}
+		// The body is restored for the handler.
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		return fmt.Sprintf("%s%s", key, string(body)), nil
+	}
+	return key, nil
+}
+
+// etag returns the strong entity tag of a response body.
+func etag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// varyHeaders returns the sorted, canonical request header names listed by
+// the Vary header of a response.
+func varyHeaders(h http.Header) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// key generates the cache key of a request with the key function, adding
+// the values of the request headers and cookies the cache varies on, and
+// the component derived from the request context.
+func (c *Client) key(r *http.Request) (string, error) {
+	key, err := c.keygenFn(r)
+	if err != nil {
+		return "", err
+	}
+
+	names := c.varyRequestHeaders
+	if rule := c.rule(r); rule != nil && len(rule.VaryHeaders) > 0 {
+		names = append(append([]string(nil), names...), rule.VaryHeaders...)
+	}
+	if len(names) > 0 {
+		values := url.Values{}
+		for _, name := range names {
+			values[name] = r.Header.Values(name)
+		}
+		key += "#headers:" + values.Encode()
+	}
+	if len(c.varyCookies) > 0 {
+		values := url.Values{}
+		for _, name := range c.varyCookies {
+			values[name] = nil
+		}
+		for _, cookie := range r.Cookies() {
+			if _, ok := values[cookie.Name]; ok {
+				values[cookie.Name] = append(values[cookie.Name], cookie.Value)
+			}
+		}
+		key += "#cookies:" + values.Encode()
+	}
+	if c.keyContextFn != nil {
+		key += "#context:" + url.QueryEscape(c.keyContextFn(r.Context()))
+	}
+
+	return key, nil
+}
+
+// varyKey derives the secondary key of a response varying on the given
+// request headers.
+func varyKey(key string, names []string, h http.Header) string {
+	values := url.Values{}
+	for _, name := range names {
+		values[name] = h.Values(name)
+	}
+
+	return key + "#vary:" + values.Encode()
+}
+
+// cachesStatus reports whether responses with a status code may be cached,
+// according to the status codes set with WithStatusCodes, if any.
+func (c *Client) cachesStatus(statusCode int) bool {
+	if c.statusCodes == nil {
+		return true
+	}
+	for _, code := range c.statusCodes {
+		if statusCode == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isCacheable is the default cacheable function. It accepts requests using
+// one of the configured methods and, unless private caching is enabled,
+// carrying no credentials, so their responses can be shared between users.
+func (c *Client) isCacheable(r *http.Request) bool {
+	if !c.privateCaching && (r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "") {
+		return false
+	}
+	for _, method := range c.methods {
+		if r.Method == method {
+			return true
+		}
+	}
+
+	return false
 }
 
 func sortURLParams(URL *url.URL) {
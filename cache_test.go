@@ -3,12 +3,16 @@ package cache
 import (
 	"bytes"
 	"context"
+	"encoding/gob"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -364,3 +368,1997 @@ func TestGenerateKey(t *testing.T) {
 		})
 	}
 }
+
+func TestBytesToResponseLegacy(t *testing.T) {
+	var b bytes.Buffer
+	gob.NewEncoder(&b).Encode(&responseMeta{
+		Value:     []byte("value 1"),
+		Header:    http.Header{"Content-Type": []string{"text/plain"}},
+		Frequency: 3,
+	})
+
+	got := BytesToResponse(b.Bytes())
+	if string(got.Value) != "value 1" {
+		t.Errorf("BytesToResponse() Value = %s, want %s", got.Value, "value 1")
+	}
+	if got.Header.Get("Content-Type") != "text/plain" || got.Frequency != 3 {
+		t.Errorf("BytesToResponse() = %+v, want legacy metadata", got)
+	}
+	if got.StatusCode != http.StatusOK {
+		t.Errorf("BytesToResponse() StatusCode = %v, want %v", got.StatusCode, http.StatusOK)
+	}
+}
+
+func TestBytesToResponseVersions(t *testing.T) {
+	b := Response{Value: []byte("value 1"), StatusCode: http.StatusCreated}.Bytes()
+
+	tests := []struct {
+		name           string
+		version        byte
+		wantValue      string
+		wantStatusCode int
+	}{
+		{"decodes current version", responseVersion, "value 1", http.StatusCreated},
+		{"defaults status code of version 1", 1, "value 1", http.StatusOK},
+		{"ignores unknown versions", responseVersion + 1, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := append([]byte(nil), b...)
+			payload[len(responseMagic)] = tt.version
+
+			got := BytesToResponse(payload)
+			if string(got.Value) != tt.wantValue || got.StatusCode != tt.wantStatusCode {
+				t.Errorf("BytesToResponse() = %s %v, want %s %v", got.Value, got.StatusCode, tt.wantValue, tt.wantStatusCode)
+			}
+		})
+	}
+}
+
+func TestMiddlewareStatusCode(t *testing.T) {
+	adapter := &adapterMock{store: map[string][]byte{}}
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+	)
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "http://foo.bar/moved")
+		w.WriteHeader(http.StatusMovedPermanently)
+	}))
+
+	for _, name := range []string{"returns new response", "returns cached response"} {
+		t.Run(name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != http.StatusMovedPermanently {
+				t.Errorf("*Client.Middleware() = %v, want %v", w.Code, http.StatusMovedPermanently)
+			}
+		})
+	}
+	if len(adapter.store) != 1 {
+		t.Errorf("store length = %v, want 1", len(adapter.store))
+	}
+}
+
+func TestBytesToResponseZeroCopy(t *testing.T) {
+	b := Response{Value: []byte("value 1")}.Bytes()
+
+	got := BytesToResponse(b)
+	if &got.Value[0] != &b[len(b)-len(got.Value)] {
+		t.Error("BytesToResponse() Value does not reference the encoded bytes")
+	}
+}
+
+func BenchmarkBytesToResponse(b *testing.B) {
+	for _, size := range []int{1 << 10, 64 << 10, 1 << 20} {
+		r := Response{
+			Value:      make([]byte, size),
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Expiration: time.Now().Add(1 * time.Minute),
+		}
+
+		var legacy bytes.Buffer
+		gob.NewEncoder(&legacy).Encode(&r)
+		framed := r.Bytes()
+
+		b.Run(fmt.Sprintf("gob-%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				BytesToResponse(legacy.Bytes())
+			}
+		})
+		b.Run(fmt.Sprintf("framed-%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				BytesToResponse(framed)
+			}
+		})
+	}
+}
+
+func TestMiddlewareRequestDirectives(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name         string
+		directives   bool
+		cacheControl string
+		wantBody     string
+	}{
+		{"ignores directives when disabled", false, "max-age=10", "cached"},
+		{"serves entry within max-age", true, "max-age=60", "cached"},
+		{"refetches entry older than max-age", true, "max-age=10", "new value"},
+		{"serves entry satisfying min-fresh", true, "min-fresh=10", "cached"},
+		{"refetches entry not satisfying min-fresh", true, "min-fresh=60", "new value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := &adapterMock{
+				store: map[string][]byte{
+					"http://foo.bar/test-1": Response{
+						Value:      []byte("cached"),
+						StoredAt:   now.Add(-30 * time.Second),
+						Expiration: now.Add(30 * time.Second),
+					}.Bytes(),
+				},
+			}
+			client, _ := NewClient(
+				WithAdapter(adapter),
+				WithTTL(1*time.Minute),
+				WithRequestDirectives(tt.directives),
+			)
+			handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("new value"))
+			}))
+
+			r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+			r.Header.Set("Cache-Control", tt.cacheControl)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("*Client.Middleware() = %v, want %v", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestMiddlewareRequestNoCache(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   http.Header
+		wantBody string
+	}{
+		{"serves cached response", http.Header{}, "cached"},
+		{"bypasses on Cache-Control: no-cache", http.Header{"Cache-Control": {"no-cache"}}, "new value"},
+		{"bypasses on Pragma: no-cache", http.Header{"Pragma": {"no-cache"}}, "new value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := &adapterMock{
+				store: map[string][]byte{
+					"http://foo.bar/test-1": Response{
+						Value:      []byte("cached"),
+						StoredAt:   time.Now(),
+						Expiration: time.Now().Add(1 * time.Minute),
+					}.Bytes(),
+				},
+			}
+			client, _ := NewClient(
+				WithAdapter(adapter),
+				WithTTL(1*time.Minute),
+				WithRequestDirectives(true),
+			)
+			handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("new value"))
+			}))
+
+			r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+			r.Header = tt.header
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("*Client.Middleware() = %v, want %v", w.Body.String(), tt.wantBody)
+			}
+			if got := BytesToResponse(adapter.store["http://foo.bar/test-1"]).Value; string(got) != tt.wantBody {
+				t.Errorf("cached value = %s, want %v", got, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestMiddlewareResponseDirectives(t *testing.T) {
+	tests := []struct {
+		name         string
+		directives   bool
+		cacheControl string
+		wantStored   bool
+	}{
+		{"stores cacheable response", true, "public, max-age=60", true},
+		{"skips no-store response", true, "no-store", false},
+		{"skips private response", true, "private", false},
+		{"skips no-cache response", true, "no-cache", false},
+		{"stores no-store response when disabled", false, "no-store", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := &adapterMock{store: map[string][]byte{}}
+			client, _ := NewClient(
+				WithAdapter(adapter),
+				WithTTL(1*time.Minute),
+				WithResponseDirectives(tt.directives),
+			)
+			handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Cache-Control", tt.cacheControl)
+				w.Write([]byte("new value"))
+			}))
+
+			r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			if _, ok := adapter.store["http://foo.bar/test-1"]; ok != tt.wantStored {
+				t.Errorf("response stored = %v, want %v", ok, tt.wantStored)
+			}
+		})
+	}
+}
+
+func TestLifetime(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		enabled bool
+		header  http.Header
+		want    time.Duration
+	}{
+		{
+			"uses ttl when disabled",
+			false,
+			http.Header{"Cache-Control": {"max-age=10"}},
+			1 * time.Minute,
+		},
+		{
+			"uses ttl without headers",
+			true,
+			http.Header{},
+			1 * time.Minute,
+		},
+		{
+			"prefers s-maxage",
+			true,
+			http.Header{"Cache-Control": {"max-age=10, s-maxage=20"}},
+			20 * time.Second,
+		},
+		{
+			"uses max-age",
+			true,
+			http.Header{"Cache-Control": {"max-age=10"}},
+			10 * time.Second,
+		},
+		{
+			"uses Expires relative to Date",
+			true,
+			http.Header{
+				"Date":    {now.UTC().Format(http.TimeFormat)},
+				"Expires": {now.Add(30 * time.Second).UTC().Format(http.TimeFormat)},
+			},
+			30 * time.Second,
+		},
+		{
+			"treats invalid Expires as expired",
+			true,
+			http.Header{"Expires": {"0"}},
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := NewClient(
+				WithAdapter(&adapterMock{}),
+				WithTTL(1*time.Minute),
+				WithTTLFromHeaders(tt.enabled),
+			)
+			if got := client.lifetime(tt.header, now); got != tt.want {
+				t.Errorf("*Client.lifetime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareVary(t *testing.T) {
+	adapter := &adapterMock{store: map[string][]byte{}}
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+	)
+
+	counter := 0
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counter++
+		if r.URL.Path == "/any" {
+			w.Header().Set("Vary", "*")
+		} else {
+			w.Header().Set("Vary", "accept-language, Accept-Encoding")
+		}
+		w.Write([]byte(fmt.Sprintf("%s %d", r.Header.Get("Accept-Language"), counter)))
+	}))
+
+	tests := []struct {
+		name     string
+		url      string
+		language string
+		wantBody string
+	}{
+		{"returns new response for first variant", "http://foo.bar/test-1", "en", "en 1"},
+		{"returns new response for second variant", "http://foo.bar/test-1", "pt", "pt 2"},
+		{"returns cached first variant", "http://foo.bar/test-1", "en", "en 1"},
+		{"returns cached second variant", "http://foo.bar/test-1", "pt", "pt 2"},
+		{"returns new response for Vary *", "http://foo.bar/any", "en", "en 3"},
+		{"does not cache Vary *", "http://foo.bar/any", "en", "en 4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+			r.Header.Set("Accept-Language", tt.language)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("*Client.Middleware() = %v, want %v", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestVaryHeaders(t *testing.T) {
+	h := http.Header{"Vary": {"accept-language, Accept-Encoding", "Accept-Language"}}
+	want := []string{"Accept-Encoding", "Accept-Language"}
+	if got := varyHeaders(h); !reflect.DeepEqual(got, want) {
+		t.Errorf("varyHeaders() = %v, want %v", got, want)
+	}
+}
+
+func TestMiddlewareETag(t *testing.T) {
+	adapter := &adapterMock{store: map[string][]byte{}}
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithETag(true),
+	)
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("value 1"))
+	}))
+	tag := etag([]byte("value 1"))
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		wantCode int
+		wantBody string
+		wantETag string
+	}{
+		{"returns new response", http.Header{}, 200, "value 1", ""},
+		{"returns cached response with ETag", http.Header{}, 200, "value 1", tag},
+		{"returns 304 for matching If-None-Match", http.Header{"If-None-Match": {`"foo", ` + tag}}, 304, "", tag},
+		{"returns 304 for wildcard If-None-Match", http.Header{"If-None-Match": {"*"}}, 304, "", tag},
+		{"returns cached response for other If-None-Match", http.Header{"If-None-Match": {`"foo"`}}, 200, "value 1", tag},
+		{
+			"returns 304 when not modified since",
+			http.Header{"If-Modified-Since": {time.Now().Add(1 * time.Minute).UTC().Format(http.TimeFormat)}},
+			304,
+			"",
+			tag,
+		},
+		{
+			"returns cached response when modified since",
+			http.Header{"If-Modified-Since": {time.Now().Add(-1 * time.Minute).UTC().Format(http.TimeFormat)}},
+			200,
+			"value 1",
+			tag,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+			r.Header = tt.header
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantCode || w.Body.String() != tt.wantBody {
+				t.Errorf("*Client.Middleware() = %v %v, want %v %v", w.Code, w.Body.String(), tt.wantCode, tt.wantBody)
+			}
+			if got := w.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("ETag = %v, want %v", got, tt.wantETag)
+			}
+		})
+	}
+}
+
+func TestMiddlewareStaleWhileRevalidate(t *testing.T) {
+	adapter := &adapterMock{
+		store: map[string][]byte{
+			"http://foo.bar/stale": Response{
+				Value:      []byte("stale value"),
+				Expiration: time.Now().Add(-10 * time.Second),
+			}.Bytes(),
+			"http://foo.bar/expired": Response{
+				Value:      []byte("expired value"),
+				Expiration: time.Now().Add(-2 * time.Minute),
+			}.Bytes(),
+		},
+	}
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithStaleWhileRevalidate(1*time.Minute),
+	)
+
+	revalidated := make(chan struct{})
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("new value"))
+		if got, want := IsRevalidation(r.Context()), r.URL.Path == "/stale"; got != want {
+			t.Errorf("IsRevalidation() = %v for %v, want %v", got, r.URL.Path, want)
+		}
+		if r.URL.Path == "/stale" {
+			close(revalidated)
+		}
+	}))
+
+	serve := func(url string) string {
+		r, _ := http.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Body.String()
+	}
+
+	if got := serve("http://foo.bar/stale"); got != "stale value" {
+		t.Errorf("*Client.Middleware() = %v, want %v", got, "stale value")
+	}
+	select {
+	case <-revalidated:
+	case <-time.After(1 * time.Second):
+		t.Fatal("stale response was not revalidated")
+	}
+	for i := 0; i < 100; i++ {
+		if _, running := client.revalidating.Load("http://foo.bar/stale"); !running {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := serve("http://foo.bar/stale"); got != "new value" {
+		t.Errorf("*Client.Middleware() = %v, want %v", got, "new value")
+	}
+	if got := serve("http://foo.bar/expired"); got != "new value" {
+		t.Errorf("*Client.Middleware() = %v, want %v", got, "new value")
+	}
+}
+
+func TestMiddlewareStaleIfError(t *testing.T) {
+	adapter := &adapterMock{
+		store: map[string][]byte{
+			"http://foo.bar/error": Response{
+				Value:      []byte("stale value"),
+				Expiration: time.Now().Add(-10 * time.Second),
+			}.Bytes(),
+			"http://foo.bar/panic": Response{
+				Value:      []byte("stale value"),
+				Expiration: time.Now().Add(-10 * time.Second),
+			}.Bytes(),
+			"http://foo.bar/expired": Response{
+				Value:      []byte("stale value"),
+				Expiration: time.Now().Add(-2 * time.Minute),
+			}.Bytes(),
+		},
+	}
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithStaleIfError(1*time.Minute),
+	)
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic("handler failure")
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("error"))
+	}))
+
+	tests := []struct {
+		name     string
+		url      string
+		wantCode int
+		wantBody string
+	}{
+		{"serves stale response on 5xx", "http://foo.bar/error", 200, "stale value"},
+		{"serves stale response on panic", "http://foo.bar/panic", 200, "stale value"},
+		{"surfaces error past the stale window", "http://foo.bar/expired", 500, "error"},
+		{"surfaces error without cached response", "http://foo.bar/missing", 500, "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantCode || w.Body.String() != tt.wantBody {
+				t.Errorf("*Client.Middleware() = %v %v, want %v %v", w.Code, w.Body.String(), tt.wantCode, tt.wantBody)
+			}
+		})
+	}
+	if _, ok := adapter.store["http://foo.bar/expired"]; ok {
+		t.Error("response past the stale window was not released")
+	}
+}
+
+func TestMiddlewareStatusHeaders(t *testing.T) {
+	adapter := &adapterMock{
+		store: map[string][]byte{
+			"http://foo.bar/hit": Response{
+				Value:      []byte("cached"),
+				StoredAt:   time.Now().Add(-30 * time.Second),
+				Expiration: time.Now().Add(30 * time.Second),
+			}.Bytes(),
+			"http://foo.bar/stale": Response{
+				Value:      []byte("cached"),
+				StoredAt:   time.Now().Add(-90 * time.Second),
+				Expiration: time.Now().Add(-30 * time.Second),
+			}.Bytes(),
+		},
+	}
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithRefreshKey("rk"),
+		WithStaleWhileRevalidate(1*time.Minute),
+		WithStatusHeaders(true),
+	)
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("new value"))
+	}))
+
+	tests := []struct {
+		name      string
+		method    string
+		url       string
+		wantCache string
+		wantAge   string
+	}{
+		{"reports hit", "GET", "http://foo.bar/hit", "HIT", "30"},
+		{"reports stale", "GET", "http://foo.bar/stale", "STALE", "90"},
+		{"reports miss", "GET", "http://foo.bar/miss", "MISS", ""},
+		{"reports refresh as bypass", "GET", "http://foo.bar/miss?rk=true", "BYPASS", ""},
+		{"reports uncacheable request as bypass", "POST", "http://foo.bar/hit", "BYPASS", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(tt.method, tt.url, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if got := w.Header().Get("X-Cache"); got != tt.wantCache {
+				t.Errorf("X-Cache = %v, want %v", got, tt.wantCache)
+			}
+			if got := w.Header().Get("Age"); got != tt.wantAge {
+				t.Errorf("Age = %v, want %v", got, tt.wantAge)
+			}
+		})
+	}
+}
+
+func TestMiddlewareDebugHeaders(t *testing.T) {
+	storedAt := time.Now().Add(-30 * time.Second).Truncate(time.Second)
+	adapter := &adapterMock{
+		store: map[string][]byte{
+			"http://foo.bar/hit": Response{
+				Value:      []byte("cached"),
+				StoredAt:   storedAt,
+				Expiration: time.Now().Add(90 * time.Second),
+			}.Bytes(),
+		},
+	}
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithDebugHeaders(true),
+		WithDebugAuthorizer(func(r *http.Request) bool {
+			return r.Header.Get("X-Debug") == "1"
+		}),
+	)
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("new value"))
+	}))
+
+	tests := []struct {
+		name         string
+		url          string
+		debug        bool
+		wantKey      string
+		wantTTL      string
+		wantStoredAt string
+	}{
+		{"reports hit", "http://foo.bar/hit", true, SHA256Key([]byte("http://foo.bar/hit")), "89", storedAt.UTC().Format(http.TimeFormat)},
+		{"reports miss", "http://foo.bar/miss", true, SHA256Key([]byte("http://foo.bar/miss")), "", ""},
+		{"requires authorization", "http://foo.bar/hit", false, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.debug {
+				r.Header.Set("X-Debug", "1")
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if got := w.Header().Get("X-Cache-Key"); got != tt.wantKey {
+				t.Errorf("X-Cache-Key = %v, want %v", got, tt.wantKey)
+			}
+			if got := w.Header().Get("X-Cache-TTL-Remaining"); got != tt.wantTTL {
+				t.Errorf("X-Cache-TTL-Remaining = %v, want %v", got, tt.wantTTL)
+			}
+			if got := w.Header().Get("X-Cache-Stored-At"); got != tt.wantStoredAt {
+				t.Errorf("X-Cache-Stored-At = %v, want %v", got, tt.wantStoredAt)
+			}
+		})
+	}
+}
+
+func TestMiddlewareRequestMetadata(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []ClientOption
+		want *RequestInfo
+	}{
+		{"is disabled by default", nil, nil},
+		{
+			"stores method and URL",
+			[]ClientOption{WithRequestMetadata()},
+			&RequestInfo{Method: http.MethodGet, URL: "http://foo.bar/test-1?a=1"},
+		},
+		{
+			"stores selected headers",
+			[]ClientOption{WithRequestMetadata("accept-language", "X-Missing")},
+			&RequestInfo{
+				Method: http.MethodGet,
+				URL:    "http://foo.bar/test-1?a=1",
+				Header: http.Header{"Accept-Language": {"en"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := &adapterMock{store: map[string][]byte{}}
+			client, _ := NewClient(append([]ClientOption{WithAdapter(adapter), WithTTL(1 * time.Minute)}, tt.opts...)...)
+			handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("value 1"))
+			}))
+
+			r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1?a=1", nil)
+			r.Header.Set("Accept-Language", "en")
+			r.Header.Set("X-Request-Id", "1")
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			b, ok := adapter.store["http://foo.bar/test-1?a=1"]
+			if !ok {
+				t.Fatalf("response not cached, store = %v", adapter.store)
+			}
+			if got := BytesToResponse(b).Request; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Response.Request = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestInfoNewRequest(t *testing.T) {
+	info := &RequestInfo{
+		Method: http.MethodGet,
+		URL:    "http://foo.bar/test-1?a=1",
+		Header: http.Header{"Accept-Language": {"en"}},
+	}
+	r, err := info.NewRequest(context.Background())
+	if err != nil {
+		t.Fatalf("RequestInfo.NewRequest() error = %v", err)
+	}
+	if r.Method != info.Method || r.URL.String() != info.URL || r.Header.Get("Accept-Language") != "en" {
+		t.Errorf("RequestInfo.NewRequest() = %v %v %v", r.Method, r.URL, r.Header)
+	}
+}
+
+func TestMiddlewareHeaders(t *testing.T) {
+	adapter := &adapterMock{store: map[string][]byte{}}
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithResponseDirectives(false),
+		WithHeaderTransform(func(h http.Header) http.Header {
+			h.Del("Set-Cookie")
+			return h
+		}),
+	)
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Link", "</style.css>; rel=preload")
+		w.Header().Add("Link", "</script.js>; rel=preload")
+		w.Header().Add("Set-Cookie", "a=1")
+		w.Header().Add("Set-Cookie", "b=2")
+		w.Write([]byte("value 1"))
+	}))
+
+	tests := []struct {
+		name          string
+		wantSetCookie []string
+	}{
+		{"returns new response headers", []string{"a=1", "b=2"}},
+		{"returns transformed cached response headers", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			wantLink := []string{"</style.css>; rel=preload", "</script.js>; rel=preload"}
+			if got := w.Header().Values("Link"); !reflect.DeepEqual(got, wantLink) {
+				t.Errorf("Link = %q, want %q", got, wantLink)
+			}
+			if got := w.Header().Values("Set-Cookie"); !reflect.DeepEqual(got, tt.wantSetCookie) {
+				t.Errorf("Set-Cookie = %q, want %q", got, tt.wantSetCookie)
+			}
+		})
+	}
+	if got := BytesToResponse(adapter.store["http://foo.bar/test-1"]).Header; got.Get("Set-Cookie") != "" {
+		t.Errorf("cached Set-Cookie = %q, want none", got.Values("Set-Cookie"))
+	}
+}
+
+func TestMiddlewarePrivateCaching(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []ClientOption
+		header     http.Header
+		setCookie  bool
+		wantStored bool
+	}{
+		{"stores public response", nil, http.Header{}, false, true},
+		{"skips response setting cookies", nil, http.Header{}, true, false},
+		{"skips request with Authorization", nil, http.Header{"Authorization": {"Bearer foo"}}, false, false},
+		{"skips request with Cookie", nil, http.Header{"Cookie": {"a=1"}}, false, false},
+		{
+			"stores private response when allowed",
+			[]ClientOption{WithPrivateCaching(true)},
+			http.Header{"Cookie": {"a=1"}},
+			true,
+			true,
+		},
+		{
+			"delegates requests to custom cacheable function",
+			[]ClientOption{WithCacheable(func(r *http.Request) bool { return true })},
+			http.Header{"Authorization": {"Bearer foo"}},
+			false,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := &adapterMock{store: map[string][]byte{}}
+			client, _ := NewClient(append([]ClientOption{
+				WithAdapter(adapter),
+				WithTTL(1 * time.Minute),
+			}, tt.opts...)...)
+			handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.setCookie {
+					w.Header().Set("Set-Cookie", "a=1")
+				}
+				w.Write([]byte("value 1"))
+			}))
+
+			r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+			r.Header = tt.header
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			if _, ok := adapter.store["http://foo.bar/test-1"]; ok != tt.wantStored {
+				t.Errorf("response stored = %v, want %v", ok, tt.wantStored)
+			}
+		})
+	}
+}
+
+func TestMiddlewareRequestCoalescing(t *testing.T) {
+	for _, coalescing := range []bool{false, true} {
+		t.Run(fmt.Sprintf("coalescing=%v", coalescing), func(t *testing.T) {
+			adapter := &adapterMock{store: map[string][]byte{}}
+			client, _ := NewClient(
+				WithAdapter(adapter),
+				WithTTL(1*time.Minute),
+				WithRequestCoalescing(coalescing),
+			)
+
+			var mutex sync.Mutex
+			calls := 0
+			handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mutex.Lock()
+				calls++
+				mutex.Unlock()
+				time.Sleep(100 * time.Millisecond)
+				w.Write([]byte("value 1"))
+			}))
+
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+					w := httptest.NewRecorder()
+					handler.ServeHTTP(w, r)
+					if w.Body.String() != "value 1" {
+						t.Errorf("*Client.Middleware() = %v, want %v", w.Body.String(), "value 1")
+					}
+				}()
+			}
+			wg.Wait()
+
+			if coalescing && calls != 1 {
+				t.Errorf("handler calls = %v, want 1", calls)
+			} else if !coalescing && calls != 10 {
+				t.Errorf("handler calls = %v, want 10", calls)
+			}
+		})
+	}
+}
+
+func TestMiddlewareStreaming(t *testing.T) {
+	adapter := &adapterMock{store: map[string][]byte{}}
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+	)
+
+	w := httptest.NewRecorder()
+	handler := client.Middleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("chunk 1 "))
+		rw.(http.Flusher).Flush()
+		if !w.Flushed || w.Body.String() != "chunk 1 " {
+			t.Errorf("client received %q flushed %v before the handler returned", w.Body.String(), w.Flushed)
+		}
+		rw.Write([]byte("chunk 2"))
+	}))
+
+	r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+	handler.ServeHTTP(w, r)
+
+	if w.Body.String() != "chunk 1 chunk 2" {
+		t.Errorf("*Client.Middleware() = %v, want %v", w.Body.String(), "chunk 1 chunk 2")
+	}
+	if got := BytesToResponse(adapter.store["http://foo.bar/test-1"]).Value; string(got) != "chunk 1 chunk 2" {
+		t.Errorf("cached value = %s, want %v", got, "chunk 1 chunk 2")
+	}
+}
+
+func TestMiddlewareMaxBodySize(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		contentLength bool
+		wantStored    bool
+	}{
+		{"stores body within limit", "value 1", false, true},
+		{"skips body over limit", "value 1 is too long", false, false},
+		{"skips declared Content-Length over limit", "value 1 is too long", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := &adapterMock{store: map[string][]byte{}}
+			client, _ := NewClient(
+				WithAdapter(adapter),
+				WithTTL(1*time.Minute),
+				WithMaxBodySize(10),
+			)
+			handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentLength {
+					w.Header().Set("Content-Length", fmt.Sprint(len(tt.body)))
+				}
+				w.Write([]byte(tt.body[:5]))
+				w.Write([]byte(tt.body[5:]))
+			}))
+
+			r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Body.String() != tt.body {
+				t.Errorf("*Client.Middleware() = %v, want %v", w.Body.String(), tt.body)
+			}
+			if _, ok := adapter.store["http://foo.bar/test-1"]; ok != tt.wantStored {
+				t.Errorf("response stored = %v, want %v", ok, tt.wantStored)
+			}
+		})
+	}
+}
+
+func TestMiddlewareNegativeTTL(t *testing.T) {
+	tests := []struct {
+		name           string
+		negativeTTL    time.Duration
+		statusCode     int
+		wantStored     bool
+		wantExpiration time.Duration
+	}{
+		{"skips 404 by default", 0, http.StatusNotFound, false, 0},
+		{"stores 404 with negative ttl", 5 * time.Second, http.StatusNotFound, true, 5 * time.Second},
+		{"stores 410 with negative ttl", 5 * time.Second, http.StatusGone, true, 5 * time.Second},
+		{"skips other errors", 5 * time.Second, http.StatusBadRequest, false, 0},
+		{"stores 200 with ttl", 5 * time.Second, http.StatusOK, true, 1 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := &adapterMock{store: map[string][]byte{}}
+			opts := []ClientOption{WithAdapter(adapter), WithTTL(1 * time.Minute)}
+			if tt.negativeTTL > 0 {
+				opts = append(opts, WithNegativeTTL(tt.negativeTTL))
+			}
+			client, _ := NewClient(opts...)
+			handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statusCode)
+			}))
+
+			r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			b, ok := adapter.store["http://foo.bar/test-1"]
+			if ok != tt.wantStored {
+				t.Fatalf("response stored = %v, want %v", ok, tt.wantStored)
+			}
+			if response := BytesToResponse(b); ok && response.Expiration.Sub(response.StoredAt) != tt.wantExpiration {
+				t.Errorf("cached for %v, want %v", response.Expiration.Sub(response.StoredAt), tt.wantExpiration)
+			}
+		})
+	}
+}
+
+func TestMiddlewareStatusCodes(t *testing.T) {
+	tests := []struct {
+		name        string
+		negativeTTL time.Duration
+		statusCode  int
+		wantStored  bool
+	}{
+		{"stores listed status codes", 0, http.StatusOK, true},
+		{"stores listed negative status codes", 5 * time.Second, http.StatusNotFound, true},
+		{"skips other status codes", 5 * time.Second, http.StatusNoContent, false},
+		{"skips listed errors without negative ttl", 0, http.StatusGone, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := &adapterMock{store: map[string][]byte{}}
+			opts := []ClientOption{
+				WithAdapter(adapter),
+				WithTTL(1 * time.Minute),
+				WithStatusCodes(http.StatusOK, http.StatusNotFound, http.StatusGone),
+			}
+			if tt.negativeTTL > 0 {
+				opts = append(opts, WithNegativeTTL(tt.negativeTTL))
+			}
+			client, _ := NewClient(opts...)
+			handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statusCode)
+			}))
+
+			r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			if _, ok := adapter.store["http://foo.bar/test-1"]; ok != tt.wantStored {
+				t.Errorf("response stored = %v, want %v", ok, tt.wantStored)
+			}
+		})
+	}
+}
+
+func TestGenerateKeyMethod(t *testing.T) {
+	tests := []struct {
+		method string
+		want   string
+	}{
+		{http.MethodGet, "http://foo.bar/test-1"},
+		{http.MethodHead, "HEAD http://foo.bar/test-1"},
+		{http.MethodPost, "POST http://foo.bar/test-1body"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			r, _ := http.NewRequest(tt.method, "http://foo.bar/test-1", bytes.NewReader([]byte("body")))
+			if got, _ := generateKey(r); got != tt.want {
+				t.Errorf("generateKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareMethods(t *testing.T) {
+	adapter := &adapterMock{store: map[string][]byte{}}
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithMethods(http.MethodGet, http.MethodPost),
+	)
+	var bodies []string
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.Write([]byte("value 1"))
+	}))
+
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut} {
+		r, _ := http.NewRequest(method, "http://foo.bar/test-1", strings.NewReader("body"))
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	// The handler still reads the body consumed by the POST key.
+	if want := []string{"body", "body", "body"}; !reflect.DeepEqual(bodies, want) {
+		t.Errorf("handler bodies = %q, want %q", bodies, want)
+	}
+	want := []string{"POST http://foo.bar/test-1body", "http://foo.bar/test-1"}
+	var got []string
+	for key := range adapter.store {
+		got = append(got, key)
+	}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cached keys = %v, want %v", got, want)
+	}
+	if _, err := NewClient(WithAdapter(adapter), WithTTL(1*time.Minute), WithMethods()); err == nil {
+		t.Error("NewClient() with no methods error = nil")
+	}
+}
+
+func TestMiddlewareHead(t *testing.T) {
+	adapter := &adapterMock{store: map[string][]byte{}}
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithCacheable(func(r *http.Request) bool {
+			return true
+		}),
+	)
+
+	counter := 0
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counter++
+		w.Header().Set("X-Counter", fmt.Sprint(counter))
+		if r.Method != http.MethodHead {
+			w.Write([]byte(fmt.Sprintf("value %d", counter)))
+		}
+	}))
+
+	tests := []struct {
+		name        string
+		method      string
+		wantBody    string
+		wantCounter string
+	}{
+		{"passes HEAD through on miss", "HEAD", "", "1"},
+		{"does not serve GET from HEAD response", "GET", "value 2", "2"},
+		{"serves HEAD from cached GET response", "HEAD", "", "2"},
+		{"serves cached GET response", "GET", "value 2", "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(tt.method, "http://foo.bar/test-1", nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Body.String() != tt.wantBody || w.Header().Get("X-Counter") != tt.wantCounter {
+				t.Errorf("*Client.Middleware() = %q %v, want %q %v", w.Body.String(), w.Header().Get("X-Counter"), tt.wantBody, tt.wantCounter)
+			}
+		})
+	}
+}
+
+func TestMiddlewareHeadPurgeRefresh(t *testing.T) {
+	adapter := &adapterMock{store: map[string][]byte{}}
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithPurgeKey("pk"),
+		WithRefreshKey("rk"),
+	)
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			w.Write([]byte("value 1"))
+		}
+	}))
+
+	tests := []struct {
+		name       string
+		url        string
+		wantStatus int
+	}{
+		{"purges the GET response", "http://foo.bar/test-1?pk=true", http.StatusNoContent},
+		{"refreshes the GET response", "http://foo.bar/test-1?rk=true", http.StatusOK},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+			if _, ok := adapter.store["http://foo.bar/test-1"]; !ok {
+				t.Fatal("GET response not cached")
+			}
+
+			r, _ = http.NewRequest(http.MethodHead, tt.url, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if _, ok := adapter.store["http://foo.bar/test-1"]; ok {
+				t.Error("GET response cached after the HEAD request, want it released")
+			}
+		})
+	}
+}
+
+func TestMiddlewareTTLFunc(t *testing.T) {
+	adapter := &adapterMock{store: map[string][]byte{}}
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithTTLFunc(func(r *http.Request, res *http.Response) time.Duration {
+			if r.URL.Path == "/short" && res.StatusCode == http.StatusOK {
+				return 5 * time.Second
+			}
+			return 0
+		}),
+	)
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("value 1"))
+	}))
+
+	tests := []struct {
+		name string
+		url  string
+		want time.Duration
+	}{
+		{"uses ttl function", "http://foo.bar/short", 5 * time.Second},
+		{"falls back to ttl", "http://foo.bar/long", 1 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			response := BytesToResponse(adapter.store[tt.url])
+			if got := response.Expiration.Sub(response.StoredAt); got != tt.want {
+				t.Errorf("cached for %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareTTLJitter(t *testing.T) {
+	adapter := &adapterMock{store: map[string][]byte{}}
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithTTLJitter(0.5),
+	)
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("value 1"))
+	}))
+
+	ttls := map[time.Duration]bool{}
+	for i := 0; i < 10; i++ {
+		url := fmt.Sprintf("http://foo.bar/test-%d", i)
+		r, _ := http.NewRequest(http.MethodGet, url, nil)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		response := BytesToResponse(adapter.store[url])
+		ttl := response.Expiration.Sub(response.StoredAt)
+		if ttl < 30*time.Second || ttl > 90*time.Second {
+			t.Errorf("cached for %v, want within 30s and 90s", ttl)
+		}
+		ttls[ttl] = true
+	}
+	if len(ttls) < 2 {
+		t.Error("cached responses share the same ttl")
+	}
+
+	for _, fraction := range []float64{-0.1, 1} {
+		if _, err := NewClient(WithAdapter(adapter), WithTTL(1*time.Minute), WithTTLJitter(fraction)); err == nil {
+			t.Errorf("NewClient() with ttl jitter %v error = nil", fraction)
+		}
+	}
+}
+
+func TestMiddlewareHooks(t *testing.T) {
+	var events []string
+	adapter := &adapterMock{store: map[string][]byte{}}
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithMethods(http.MethodGet, http.MethodPost),
+		WithHooks(Hooks{
+			OnHit: func(r *http.Request, key string, response Response) {
+				events = append(events, fmt.Sprintf("hit %s %s", key, response.Value))
+			},
+			OnMiss: func(r *http.Request, key string) {
+				events = append(events, "miss "+key)
+			},
+			OnStore: func(r *http.Request, key string, response Response) {
+				events = append(events, fmt.Sprintf("store %s %s", key, response.Value))
+			},
+			OnError: func(r *http.Request, key string, err error) {
+				events = append(events, "error "+err.Error())
+			},
+		}),
+	)
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("value 1"))
+	}))
+
+	r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	r, _ = http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	r, _ = http.NewRequest(http.MethodPost, "http://foo.bar/test-1", errReader(0))
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	want := []string{
+		"miss http://foo.bar/test-1",
+		"store http://foo.bar/test-1 value 1",
+		"hit http://foo.bar/test-1 value 1",
+		"error error reading body: readAll error",
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("hook events = %q, want %q", events, want)
+	}
+}
+
+type failingAdapter struct{}
+
+func (failingAdapter) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return nil, false, errors.New("get error")
+}
+
+func (failingAdapter) Set(ctx context.Context, key string, response []byte, expiration time.Time) error {
+	return errors.New("set error")
+}
+
+func (failingAdapter) Release(ctx context.Context, key string) error {
+	return errors.New("release error")
+}
+
+func TestMiddlewareAdapterV2(t *testing.T) {
+	var errs []string
+	client, err := NewClient(
+		WithAdapterV2(failingAdapter{}),
+		WithTTL(1*time.Minute),
+		WithRefreshKey("rk"),
+		WithHooks(Hooks{
+			OnError: func(r *http.Request, key string, err error) {
+				errs = append(errs, err.Error())
+			},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("value 1"))
+	}))
+
+	for _, url := range []string{"http://foo.bar/test-1", "http://foo.bar/test-1?rk=true"} {
+		r, _ := http.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Body.String() != "value 1" {
+			t.Errorf("*Client.Middleware() = %v, want %v", w.Body.String(), "value 1")
+		}
+	}
+
+	want := []string{"adapter get: get error", "adapter set: set error", "adapter release: release error", "adapter set: set error"}
+	if !reflect.DeepEqual(errs, want) {
+		t.Errorf("reported errors = %q, want %q", errs, want)
+	}
+}
+
+func TestWithAdapter(t *testing.T) {
+	tests := []struct {
+		name    string
+		option  ClientOption
+		wantErr bool
+	}{
+		{"accepts Adapter", WithAdapter(&adapterMock{}), false},
+		{"accepts AdapterV2", WithAdapterV2(failingAdapter{}), false},
+		{"rejects nil Adapter", WithAdapter(nil), true},
+		{"rejects nil AdapterV2", WithAdapterV2(nil), true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClient(tt.option, WithTTL(1*time.Minute))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAdapterV2Of(t *testing.T) {
+	adapter := &adapterMock{store: map[string][]byte{}}
+	a := AdapterV2Of(adapter)
+	ctx := context.Background()
+
+	if err := a.Set(ctx, "foo", []byte("value"), time.Time{}); err != nil {
+		t.Errorf("Set() error = %v", err)
+	}
+	if b, ok, err := a.Get(ctx, "foo"); string(b) != "value" || !ok || err != nil {
+		t.Errorf("Get() = %q, %v, %v, want %q, true, nil", b, ok, err, "value")
+	}
+	if got := UnwrapAdapterV2(a); got != adapter {
+		t.Errorf("UnwrapAdapterV2() = %v, want the adapted Adapter", got)
+	}
+	if got := UnwrapAdapterV2(failingAdapter{}); got != (failingAdapter{}) {
+		t.Errorf("UnwrapAdapterV2() = %v, want the AdapterV2 itself", got)
+	}
+}
+
+type slowAdapter struct {
+	adapterMock
+	delay time.Duration
+}
+
+func (a *slowAdapter) Get(ctx context.Context, key string) ([]byte, bool) {
+	time.Sleep(a.delay)
+	return a.adapterMock.Get(ctx, key)
+}
+
+func TestMiddlewareAdapterTimeout(t *testing.T) {
+	adapter := &slowAdapter{
+		adapterMock: adapterMock{
+			store: map[string][]byte{
+				"http://foo.bar/test-1": Response{
+					Value:      []byte("cached"),
+					Expiration: time.Now().Add(1 * time.Minute),
+				}.Bytes(),
+			},
+		},
+		delay: 200 * time.Millisecond,
+	}
+
+	var errs []error
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithAdapterTimeout(10*time.Millisecond),
+		WithHooks(Hooks{
+			OnError: func(r *http.Request, key string, err error) {
+				errs = append(errs, err)
+			},
+		}),
+	)
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("new value"))
+	}))
+
+	start := time.Now()
+	r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if elapsed := time.Since(start); elapsed >= adapter.delay {
+		t.Errorf("*Client.Middleware() took %v, want less than %v", elapsed, adapter.delay)
+	}
+	if w.Body.String() != "new value" {
+		t.Errorf("*Client.Middleware() = %v, want %v", w.Body.String(), "new value")
+	}
+	if len(errs) == 0 || !errors.Is(errs[0], context.DeadlineExceeded) {
+		t.Errorf("reported errors = %v, want %v", errs, context.DeadlineExceeded)
+	}
+}
+
+type blockingAdapter struct {
+	adapterMock
+	started chan struct{}
+	unblock chan struct{}
+}
+
+func (a *blockingAdapter) Set(ctx context.Context, key string, response []byte, expiration time.Time) {
+	a.started <- struct{}{}
+	<-a.unblock
+	a.adapterMock.Set(ctx, key, response, expiration)
+}
+
+func TestMiddlewareAsyncWrites(t *testing.T) {
+	adapter := &adapterMock{store: map[string][]byte{}}
+	client, err := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithAsyncWrites(2, 10),
+	)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("value 1"))
+	}))
+
+	for _, url := range []string{"http://foo.bar/test-1", "http://foo.bar/test-2"} {
+		r, _ := http.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Body.String() != "value 1" {
+			t.Errorf("*Client.Middleware() = %v, want %v", w.Body.String(), "value 1")
+		}
+	}
+	client.Close()
+
+	for _, key := range []string{"http://foo.bar/test-1", "http://foo.bar/test-2"} {
+		b, ok := adapter.Get(context.Background(), key)
+		if !ok || string(BytesToResponse(b).Value) != "value 1" {
+			t.Errorf("adapter.Get(%q) = %q, want %q", key, BytesToResponse(b).Value, "value 1")
+		}
+	}
+	if client.DroppedWrites() != 0 {
+		t.Errorf("*Client.DroppedWrites() = %v, want %v", client.DroppedWrites(), 0)
+	}
+}
+
+func TestMiddlewareAsyncWritesDropped(t *testing.T) {
+	adapter := &blockingAdapter{
+		adapterMock: adapterMock{store: map[string][]byte{}},
+		started:     make(chan struct{}, 3),
+		unblock:     make(chan struct{}),
+	}
+	var errs []error
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithAsyncWrites(1, 1),
+		WithHooks(Hooks{
+			OnError: func(r *http.Request, key string, err error) {
+				errs = append(errs, err)
+			},
+		}),
+	)
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("value 1"))
+	}))
+
+	r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	<-adapter.started
+
+	for _, url := range []string{"http://foo.bar/test-2", "http://foo.bar/test-3"} {
+		r, _ := http.NewRequest(http.MethodGet, url, nil)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	if client.DroppedWrites() != 1 {
+		t.Errorf("*Client.DroppedWrites() = %v, want %v", client.DroppedWrites(), 1)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], ErrWriteDropped) {
+		t.Errorf("reported errors = %v, want %v", errs, ErrWriteDropped)
+	}
+
+	close(adapter.unblock)
+	client.Close()
+
+	if _, ok := adapter.adapterMock.Get(context.Background(), "http://foo.bar/test-2"); !ok {
+		t.Errorf("adapter.Get(%q) = false, want true", "http://foo.bar/test-2")
+	}
+}
+
+func TestWithAsyncWrites(t *testing.T) {
+	tests := []struct {
+		name      string
+		workers   int
+		queueSize int
+		wantErr   bool
+	}{
+		{"valid", 1, 1, false},
+		{"invalid workers", 0, 1, true},
+		{"invalid queue size", 1, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClient(
+				WithAdapter(&adapterMock{}),
+				WithTTL(1*time.Minute),
+				WithAsyncWrites(tt.workers, tt.queueSize),
+			)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+type countingAdapter struct {
+	adapterMock
+	sets int
+}
+
+func (a *countingAdapter) Set(ctx context.Context, key string, response []byte, expiration time.Time) {
+	a.sets++
+	a.adapterMock.Set(ctx, key, response, expiration)
+}
+
+func TestMiddlewareAccessTracking(t *testing.T) {
+	tests := []struct {
+		name          string
+		enabled       bool
+		wantSets      int
+		wantFrequency int
+	}{
+		{"skips write back on hits by default", false, 1, 1},
+		{"writes back on hits when enabled", true, 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := &countingAdapter{adapterMock: adapterMock{store: map[string][]byte{}}}
+			client, _ := NewClient(
+				WithAdapter(adapter),
+				WithTTL(1*time.Minute),
+				WithAccessTracking(tt.enabled),
+			)
+			handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("value 1"))
+			}))
+
+			for i := 0; i < 3; i++ {
+				r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+				handler.ServeHTTP(httptest.NewRecorder(), r)
+			}
+
+			if adapter.sets != tt.wantSets {
+				t.Errorf("adapter.Set() calls = %v, want %v", adapter.sets, tt.wantSets)
+			}
+			b, _ := adapter.Get(context.Background(), "http://foo.bar/test-1")
+			if got := BytesToResponse(b).Frequency; got != tt.wantFrequency {
+				t.Errorf("Response.Frequency = %v, want %v", got, tt.wantFrequency)
+			}
+		})
+	}
+}
+
+func TestResponseUnmarshalBinary(t *testing.T) {
+	valid := Response{Value: []byte("value 1"), StatusCode: http.StatusCreated}.Bytes()
+
+	tests := []struct {
+		name    string
+		b       []byte
+		wantErr bool
+	}{
+		{"decodes a response", valid, false},
+		{"rejects empty input", nil, true},
+		{"rejects garbage", []byte("garbage"), true},
+		{"rejects a missing version", []byte(responseMagic), true},
+		{"rejects a newer version", append([]byte(responseMagic), responseVersion+1), true},
+		{"rejects a truncated frame", valid[:len(responseMagic)+3], true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Response
+			err := got.UnmarshalBinary(tt.b)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Response.UnmarshalBinary() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !reflect.DeepEqual(got, Response{}) {
+				t.Errorf("Response.UnmarshalBinary() = %+v, want a zero Response", got)
+			}
+		})
+	}
+}
+
+func TestMiddlewareCorruptEntry(t *testing.T) {
+	adapter := &adapterMock{store: map[string][]byte{
+		"http://foo.bar/test-1": []byte("garbage"),
+	}}
+	var errs []error
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithHooks(Hooks{
+			OnError: func(r *http.Request, key string, err error) {
+				errs = append(errs, err)
+			},
+		}),
+	)
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("new value"))
+	}))
+
+	r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Body.String() != "new value" {
+		t.Errorf("*Client.Middleware() = %v, want %v", w.Body.String(), "new value")
+	}
+	if len(errs) != 1 {
+		t.Errorf("reported errors = %v, want 1 codec error", errs)
+	}
+	b, _ := adapter.Get(context.Background(), "http://foo.bar/test-1")
+	if got := BytesToResponse(b).Value; string(got) != "new value" {
+		t.Errorf("stored response = %q, want %q", got, "new value")
+	}
+}
+
+func TestMiddlewareKeyHashing(t *testing.T) {
+	tests := []struct {
+		name     string
+		hash     func([]byte) string
+		verify   bool
+		wantKeys []string
+		want     []string
+	}{
+		{
+			"hashes keys",
+			SHA256Key,
+			false,
+			[]string{SHA256Key([]byte("http://foo.bar/test-1")), SHA256Key([]byte("http://foo.bar/test-2"))},
+			[]string{"http://foo.bar/test-1", "http://foo.bar/test-2", "http://foo.bar/test-1"},
+		},
+		{
+			"serves colliding entries without verification",
+			func([]byte) string { return "collision" },
+			false,
+			[]string{"collision"},
+			[]string{"http://foo.bar/test-1", "http://foo.bar/test-1", "http://foo.bar/test-1"},
+		},
+		{
+			"detects colliding entries with verification",
+			func([]byte) string { return "collision" },
+			true,
+			[]string{"collision"},
+			[]string{"http://foo.bar/test-1", "http://foo.bar/test-2", "http://foo.bar/test-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := &adapterMock{store: map[string][]byte{}}
+			client, _ := NewClient(
+				WithAdapter(adapter),
+				WithTTL(1*time.Minute),
+				WithKeyHashing(tt.hash, tt.verify),
+			)
+			handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(r.URL.String()))
+			}))
+
+			var got []string
+			for _, url := range []string{"http://foo.bar/test-1", "http://foo.bar/test-2", "http://foo.bar/test-1"} {
+				r, _ := http.NewRequest(http.MethodGet, url, nil)
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+				got = append(got, w.Body.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("*Client.Middleware() = %v, want %v", got, tt.want)
+			}
+
+			var keys []string
+			for key := range adapter.store {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			sort.Strings(tt.wantKeys)
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("adapter keys = %v, want %v", keys, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestKeyHashes(t *testing.T) {
+	tests := []struct {
+		name string
+		hash func([]byte) string
+		want string
+	}{
+		{"sha256", SHA256Key, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{"xxhash", XXHashKey, "ef46db3751d8e999"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hash(nil); got != tt.want {
+				t.Errorf("hash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareCacheVersion(t *testing.T) {
+	adapter := &adapterMock{store: map[string][]byte{}}
+	counter := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counter++
+		w.Write([]byte(fmt.Sprintf("value %v", counter)))
+	})
+
+	tests := []struct {
+		name    string
+		opts    []ClientOption
+		wantKey string
+		want    string
+	}{
+		{"caches under versioned key", []ClientOption{WithCacheVersion("v1")}, "v1:http://foo.bar/test-1", "value 1"},
+		{"serves same version", []ClientOption{WithCacheVersion("v1")}, "v1:http://foo.bar/test-1", "value 1"},
+		{"misses after version bump", []ClientOption{WithCacheVersion("v2")}, "v2:http://foo.bar/test-1", "value 2"},
+		{
+			"composes with hashing",
+			[]ClientOption{WithCacheVersion("v3"), WithKeyHashing(SHA256Key, false)},
+			SHA256Key([]byte("v3:http://foo.bar/test-1")),
+			"value 3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(append([]ClientOption{WithAdapter(adapter), WithTTL(1 * time.Minute)}, tt.opts...)...)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+			w := httptest.NewRecorder()
+			client.Middleware(handler).ServeHTTP(w, r)
+
+			if w.Body.String() != tt.want {
+				t.Errorf("*Client.Middleware() = %v, want %v", w.Body.String(), tt.want)
+			}
+			if _, ok := adapter.store[tt.wantKey]; !ok {
+				t.Errorf("adapter key %v not found", tt.wantKey)
+			}
+		})
+	}
+
+	if _, err := NewClient(WithAdapter(adapter), WithTTL(1*time.Minute), WithCacheVersion("")); err == nil {
+		t.Errorf("NewClient() error = nil, want an error for an empty version")
+	}
+}
+
+type tenantKey struct{}
+
+func TestClientKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []ClientOption
+		headers map[string]string
+		want    string
+	}{
+		{
+			"uses key function",
+			nil,
+			map[string]string{"Accept-Language": "en"},
+			"http://foo.bar/test-1",
+		},
+		{
+			"adds vary headers",
+			[]ClientOption{WithVaryHeaders("x-tenant-id", "Accept-Language")},
+			map[string]string{"Accept-Language": "en", "X-Tenant-Id": "acme"},
+			"http://foo.bar/test-1#headers:Accept-Language=en&X-Tenant-Id=acme",
+		},
+		{
+			"adds missing vary headers",
+			[]ClientOption{WithVaryHeaders("X-Tenant-ID")},
+			nil,
+			"http://foo.bar/test-1#headers:",
+		},
+		{
+			"adds vary cookies",
+			[]ClientOption{WithVaryCookies("session_region")},
+			map[string]string{"Cookie": "session_id=123; session_region=eu"},
+			"http://foo.bar/test-1#cookies:session_region=eu",
+		},
+		{
+			"adds context component",
+			[]ClientOption{WithKeyContext(func(ctx context.Context) string {
+				tenant, _ := ctx.Value(tenantKey{}).(string)
+				return tenant
+			})},
+			nil,
+			"http://foo.bar/test-1#context:acme+corp",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(append([]ClientOption{WithAdapter(&adapterMock{}), WithTTL(1 * time.Minute)}, tt.opts...)...)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			ctx := context.WithValue(context.Background(), tenantKey{}, "acme corp")
+			r, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://foo.bar/test-1", nil)
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			if got, _ := client.key(r); got != tt.want {
+				t.Errorf("*Client.key() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareVaryHeaders(t *testing.T) {
+	client, _ := NewClient(
+		WithAdapter(&adapterMock{store: map[string][]byte{}}),
+		WithTTL(1*time.Minute),
+		WithVaryHeaders("X-Tenant-ID"),
+	)
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tenant " + r.Header.Get("X-Tenant-ID")))
+	}))
+
+	for _, tenant := range []string{"a", "b", "a"} {
+		r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+		r.Header.Set("X-Tenant-ID", tenant)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if want := "tenant " + tenant; w.Body.String() != want {
+			t.Errorf("*Client.Middleware() = %v, want %v", w.Body.String(), want)
+		}
+	}
+}
+
+func TestMiddlewareRefreshHeader(t *testing.T) {
+	counter := 0
+	client, _ := NewClient(
+		WithAdapter(&adapterMock{store: map[string][]byte{}}),
+		WithTTL(1*time.Minute),
+		WithRefreshKey("rk"),
+		WithRefreshHeader("x-cache-refresh"),
+		WithRefreshAuthorizer(func(r *http.Request) bool {
+			return r.Header.Get("X-Secret") == "secret"
+		}),
+	)
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counter++
+		w.Write([]byte(fmt.Sprintf("value %v", counter)))
+	}))
+
+	tests := []struct {
+		name    string
+		url     string
+		headers map[string]string
+		want    string
+	}{
+		{"caches response", "http://foo.bar/test-1", nil, "value 1"},
+		{"ignores unauthorized refresh header", "http://foo.bar/test-1", map[string]string{"X-Cache-Refresh": "1"}, "value 1"},
+		{"ignores unauthorized refresh key", "http://foo.bar/test-1?rk=true", nil, "value 1"},
+		{
+			"refreshes with authorized refresh header",
+			"http://foo.bar/test-1",
+			map[string]string{"X-Cache-Refresh": "1", "X-Secret": "secret"},
+			"value 2",
+		},
+		{"serves refreshed response", "http://foo.bar/test-1", nil, "value 2"},
+		{"refreshes with authorized refresh key", "http://foo.bar/test-1?rk=true", map[string]string{"X-Secret": "secret"}, "value 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Body.String() != tt.want {
+				t.Errorf("*Client.Middleware() = %v, want %v", w.Body.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddlewarePurge(t *testing.T) {
+	counter := 0
+	adapter := &adapterMock{store: map[string][]byte{}}
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithPurgeKey("purge"),
+		WithPurgeHeader("X-Cache-Purge"),
+		WithRefreshAuthorizer(func(r *http.Request) bool {
+			return r.Header.Get("X-Secret") == "secret"
+		}),
+	)
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counter++
+		w.Write([]byte(fmt.Sprintf("value %v", counter)))
+	}))
+
+	tests := []struct {
+		name     string
+		url      string
+		headers  map[string]string
+		wantCode int
+		want     string
+		wantKeys int
+	}{
+		{"caches response", "http://foo.bar/test-1", nil, http.StatusOK, "value 1", 1},
+		{"ignores unauthorized purge", "http://foo.bar/test-1?purge=true", nil, http.StatusOK, "value 1", 1},
+		{
+			"purges with authorized purge key",
+			"http://foo.bar/test-1?purge=true",
+			map[string]string{"X-Secret": "secret"},
+			http.StatusNoContent,
+			"",
+			0,
+		},
+		{"repopulates on next request", "http://foo.bar/test-1", nil, http.StatusOK, "value 2", 1},
+		{
+			"purges with authorized purge header",
+			"http://foo.bar/test-1",
+			map[string]string{"X-Cache-Purge": "1", "X-Secret": "secret"},
+			http.StatusNoContent,
+			"",
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.wantCode || w.Body.String() != tt.want {
+				t.Errorf("*Client.Middleware() = %v %q, want %v %q", w.Code, w.Body.String(), tt.wantCode, tt.want)
+			}
+			if len(adapter.store) != tt.wantKeys {
+				t.Errorf("adapter entries = %v, want %v", len(adapter.store), tt.wantKeys)
+			}
+		})
+	}
+	if counter != 2 {
+		t.Errorf("handler calls = %v, want %v", counter, 2)
+	}
+}
+
+func TestMiddlewareWriteThroughInvalidation(t *testing.T) {
+	adapter := &adapterMock{store: map[string][]byte{}}
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithWriteThroughInvalidation("/items"),
+	)
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusConflict)
+		}
+		w.Write([]byte(r.Method))
+	}))
+
+	tests := []struct {
+		name     string
+		method   string
+		url      string
+		want     string
+		wantKeys []string
+	}{
+		{"caches item", http.MethodGet, "http://foo.bar/items/1", "GET", []string{"http://foo.bar/items/1"}},
+		{"caches collection", http.MethodGet, "http://foo.bar/items", "GET", []string{"http://foo.bar/items", "http://foo.bar/items/1"}},
+		{"caches other item", http.MethodGet, "http://foo.bar/items/2", "GET", []string{"http://foo.bar/items", "http://foo.bar/items/1", "http://foo.bar/items/2"}},
+		{"keeps entries on failed write", http.MethodPut, "http://foo.bar/items/1?fail=1", "PUT", []string{"http://foo.bar/items", "http://foo.bar/items/1", "http://foo.bar/items/2"}},
+		{"releases item and related paths", http.MethodPut, "http://foo.bar/items/1", "PUT", []string{"http://foo.bar/items/2"}},
+		{"releases on delete", http.MethodDelete, "http://foo.bar/items/2", "DELETE", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(tt.method, tt.url, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Body.String() != tt.want {
+				t.Errorf("*Client.Middleware() = %q, want %q", w.Body.String(), tt.want)
+			}
+			keys := []string{}
+			for key := range adapter.store {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("adapter keys = %v, want %v", keys, tt.wantKeys)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCacheControl(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []string
+		want   cacheControl
+	}{
+		{
+			"parses directives and arguments",
+			[]string{`max-age=60, No-Cache, private="Set-Cookie"`},
+			cacheControl{"max-age": "60", "no-cache": "", "private": "Set-Cookie"},
+		},
+		{
+			"merges multiple headers",
+			[]string{"max-age=60", "min-fresh=10"},
+			cacheControl{"max-age": "60", "min-fresh": "10"},
+		},
+		{
+			"ignores empty directives",
+			[]string{" , ,"},
+			cacheControl{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseCacheControl(http.Header{"Cache-Control": tt.header})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCacheControl() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheControlDuration(t *testing.T) {
+	cc := cacheControl{"max-age": "60", "min-fresh": "-1", "s-maxage": "abc"}
+
+	tests := []struct {
+		directive string
+		want      time.Duration
+		ok        bool
+	}{
+		{"max-age", 60 * time.Second, true},
+		{"min-fresh", 0, false},
+		{"s-maxage", 0, false},
+		{"max-stale", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.directive, func(t *testing.T) {
+			got, ok := cc.duration(tt.directive)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("cacheControl.duration() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
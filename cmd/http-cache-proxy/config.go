@@ -0,0 +1,197 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	"github.com/cludden/http-cache/adapter/fs"
+	"github.com/cludden/http-cache/adapter/memory"
+	"github.com/cludden/http-cache/adapter/redis"
+	"gopkg.in/yaml.v3"
+)
+
+// config is the proxy configuration, read from a YAML file and overridden
+// by flags.
+type config struct {
+	// Listen is the address the proxy listens on.
+	Listen string `yaml:"listen"`
+
+	// Upstream is the URL of the server the proxy forwards requests to.
+	Upstream string `yaml:"upstream"`
+
+	// TTL is how long responses are cached when no rule matches and the
+	// upstream server sets no Cache-Control max-age or Expires header.
+	TTL time.Duration `yaml:"ttl"`
+
+	// Rules set the TTL of the responses to requests by path prefix, the
+	// longest matching prefix winning over the upstream response headers.
+	Rules []rule `yaml:"rules"`
+
+	// StaleIfError is how long expired responses are served when the
+	// upstream server fails.
+	StaleIfError time.Duration `yaml:"stale_if_error"`
+
+	Adapter adapterConfig `yaml:"adapter"`
+	Admin   adminConfig   `yaml:"admin"`
+}
+
+// rule sets the TTL of the responses to requests under a path prefix.
+type rule struct {
+	Prefix string        `yaml:"prefix"`
+	TTL    time.Duration `yaml:"ttl"`
+}
+
+// adapterConfig selects and configures the cache adapter.
+type adapterConfig struct {
+	// Type is memory, redis or fs.
+	Type string `yaml:"type"`
+
+	// Capacity is the number of responses the memory adapter holds.
+	Capacity int `yaml:"capacity"`
+
+	// Addrs are the addresses of the Redis nodes.
+	Addrs []string `yaml:"addrs"`
+
+	// Dir is the directory of the fs adapter.
+	Dir string `yaml:"dir"`
+}
+
+// adminConfig configures the admin server, serving the Prometheus metrics
+// under /metrics and the cache admin handler, to purge responses, under
+// /cache/.
+type adminConfig struct {
+	// Listen is the address the admin server listens on, disabled when
+	// empty.
+	Listen string `yaml:"listen"`
+
+	// Token is the bearer token admin requests must carry, the admin
+	// handler being disabled when empty.
+	Token string `yaml:"token"`
+}
+
+// defaultConfig returns the configuration applied before the file and the
+// flags.
+func defaultConfig() config {
+	return config{
+		Listen:  ":8080",
+		TTL:     time.Minute,
+		Adapter: adapterConfig{Type: "memory", Capacity: 10000},
+		Admin:   adminConfig{Listen: ":9090"},
+	}
+}
+
+// loadConfig reads a YAML configuration file over c.
+func loadConfig(path string, c *config) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// parseRule parses a rule flag of the form PREFIX=TTL, such as /api=30s.
+func parseRule(s string) (rule, error) {
+	i := strings.LastIndexByte(s, '=')
+	if i < 0 {
+		return rule{}, fmt.Errorf("rule %q is invalid, want PREFIX=TTL", s)
+	}
+	ttl, err := time.ParseDuration(s[i+1:])
+	if err != nil {
+		return rule{}, fmt.Errorf("rule %q is invalid: %w", s, err)
+	}
+
+	return rule{Prefix: s[:i], TTL: ttl}, nil
+}
+
+// upstream parses the upstream URL.
+func (c config) upstream() (*url.URL, error) {
+	if c.Upstream == "" {
+		return nil, errors.New("upstream is required")
+	}
+	u, err := url.Parse(c.Upstream)
+	if err != nil {
+		return nil, fmt.Errorf("upstream %q is invalid: %w", c.Upstream, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("upstream %q is invalid", c.Upstream)
+	}
+
+	return u, nil
+}
+
+// adapter initializes the configured cache adapter.
+func (c config) adapter() (cache.Adapter, error) {
+	switch c.Adapter.Type {
+	case "memory":
+		return memory.NewAdapter(
+			memory.AdapterWithAlgorithm(memory.LRU),
+			memory.AdapterWithCapacity(c.Adapter.Capacity),
+		)
+	case "redis":
+		if len(c.Adapter.Addrs) == 0 {
+			return nil, errors.New("redis adapter addrs are required")
+		}
+		return redis.NewAdapterFromOptions(redis.AdapterWithAddrs(c.Adapter.Addrs...)), nil
+	case "fs":
+		if c.Adapter.Dir == "" {
+			return nil, errors.New("fs adapter dir is required")
+		}
+		return fs.NewAdapter(c.Adapter.Dir)
+	default:
+		return nil, fmt.Errorf("adapter type %q is invalid, want memory, redis or fs", c.Adapter.Type)
+	}
+}
+
+// ttlFunc returns the function applying the TTL rules, longest prefix
+// first. Requests matching no rule get a zero TTL, deferring to the
+// response headers and the default TTL.
+func (c config) ttlFunc() func(*http.Request, *http.Response) time.Duration {
+	rules := append([]rule(nil), c.Rules...)
+	sort.SliceStable(rules, func(i, j int) bool {
+		return len(rules[i].Prefix) > len(rules[j].Prefix)
+	})
+
+	return func(r *http.Request, _ *http.Response) time.Duration {
+		for _, rule := range rules {
+			if strings.HasPrefix(r.URL.Path, rule.Prefix) {
+				return rule.TTL
+			}
+		}
+		return 0
+	}
+}
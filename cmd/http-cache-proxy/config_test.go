@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxy.yaml")
+	ioutil.WriteFile(path, []byte(`
+upstream: http://localhost:3000
+ttl: 5m
+rules:
+  - prefix: /api
+    ttl: 30s
+adapter:
+  type: redis
+  addrs: [localhost:6379]
+`), 0o600)
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    config
+		wantErr bool
+	}{
+		{
+			"applies defaults",
+			[]string{"-upstream", "http://localhost:3000"},
+			config{
+				Listen:   ":8080",
+				Upstream: "http://localhost:3000",
+				TTL:      time.Minute,
+				Adapter:  adapterConfig{Type: "memory", Capacity: 10000},
+				Admin:    adminConfig{Listen: ":9090"},
+			},
+			false,
+		},
+		{
+			"reads the config file",
+			[]string{"-config", path},
+			config{
+				Listen:   ":8080",
+				Upstream: "http://localhost:3000",
+				TTL:      5 * time.Minute,
+				Rules:    []rule{{Prefix: "/api", TTL: 30 * time.Second}},
+				Adapter:  adapterConfig{Type: "redis", Capacity: 10000, Addrs: []string{"localhost:6379"}},
+				Admin:    adminConfig{Listen: ":9090"},
+			},
+			false,
+		},
+		{
+			"overrides the config file with flags",
+			[]string{"-config", path, "-ttl", "1h", "-rule", "/static=24h", "-adapter", "fs", "-fs-dir", "/tmp/cache"},
+			config{
+				Listen:   ":8080",
+				Upstream: "http://localhost:3000",
+				TTL:      time.Hour,
+				Rules:    []rule{{Prefix: "/static", TTL: 24 * time.Hour}},
+				Adapter:  adapterConfig{Type: "fs", Capacity: 10000, Addrs: []string{"localhost:6379"}, Dir: "/tmp/cache"},
+				Admin:    adminConfig{Listen: ":9090"},
+			},
+			false,
+		},
+		{"rejects invalid rules", []string{"-rule", "/api"}, config{}, true},
+		{"rejects invalid ttls", []string{"-ttl", "0s"}, config{}, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFlags(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFlags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFlags() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigTTLFunc(t *testing.T) {
+	c := config{Rules: []rule{
+		{Prefix: "/api", TTL: 30 * time.Second},
+		{Prefix: "/api/static", TTL: time.Hour},
+	}}
+	ttl := c.ttlFunc()
+
+	tests := []struct {
+		path string
+		want time.Duration
+	}{
+		{"/api/users", 30 * time.Second},
+		{"/api/static/app.js", time.Hour},
+		{"/index.html", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "http://foo.bar"+tt.path, nil)
+			if got := ttl(r, nil); got != tt.want {
+				t.Errorf("ttlFunc() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Command http-cache-proxy is a caching reverse proxy in front of an
+// upstream server, configured with flags or a YAML file:
+//
+//	http-cache-proxy -upstream http://localhost:3000 -ttl 5m -rule /api=30s
+//	http-cache-proxy -config proxy.yaml
+//
+// Flags override the settings of the file. An admin server serves the
+// Prometheus metrics under /metrics and, when a token is set, the cache
+// admin handler under /cache/ to purge responses:
+//
+//	curl -X DELETE -H "Authorization: Bearer $TOKEN" \
+//	  "localhost:9090/cache/entry?key=http://example.com/page"
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	cacheprometheus "github.com/cludden/http-cache/metrics/prometheus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// shutdownTimeout is how long in-flight requests are given to complete on
+// shutdown.
+const shutdownTimeout = 10 * time.Second
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run parses the command line and serves until interrupted.
+func run(args []string) error {
+	c, err := parseFlags(args)
+	if err != nil {
+		return err
+	}
+	upstream, err := c.upstream()
+	if err != nil {
+		return err
+	}
+	adapter, err := c.adapter()
+	if err != nil {
+		return err
+	}
+	if closer, ok := adapter.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	sink := cacheprometheus.NewSink()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(sink, collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+
+	opts := []cache.ClientOption{
+		cache.WithAdapter(adapter),
+		cache.WithTTL(c.TTL),
+		cache.WithTTLFromHeaders(true),
+		cache.WithStatusHeaders(true),
+		cache.WithMetrics(sink),
+		cache.WithAdminAuthorizer(func(r *http.Request) bool {
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			return c.Admin.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(c.Admin.Token)) == 1
+		}),
+	}
+	if len(c.Rules) > 0 {
+		opts = append(opts, cache.WithTTLFunc(c.ttlFunc()))
+	}
+	if c.StaleIfError > 0 {
+		opts = append(opts, cache.WithStaleIfError(c.StaleIfError))
+	}
+	client, err := cache.NewClient(opts...)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	servers := []*http.Server{{Addr: c.Listen, Handler: client.ReverseProxy(upstream)}}
+	if c.Admin.Listen != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+		mux.Handle("/cache/", http.StripPrefix("/cache", client.AdminHandler()))
+		servers = append(servers, &http.Server{Addr: c.Admin.Listen, Handler: mux})
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, len(servers))
+	for _, server := range servers {
+		server := server
+		go func() {
+			log.Printf("listening on %s", server.Addr)
+			if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				errs <- err
+			}
+		}()
+	}
+	log.Printf("caching %s with the %s adapter", upstream, c.Adapter.Type)
+
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, server := range servers {
+		server.Shutdown(shutdown)
+	}
+
+	return err
+}
+
+// parseFlags returns the configuration set by the file and the flags of the
+// command line.
+func parseFlags(args []string) (config, error) {
+	var (
+		c        = defaultConfig()
+		flagset  = flag.NewFlagSet("http-cache-proxy", flag.ContinueOnError)
+		path     = flagset.String("config", "", "YAML configuration `file`")
+		listen   = flagset.String("listen", c.Listen, "proxy listen `address`")
+		upstream = flagset.String("upstream", "", "upstream `URL`")
+		ttl      = flagset.Duration("ttl", c.TTL, "default TTL of the responses")
+		stale    = flagset.Duration("stale-if-error", 0, "how long expired responses are served when the upstream fails")
+		adapter  = flagset.String("adapter", c.Adapter.Type, "cache adapter: memory, redis or fs")
+		capacity = flagset.Int("memory-capacity", c.Adapter.Capacity, "number of responses held by the memory adapter")
+		addrs    = flagset.String("redis-addrs", "", "comma separated Redis `addresses`")
+		dir      = flagset.String("fs-dir", "", "`directory` of the fs adapter")
+		admin    = flagset.String("admin-listen", c.Admin.Listen, "admin listen `address`, disabled when empty")
+		token    = flagset.String("admin-token", "", "bearer `token` of the admin handler, disabled when empty")
+		rules    []rule
+	)
+	flagset.Func("rule", "TTL rule of the form `PREFIX=TTL`, such as /api=30s, may be repeated", func(s string) error {
+		r, err := parseRule(s)
+		rules = append(rules, r)
+		return err
+	})
+	if err := flagset.Parse(args); err != nil {
+		return config{}, err
+	}
+
+	if *path != "" {
+		if err := loadConfig(*path, &c); err != nil {
+			return config{}, err
+		}
+	}
+
+	flagset.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "listen":
+			c.Listen = *listen
+		case "upstream":
+			c.Upstream = *upstream
+		case "ttl":
+			c.TTL = *ttl
+		case "stale-if-error":
+			c.StaleIfError = *stale
+		case "adapter":
+			c.Adapter.Type = *adapter
+		case "memory-capacity":
+			c.Adapter.Capacity = *capacity
+		case "redis-addrs":
+			c.Adapter.Addrs = strings.Split(*addrs, ",")
+		case "fs-dir":
+			c.Adapter.Dir = *dir
+		case "admin-listen":
+			c.Admin.Listen = *admin
+		case "admin-token":
+			c.Admin.Token = *token
+		case "rule":
+			c.Rules = rules
+		}
+	})
+	if c.TTL <= 0 {
+		return config{}, fmt.Errorf("ttl %v is invalid", c.TTL)
+	}
+
+	return c, nil
+}
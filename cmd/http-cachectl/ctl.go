@@ -0,0 +1,245 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+)
+
+// ctl runs the commands against an adapter.
+type ctl struct {
+	adapter cache.Adapter
+	codec   cache.Codec
+	in      io.Reader
+	out     io.Writer
+	// retention is how long the client keeps expired responses to serve
+	// them stale, added to their expiration on export.
+	retention time.Duration
+}
+
+// entry is an exported cache entry, one JSON object per line. Its expiration
+// is the one of the adapter, including the stale retention.
+type entry struct {
+	Key        string    `json:"key"`
+	Value      []byte    `json:"value"`
+	Expiration time.Time `json:"expiration"`
+}
+
+// list prints the keys starting with a prefix, sorted.
+func (c *ctl) list(ctx context.Context, prefix string) error {
+	keys, err := c.keys(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		fmt.Fprintln(c.out, key)
+	}
+
+	return nil
+}
+
+// get prints the response cached under a key.
+func (c *ctl) get(ctx context.Context, key string) error {
+	b, ok := c.adapter.Get(ctx, key)
+	if !ok {
+		return fmt.Errorf("key %q not found", key)
+	}
+	response, err := c.codec.Unmarshal(b)
+	if err != nil {
+		return fmt.Errorf("key %q can not be decoded: %w", key, err)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Key:\t%s\n", key)
+	if len(response.Vary) > 0 && len(response.Value) == 0 {
+		fmt.Fprintf(w, "Vary marker:\t%s\n", strings.Join(response.Vary, ", "))
+	} else {
+		status := response.StatusCode
+		if status == 0 {
+			status = 200
+		}
+		fmt.Fprintf(w, "Status:\t%d\n", status)
+		fmt.Fprintf(w, "Size:\t%d bytes\n", len(response.Value))
+		if response.Encoding != "" {
+			fmt.Fprintf(w, "Encoding:\t%s\n", response.Encoding)
+		}
+	}
+	if !response.StoredAt.IsZero() {
+		fmt.Fprintf(w, "Stored at:\t%s\n", response.StoredAt.Format(time.RFC3339))
+	}
+	if response.Expiration.IsZero() {
+		fmt.Fprintf(w, "Expiration:\tnever\n")
+	} else if remaining := time.Until(response.Expiration).Round(time.Second); remaining > 0 {
+		fmt.Fprintf(w, "Expiration:\t%s\n", response.Expiration.Format(time.RFC3339))
+		fmt.Fprintf(w, "TTL remaining:\t%s\n", remaining)
+	} else {
+		fmt.Fprintf(w, "Expiration:\t%s (expired %s ago)\n", response.Expiration.Format(time.RFC3339), -remaining)
+	}
+	if response.Frequency > 0 {
+		fmt.Fprintf(w, "Accesses:\t%d\n", response.Frequency)
+	}
+	if r := response.Request; r != nil {
+		fmt.Fprintf(w, "Request:\t%s %s\n", r.Method, r.URL)
+	}
+	w.Flush()
+
+	if len(response.Header) > 0 {
+		fmt.Fprintln(c.out, "Header:")
+		names := make([]string, 0, len(response.Header))
+		for name := range response.Header {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, value := range response.Header[name] {
+				fmt.Fprintf(c.out, "  %s: %s\n", name, value)
+			}
+		}
+	}
+
+	return nil
+}
+
+// delete releases keys.
+func (c *ctl) delete(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return errors.New("delete requires a key")
+	}
+	for _, key := range keys {
+		c.adapter.Release(ctx, key)
+	}
+
+	return nil
+}
+
+// invalidate releases the keys by prefix, glob pattern or tag, whichever
+// is set.
+func (c *ctl) invalidate(ctx context.Context, prefix, pattern, tag string) error {
+	switch {
+	case tag != "":
+		tagger, ok := c.adapter.(cache.TaggingAdapter)
+		if !ok {
+			return cache.ErrTaggingUnsupported
+		}
+		return tagger.InvalidateTag(ctx, tag)
+	case prefix != "" || pattern != "":
+		invalidator, ok := c.adapter.(cache.InvalidatingAdapter)
+		if !ok {
+			return cache.ErrInvalidationUnsupported
+		}
+		if prefix != "" {
+			return invalidator.InvalidatePrefix(ctx, prefix)
+		}
+		return invalidator.InvalidatePattern(ctx, pattern)
+	default:
+		return errors.New("invalidate requires a prefix, pattern or tag")
+	}
+}
+
+// export writes the entries whose key starts with a prefix as JSON lines,
+// expiring after their stale retention. Entries that can't be decoded, to
+// read their expiration, are skipped.
+func (c *ctl) export(ctx context.Context, prefix string, warn io.Writer) error {
+	keys, err := c.keys(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(c.out)
+	for _, key := range keys {
+		b, ok := c.adapter.Get(ctx, key)
+		if !ok {
+			continue
+		}
+		response, err := c.codec.Unmarshal(b)
+		if err != nil {
+			fmt.Fprintf(warn, "skipping key %q: %v\n", key, err)
+			continue
+		}
+		expiration := response.Expiration
+		if !expiration.IsZero() {
+			expiration = expiration.Add(c.retention)
+		}
+		if err := enc.Encode(entry{Key: key, Value: b, Expiration: expiration}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// load reads entries written by export, skipping expired ones, and reports
+// how many it stored.
+func (c *ctl) load(ctx context.Context) (int, error) {
+	scanner := bufio.NewScanner(c.in)
+	scanner.Buffer(nil, 1<<30)
+
+	n, line := 0, 0
+	now := time.Now()
+	for scanner.Scan() {
+		if line++; len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var e entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return n, fmt.Errorf("line %d is invalid: %w", line, err)
+		}
+		if !e.Expiration.IsZero() && !e.Expiration.After(now) {
+			continue
+		}
+		c.adapter.Set(ctx, e.Key, e.Value, e.Expiration)
+		n++
+	}
+
+	return n, scanner.Err()
+}
+
+// keys returns the sorted keys starting with a prefix.
+func (c *ctl) keys(ctx context.Context, prefix string) ([]string, error) {
+	scanner, ok := c.adapter.(cache.KeyScanner)
+	if !ok {
+		return nil, errors.New("adapter can not list keys")
+	}
+
+	var keys []string
+	err := scanner.ScanKeys(ctx, prefix, func(key string) error {
+		keys = append(keys, key)
+		return nil
+	})
+	sort.Strings(keys)
+
+	return keys, err
+}
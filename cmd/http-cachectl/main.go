@@ -0,0 +1,194 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Command http-cachectl inspects and invalidates the responses cached in a
+// shared adapter:
+//
+//	http-cachectl -redis-addrs localhost:6379 list /api/
+//	http-cachectl -redis-addrs localhost:6379 get /api/products/1
+//	http-cachectl -adapter fs -fs-dir /var/cache/http delete /api/products/1
+//	http-cachectl -redis-addrs localhost:6379 invalidate -tag products
+//	http-cachectl -redis-addrs localhost:6379 export /api/ > entries.jsonl
+//	http-cachectl -redis-addrs localhost:6379 import < entries.jsonl
+//
+// Clients serving stale responses keep them past their expiration: export
+// them with -stale-retention set to the larger of their stale-if-error and
+// stale-while-revalidate durations, so that imported entries are kept as
+// long.
+//
+// Keys are the ones passed to the adapter, prefixed with the cache version
+// when set. Entries must be encoded with the selected codec to be shown or
+// exported, so encrypted or signed entries can't be.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	cache "github.com/cludden/http-cache"
+	"github.com/cludden/http-cache/adapter/fs"
+	"github.com/cludden/http-cache/adapter/redis"
+	"github.com/cludden/http-cache/codec/msgpack"
+	"github.com/cludden/http-cache/codec/protobuf"
+)
+
+const usage = `usage: http-cachectl [flags] command [args]
+
+Commands:
+  list [PREFIX]                 lists the keys starting with PREFIX
+  get KEY                       shows the response cached under KEY
+  delete KEY...                 releases keys
+  invalidate -prefix PREFIX     releases the keys starting with PREFIX
+  invalidate -pattern PATTERN   releases the keys matching a glob PATTERN
+  invalidate -tag TAG           releases the keys tagged with TAG
+  export [PREFIX]               writes the entries as JSON lines to stdout
+  import                        reads entries written by export from stdin
+
+Flags:
+`
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "http-cachectl:", err)
+		os.Exit(1)
+	}
+}
+
+// run parses the command line and runs the command.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flagset := flag.NewFlagSet("http-cachectl", flag.ContinueOnError)
+	flagset.SetOutput(stderr)
+	flagset.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flagset.PrintDefaults()
+	}
+	var (
+		adapterType = flagset.String("adapter", "redis", "cache adapter: redis or fs")
+		addrs       = flagset.String("redis-addrs", "localhost:6379", "comma separated Redis `addresses`")
+		prefix      = flagset.String("redis-prefix", "", "key `prefix` of the Redis adapter")
+		dir         = flagset.String("fs-dir", "", "`directory` of the fs adapter")
+		codecName   = flagset.String("codec", "gob", "codec of the entries: gob, json, msgpack or protobuf")
+		retention   = flagset.Duration("stale-retention", 0, "`duration` expired entries are kept to be served stale, the larger of the client stale-if-error and stale-while-revalidate")
+	)
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+	if flagset.NArg() == 0 {
+		flagset.Usage()
+		return flag.ErrHelp
+	}
+
+	codec, err := newCodec(*codecName)
+	if err != nil {
+		return err
+	}
+
+	var adapter cache.Adapter
+	switch *adapterType {
+	case "redis":
+		adapter = redis.NewAdapterFromOptions(
+			redis.AdapterWithAddrs(strings.Split(*addrs, ",")...),
+			redis.AdapterWithKeyPrefix(*prefix),
+			redis.AdapterWithErrorHandler(func(op, key string, err error) {
+				fmt.Fprintf(stderr, "redis %s %q: %v\n", op, key, err)
+			}),
+		)
+	case "fs":
+		if *dir == "" {
+			return fmt.Errorf("fs adapter requires -fs-dir")
+		}
+		if adapter, err = fs.NewAdapter(*dir); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("adapter %q is invalid, want redis or fs", *adapterType)
+	}
+	if closer, ok := adapter.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	if *retention < 0 {
+		return fmt.Errorf("stale retention %v is invalid", *retention)
+	}
+
+	c := &ctl{adapter: adapter, codec: codec, in: stdin, out: stdout, retention: *retention}
+	command, args := flagset.Arg(0), flagset.Args()[1:]
+	switch command {
+	case "list":
+		return c.list(ctx, optionalArg(args))
+	case "get":
+		if len(args) != 1 {
+			return fmt.Errorf("get requires a key")
+		}
+		return c.get(ctx, args[0])
+	case "delete":
+		return c.delete(ctx, args)
+	case "invalidate":
+		invalidate := flag.NewFlagSet("invalidate", flag.ContinueOnError)
+		invalidate.SetOutput(stderr)
+		prefix := invalidate.String("prefix", "", "releases the keys starting with `prefix`")
+		pattern := invalidate.String("pattern", "", "releases the keys matching a glob `pattern`")
+		tag := invalidate.String("tag", "", "releases the keys tagged with `tag`")
+		if err := invalidate.Parse(args); err != nil {
+			return err
+		}
+		return c.invalidate(ctx, *prefix, *pattern, *tag)
+	case "export":
+		return c.export(ctx, optionalArg(args), stderr)
+	case "import":
+		n, err := c.load(ctx)
+		fmt.Fprintf(stderr, "imported %d entries\n", n)
+		return err
+	default:
+		return fmt.Errorf("command %q is invalid", command)
+	}
+}
+
+// newCodec returns the codec of a name.
+func newCodec(name string) (cache.Codec, error) {
+	switch name {
+	case "gob":
+		return cache.GobCodec{}, nil
+	case "json":
+		return cache.JSONCodec{}, nil
+	case "msgpack":
+		return msgpack.NewCodec(), nil
+	case "protobuf":
+		return protobuf.NewCodec(), nil
+	default:
+		return nil, fmt.Errorf("codec %q is invalid, want gob, json, msgpack or protobuf", name)
+	}
+}
+
+// optionalArg returns the first argument, if any.
+func optionalArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
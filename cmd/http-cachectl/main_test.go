@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	"github.com/cludden/http-cache/adapter/fs"
+)
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	dir, imported, importedStale := t.TempDir(), t.TempDir(), t.TempDir()
+	a, _ := fs.NewAdapter(dir)
+	for _, key := range []string{"/api/1", "/api/2", "/web/1", "/stale/1"} {
+		expiration := time.Now().Add(time.Hour)
+		if strings.HasPrefix(key, "/stale/") {
+			expiration = time.Now().Add(-time.Minute)
+		}
+		a.Set(ctx, key, cache.Response{
+			Value:      []byte("value " + key),
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/plain"}},
+			Expiration: expiration,
+		}.Bytes(), time.Time{})
+	}
+	a.(*fs.Adapter).Close()
+
+	var exported, exportedStale bytes.Buffer
+	tests := []struct {
+		name    string
+		dir     string
+		args    []string
+		stdin   *bytes.Buffer
+		stdout  *bytes.Buffer
+		want    []string
+		wantErr bool
+	}{
+		{"lists keys", dir, []string{"list", "/api/"}, nil, nil, []string{"/api/1\n/api/2\n"}, false},
+		{"shows entries", dir, []string{"get", "/api/1"}, nil, nil, []string{"Status:         200", "Size:           12 bytes", "Content-Type: text/plain"}, false},
+		{"rejects missing keys", dir, []string{"get", "/api/3"}, nil, nil, nil, true},
+		{"exports entries", dir, []string{"export", "/api/"}, nil, &exported, []string{`"key":"/api/1"`, `"key":"/api/2"`}, false},
+		{"imports entries", imported, []string{"import"}, &exported, nil, nil, false},
+		{"lists imported keys", imported, []string{"list"}, nil, nil, []string{"/api/1\n/api/2\n"}, false},
+		{"exports stale entries", dir, []string{"-stale-retention", "1h", "export", "/stale/"}, nil, &exportedStale, []string{`"key":"/stale/1"`}, false},
+		{"imports stale entries within their retention", importedStale, []string{"import"}, &exportedStale, nil, nil, false},
+		{"lists imported stale keys", importedStale, []string{"list"}, nil, nil, []string{"/stale/1\n"}, false},
+		{"rejects negative stale retention", dir, []string{"-stale-retention", "-1h", "export"}, nil, nil, nil, true},
+		{"deletes keys", dir, []string{"delete", "/api/1"}, nil, nil, nil, false},
+		{"invalidates prefixes", dir, []string{"invalidate", "-prefix", "/web/"}, nil, nil, nil, false},
+		{"lists remaining keys", dir, []string{"list"}, nil, nil, []string{"/api/2\n/stale/1\n"}, false},
+		{"rejects unknown commands", dir, []string{"purge"}, nil, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdin, stdout := tt.stdin, tt.stdout
+			if stdin == nil {
+				stdin = &bytes.Buffer{}
+			}
+			if stdout == nil {
+				stdout = &bytes.Buffer{}
+			}
+
+			args := append([]string{"-adapter", "fs", "-fs-dir", tt.dir}, tt.args...)
+			err := run(ctx, args, stdin, stdout, &bytes.Buffer{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("run() output = %q, want %q", stdout, want)
+				}
+			}
+		})
+	}
+}
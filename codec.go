@@ -0,0 +1,74 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cache
+
+import "encoding/json"
+
+// Codec converts responses into the bytes stored by the adapter and back.
+//
+// Entries are only readable by the codec that wrote them, so changing the
+// codec of a deployed client effectively empties its cache.
+type Codec interface {
+	// Marshal encodes a response.
+	Marshal(response Response) ([]byte, error)
+
+	// Unmarshal decodes a response encoded by Marshal.
+	Unmarshal(b []byte) (Response, error)
+}
+
+// GobCodec is the default Codec, encoding responses with their binary
+// marshaling methods. Entries written by older versions of this package
+// remain readable.
+type GobCodec struct{}
+
+// Marshal implements the Codec interface Marshal method.
+func (GobCodec) Marshal(response Response) ([]byte, error) {
+	return response.MarshalBinary()
+}
+
+// Unmarshal implements the Codec interface Unmarshal method.
+func (GobCodec) Unmarshal(b []byte) (Response, error) {
+	var response Response
+	err := response.UnmarshalBinary(b)
+
+	return response, err
+}
+
+// JSONCodec encodes responses as JSON objects, making entries readable by
+// services written in other languages. Bodies are base64 encoded.
+type JSONCodec struct{}
+
+// Marshal implements the Codec interface Marshal method.
+func (JSONCodec) Marshal(response Response) ([]byte, error) {
+	return json.Marshal(response)
+}
+
+// Unmarshal implements the Codec interface Unmarshal method.
+func (JSONCodec) Unmarshal(b []byte) (Response, error) {
+	var response Response
+	err := json.Unmarshal(b, &response)
+
+	return response, err
+}
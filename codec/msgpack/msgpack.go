@@ -0,0 +1,75 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package msgpack
+
+import (
+	"bytes"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec encodes responses as MessagePack maps keyed by the Response JSON
+// field names, which keeps entries compact and readable from other
+// languages.
+type Codec struct{}
+
+// Marshal implements the cache Codec interface Marshal method.
+func (Codec) Marshal(response cache.Response) ([]byte, error) {
+	var b bytes.Buffer
+	enc := msgpack.NewEncoder(&b)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(&response); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// Unmarshal implements the cache Codec interface Unmarshal method.
+func (Codec) Unmarshal(b []byte) (cache.Response, error) {
+	var response cache.Response
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	dec.SetCustomStructTag("json")
+	if err := dec.Decode(&response); err != nil {
+		return cache.Response{}, err
+	}
+
+	// MessagePack timestamps decode in the local time zone, normalize zero
+	// dates back to the zero value.
+	for _, t := range []*time.Time{&response.Expiration, &response.StoredAt, &response.LastAccess} {
+		if t.IsZero() {
+			*t = time.Time{}
+		}
+	}
+
+	return response, nil
+}
+
+// NewCodec initializes the MessagePack codec.
+func NewCodec() cache.Codec {
+	return Codec{}
+}
@@ -0,0 +1,311 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package protobuf
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Codec encodes responses as Protocol Buffers messages matching the
+// following schema, with dates as Unix nanoseconds and zero dates omitted:
+//
+//	message Response {
+//	  message Header {
+//	    string name = 1;
+//	    repeated string values = 2;
+//	  }
+//
+//	  bytes value = 1;
+//	  int64 status_code = 2;
+//	  repeated Header header = 3;
+//	  int64 expiration = 4;
+//	  int64 stored_at = 5;
+//	  int64 last_access = 6;
+//	  int64 frequency = 7;
+//	  repeated string vary = 8;
+//	  string encoding = 9;
+//	  string key = 10;
+//	  Request request = 11;
+//	}
+//
+//	message Request {
+//	  string method = 1;
+//	  string url = 2;
+//	  repeated Response.Header header = 3;
+//	}
+type Codec struct{}
+
+const (
+	fieldValue      protowire.Number = 1
+	fieldStatusCode protowire.Number = 2
+	fieldHeader     protowire.Number = 3
+	fieldExpiration protowire.Number = 4
+	fieldStoredAt   protowire.Number = 5
+	fieldLastAccess protowire.Number = 6
+	fieldFrequency  protowire.Number = 7
+	fieldVary       protowire.Number = 8
+	fieldEncoding   protowire.Number = 9
+	fieldKey        protowire.Number = 10
+	fieldRequest    protowire.Number = 11
+
+	fieldHeaderName   protowire.Number = 1
+	fieldHeaderValues protowire.Number = 2
+
+	fieldRequestMethod protowire.Number = 1
+	fieldRequestURL    protowire.Number = 2
+	fieldRequestHeader protowire.Number = 3
+)
+
+// errMalformed is returned when a message can't be parsed.
+var errMalformed = errors.New("protobuf codec: malformed response")
+
+// Marshal implements the cache Codec interface Marshal method.
+func (Codec) Marshal(response cache.Response) ([]byte, error) {
+	var b []byte
+	if len(response.Value) > 0 {
+		b = protowire.AppendTag(b, fieldValue, protowire.BytesType)
+		b = protowire.AppendBytes(b, response.Value)
+	}
+	b = appendInt(b, fieldStatusCode, int64(response.StatusCode))
+	b = appendHeader(b, fieldHeader, response.Header)
+	b = appendTime(b, fieldExpiration, response.Expiration)
+	b = appendTime(b, fieldStoredAt, response.StoredAt)
+	b = appendTime(b, fieldLastAccess, response.LastAccess)
+	b = appendInt(b, fieldFrequency, int64(response.Frequency))
+	for _, name := range response.Vary {
+		b = protowire.AppendTag(b, fieldVary, protowire.BytesType)
+		b = protowire.AppendString(b, name)
+	}
+	if response.Encoding != "" {
+		b = protowire.AppendTag(b, fieldEncoding, protowire.BytesType)
+		b = protowire.AppendString(b, string(response.Encoding))
+	}
+	if response.Key != "" {
+		b = protowire.AppendTag(b, fieldKey, protowire.BytesType)
+		b = protowire.AppendString(b, response.Key)
+	}
+	if response.Request != nil {
+		var req []byte
+		req = appendString(req, fieldRequestMethod, response.Request.Method)
+		req = appendString(req, fieldRequestURL, response.Request.URL)
+		req = appendHeader(req, fieldRequestHeader, response.Request.Header)
+		b = protowire.AppendTag(b, fieldRequest, protowire.BytesType)
+		b = protowire.AppendBytes(b, req)
+	}
+
+	return b, nil
+}
+
+// Unmarshal implements the cache Codec interface Unmarshal method.
+//
+// Like the default codec, the returned Value references b directly.
+func (Codec) Unmarshal(b []byte) (cache.Response, error) {
+	var response cache.Response
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return cache.Response{}, errMalformed
+		}
+		b = b[n:]
+
+		switch {
+		case typ == protowire.BytesType && num == fieldValue:
+			response.Value, n = protowire.ConsumeBytes(b)
+		case typ == protowire.BytesType && num == fieldHeader:
+			var h []byte
+			h, n = protowire.ConsumeBytes(b)
+			if n >= 0 {
+				if response.Header == nil {
+					response.Header = http.Header{}
+				}
+				if err := consumeHeader(h, response.Header); err != nil {
+					return cache.Response{}, err
+				}
+			}
+		case typ == protowire.BytesType && num == fieldVary:
+			var name string
+			name, n = protowire.ConsumeString(b)
+			response.Vary = append(response.Vary, name)
+		case typ == protowire.BytesType && num == fieldEncoding:
+			var encoding string
+			encoding, n = protowire.ConsumeString(b)
+			response.Encoding = cache.Compression(encoding)
+		case typ == protowire.BytesType && num == fieldKey:
+			response.Key, n = protowire.ConsumeString(b)
+		case typ == protowire.BytesType && num == fieldRequest:
+			var req []byte
+			req, n = protowire.ConsumeBytes(b)
+			if n >= 0 {
+				info, err := consumeRequest(req)
+				if err != nil {
+					return cache.Response{}, err
+				}
+				response.Request = info
+			}
+		case typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			switch num {
+			case fieldStatusCode:
+				response.StatusCode = int(v)
+			case fieldExpiration:
+				response.Expiration = time.Unix(0, int64(v))
+			case fieldStoredAt:
+				response.StoredAt = time.Unix(0, int64(v))
+			case fieldLastAccess:
+				response.LastAccess = time.Unix(0, int64(v))
+			case fieldFrequency:
+				response.Frequency = int(v)
+			}
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return cache.Response{}, errMalformed
+		}
+		b = b[n:]
+	}
+
+	return response, nil
+}
+
+func consumeRequest(b []byte) (*cache.RequestInfo, error) {
+	info := &cache.RequestInfo{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, errMalformed
+		}
+		b = b[n:]
+
+		switch {
+		case typ == protowire.BytesType && num == fieldRequestMethod:
+			info.Method, n = protowire.ConsumeString(b)
+		case typ == protowire.BytesType && num == fieldRequestURL:
+			info.URL, n = protowire.ConsumeString(b)
+		case typ == protowire.BytesType && num == fieldRequestHeader:
+			var h []byte
+			h, n = protowire.ConsumeBytes(b)
+			if n >= 0 {
+				if info.Header == nil {
+					info.Header = http.Header{}
+				}
+				if err := consumeHeader(h, info.Header); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return nil, errMalformed
+		}
+		b = b[n:]
+	}
+
+	return info, nil
+}
+
+func consumeHeader(b []byte, header http.Header) error {
+	var name string
+	var values []string
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return errMalformed
+		}
+		b = b[n:]
+
+		switch {
+		case typ == protowire.BytesType && num == fieldHeaderName:
+			name, n = protowire.ConsumeString(b)
+		case typ == protowire.BytesType && num == fieldHeaderValues:
+			var value string
+			value, n = protowire.ConsumeString(b)
+			values = append(values, value)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return errMalformed
+		}
+		b = b[n:]
+	}
+	header[name] = append(header[name], values...)
+
+	return nil
+}
+
+func appendHeader(b []byte, num protowire.Number, header http.Header) []byte {
+	for name, values := range header {
+		var h []byte
+		h = protowire.AppendTag(h, fieldHeaderName, protowire.BytesType)
+		h = protowire.AppendString(h, name)
+		for _, value := range values {
+			h = protowire.AppendTag(h, fieldHeaderValues, protowire.BytesType)
+			h = protowire.AppendString(h, value)
+		}
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendBytes(b, h)
+	}
+
+	return b
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+
+	return protowire.AppendString(b, s)
+}
+
+func appendInt(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+func appendTime(b []byte, num protowire.Number, t time.Time) []byte {
+	if t.IsZero() {
+		return b
+	}
+
+	return appendInt(b, num, t.UnixNano())
+}
+
+// NewCodec initializes the Protocol Buffers codec.
+func NewCodec() cache.Codec {
+	return Codec{}
+}
@@ -0,0 +1,75 @@
+package protobuf
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+)
+
+func TestCodec(t *testing.T) {
+	tests := []struct {
+		name     string
+		response cache.Response
+	}{
+		{
+			"round trips a response",
+			cache.Response{
+				Value:      []byte("value 1"),
+				StatusCode: http.StatusCreated,
+				Header:     http.Header{"Content-Type": {"text/plain"}, "X-Foo": {"a", "b"}},
+				Expiration: time.Unix(0, 1609556645000000006),
+				StoredAt:   time.Unix(0, 1609556585000000006),
+				LastAccess: time.Unix(0, 1609556595000000006),
+				Frequency:  2,
+				Vary:       []string{"Accept"},
+				Encoding:   cache.Gzip,
+				Key:        "http://foo.bar/test-1",
+			},
+		},
+		{
+			"round trips request metadata",
+			cache.Response{
+				Value:      []byte("value 1"),
+				StatusCode: http.StatusOK,
+				Expiration: time.Unix(0, 1609556645000000006),
+				Request: &cache.RequestInfo{
+					Method: http.MethodGet,
+					URL:    "http://foo.bar/test-1?a=1",
+					Header: http.Header{"Accept-Language": {"en", "fr"}},
+				},
+			},
+		},
+		{
+			"round trips a vary marker",
+			cache.Response{
+				Expiration: time.Unix(0, 1609556645000000006),
+				Vary:       []string{"Accept", "Accept-Language"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codec := NewCodec()
+			b, err := codec.Marshal(tt.response)
+			if err != nil {
+				t.Fatalf("Codec.Marshal() error = %v", err)
+			}
+			got, err := codec.Unmarshal(b)
+			if err != nil {
+				t.Fatalf("Codec.Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.response) {
+				t.Errorf("Codec.Unmarshal() = %v, want %v", got, tt.response)
+			}
+		})
+	}
+}
+
+func TestCodecMalformed(t *testing.T) {
+	if _, err := NewCodec().Unmarshal([]byte{0x0a, 0x05, 'a'}); err == nil {
+		t.Errorf("Codec.Unmarshal() error = nil, want an error")
+	}
+}
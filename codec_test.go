@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCodecs(t *testing.T) {
+	want := Response{
+		Value:      []byte("value 1"),
+		StatusCode: http.StatusCreated,
+		Header:     http.Header{"Content-Type": {"text/plain"}},
+		Expiration: time.Date(2021, 1, 2, 3, 4, 5, 6, time.UTC),
+		StoredAt:   time.Date(2021, 1, 2, 3, 3, 5, 6, time.UTC),
+		Frequency:  2,
+		Vary:       []string{"Accept"},
+	}
+
+	tests := []struct {
+		name  string
+		codec Codec
+	}{
+		{"gob", GobCodec{}},
+		{"json", JSONCodec{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := tt.codec.Marshal(want)
+			if err != nil {
+				t.Fatalf("Codec.Marshal() error = %v", err)
+			}
+			got, err := tt.codec.Unmarshal(b)
+			if err != nil {
+				t.Fatalf("Codec.Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Codec.Unmarshal() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareCodec(t *testing.T) {
+	adapter := &adapterMock{store: map[string][]byte{
+		"http://foo.bar/test-2": []byte("not json"),
+	}}
+	var errs []error
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithCodec(JSONCodec{}),
+		WithHooks(Hooks{
+			OnError: func(r *http.Request, key string, err error) {
+				errs = append(errs, err)
+			},
+		}),
+	)
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("new value"))
+	}))
+
+	r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	got, err := JSONCodec{}.Unmarshal(adapter.store["http://foo.bar/test-1"])
+	if err != nil || string(got.Value) != "new value" {
+		t.Errorf("stored response = %q, %v, want %q", got.Value, err, "new value")
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "http://foo.bar/test-2", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Body.String() != "new value" {
+		t.Errorf("*Client.Middleware() = %v, want %v", w.Body.String(), "new value")
+	}
+	if len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), "codec unmarshal: ") {
+		t.Errorf("reported errors = %v, want a codec unmarshal error", errs)
+	}
+
+	if _, err := NewClient(WithAdapter(adapter), WithTTL(1*time.Minute), WithCodec(nil)); err == nil {
+		t.Errorf("NewClient() error = nil, want an error for a nil codec")
+	}
+}
@@ -0,0 +1,176 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is the string type for cached body compression algorithms.
+type Compression string
+
+const (
+	// Gzip is the constant for gzip compression.
+	Gzip Compression = "gzip"
+
+	// Snappy is the constant for snappy compression, which is fast but not a
+	// valid HTTP content coding, so snappy bodies are always decompressed.
+	Snappy Compression = "snappy"
+
+	// Zstd is the constant for Zstandard compression.
+	Zstd Compression = "zstd"
+)
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+)
+
+func initZstd() {
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+}
+
+// Compress compresses b with the given algorithm, for adapters compressing
+// the entries they store.
+func Compress(compression Compression, b []byte) ([]byte, error) {
+	switch compression {
+	case Gzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(b); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case Snappy:
+		return snappy.Encode(nil, b), nil
+	case Zstd:
+		zstdOnce.Do(initZstd)
+		return zstdEncoder.EncodeAll(b, nil), nil
+	}
+
+	return nil, fmt.Errorf("compression %q is not supported", compression)
+}
+
+// Decompress decompresses b with the given algorithm.
+func Decompress(compression Compression, b []byte) ([]byte, error) {
+	switch compression {
+	case Gzip:
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(zr)
+	case Snappy:
+		return snappy.Decode(nil, b)
+	case Zstd:
+		zstdOnce.Do(initZstd)
+		return zstdDecoder.DecodeAll(b, nil)
+	}
+
+	return nil, fmt.Errorf("compression %q is not supported", compression)
+}
+
+// accepts reports whether a request accepts responses with the given
+// compression as their content coding, honoring quality values and the "*"
+// wildcard of its Accept-Encoding header.
+func accepts(r *http.Request, compression Compression) bool {
+	if compression == Snappy {
+		return false
+	}
+
+	wildcard := false
+	for _, field := range r.Header.Values("Accept-Encoding") {
+		for _, coding := range strings.Split(field, ",") {
+			name, q := parseCoding(coding)
+			if strings.EqualFold(name, string(compression)) {
+				return q > 0
+			}
+			if name == "*" {
+				wildcard = q > 0
+			}
+		}
+	}
+
+	return wildcard
+}
+
+// parseCoding parses an Accept-Encoding element into its content coding and
+// quality value. Invalid quality values are treated as 0.
+func parseCoding(coding string) (string, float64) {
+	params := strings.Split(coding, ";")
+	q := 1.0
+	for _, param := range params[1:] {
+		param = strings.TrimSpace(param)
+		if len(param) < 2 || !strings.EqualFold(param[:2], "q=") {
+			continue
+		}
+		v, err := strconv.ParseFloat(param[2:], 64)
+		if err != nil {
+			v = 0
+		}
+		q = v
+	}
+
+	return strings.TrimSpace(params[0]), q
+}
+
+// encodedETag derives the entity tag of a representation compressed with
+// the given algorithm from the tag of its identity representation, since
+// strong validators must differ between content codings.
+func encodedETag(tag string, compression Compression) string {
+	if !strings.HasSuffix(tag, `"`) || len(tag) < 2 {
+		return tag
+	}
+
+	return tag[:len(tag)-1] + "-" + string(compression) + `"`
+}
+
+// addVary adds a header name to the Vary header of h, unless already listed.
+func addVary(h http.Header, name string) {
+	for _, field := range h.Values("Vary") {
+		for _, listed := range strings.Split(field, ",") {
+			listed = strings.TrimSpace(listed)
+			if listed == "*" || strings.EqualFold(listed, name) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", name)
+}
@@ -0,0 +1,189 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompression(t *testing.T) {
+	value := []byte(strings.Repeat("value 1 ", 100))
+	for _, compression := range []Compression{Gzip, Snappy, Zstd} {
+		t.Run(string(compression), func(t *testing.T) {
+			b, err := Compress(compression, value)
+			if err != nil {
+				t.Fatalf("Compress() error = %v", err)
+			}
+			if len(b) >= len(value) {
+				t.Errorf("Compress() length = %v, want less than %v", len(b), len(value))
+			}
+			got, err := Decompress(compression, b)
+			if err != nil {
+				t.Fatalf("Decompress() error = %v", err)
+			}
+			if !bytes.Equal(got, value) {
+				t.Errorf("Decompress() = %s, want %s", got, value)
+			}
+			if _, err := Decompress(compression, []byte("garbage")); err == nil {
+				t.Errorf("Decompress() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestAccepts(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		compression    Compression
+		want           bool
+	}{
+		{"accepts listed coding", "gzip, deflate", Gzip, true},
+		{"accepts coding with params", "deflate, zstd;q=0.8", Zstd, true},
+		{"is case insensitive", "GZIP", Gzip, true},
+		{"rejects unlisted coding", "deflate, br", Gzip, false},
+		{"rejects missing header", "", Gzip, false},
+		{"never accepts snappy", "snappy", Snappy, false},
+		{"rejects zero quality", "gzip;q=0, deflate", Gzip, false},
+		{"rejects invalid quality", "gzip;q=high", Gzip, false},
+		{"accepts wildcard", "deflate, *", Zstd, true},
+		{"prefers listed coding over wildcard", "gzip;q=0, *", Gzip, false},
+		{"rejects zero quality wildcard", "*;q=0", Gzip, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+			if tt.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			if got := accepts(r, tt.compression); got != tt.want {
+				t.Errorf("accepts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareCompression(t *testing.T) {
+	value := strings.Repeat("value 1 ", 100)
+	adapter := &adapterMock{store: map[string][]byte{}}
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithCompression(Gzip, 64),
+		WithETag(true),
+	)
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/small" {
+			w.Write([]byte("value 1"))
+			return
+		}
+		w.Write([]byte(value))
+	}))
+
+	for _, path := range []string{"/large", "/small"} {
+		r, _ := http.NewRequest(http.MethodGet, "http://foo.bar"+path, nil)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	if got := BytesToResponse(adapter.store["http://foo.bar/large"]); got.Encoding != Gzip || len(got.Value) >= len(value) {
+		t.Errorf("stored response encoding = %q, length %v, want gzip compressed", got.Encoding, len(got.Value))
+	}
+	if got := BytesToResponse(adapter.store["http://foo.bar/small"]); got.Encoding != "" {
+		t.Errorf("stored response encoding = %q, want none", got.Encoding)
+	}
+
+	identityTag := etag([]byte(value))
+	gzipTag := encodedETag(identityTag, Gzip)
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		wantEncoding   string
+		wantETag       string
+	}{
+		{"decompresses for clients not accepting gzip", "", "", identityTag},
+		{"serves as is to clients accepting gzip", "gzip", "gzip", gzipTag},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/large", nil)
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if got := w.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+			if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want %q", got, "Accept-Encoding")
+			}
+			if got := w.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("ETag = %q, want %q", got, tt.wantETag)
+			}
+			body := w.Body.Bytes()
+			if tt.wantEncoding == "gzip" {
+				zr, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatalf("gzip.NewReader() error = %v", err)
+				}
+				body, _ = ioutil.ReadAll(zr)
+			}
+			if string(body) != value {
+				t.Errorf("*Client.Middleware() = %s, want %s", body, value)
+			}
+
+			r.Header.Set("If-None-Match", tt.wantETag)
+			w = httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != http.StatusNotModified {
+				t.Errorf("*Client.Middleware() status = %v, want %v", w.Code, http.StatusNotModified)
+			}
+		})
+	}
+}
+
+func TestEncodedETag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{`"abc"`, `"abc-gzip"`},
+		{`W/"abc"`, `W/"abc-gzip"`},
+		{`abc`, `abc`},
+	}
+	for _, tt := range tests {
+		if got := encodedETag(tt.tag, Gzip); got != tt.want {
+			t.Errorf("encodedETag(%v) = %v, want %v", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestWithCompression(t *testing.T) {
+	tests := []struct {
+		name        string
+		compression Compression
+		minSize     int
+		wantErr     bool
+	}{
+		{"valid", Zstd, 0, false},
+		{"invalid compression", "br", 0, true},
+		{"invalid min size", Gzip, -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClient(
+				WithAdapter(&adapterMock{}),
+				WithTTL(1*time.Minute),
+				WithCompression(tt.compression, tt.minSize),
+			)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package config builds cache clients from declarative YAML or JSON files,
+// so that cache policies are reviewed as configuration rather than code:
+//
+//	adapter:
+//	  type: redis
+//	  addrs: [localhost:6379]
+//	ttl: 5m
+//	vary_headers: [Accept-Language]
+//	status_codes: [200, 301, 404]
+//	negative_ttl: 30s
+//	max_body_size: 1048576
+//	rules:
+//	  - path: /api/*/search
+//	    bypass: true
+//	  - path: /static/*
+//	    ttl: 24h
+//
+// Durations are strings such as "1m30s". Paths are glob patterns matched
+// against the request path, where "*" matches any sequence of characters,
+// including slashes.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	"github.com/cludden/http-cache/adapter/fs"
+	"github.com/cludden/http-cache/adapter/memory"
+	"github.com/cludden/http-cache/adapter/redis"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultCapacity is the capacity of the memory adapter by default.
+const DefaultCapacity = 10000
+
+// Config is the declarative configuration of a client and its adapter.
+type Config struct {
+	// Adapter configures the cache adapter.
+	Adapter Adapter `json:"adapter"`
+
+	// TTL is how long responses are cached by default.
+	TTL Duration `json:"ttl"`
+
+	// TTLFromHeaders makes the response Cache-Control and Expires headers
+	// take precedence over the TTL.
+	TTLFromHeaders bool `json:"ttl_from_headers"`
+
+	// NegativeTTL is how long 404 Not Found and 410 Gone responses are
+	// cached, which they aren't when zero.
+	NegativeTTL Duration `json:"negative_ttl"`
+
+	// StaleWhileRevalidate is how long expired responses are served while
+	// refreshed in the background.
+	StaleWhileRevalidate Duration `json:"stale_while_revalidate"`
+
+	// StaleIfError is how long expired responses are served when the
+	// handler fails.
+	StaleIfError Duration `json:"stale_if_error"`
+
+	// Methods are the cached request methods, GET by default.
+	Methods []string `json:"methods"`
+
+	// VaryHeaders are the request headers whose values are added to keys.
+	VaryHeaders []string `json:"vary_headers"`
+
+	// StatusCodes restricts the cached responses to the given status codes.
+	StatusCodes []int `json:"status_codes"`
+
+	// MaxBodySize is the size of the largest cached response body, in
+	// bytes, unlimited when zero.
+	MaxBodySize int64 `json:"max_body_size"`
+
+	// PrivateCaching allows caching requests with credentials and
+	// responses setting cookies.
+	PrivateCaching bool `json:"private_caching"`
+
+	// Rules override the policy by request path, the first matching rule
+	// winning. Requests matching no rule are cached with the default
+	// policy.
+	Rules []Rule `json:"rules"`
+}
+
+// Rule overrides the cache policy of the requests it matches.
+type Rule struct {
+	// Path is the glob pattern matched against the request path.
+	Path string `json:"path"`
+
+	// Methods restricts the rule to the given request methods.
+	Methods []string `json:"methods"`
+
+	// TTL is how long the matched responses are cached, taking precedence
+	// over the response headers, unless zero.
+	TTL Duration `json:"ttl"`
+
+	// VaryHeaders are request headers whose values are added to the keys of
+	// the matched requests.
+	VaryHeaders []string `json:"vary_headers"`
+
+	// Bypass disables caching the matched requests.
+	Bypass bool `json:"bypass"`
+}
+
+// Adapter configures the cache adapter.
+type Adapter struct {
+	// Type is memory, redis or fs.
+	Type string `json:"type"`
+
+	// Capacity is the number of responses held by the memory adapter,
+	// DefaultCapacity when zero.
+	Capacity int `json:"capacity"`
+
+	// MaxBytes is the total size of the responses held by the memory and fs
+	// adapters, unlimited when zero.
+	MaxBytes int64 `json:"max_bytes"`
+
+	// Addrs are the addresses of the Redis nodes.
+	Addrs []string `json:"addrs"`
+
+	// KeyPrefix prefixes the Redis keys.
+	KeyPrefix string `json:"key_prefix"`
+
+	// Dir is the directory of the fs adapter.
+	Dir string `json:"dir"`
+}
+
+// Duration is a time.Duration read from a string such as "1m30s".
+type Duration time.Duration
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration %s is invalid, want a string such as \"1m30s\"", b)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Load reads a YAML or JSON configuration file.
+func Load(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+
+	return c, nil
+}
+
+// Parse decodes a YAML or JSON configuration, rejecting unknown fields.
+func Parse(b []byte) (*Config, error) {
+	// JSON being YAML, the document is decoded as YAML then converted to
+	// JSON, to be decoded with a single set of field names.
+	var doc interface{}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		doc = map[string]interface{}{}
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Config{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// NewClient initializes the configured adapter and a client using it, with
+// the configured options followed by opts.
+func (c *Config) NewClient(opts ...cache.ClientOption) (*cache.Client, error) {
+	adapter, err := c.NewAdapter()
+	if err != nil {
+		return nil, err
+	}
+
+	return cache.NewClient(append(append([]cache.ClientOption{cache.WithAdapter(adapter)}, c.Options()...), opts...)...)
+}
+
+// NewAdapter initializes the configured adapter.
+func (c *Config) NewAdapter() (cache.Adapter, error) {
+	switch c.Adapter.Type {
+	case "memory":
+		capacity := c.Adapter.Capacity
+		if capacity == 0 {
+			capacity = DefaultCapacity
+		}
+		opts := []memory.AdapterOptions{
+			memory.AdapterWithAlgorithm(memory.LRU),
+			memory.AdapterWithCapacity(capacity),
+		}
+		if c.Adapter.MaxBytes > 0 {
+			opts = append(opts, memory.AdapterWithMaxBytes(c.Adapter.MaxBytes))
+		}
+		return memory.NewAdapter(opts...)
+	case "redis":
+		if len(c.Adapter.Addrs) == 0 {
+			return nil, errors.New("config redis adapter addrs can not be empty")
+		}
+		return redis.NewAdapterFromOptions(
+			redis.AdapterWithAddrs(c.Adapter.Addrs...),
+			redis.AdapterWithKeyPrefix(c.Adapter.KeyPrefix),
+		), nil
+	case "fs":
+		if c.Adapter.Dir == "" {
+			return nil, errors.New("config fs adapter dir can not be empty")
+		}
+		var opts []fs.AdapterOptions
+		if c.Adapter.MaxBytes > 0 {
+			opts = append(opts, fs.AdapterWithMaxBytes(c.Adapter.MaxBytes))
+		}
+		return fs.NewAdapter(c.Adapter.Dir, opts...)
+	default:
+		return nil, fmt.Errorf("config adapter type %q is invalid, want memory, redis or fs", c.Adapter.Type)
+	}
+}
+
+// Options returns the client options of the configuration, besides the
+// adapter.
+func (c *Config) Options() []cache.ClientOption {
+	opts := []cache.ClientOption{
+		cache.WithTTL(time.Duration(c.TTL)),
+		cache.WithTTLFromHeaders(c.TTLFromHeaders),
+		cache.WithPrivateCaching(c.PrivateCaching),
+	}
+	if len(c.Methods) > 0 {
+		opts = append(opts, cache.WithMethods(c.Methods...))
+	}
+	if len(c.Rules) > 0 {
+		rules := make([]cache.Rule, 0, len(c.Rules)+1)
+		for _, rule := range c.Rules {
+			rules = append(rules, cache.Rule{
+				Path:        rule.Path,
+				Methods:     rule.Methods,
+				TTL:         time.Duration(rule.TTL),
+				VaryHeaders: rule.VaryHeaders,
+				Disabled:    rule.Bypass,
+			})
+		}
+		opts = append(opts, cache.WithRules(append(rules, cache.Rule{})))
+	}
+	if c.NegativeTTL != 0 {
+		opts = append(opts, cache.WithNegativeTTL(time.Duration(c.NegativeTTL)))
+	}
+	if c.StaleWhileRevalidate != 0 {
+		opts = append(opts, cache.WithStaleWhileRevalidate(time.Duration(c.StaleWhileRevalidate)))
+	}
+	if c.StaleIfError != 0 {
+		opts = append(opts, cache.WithStaleIfError(time.Duration(c.StaleIfError)))
+	}
+	if len(c.VaryHeaders) > 0 {
+		opts = append(opts, cache.WithVaryHeaders(c.VaryHeaders...))
+	}
+	if len(c.StatusCodes) > 0 {
+		opts = append(opts, cache.WithStatusCodes(c.StatusCodes...))
+	}
+	if c.MaxBodySize != 0 {
+		opts = append(opts, cache.WithMaxBodySize(c.MaxBodySize))
+	}
+
+	return opts
+}
@@ -0,0 +1,160 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+)
+
+func TestParse(t *testing.T) {
+	want := &Config{
+		Adapter:     Adapter{Type: "redis", Addrs: []string{"localhost:6379"}},
+		TTL:         Duration(5 * time.Minute),
+		StatusCodes: []int{200, 404},
+		Rules: []Rule{
+			{Path: "/api/*/search", Bypass: true},
+			{Path: "/static/*", TTL: Duration(24 * time.Hour)},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		doc     string
+		want    *Config
+		wantErr bool
+	}{
+		{
+			"parses YAML",
+			`
+adapter:
+  type: redis
+  addrs: [localhost:6379]
+ttl: 5m
+status_codes: [200, 404]
+rules:
+  - path: /api/*/search
+    bypass: true
+  - path: /static/*
+    ttl: 24h
+`,
+			want,
+			false,
+		},
+		{
+			"parses JSON",
+			`{"adapter": {"type": "redis", "addrs": ["localhost:6379"]}, "ttl": "5m", "status_codes": [200, 404],
+			"rules": [{"path": "/api/*/search", "bypass": true}, {"path": "/static/*", "ttl": "24h"}]}`,
+			want,
+			false,
+		},
+		{"parses empty documents", "", &Config{}, false},
+		{"rejects unknown fields", "tll: 5m", nil, true},
+		{"rejects invalid durations", "ttl: 5", nil, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.doc))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigNewClient(t *testing.T) {
+	c, err := Parse([]byte(`
+adapter:
+  type: memory
+ttl: 1m
+status_codes: [200]
+rules:
+  - path: /api/*/search
+    bypass: true
+  - path: /static/*
+    ttl: 24h
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if _, err := c.NewClient(); err != nil {
+		t.Fatalf("Config.NewClient() error = %v", err)
+	}
+	adapter, _ := c.NewAdapter()
+	client, err := cache.NewClient(append([]cache.ClientOption{cache.WithAdapter(adapter)}, c.Options()...)...)
+	if err != nil {
+		t.Fatalf("cache.NewClient() error = %v", err)
+	}
+	calls := 0
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write([]byte("value"))
+	}))
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		wantCalls int
+		wantTTL   time.Duration
+	}{
+		{"caches with the default ttl", http.MethodGet, "/api/products", 1, time.Minute},
+		{"caches with the rule ttl", http.MethodGet, "/static/app.js", 1, 24 * time.Hour},
+		{"bypasses matching rules", http.MethodGet, "/api/products/search", 2, 0},
+		{"bypasses other methods", http.MethodPost, "/api/products", 2, 0},
+		{"skips other status codes", http.MethodGet, "/missing", 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			for i := 0; i < 2; i++ {
+				r := httptest.NewRequest(tt.method, "http://foo.bar"+tt.path, nil)
+				handler.ServeHTTP(httptest.NewRecorder(), r)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("handler calls = %v, want %v", calls, tt.wantCalls)
+			}
+			if tt.wantTTL > 0 {
+				b, _ := adapter.Get(context.Background(), "http://foo.bar"+tt.path)
+				response := cache.BytesToResponse(b)
+				if got := response.Expiration.Sub(response.StoredAt); got != tt.wantTTL {
+					t.Errorf("cached for %v, want %v", got, tt.wantTTL)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigNewAdapter(t *testing.T) {
+	tests := []struct {
+		name    string
+		adapter Adapter
+		wantErr bool
+	}{
+		{"initializes memory adapters", Adapter{Type: "memory"}, false},
+		{"initializes fs adapters", Adapter{Type: "fs", Dir: t.TempDir()}, false},
+		{"requires redis addrs", Adapter{Type: "redis"}, true},
+		{"requires fs dir", Adapter{Type: "fs"}, true},
+		{"rejects unknown types", Adapter{Type: "memcached"}, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{Adapter: tt.adapter}
+			if _, err := c.NewAdapter(); (err != nil) != tt.wantErr {
+				t.Errorf("Config.NewAdapter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
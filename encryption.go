@@ -0,0 +1,121 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cache
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// encryptionMagic prefixes entries encrypted by the client.
+const encryptionMagic = "\x00hce"
+
+// keyIDSize is the size of the key ID identifying the key an entry is
+// encrypted with.
+const keyIDSize = 4
+
+// encryption seals entries with AES-GCM, using the primary key for new
+// entries and any known key for existing ones.
+type encryption struct {
+	primary [keyIDSize]byte
+	aeads   map[[keyIDSize]byte]cipher.AEAD
+}
+
+func newEncryption(key []byte, previous ...[]byte) (*encryption, error) {
+	e := &encryption{aeads: map[[keyIDSize]byte]cipher.AEAD{}}
+	for i, k := range append([][]byte{key}, previous...) {
+		block, err := aes.NewCipher(k)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+
+		id := keyID(k)
+		if i == 0 {
+			e.primary = id
+		}
+		e.aeads[id] = aead
+	}
+
+	return e, nil
+}
+
+// keyID derives the ID of a key from its SHA-256 hash, so the ID doesn't
+// reveal the key.
+func keyID(key []byte) [keyIDSize]byte {
+	sum := sha256.Sum256(append([]byte("http-cache key id:"), key...))
+
+	var id [keyIDSize]byte
+	copy(id[:], sum[:])
+
+	return id
+}
+
+// seal encrypts b with the primary key, using key as additional data so an
+// entry can't be moved to another key.
+func (e *encryption) seal(key string, b []byte) ([]byte, error) {
+	aead := e.aeads[e.primary]
+	size := len(encryptionMagic) + keyIDSize + aead.NonceSize()
+	out := make([]byte, size, size+len(b)+aead.Overhead())
+	copy(out, encryptionMagic)
+	copy(out[len(encryptionMagic):], e.primary[:])
+
+	nonce := out[len(encryptionMagic)+keyIDSize:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(out, nonce, b, []byte(key)), nil
+}
+
+// open decrypts an entry sealed under key.
+func (e *encryption) open(key string, b []byte) ([]byte, error) {
+	if !bytes.HasPrefix(b, []byte(encryptionMagic)) || len(b) < len(encryptionMagic)+keyIDSize {
+		return nil, errors.New("entry is not encrypted")
+	}
+	b = b[len(encryptionMagic):]
+
+	var id [keyIDSize]byte
+	copy(id[:], b)
+	aead, ok := e.aeads[id]
+	if !ok {
+		return nil, fmt.Errorf("key id %x is unknown", id)
+	}
+	b = b[keyIDSize:]
+
+	if len(b) < aead.NonceSize() {
+		return nil, errors.New("entry is truncated")
+	}
+
+	return aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], []byte(key))
+}
@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEncryption(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 16)
+	newKey := bytes.Repeat([]byte{2}, 32)
+
+	old, _ := newEncryption(oldKey)
+	sealed, err := old.seal("key", []byte("value 1"))
+	if err != nil {
+		t.Fatalf("encryption.seal() error = %v", err)
+	}
+	if bytes.Contains(sealed, []byte("value 1")) {
+		t.Errorf("encryption.seal() = %q, contains plaintext", sealed)
+	}
+
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+
+	rotated, _ := newEncryption(newKey, oldKey)
+	other, _ := newEncryption(newKey)
+
+	tests := []struct {
+		name       string
+		encryption *encryption
+		key        string
+		b          []byte
+		wantErr    bool
+	}{
+		{"opens sealed entry", old, "key", sealed, false},
+		{"opens entry sealed with a previous key", rotated, "key", sealed, false},
+		{"rejects unknown key id", other, "key", sealed, true},
+		{"rejects entry moved to another key", old, "other", sealed, true},
+		{"rejects tampered entry", old, "key", tampered, true},
+		{"rejects plaintext entry", old, "key", []byte("value 1"), true},
+		{"rejects truncated entry", old, "key", sealed[:len(encryptionMagic)+keyIDSize+2], true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.encryption.open(tt.key, tt.b)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("encryption.open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && string(got) != "value 1" {
+				t.Errorf("encryption.open() = %q, want %q", got, "value 1")
+			}
+		})
+	}
+}
+
+func TestMiddlewareEncryption(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 16)
+	newKey := bytes.Repeat([]byte{2}, 16)
+	adapter := &adapterMock{store: map[string][]byte{}}
+
+	counter := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counter++
+		w.Write([]byte("value 1"))
+	})
+	serve := func(opts ...ClientOption) {
+		client, err := NewClient(append([]ClientOption{WithAdapter(adapter), WithTTL(1 * time.Minute)}, opts...)...)
+		if err != nil {
+			t.Fatalf("NewClient() error = %v", err)
+		}
+		r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+		w := httptest.NewRecorder()
+		client.Middleware(handler).ServeHTTP(w, r)
+		if w.Body.String() != "value 1" {
+			t.Errorf("*Client.Middleware() = %v, want %v", w.Body.String(), "value 1")
+		}
+	}
+
+	serve(WithEncryption(oldKey))
+	if bytes.Contains(adapter.store["http://foo.bar/test-1"], []byte("value 1")) {
+		t.Errorf("stored entry contains plaintext")
+	}
+	serve(WithEncryption(newKey, oldKey))
+	if counter != 1 {
+		t.Errorf("handler calls after key rotation = %v, want %v", counter, 1)
+	}
+	serve(WithEncryption(bytes.Repeat([]byte{3}, 16)))
+	if counter != 2 {
+		t.Errorf("handler calls with an unknown key = %v, want %v", counter, 2)
+	}
+
+	if _, err := NewClient(WithAdapter(adapter), WithTTL(1*time.Minute), WithEncryption([]byte("short"))); err == nil {
+		t.Errorf("NewClient() error = nil, want an error for an invalid key")
+	}
+}
@@ -0,0 +1,54 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cache
+
+import (
+	"context"
+	"fmt"
+)
+
+// HealthChecker is implemented by adapters able to check the connectivity
+// of their store, reported by Client.Healthy.
+type HealthChecker interface {
+	// Ping checks that the store is reachable.
+	Ping(ctx context.Context) error
+}
+
+// Healthy checks that the adapter store is reachable, such as for readiness
+// probes, within the adapter timeout, if any. Adapters not implementing
+// HealthChecker, such as in-process ones, are always healthy.
+func (c *Client) Healthy(ctx context.Context) error {
+	checker, ok := UnwrapAdapterV2(c.adapter).(HealthChecker)
+	if !ok {
+		return nil
+	}
+
+	err := c.call(ctx, "ping", checker.Ping)
+	if err != nil {
+		return fmt.Errorf("cache adapter ping: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type healthCheckerMock struct {
+	adapterMock
+	err error
+}
+
+func (a *healthCheckerMock) Ping(ctx context.Context) error {
+	return a.err
+}
+
+func TestClientHealthy(t *testing.T) {
+	tests := []struct {
+		name    string
+		adapter Adapter
+		wantErr bool
+	}{
+		{"healthy", &healthCheckerMock{}, false},
+		{"unhealthy", &healthCheckerMock{err: errors.New("connection refused")}, true},
+		{"unsupported", &adapterMock{}, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := NewClient(
+				WithAdapter(tt.adapter),
+				WithTTL(1*time.Minute),
+			)
+			if err := client.Healthy(context.Background()); (err != nil) != tt.wantErr {
+				t.Errorf("*Client.Healthy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cache
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Hooks are functions the middleware invokes at each stage of handling a
+// request, giving visibility into the cache behavior. Any of them may be nil.
+type Hooks struct {
+	// OnHit is called when a request is served from the cache, including
+	// stale responses.
+	OnHit func(r *http.Request, key string, response Response)
+
+	// OnMiss is called when no cached response can serve a request, before
+	// the handler is invoked.
+	OnMiss func(r *http.Request, key string)
+
+	// OnStore is called when a response is cached.
+	OnStore func(r *http.Request, key string, response Response)
+
+	// OnError is called when handling a request fails, for instance when
+	// its key can't be generated. The key is empty when unknown.
+	OnError func(r *http.Request, key string, err error)
+
+	// OnVerifyFailure is called when a cached entry fails signature
+	// verification, which may indicate the cache store was tampered with.
+	OnVerifyFailure func(r *http.Request, key string, err error)
+
+	// OnInvalidationError is called when publishing an invalidation to the
+	// invalidation bus, or applying one received from it, fails.
+	OnInvalidationError func(invalidation Invalidation, err error)
+
+	// logger logs the events, set with WithLogger.
+	logger eventLogger
+
+	// metrics counts the events, set with WithMetrics.
+	metrics MetricsSink
+
+	// metricTags returns the tags of the metrics of a request, set with
+	// WithMetricTags.
+	metricTags func(*http.Request) []string
+}
+
+// eventLogger logs the events of the middleware, as key value pairs.
+type eventLogger interface {
+	debug(ctx context.Context, msg string, args ...interface{})
+	warn(ctx context.Context, msg string, args ...interface{})
+}
+
+// requestArgs returns the logged key value pairs of a request and its key.
+func requestArgs(r *http.Request, key string, args ...interface{}) []interface{} {
+	return append([]interface{}{"method", r.Method, "url", r.URL.String(), "key", key}, args...)
+}
+
+func (h Hooks) hit(r *http.Request, key string, response Response) {
+	if h.metrics != nil {
+		h.metrics.Incr("hits", h.tags(r))
+	}
+	if h.OnHit != nil {
+		h.OnHit(r, key, response)
+	}
+}
+
+func (h Hooks) miss(r *http.Request, key string) {
+	if h.logger != nil {
+		h.logger.debug(r.Context(), "cache miss", requestArgs(r, key)...)
+	}
+	if h.metrics != nil {
+		h.metrics.Incr("misses", h.tags(r))
+	}
+	if h.OnMiss != nil {
+		h.OnMiss(r, key)
+	}
+}
+
+func (h Hooks) store(r *http.Request, key string, response Response) {
+	if h.logger != nil {
+		h.logger.debug(r.Context(), "cache store", requestArgs(r, key, "status", response.StatusCode, "expiration", response.Expiration)...)
+	}
+	if h.metrics != nil {
+		tags := h.tags(r)
+		h.metrics.Incr("stores", tags)
+		h.metrics.Gauge("response.size", float64(len(response.Value)), tags)
+	}
+	if h.OnStore != nil {
+		h.OnStore(r, key, response)
+	}
+}
+
+// refresh logs the release of a response by a refresh request.
+func (h Hooks) refresh(r *http.Request, key string) {
+	if h.logger != nil {
+		h.logger.debug(r.Context(), "cache refresh", requestArgs(r, key)...)
+	}
+}
+
+func (h Hooks) error(r *http.Request, key string, err error) {
+	if h.logger != nil {
+		h.logger.warn(r.Context(), "cache error", requestArgs(r, key, "err", err)...)
+	}
+	if h.metrics != nil {
+		h.metrics.Incr("errors", h.tags(r))
+	}
+	if h.OnError != nil {
+		h.OnError(r, key, err)
+	}
+}
+
+// decodeFailure reports a cached entry failing to decode to the OnError
+// hook.
+func (h Hooks) decodeFailure(r *http.Request, key string, err error) {
+	if h.logger != nil {
+		h.logger.warn(r.Context(), "cache decode failure", requestArgs(r, key, "err", err)...)
+	}
+	if h.metrics != nil {
+		h.metrics.Incr("errors", h.tags(r, "error:decode"))
+	}
+	if h.OnError != nil {
+		h.OnError(r, key, err)
+	}
+}
+
+func (h Hooks) invalidationError(invalidation Invalidation, err error) {
+	if h.logger != nil {
+		h.logger.warn(context.Background(), "cache invalidation error", "kind", invalidation.Kind, "value", invalidation.Value, "source", invalidation.Source, "err", err)
+	}
+	if h.metrics != nil {
+		h.metrics.Incr("errors", []string{"error:invalidation"})
+	}
+	if h.OnInvalidationError != nil {
+		h.OnInvalidationError(invalidation, err)
+	}
+}
+
+func (h Hooks) verifyFailure(r *http.Request, key string, err error) {
+	if h.logger != nil {
+		h.logger.warn(r.Context(), "cache verify failure", requestArgs(r, key, "err", err)...)
+	}
+	if h.metrics != nil {
+		h.metrics.Incr("errors", h.tags(r, "error:verify"))
+	}
+	if h.OnVerifyFailure != nil {
+		h.OnVerifyFailure(r, key, err)
+	}
+}
+
+// requestTiming records the latency of a request started at a date, tagged
+// once the request is handled, when routers have set its route.
+func (h Hooks) requestTiming(r *http.Request, start time.Time) {
+	h.timing("request.latency", start, h.tags(r)...)
+}
+
+// tags returns the metric tags of a request followed by the given ones.
+func (h Hooks) tags(r *http.Request, tags ...string) []string {
+	if h.metricTags == nil {
+		return tags
+	}
+	return append(h.metricTags(r), tags...)
+}
+
+// timing records the latency of an operation started at a date.
+func (h Hooks) timing(name string, start time.Time, tags ...string) {
+	h.metrics.Timing(name, time.Since(start), tags)
+}
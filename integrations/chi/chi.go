@@ -0,0 +1,84 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package chi resolves the chi route templates of requests, such as
+// "/users/{id}", for the cache client to apply rules by route, tag cached
+// responses and metrics by route, while still caching per concrete URL:
+//
+//	client, _ := cache.NewClient(
+//		cache.WithAdapter(adapter),
+//		cache.WithTTL(10*time.Minute),
+//		cache.WithRoute(cachechi.Route),
+//		cache.WithRules([]cache.Rule{{Route: "/users/{id}", TTL: time.Minute}, {}}),
+//		cache.WithTagger(cachechi.RouteTagger),
+//		cache.WithMetricTags(cachechi.RouteTags),
+//	)
+//	router := chi.NewRouter()
+//	router.Use(client.Middleware)
+package chi
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Route returns the chi route template of a request, or an empty string for
+// requests matching no route or not routed by chi. As middlewares set with
+// Use run before routing, the template is resolved from the root router.
+func Route(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.Routes == nil {
+		return ""
+	}
+
+	path := r.URL.RawPath
+	if path == "" {
+		path = r.URL.Path
+	}
+	match := chi.NewRouteContext()
+	if !rctx.Routes.Match(match, r.Method, path) {
+		return ""
+	}
+
+	return match.RoutePattern()
+}
+
+// RouteTags returns the "route:" metric tag of a request, to use with
+// cache.WithMetricTags.
+func RouteTags(r *http.Request) []string {
+	return []string{"route:" + Route(r)}
+}
+
+// RouteTagger tags the cached responses of a request with "route:" followed
+// by its route template, to use with cache.WithTagger, so that the responses
+// of a route can be invalidated at once with Client.InvalidateTag.
+func RouteTagger(r *http.Request, header http.Header) []string {
+	route := Route(r)
+	if route == "" {
+		return nil
+	}
+
+	return []string{"route:" + route}
+}
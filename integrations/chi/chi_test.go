@@ -0,0 +1,121 @@
+package chi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	"github.com/cludden/http-cache/adapter/memory"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRoute(t *testing.T) {
+	var route string
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	router := chi.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route = Route(r)
+			next.ServeHTTP(w, r)
+		})
+	})
+	router.Get("/users/{id}", handler)
+	router.Route("/api", func(r chi.Router) {
+		r.Get("/items/{id:[0-9]+}", handler)
+		r.Get("/files/*", handler)
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		want   string
+	}{
+		{"resolves routes", http.MethodGet, "http://foo.bar/users/1", "/users/{id}"},
+		{"resolves subrouter routes", http.MethodGet, "http://foo.bar/api/items/2", "/api/items/{id:[0-9]+}"},
+		{"resolves wildcards", http.MethodGet, "http://foo.bar/api/files/a/b", "/api/files/*"},
+		{"ignores unmatched paths", http.MethodGet, "http://foo.bar/api/items/me", ""},
+		{"ignores unmatched methods", http.MethodPost, "http://foo.bar/users/1", ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			route = "unset"
+			r, _ := http.NewRequest(tt.method, tt.url, nil)
+			router.ServeHTTP(httptest.NewRecorder(), r)
+			if route != tt.want {
+				t.Errorf("Route() = %q, want %q", route, tt.want)
+			}
+		})
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/users/1", nil)
+	if got := Route(r); got != "" {
+		t.Errorf("Route() = %q for a request not routed by chi, want none", got)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	adapter, _ := memory.NewAdapter(memory.AdapterWithAlgorithm(memory.LRU), memory.AdapterWithCapacity(100))
+	client, err := cache.NewClient(
+		cache.WithAdapter(adapter),
+		cache.WithTTL(1*time.Minute),
+		cache.WithStatusHeaders(true),
+		cache.WithRoute(Route),
+		cache.WithRules([]cache.Rule{{Route: "/users/{id}/orders", Disabled: true}, {}}),
+		cache.WithTagger(RouteTagger),
+	)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	calls := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(r.URL.Path))
+	}
+	router := chi.NewRouter()
+	router.Use(client.Middleware)
+	router.Get("/users/{id}", handler)
+	router.Get("/users/{id}/orders", handler)
+
+	do := func(url string) string {
+		r, _ := http.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Header().Get("X-Cache")
+	}
+
+	tests := []struct {
+		name       string
+		url        string
+		invalidate string
+		wantCache  string
+		wantCalls  int
+	}{
+		{"miss", "http://foo.bar/users/1", "", "MISS", 1},
+		{"hit", "http://foo.bar/users/1", "", "HIT", 1},
+		{"caches per url", "http://foo.bar/users/2", "", "MISS", 2},
+		{"bypasses disabled routes", "http://foo.bar/users/1/orders", "", "BYPASS", 3},
+		{"invalidates routes", "http://foo.bar/users/2", "route:/users/{id}", "MISS", 4},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.invalidate != "" {
+				if err := client.InvalidateTag(context.Background(), tt.invalidate); err != nil {
+					t.Fatalf("Client.InvalidateTag() error = %v", err)
+				}
+			}
+			if got := do(tt.url); got != tt.wantCache {
+				t.Errorf("X-Cache = %q, want %q", got, tt.wantCache)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
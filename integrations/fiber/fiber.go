@@ -0,0 +1,186 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package fiber integrates the cache client with fasthttp and the Fiber web
+// framework, whose handlers work with a fasthttp.RequestCtx rather than the
+// net/http types of the client middleware.
+//
+// Requests are converted to net/http requests handled by the client
+// middleware, which invokes the next handlers with the original RequestCtx and
+// records their response. Background revalidations, which outlive the
+// RequestCtx, invoke them with a new RequestCtx built from the request.
+//
+//	app := fiber.New()
+//	app.Use(cachefiber.Middleware(client))
+package fiber
+
+import (
+	"net"
+	"net/http"
+
+	cache "github.com/cludden/http-cache"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+// revalidationKey is the RequestCtx user value marking background
+// revalidations dispatched through a Fiber app, which Middleware lets through.
+type revalidationKey struct{}
+
+// Handler returns a fasthttp handler caching the responses of next with the
+// client.
+func Handler(client *cache.Client, next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		serve(client, ctx, func() error {
+			next(ctx)
+			return nil
+		}, next)
+	}
+}
+
+// Middleware returns a Fiber handler caching the responses of the next
+// handlers with the client. Errors returned by the next handlers go through
+// the error handler of the app before the response is cached.
+func Middleware(client *cache.Client) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Context().UserValue(revalidationKey{}) != nil {
+			return c.Next()
+		}
+
+		app := c.App()
+		handler := app.Handler()
+		return serve(client, c.Context(), func() error {
+			if err := c.Next(); err != nil {
+				return app.Config().ErrorHandler(c, err)
+			}
+			return nil
+		}, func(ctx *fasthttp.RequestCtx) {
+			ctx.SetUserValue(revalidationKey{}, true)
+			handler(ctx)
+		})
+	}
+}
+
+// serve handles a RequestCtx with the client middleware, which invokes next
+// to handle the request, or revalidate to handle it in the background.
+func serve(client *cache.Client, ctx *fasthttp.RequestCtx, next func() error, revalidate fasthttp.RequestHandler) error {
+	var r http.Request
+	if err := fasthttpadaptor.ConvertRequest(ctx, &r, true); err != nil {
+		return err
+	}
+
+	remoteAddr := ctx.RemoteAddr()
+	var err error
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cache.IsRevalidation(r.Context()) {
+			background(w, r, remoteAddr, revalidate)
+			return
+		}
+
+		err = next()
+		copyResponse(w, &ctx.Response)
+	}))
+
+	w := &responseWriter{ctx: ctx, header: http.Header{}}
+	handler.ServeHTTP(w, &r)
+	w.WriteHeader(http.StatusOK)
+
+	return err
+}
+
+// background handles a request with a new RequestCtx, and copies its response
+// to w.
+func background(w http.ResponseWriter, r *http.Request, remoteAddr net.Addr, handler fasthttp.RequestHandler) {
+	var req fasthttp.Request
+	req.Header.SetMethod(r.Method)
+	req.SetRequestURI(r.URL.RequestURI())
+	req.SetHost(r.Host)
+	for name, values := range r.Header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	var ctx fasthttp.RequestCtx
+	ctx.Init(&req, remoteAddr, nil)
+	handler(&ctx)
+	copyResponse(w, &ctx.Response)
+}
+
+// copyResponse writes a fasthttp response to w, and resets it as w may write
+// to the same RequestCtx.
+func copyResponse(w http.ResponseWriter, resp *fasthttp.Response) {
+	status := resp.StatusCode()
+	header := w.Header()
+	resp.Header.VisitAll(func(name, value []byte) {
+		switch key := string(name); key {
+		case fasthttp.HeaderContentLength, fasthttp.HeaderConnection:
+		default:
+			header.Add(key, string(value))
+		}
+	})
+	body := append([]byte(nil), resp.Body()...)
+	resp.Reset()
+
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// responseWriter is the http.ResponseWriter writing to a RequestCtx.
+type responseWriter struct {
+	ctx     *fasthttp.RequestCtx
+	header  http.Header
+	written bool
+}
+
+// Header returns the header of the response, copied to the RequestCtx when
+// written.
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader writes the status code and the header of the response.
+func (w *responseWriter) WriteHeader(code int) {
+	if w.written {
+		return
+	}
+	w.written = true
+
+	w.ctx.SetStatusCode(code)
+	for name, values := range w.header {
+		if name == fasthttp.HeaderContentLength {
+			continue
+		}
+		for _, value := range values {
+			w.ctx.Response.Header.Add(name, value)
+		}
+	}
+}
+
+// Write writes the header of the response if needed, and data to its body.
+func (w *responseWriter) Write(data []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.ctx.Write(data)
+}
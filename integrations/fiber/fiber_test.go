@@ -0,0 +1,154 @@
+package fiber
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	"github.com/cludden/http-cache/adapter/memory"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+func newClient(t *testing.T, opts ...cache.ClientOption) *cache.Client {
+	adapter, _ := memory.NewAdapter(memory.AdapterWithAlgorithm(memory.LRU), memory.AdapterWithCapacity(100))
+	client, err := cache.NewClient(append([]cache.ClientOption{
+		cache.WithAdapter(adapter),
+		cache.WithTTL(1 * time.Minute),
+		cache.WithStatusHeaders(true),
+	}, opts...)...)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return client
+}
+
+func TestHandler(t *testing.T) {
+	var calls int32
+	handler := Handler(newClient(t), func(ctx *fasthttp.RequestCtx) {
+		atomic.AddInt32(&calls, 1)
+		ctx.Response.Header.Set("X-Path", string(ctx.Path()))
+		ctx.Response.Header.SetCookie(&fasthttp.Cookie{})
+		ctx.SetContentType("text/plain")
+		ctx.SetStatusCode(http.StatusCreated)
+		ctx.WriteString("value " + string(ctx.Path()))
+	})
+
+	tests := []struct {
+		name      string
+		url       string
+		wantBody  string
+		wantCache string
+		wantCalls int32
+	}{
+		{"miss", "http://foo.bar/test-1", "value /test-1", "MISS", 1},
+		{"hit", "http://foo.bar/test-1", "value /test-1", "HIT", 1},
+		{"other url", "http://foo.bar/test-2", "value /test-2", "MISS", 2},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var req fasthttp.Request
+			req.SetRequestURI(tt.url)
+			var ctx fasthttp.RequestCtx
+			ctx.Init(&req, nil, nil)
+			handler(&ctx)
+
+			if got := ctx.Response.StatusCode(); got != http.StatusCreated {
+				t.Errorf("status = %d, want %d", got, http.StatusCreated)
+			}
+			if got := string(ctx.Response.Body()); got != tt.wantBody {
+				t.Errorf("body = %q, want %q", got, tt.wantBody)
+			}
+			if got := string(ctx.Response.Header.Peek("X-Cache")); got != tt.wantCache {
+				t.Errorf("X-Cache = %q, want %q", got, tt.wantCache)
+			}
+			if got := string(ctx.Response.Header.ContentType()); got != "text/plain" {
+				t.Errorf("Content-Type = %q, want %q", got, "text/plain")
+			}
+			if got := atomic.LoadInt32(&calls); got != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var calls, version int32
+	app := fiber.New()
+	app.Use(Middleware(newClient(t,
+		cache.WithTTL(100*time.Millisecond),
+		cache.WithStaleWhileRevalidate(1*time.Minute),
+		cache.WithNegativeTTL(1*time.Minute),
+	)))
+	app.Get("/items/:id", func(c *fiber.Ctx) error {
+		atomic.AddInt32(&calls, 1)
+		return c.SendString("item " + c.Params("id") + " v" + string(rune('0'+atomic.LoadInt32(&version))))
+	})
+	app.Get("/error", func(c *fiber.Ctx) error {
+		atomic.AddInt32(&calls, 1)
+		return fiber.NewError(http.StatusNotFound, "missing")
+	})
+	app.Get("/failure", func(c *fiber.Ctx) error {
+		atomic.AddInt32(&calls, 1)
+		return errors.New("failure")
+	})
+
+	do := func(url string) (int, string, string) {
+		t.Helper()
+		r, _ := http.NewRequest(http.MethodGet, url, nil)
+		resp, err := app.Test(r, -1)
+		if err != nil {
+			t.Fatalf("App.Test() error = %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body), resp.Header.Get("X-Cache")
+	}
+
+	tests := []struct {
+		name       string
+		url        string
+		sleep      time.Duration
+		wantStatus int
+		wantBody   string
+		wantCache  string
+		wantCalls  int32
+	}{
+		{"miss", "http://foo.bar/items/1", 0, http.StatusOK, "item 1 v0", "MISS", 1},
+		{"hit", "http://foo.bar/items/1", 0, http.StatusOK, "item 1 v0", "HIT", 1},
+		{"stale", "http://foo.bar/items/1", 150 * time.Millisecond, http.StatusOK, "item 1 v0", "STALE", -1},
+		{"revalidated", "http://foo.bar/items/1", 20 * time.Millisecond, http.StatusOK, "item 1 v1", "HIT", 2},
+		{"handled error", "http://foo.bar/error", 0, http.StatusNotFound, "missing", "MISS", 3},
+		{"cached error", "http://foo.bar/error", 0, http.StatusNotFound, "missing", "HIT", 3},
+		{"failure", "http://foo.bar/failure", 0, http.StatusInternalServerError, "failure", "MISS", 4},
+		{"uncached failure", "http://foo.bar/failure", 0, http.StatusInternalServerError, "failure", "MISS", 5},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			time.Sleep(tt.sleep)
+			if tt.name == "stale" {
+				atomic.StoreInt32(&version, 1)
+			}
+			status, body, cacheStatus := do(tt.url)
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if body != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+			if cacheStatus != tt.wantCache {
+				t.Errorf("X-Cache = %q, want %q", cacheStatus, tt.wantCache)
+			}
+			// the revalidation of stale responses runs in the background
+			if got := atomic.LoadInt32(&calls); tt.wantCalls >= 0 && got != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package gin integrates the cache client with the gin web framework.
+//
+// The client middleware hands handlers its own http.ResponseWriter to record
+// responses, while gin handlers write through the gin.ResponseWriter of their
+// context. Middleware swaps the context writer for one writing to the client
+// middleware, and exposes the gin route templates of requests, to tag metrics
+// with low cardinality labels:
+//
+//	client, _ := cache.NewClient(
+//		cache.WithAdapter(adapter),
+//		cache.WithTTL(10*time.Minute),
+//		cache.WithMetrics(sink),
+//		cache.WithMetricTags(cachegin.RouteTags),
+//	)
+//	router := gin.New()
+//	router.GET("/items/:id", cachegin.Middleware(client), handler)
+package gin
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+
+	cache "github.com/cludden/http-cache"
+	"github.com/gin-gonic/gin"
+)
+
+// noWritten is the size of responses whose header is not written yet.
+const noWritten = -1
+
+// routeKey is the context key of the route template of a request.
+type routeKey struct{}
+
+// Middleware returns a gin handler caching the responses of the next handlers
+// with the client. Responses served from the cache abort the handlers chain.
+func Middleware(client *cache.Client) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		original, request := ctx.Writer, ctx.Request
+		defer func() {
+			ctx.Writer, ctx.Request = original, request
+		}()
+
+		handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writer := &responseWriter{ResponseWriter: w, gin: original, status: http.StatusOK, size: noWritten}
+			ctx.Writer, ctx.Request = writer, r
+			ctx.Next()
+			writer.WriteHeaderNow()
+		}))
+
+		r := request.WithContext(context.WithValue(request.Context(), routeKey{}, ctx.FullPath()))
+		handler.ServeHTTP(original, r)
+		ctx.Abort()
+	}
+}
+
+// Route returns the gin route template of a request handled by Middleware,
+// such as "/items/:id", or an empty string for requests matching no route.
+func Route(r *http.Request) string {
+	route, _ := r.Context().Value(routeKey{}).(string)
+	return route
+}
+
+// RouteTags returns the "route:" metric tag of a request handled by
+// Middleware, to use with cache.WithMetricTags.
+func RouteTags(r *http.Request) []string {
+	return []string{"route:" + Route(r)}
+}
+
+// responseWriter is the gin.ResponseWriter writing to the client middleware.
+// As gin's own writer, it holds the status code until the header or the body
+// is written, so that handlers can still change it.
+type responseWriter struct {
+	http.ResponseWriter
+	gin    gin.ResponseWriter
+	status int
+	size   int
+}
+
+// WriteHeader sets the status code of the response, written with the header.
+func (w *responseWriter) WriteHeader(code int) {
+	if code > 0 && !w.Written() {
+		w.status = code
+	}
+}
+
+// WriteHeaderNow writes the header of the response if not already written.
+func (w *responseWriter) WriteHeaderNow() {
+	if !w.Written() {
+		w.size = 0
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+// Write writes the header of the response if needed, and data to its body.
+func (w *responseWriter) Write(data []byte) (int, error) {
+	w.WriteHeaderNow()
+	n, err := w.ResponseWriter.Write(data)
+	w.size += n
+	return n, err
+}
+
+// WriteString writes a string to the body of the response.
+func (w *responseWriter) WriteString(s string) (int, error) {
+	w.WriteHeaderNow()
+	n, err := io.WriteString(w.ResponseWriter, s)
+	w.size += n
+	return n, err
+}
+
+// Status returns the status code of the response.
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+// Size returns the number of bytes written to the body of the response, or -1
+// when its header is not written yet.
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+// Written returns whether the header of the response is written.
+func (w *responseWriter) Written() bool {
+	return w.size != noWritten
+}
+
+// Hijack implements the http.Hijacker interface, handing the client
+// connection over when the client middleware writer supports it.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("cache gin response writer does not support hijacking")
+	}
+	if w.size < 0 {
+		w.size = 0
+	}
+	return h.Hijack()
+}
+
+// Flush implements the http.Flusher interface.
+func (w *responseWriter) Flush() {
+	w.WriteHeaderNow()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// CloseNotify implements the http.CloseNotifier interface of the gin writer.
+func (w *responseWriter) CloseNotify() <-chan bool {
+	return w.gin.CloseNotify()
+}
+
+// Pusher returns the http.Pusher of the client middleware writer, if any.
+func (w *responseWriter) Pusher() http.Pusher {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package gin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	"github.com/cludden/http-cache/adapter/memory"
+	"github.com/gin-gonic/gin"
+)
+
+type metricsSinkMock struct {
+	sync.Mutex
+	tags map[string][]string
+}
+
+func (s *metricsSinkMock) record(name string, tags []string) {
+	s.Lock()
+	defer s.Unlock()
+	s.tags[name] = tags
+}
+
+func (s *metricsSinkMock) Incr(name string, tags []string) {
+	s.record(name, tags)
+}
+
+func (s *metricsSinkMock) Timing(name string, d time.Duration, tags []string) {
+	s.record(name, tags)
+}
+
+func (s *metricsSinkMock) Gauge(name string, value float64, tags []string) {
+	s.record(name, tags)
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	adapter, _ := memory.NewAdapter(memory.AdapterWithAlgorithm(memory.LRU), memory.AdapterWithCapacity(100))
+	sink := &metricsSinkMock{tags: map[string][]string{}}
+	client, err := cache.NewClient(
+		cache.WithAdapter(adapter),
+		cache.WithTTL(1*time.Minute),
+		cache.WithStatusHeaders(true),
+		cache.WithMetrics(sink),
+		cache.WithMetricTags(RouteTags),
+	)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	calls, after := 0, 0
+	router := gin.New()
+	router.Use(Middleware(client), func(ctx *gin.Context) {
+		ctx.Next()
+		after++
+	})
+	router.GET("/items/:id", func(ctx *gin.Context) {
+		calls++
+		ctx.Header("X-Item", ctx.Param("id"))
+		ctx.Status(http.StatusCreated)
+		ctx.String(http.StatusOK, "item %s", ctx.Param("id"))
+	})
+	router.GET("/empty", func(ctx *gin.Context) {
+		calls++
+		ctx.Status(http.StatusAccepted)
+	})
+
+	tests := []struct {
+		name       string
+		url        string
+		wantStatus int
+		wantBody   string
+		wantCache  string
+		wantCalls  int
+	}{
+		{"miss", "http://foo.bar/items/1", http.StatusOK, "item 1", "MISS", 1},
+		{"hit", "http://foo.bar/items/1", http.StatusOK, "item 1", "HIT", 1},
+		{"other item", "http://foo.bar/items/2", http.StatusOK, "item 2", "MISS", 2},
+		{"empty body", "http://foo.bar/empty", http.StatusAccepted, "", "MISS", 3},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Body.String(); got != tt.wantBody {
+				t.Errorf("body = %q, want %q", got, tt.wantBody)
+			}
+			if got := w.Header().Get("X-Cache"); got != tt.wantCache {
+				t.Errorf("X-Cache = %q, want %q", got, tt.wantCache)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+
+	// the hit aborts the handlers chain
+	if want := len(tests) - 1; after != want {
+		t.Errorf("middleware calls = %d, want %d", after, want)
+	}
+	r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/items/1", nil)
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	if got, want := sink.tags["hits"], []string{"route:/items/:id"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("hits tags = %q, want %q", got, want)
+	}
+	if got, want := sink.tags["request.latency"], []string{"route:/items/:id"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("request.latency tags = %q, want %q", got, want)
+	}
+}
+
+func TestRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	adapter, _ := memory.NewAdapter(memory.AdapterWithAlgorithm(memory.LRU), memory.AdapterWithCapacity(100))
+	client, _ := cache.NewClient(cache.WithAdapter(adapter), cache.WithTTL(1*time.Minute))
+
+	var routes []string
+	router := gin.New()
+	router.Use(Middleware(client))
+	handler := func(ctx *gin.Context) {
+		routes = append(routes, Route(ctx.Request))
+	}
+	router.GET("/items/:id", handler)
+	router.GET("/files/*path", handler)
+	router.NoRoute(handler)
+
+	for _, url := range []string{"http://foo.bar/items/1", "http://foo.bar/files/a/b", "http://foo.bar/missing"} {
+		r, _ := http.NewRequest(http.MethodGet, url, nil)
+		router.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	want := []string{"", "/files/*path", "/items/:id"}
+	sort.Strings(routes)
+	if !reflect.DeepEqual(routes, want) {
+		t.Errorf("routes = %q, want %q", routes, want)
+	}
+}
@@ -0,0 +1,80 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package mux resolves the gorilla/mux route templates of requests, such as
+// "/users/{id}", for the cache client to apply rules by route, tag cached
+// responses and metrics by route, while still caching per concrete URL:
+//
+//	client, _ := cache.NewClient(
+//		cache.WithAdapter(adapter),
+//		cache.WithTTL(10*time.Minute),
+//		cache.WithRoute(cachemux.Route),
+//		cache.WithRules([]cache.Rule{{Route: "/users/{id}", TTL: time.Minute}, {}}),
+//		cache.WithTagger(cachemux.RouteTagger),
+//		cache.WithMetricTags(cachemux.RouteTags),
+//	)
+//	router := mux.NewRouter()
+//	router.Use(client.Middleware)
+package mux
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Route returns the gorilla/mux route template of a request, or an empty
+// string for requests matching no route. The route is only known to handlers
+// and to middlewares set with Router.Use, which run once the request matched.
+func Route(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return ""
+	}
+
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+
+	return template
+}
+
+// RouteTags returns the "route:" metric tag of a request, to use with
+// cache.WithMetricTags.
+func RouteTags(r *http.Request) []string {
+	return []string{"route:" + Route(r)}
+}
+
+// RouteTagger tags the cached responses of a request with "route:" followed
+// by its route template, to use with cache.WithTagger, so that the responses
+// of a route can be invalidated at once with Client.InvalidateTag.
+func RouteTagger(r *http.Request, header http.Header) []string {
+	route := Route(r)
+	if route == "" {
+		return nil
+	}
+
+	return []string{"route:" + route}
+}
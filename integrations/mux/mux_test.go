@@ -0,0 +1,138 @@
+package mux
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	"github.com/cludden/http-cache/adapter/memory"
+	"github.com/gorilla/mux"
+)
+
+type metricsSinkMock struct {
+	sync.Mutex
+	tags map[string][]string
+}
+
+func (s *metricsSinkMock) record(name string, tags []string) {
+	s.Lock()
+	defer s.Unlock()
+	s.tags[name] = tags
+}
+
+func (s *metricsSinkMock) Incr(name string, tags []string) {
+	s.record(name, tags)
+}
+
+func (s *metricsSinkMock) Timing(name string, d time.Duration, tags []string) {
+	s.record(name, tags)
+}
+
+func (s *metricsSinkMock) Gauge(name string, value float64, tags []string) {
+	s.record(name, tags)
+}
+
+func TestRoute(t *testing.T) {
+	var route string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		route = Route(r)
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/users/{id}", handler).Methods(http.MethodGet)
+	router.PathPrefix("/api").Subrouter().HandleFunc("/items/{id:[0-9]+}", handler)
+
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"resolves routes", "http://foo.bar/users/1", "/users/{id}"},
+		{"resolves subrouter routes", "http://foo.bar/api/items/2", "/api/items/{id:[0-9]+}"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			route = "unset"
+			r, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+			router.ServeHTTP(httptest.NewRecorder(), r)
+			if route != tt.want {
+				t.Errorf("Route() = %q, want %q", route, tt.want)
+			}
+		})
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/users/1", nil)
+	if got := Route(r); got != "" {
+		t.Errorf("Route() = %q for a request not routed by gorilla/mux, want none", got)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	adapter, _ := memory.NewAdapter(memory.AdapterWithAlgorithm(memory.LRU), memory.AdapterWithCapacity(100))
+	sink := &metricsSinkMock{tags: map[string][]string{}}
+	client, err := cache.NewClient(
+		cache.WithAdapter(adapter),
+		cache.WithTTL(1*time.Minute),
+		cache.WithStatusHeaders(true),
+		cache.WithRoute(Route),
+		cache.WithRules([]cache.Rule{{Route: "/users/{id}/orders", Disabled: true}, {}}),
+		cache.WithTagger(RouteTagger),
+		cache.WithMetrics(sink),
+		cache.WithMetricTags(RouteTags),
+	)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	calls := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(r.URL.Path))
+	}
+	router := mux.NewRouter()
+	router.Use(client.Middleware)
+	router.HandleFunc("/users/{id}", handler)
+	router.HandleFunc("/users/{id}/orders", handler)
+
+	tests := []struct {
+		name       string
+		url        string
+		invalidate string
+		wantCache  string
+		wantCalls  int
+	}{
+		{"miss", "http://foo.bar/users/1", "", "MISS", 1},
+		{"hit", "http://foo.bar/users/1", "", "HIT", 1},
+		{"caches per url", "http://foo.bar/users/2", "", "MISS", 2},
+		{"bypasses disabled routes", "http://foo.bar/users/1/orders", "", "BYPASS", 3},
+		{"invalidates routes", "http://foo.bar/users/2", "route:/users/{id}", "MISS", 4},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.invalidate != "" {
+				if err := client.InvalidateTag(context.Background(), tt.invalidate); err != nil {
+					t.Fatalf("Client.InvalidateTag() error = %v", err)
+				}
+			}
+			r, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			if got := w.Header().Get("X-Cache"); got != tt.wantCache {
+				t.Errorf("X-Cache = %q, want %q", got, tt.wantCache)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+
+	if got, want := sink.tags["misses"], []string{"route:/users/{id}"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("misses tags = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,232 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// InvalidatingAdapter is implemented by adapters able to release keys by
+// prefix or glob pattern, enabling Client.InvalidatePrefix and
+// Client.InvalidatePattern.
+type InvalidatingAdapter interface {
+	// InvalidatePrefix releases every key starting with a prefix.
+	InvalidatePrefix(ctx context.Context, prefix string) error
+
+	// InvalidatePattern releases every key matching a glob pattern, where
+	// "*" matches any sequence of characters, including slashes, "?" any
+	// single character, "[...]" a character class and "\" escapes the
+	// next character, as with Redis patterns.
+	InvalidatePattern(ctx context.Context, pattern string) error
+}
+
+// KeyScanner is implemented by adapters able to list the keys they store,
+// for tools inspecting the cache.
+type KeyScanner interface {
+	// ScanKeys calls fn with every key starting with a prefix, in no
+	// particular order, stopping at the first error fn returns.
+	ScanKeys(ctx context.Context, prefix string, fn func(key string) error) error
+}
+
+// ErrInvalidationUnsupported is returned when invalidating keys by prefix or
+// pattern with an adapter that doesn't implement InvalidatingAdapter, or
+// with hashed keys.
+var ErrInvalidationUnsupported = errors.New("cache adapter does not support invalidation by prefix or pattern")
+
+// InvalidatePrefix releases every cached response whose key starts with a
+// prefix, such as "/api/products/" with the default key function, on every
+// instance when an invalidation bus is set.
+func (c *Client) InvalidatePrefix(ctx context.Context, prefix string) error {
+	if err := c.invalidatePrefix(ctx, prefix); err != nil {
+		return err
+	}
+
+	c.publish(ctx, InvalidationPrefix, prefix)
+
+	return nil
+}
+
+func (c *Client) invalidatePrefix(ctx context.Context, prefix string) error {
+	if c.invalidator == nil || c.keyHashFn != nil {
+		return ErrInvalidationUnsupported
+	}
+	if c.version != "" {
+		prefix = c.version + ":" + prefix
+	}
+
+	return c.call(ctx, "invalidate_prefix", func(ctx context.Context) error {
+		return c.invalidator.InvalidatePrefix(ctx, prefix)
+	})
+}
+
+// InvalidatePattern releases every cached response whose key matches a glob
+// pattern, with the syntax described by InvalidatingAdapter, on every
+// instance when an invalidation bus is set.
+func (c *Client) InvalidatePattern(ctx context.Context, pattern string) error {
+	if err := c.invalidatePattern(ctx, pattern); err != nil {
+		return err
+	}
+
+	c.publish(ctx, InvalidationPattern, pattern)
+
+	return nil
+}
+
+func (c *Client) invalidatePattern(ctx context.Context, pattern string) error {
+	if c.invalidator == nil || c.keyHashFn != nil {
+		return ErrInvalidationUnsupported
+	}
+	if c.version != "" {
+		pattern = EscapePattern(c.version+":") + pattern
+	}
+
+	return c.call(ctx, "invalidate_pattern", func(ctx context.Context) error {
+		return c.invalidator.InvalidatePattern(ctx, pattern)
+	})
+}
+
+// EscapePattern escapes the glob special characters of s, so that it only
+// matches itself in an InvalidatePattern pattern.
+func EscapePattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// PatternPrefix returns the literal characters a glob pattern starts with,
+// which every string matching the pattern starts with, so that adapters
+// can narrow down the keys to match with MatchPattern.
+func PatternPrefix(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*', '?', '[':
+			return b.String()
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+		}
+		b.WriteByte(pattern[i])
+	}
+
+	return b.String()
+}
+
+// MatchPattern reports whether s matches a glob pattern, with the syntax
+// described by InvalidatingAdapter, for adapters implementing pattern
+// invalidation natively.
+func MatchPattern(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if MatchPattern(pattern, s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			end := strings.IndexByte(pattern[1:], ']')
+			if end < 0 {
+				if s[0] != '[' {
+					return false
+				}
+				break
+			}
+			class := pattern[1 : end+1]
+			if !matchClass(class, s[0]) {
+				return false
+			}
+			pattern = pattern[end+2:]
+			s = s[1:]
+			continue
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern = pattern[1:]
+		s = s[1:]
+	}
+
+	return len(s) == 0
+}
+
+// matchClass reports whether c belongs to a character class, such as "abc",
+// "a-z" or "^0-9".
+func matchClass(class string, c byte) bool {
+	negate := strings.HasPrefix(class, "^")
+	if negate {
+		class = class[1:]
+	}
+
+	match := false
+	for i := 0; i < len(class); i++ {
+		switch {
+		case class[i] == '\\' && i+1 < len(class):
+			i++
+			match = match || class[i] == c
+		case i+2 < len(class) && class[i+1] == '-':
+			lo, hi := class[i], class[i+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			match = match || (lo <= c && c <= hi)
+			i += 2
+		default:
+			match = match || class[i] == c
+		}
+	}
+
+	return match != negate
+}
@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{"/api/*", "/api/products/1", true},
+		{"/api/*/1", "/api/products/1", true},
+		{"/api/*/1", "/api/products/2", false},
+		{"/api/products/?", "/api/products/1", true},
+		{"/api/products/?", "/api/products/12", false},
+		{"/api/products/[0-9]", "/api/products/7", true},
+		{"/api/products/[^0-9]", "/api/products/7", false},
+		{"/api/products/[abc]", "/api/products/b", true},
+		{`/api/\*`, "/api/*", true},
+		{`/api/\*`, "/api/x", false},
+		{"/api/[", "/api/[", true},
+		{"*", "", true},
+		{"", "/api", false},
+	}
+	for _, tt := range tests {
+		if got := MatchPattern(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("MatchPattern(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestPatternPrefix(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"/api/*", "/api/"},
+		{"/api/?/1", "/api/"},
+		{"/api/[0-9]", "/api/"},
+		{`/api/\*/1*`, "/api/*/1"},
+		{"/api/1", "/api/1"},
+	}
+	for _, tt := range tests {
+		if got := PatternPrefix(tt.pattern); got != tt.want {
+			t.Errorf("PatternPrefix(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestEscapePattern(t *testing.T) {
+	s := `/api/*?[x]\`
+	if got := EscapePattern(s); got != `/api/\*\?\[x\]\\` {
+		t.Errorf("EscapePattern() = %v, want %v", got, `/api/\*\?\[x\]\\`)
+	}
+	if !MatchPattern(EscapePattern(s), s) || MatchPattern(EscapePattern(s), "/api/a?[x]\\") {
+		t.Errorf("EscapePattern() = %v, does not match only %v", EscapePattern(s), s)
+	}
+}
+
+type invalidatingAdapterMock struct {
+	adapterMock
+	prefixes []string
+	patterns []string
+}
+
+func (a *invalidatingAdapterMock) InvalidatePrefix(ctx context.Context, prefix string) error {
+	a.prefixes = append(a.prefixes, prefix)
+	return nil
+}
+
+func (a *invalidatingAdapterMock) InvalidatePattern(ctx context.Context, pattern string) error {
+	a.patterns = append(a.patterns, pattern)
+	return nil
+}
+
+func TestClientInvalidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		adapter     Adapter
+		opts        []ClientOption
+		wantPrefix  string
+		wantPattern string
+		wantErr     bool
+	}{
+		{"invalidates", &invalidatingAdapterMock{}, nil, "/api/", "/api/*", false},
+		{"adds version", &invalidatingAdapterMock{}, []ClientOption{WithCacheVersion("v1.2")}, "v1.2:/api/", `v1.2:/api/*`, false},
+		{"rejects hashed keys", &invalidatingAdapterMock{}, []ClientOption{WithKeyHashing(SHA256Key, false)}, "", "", true},
+		{"rejects unsupported adapter", &adapterMock{}, nil, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := NewClient(append([]ClientOption{WithAdapter(tt.adapter), WithTTL(1 * time.Minute)}, tt.opts...)...)
+			errPrefix := client.InvalidatePrefix(context.Background(), "/api/")
+			errPattern := client.InvalidatePattern(context.Background(), "/api/*")
+			if (errPrefix != nil) != tt.wantErr || (errPattern != nil) != tt.wantErr {
+				t.Fatalf("*Client.Invalidate errors = %v, %v, wantErr %v", errPrefix, errPattern, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			a := tt.adapter.(*invalidatingAdapterMock)
+			if len(a.prefixes) != 1 || a.prefixes[0] != tt.wantPrefix {
+				t.Errorf("adapter prefixes = %v, want %v", a.prefixes, tt.wantPrefix)
+			}
+			if len(a.patterns) != 1 || a.patterns[0] != tt.wantPattern {
+				t.Errorf("adapter patterns = %v, want %v", a.patterns, tt.wantPattern)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// LockingAdapter is implemented by adapters able to lock a key across every
+// instance sharing the cache, so that WithFillLock makes a single instance
+// fill a missing response.
+type LockingAdapter interface {
+	// Lock tries to acquire the lock on a key for up to a ttl, reporting
+	// false when it is held elsewhere. The returned function releases the
+	// lock, unless it expired and was acquired by another holder meanwhile.
+	Lock(ctx context.Context, key string, ttl time.Duration) (unlock func(context.Context) error, ok bool, err error)
+}
+
+// fillLockInterval is how often requests waiting on a fill lock look the
+// response up.
+const fillLockInterval = 20 * time.Millisecond
+
+// WithFillLock makes a single instance, among all those sharing the cache,
+// invoke the handler on a cache miss, by locking the key for up to ttl with
+// an adapter implementing LockingAdapter. Requests failing to get the lock
+// are served the stale response when stale-if-error allows it, and otherwise
+// wait up to wait for the response to be cached, invoking the handler
+// themselves past that.
+func WithFillLock(ttl, wait time.Duration) ClientOption {
+	return func(c *Client) error {
+		if int64(ttl) < 1 {
+			return fmt.Errorf("cache client fill lock ttl %v is invalid", ttl)
+		}
+		if wait < 0 {
+			return fmt.Errorf("cache client fill lock wait %v is invalid", wait)
+		}
+
+		c.fillLockTTL = ttl
+		c.fillLockWait = wait
+
+		return nil
+	}
+}
+
+// fill runs fetch once the fill lock of a key is acquired, or serves the
+// response cached by the instance holding it. It reports whether the
+// request was served, which isn't the case when locking fails or no fresh
+// response was cached in time.
+func (c *Client) fill(w http.ResponseWriter, r *http.Request, key string, fallback *Response, fetch func()) bool {
+	var unlock func(context.Context) error
+	var ok bool
+	err := c.call(r.Context(), "lock", func(ctx context.Context) (err error) {
+		unlock, ok, err = c.locker.Lock(ctx, c.adapterKey(key), c.fillLockTTL)
+		return err
+	})
+	if err != nil {
+		c.hooks.error(r, key, fmt.Errorf("adapter lock: %w", err))
+		return false
+	}
+
+	if ok {
+		defer func() {
+			err := c.call(r.Context(), "unlock", unlock)
+			if err != nil {
+				c.hooks.error(r, key, fmt.Errorf("adapter unlock: %w", err))
+			}
+		}()
+		fetch()
+		return true
+	}
+
+	if fallback != nil {
+		c.serve(w, r, key, *fallback, cacheStale)
+		return true
+	}
+
+	timer := time.NewTimer(c.fillLockWait)
+	defer timer.Stop()
+	ticker := time.NewTicker(fillLockInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return false
+		case <-timer.C:
+			return false
+		case <-ticker.C:
+		}
+
+		_, response, ok := c.lookup(key, r)
+		if ok && response.Expiration.After(time.Now()) {
+			c.serve(w, r, key, response, cacheHit)
+			return true
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type lockingAdapterMock struct {
+	adapterMock
+	locks map[string]bool
+}
+
+func (a *lockingAdapterMock) Lock(ctx context.Context, key string, ttl time.Duration) (func(context.Context) error, bool, error) {
+	a.adapterMock.Lock()
+	defer a.Unlock()
+	if a.locks[key] {
+		return nil, false, nil
+	}
+	a.locks[key] = true
+	return func(context.Context) error {
+		a.adapterMock.Lock()
+		defer a.Unlock()
+		delete(a.locks, key)
+		return nil
+	}, true, nil
+}
+
+func TestMiddlewareFillLock(t *testing.T) {
+	adapter := &lockingAdapterMock{
+		adapterMock: adapterMock{store: map[string][]byte{}},
+		locks:       map[string]bool{},
+	}
+
+	var mutex sync.Mutex
+	calls := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		calls++
+		mutex.Unlock()
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("value 1"))
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		client, _ := NewClient(
+			WithAdapter(adapter),
+			WithTTL(1*time.Minute),
+			WithFillLock(time.Second, time.Second),
+		)
+		handler := client.Middleware(next)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Body.String() != "value 1" {
+				t.Errorf("*Client.Middleware() = %v, want %v", w.Body.String(), "value 1")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("handler calls = %v, want 1", calls)
+	}
+	if len(adapter.locks) != 0 {
+		t.Errorf("locks = %v, want them released", adapter.locks)
+	}
+}
+
+func TestMiddlewareFillLockHeld(t *testing.T) {
+	adapter := &lockingAdapterMock{
+		adapterMock: adapterMock{
+			store: map[string][]byte{
+				"http://foo.bar/stale": Response{
+					Value:      []byte("stale value"),
+					Expiration: time.Now().Add(-10 * time.Second),
+				}.Bytes(),
+			},
+		},
+		locks: map[string]bool{
+			"http://foo.bar/stale":   true,
+			"http://foo.bar/missing": true,
+		},
+	}
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithStaleIfError(1*time.Minute),
+		WithFillLock(time.Second, 50*time.Millisecond),
+	)
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("value 1"))
+	}))
+
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"serves stale response", "http://foo.bar/stale", "stale value"},
+		{"invokes handler past the wait", "http://foo.bar/missing", "value 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Body.String() != tt.want {
+				t.Errorf("*Client.Middleware() = %v, want %v", w.Body.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWithFillLock(t *testing.T) {
+	tests := []struct {
+		name    string
+		adapter Adapter
+		ttl     time.Duration
+		wait    time.Duration
+		wantErr bool
+	}{
+		{"valid", &lockingAdapterMock{}, time.Second, time.Second, false},
+		{"no wait", &lockingAdapterMock{}, time.Second, 0, false},
+		{"invalid ttl", &lockingAdapterMock{}, 0, time.Second, true},
+		{"invalid wait", &lockingAdapterMock{}, time.Second, -1, true},
+		{"unsupported adapter", &adapterMock{}, time.Second, time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClient(
+				WithAdapter(tt.adapter),
+				WithTTL(1*time.Minute),
+				WithFillLock(tt.ttl, tt.wait),
+			)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
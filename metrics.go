@@ -0,0 +1,76 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cache
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// MetricsSink receives the metrics of the middleware, such as a StatsD
+// client. Tags are formatted as "name:value".
+type MetricsSink interface {
+	// Incr increments a counter.
+	Incr(name string, tags []string)
+
+	// Timing records the duration of an operation.
+	Timing(name string, d time.Duration, tags []string)
+
+	// Gauge sets the value of a gauge.
+	Gauge(name string, value float64, tags []string)
+}
+
+// WithMetrics emits the middleware metrics to a sink: the hits, misses,
+// stores and errors counters, the response.size gauge of the cached
+// responses, and the request.latency and adapter.latency timings, the latter
+// tagged with the adapter operation.
+func WithMetrics(sink MetricsSink) ClientOption {
+	return func(c *Client) error {
+		if sink == nil {
+			return errors.New("cache client metrics sink can not be nil")
+		}
+
+		c.hooks.metrics = sink
+
+		return nil
+	}
+}
+
+// WithMetricTags adds the tags returned by fn to the metrics of each
+// request: the hits, misses, stores and errors counters, the response.size
+// gauge and the request.latency timing. Tags must keep a low cardinality, such
+// as the route template of the request rather than its URL.
+func WithMetricTags(fn func(*http.Request) []string) ClientOption {
+	return func(c *Client) error {
+		if fn == nil {
+			return errors.New("cache client metric tags function can not be nil")
+		}
+
+		c.hooks.metricTags = fn
+
+		return nil
+	}
+}
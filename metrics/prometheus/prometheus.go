@@ -0,0 +1,237 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package prometheus provides a cache.MetricsSink exposing the client
+// metrics to Prometheus.
+package prometheus
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultNamespace is the namespace of the metric names by default.
+const DefaultNamespace = "http_cache"
+
+// Sink is the Prometheus metrics sink, a prometheus.Collector to register
+// with a registry. Counters are suffixed with _total and timings are
+// histograms in seconds. Tags such as "op:get" become labels, missing labels
+// of a series being empty.
+type Sink struct {
+	namespace   string
+	buckets     []float64
+	constLabels prometheus.Labels
+
+	mutex    sync.Mutex
+	families map[string]*family
+}
+
+// family is a metric and its series, by label values.
+type family struct {
+	kind   prometheus.ValueType
+	timing bool
+	series map[string]*series
+}
+
+// series is a metric with a set of labels.
+type series struct {
+	labels  map[string]string
+	value   float64
+	count   uint64
+	buckets map[float64]uint64
+}
+
+var (
+	_ cache.MetricsSink    = (*Sink)(nil)
+	_ prometheus.Collector = (*Sink)(nil)
+)
+
+// SinkOptions is used to set Sink settings.
+type SinkOptions func(s *Sink)
+
+// NewSink initializes a Prometheus metrics sink.
+func NewSink(opts ...SinkOptions) *Sink {
+	s := &Sink{
+		namespace: DefaultNamespace,
+		buckets:   prometheus.DefBuckets,
+		families:  map[string]*family{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// SinkWithNamespace sets the namespace of the metric names.
+func SinkWithNamespace(namespace string) SinkOptions {
+	return func(s *Sink) {
+		s.namespace = namespace
+	}
+}
+
+// SinkWithBuckets sets the buckets of the timing histograms, in seconds.
+func SinkWithBuckets(buckets ...float64) SinkOptions {
+	return func(s *Sink) {
+		s.buckets = buckets
+	}
+}
+
+// SinkWithConstLabels sets labels added to every metric, such as
+// {"service": "api"}.
+func SinkWithConstLabels(labels prometheus.Labels) SinkOptions {
+	return func(s *Sink) {
+		s.constLabels = labels
+	}
+}
+
+// Incr implements the cache.MetricsSink interface Incr method.
+func (s *Sink) Incr(name string, tags []string) {
+	s.observe(name+"_total", prometheus.CounterValue, false, tags, func(m *series) {
+		m.value++
+	})
+}
+
+// Timing implements the cache.MetricsSink interface Timing method, in
+// seconds.
+func (s *Sink) Timing(name string, d time.Duration, tags []string) {
+	seconds := d.Seconds()
+	s.observe(name+"_seconds", prometheus.UntypedValue, true, tags, func(m *series) {
+		m.value += seconds
+		m.count++
+		if m.buckets == nil {
+			m.buckets = map[float64]uint64{}
+		}
+		for _, bound := range s.buckets {
+			if seconds <= bound {
+				m.buckets[bound]++
+			}
+		}
+	})
+}
+
+// Gauge implements the cache.MetricsSink interface Gauge method.
+func (s *Sink) Gauge(name string, value float64, tags []string) {
+	s.observe(name, prometheus.GaugeValue, false, tags, func(m *series) {
+		m.value = value
+	})
+}
+
+// Describe implements the prometheus.Collector interface Describe method.
+// Metrics being known as they are observed, the sink is an unchecked
+// collector.
+func (s *Sink) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements the prometheus.Collector interface Collect method.
+func (s *Sink) Collect(ch chan<- prometheus.Metric) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for name, f := range s.families {
+		var labels []string
+		seen := map[string]bool{}
+		for _, m := range f.series {
+			for label := range m.labels {
+				if !seen[label] {
+					seen[label] = true
+					labels = append(labels, label)
+				}
+			}
+		}
+		sort.Strings(labels)
+
+		desc := prometheus.NewDesc(prometheus.BuildFQName(s.namespace, "", name), "http-cache "+strings.ReplaceAll(name, "_", " ")+".", labels, s.constLabels)
+		for _, m := range f.series {
+			values := make([]string, len(labels))
+			for i, label := range labels {
+				values[i] = m.labels[label]
+			}
+			if f.timing {
+				buckets := make(map[float64]uint64, len(m.buckets))
+				for bound, n := range m.buckets {
+					buckets[bound] = n
+				}
+				ch <- prometheus.MustNewConstHistogram(desc, m.count, m.value, buckets, values...)
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(desc, f.kind, m.value, values...)
+		}
+	}
+}
+
+// observe updates the series of a metric matching tags.
+func (s *Sink) observe(name string, kind prometheus.ValueType, timing bool, tags []string, fn func(*series)) {
+	name = sanitize(name)
+	labels := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		label, value := tag, ""
+		if i := strings.IndexByte(tag, ':'); i >= 0 {
+			label, value = tag[:i], tag[i+1:]
+		}
+		labels[sanitize(label)] = value
+	}
+	id := seriesID(labels)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	f, ok := s.families[name]
+	if !ok {
+		f = &family{kind: kind, timing: timing, series: map[string]*series{}}
+		s.families[name] = f
+	}
+	m, ok := f.series[id]
+	if !ok {
+		m = &series{labels: labels}
+		f.series[id] = m
+	}
+	fn(m)
+}
+
+// seriesID returns a key identifying a set of labels.
+func seriesID(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for label, value := range labels {
+		pairs = append(pairs, label+"\xff"+value)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, "\xfe")
+}
+
+// sanitize replaces the characters Prometheus doesn't allow in metric and
+// label names with underscores.
+func sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+}
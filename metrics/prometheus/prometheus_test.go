@@ -0,0 +1,80 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestSink(t *testing.T) {
+	sink := NewSink(SinkWithConstLabels(prometheus.Labels{"service": "api"}))
+	sink.Incr("hits", nil)
+	sink.Incr("hits", nil)
+	sink.Incr("errors", nil)
+	sink.Incr("errors", []string{"error:decode"})
+	sink.Gauge("response.size", 42, nil)
+	sink.Timing("adapter.latency", 3*time.Millisecond, []string{"op:get"})
+	sink.Timing("adapter.latency", 2*time.Second, []string{"op:get"})
+
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(sink); err != nil {
+		t.Fatalf("Registry.Register() error = %v", err)
+	}
+	gathered, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Registry.Gather() error = %v", err)
+	}
+	families := map[string]*dto.MetricFamily{}
+	for _, f := range gathered {
+		families[f.GetName()] = f
+	}
+
+	tests := []struct {
+		name       string
+		family     string
+		wantSeries int
+		wantValue  float64
+		wantLabels map[string]string
+	}{
+		{"counts", "http_cache_hits_total", 1, 2, map[string]string{"service": "api"}},
+		{"unions labels", "http_cache_errors_total", 2, 1, map[string]string{"service": "api", "error": ""}},
+		{"gauges", "http_cache_response_size", 1, 42, map[string]string{"service": "api"}},
+		{"times", "http_cache_adapter_latency_seconds", 1, 2, map[string]string{"service": "api", "op": "get"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, ok := families[tt.family]
+			if !ok {
+				t.Fatalf("family %v not gathered", tt.family)
+			}
+			if got := len(f.GetMetric()); got != tt.wantSeries {
+				t.Fatalf("series = %v, want %v", got, tt.wantSeries)
+			}
+
+			m := f.GetMetric()[0]
+			var value float64
+			switch {
+			case m.GetCounter() != nil:
+				value = m.GetCounter().GetValue()
+			case m.GetGauge() != nil:
+				value = m.GetGauge().GetValue()
+			case m.GetHistogram() != nil:
+				value = float64(m.GetHistogram().GetSampleCount())
+			}
+			if value != tt.wantValue {
+				t.Errorf("value = %v, want %v", value, tt.wantValue)
+			}
+			labels := map[string]string{}
+			for _, pair := range m.GetLabel() {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+			for name, want := range tt.wantLabels {
+				if got, ok := labels[name]; !ok || got != want {
+					t.Errorf("label %v = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
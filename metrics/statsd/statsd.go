@@ -0,0 +1,121 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package statsd
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+)
+
+// DefaultPrefix is the prefix of the metric names by default.
+const DefaultPrefix = "http_cache."
+
+// Sink is the StatsD metrics sink, sending each metric in a UDP packet, with
+// tags in the DogStatsD format understood by Datadog and Telegraf.
+type Sink struct {
+	conn   net.Conn
+	prefix string
+	tags   []string
+}
+
+var _ cache.MetricsSink = (*Sink)(nil)
+
+// SinkOptions is used to set Sink settings.
+type SinkOptions func(s *Sink)
+
+// NewSink initializes a StatsD metrics sink sending metrics to addr, such as
+// "127.0.0.1:8125".
+func NewSink(addr string, opts ...SinkOptions) (*Sink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Sink{
+		conn:   conn,
+		prefix: DefaultPrefix,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, nil
+}
+
+// SinkWithPrefix sets the prefix of the metric names.
+func SinkWithPrefix(prefix string) SinkOptions {
+	return func(s *Sink) {
+		s.prefix = prefix
+	}
+}
+
+// SinkWithTags sets tags added to every metric, such as "service:api".
+func SinkWithTags(tags ...string) SinkOptions {
+	return func(s *Sink) {
+		s.tags = tags
+	}
+}
+
+// Incr implements the cache.MetricsSink interface Incr method.
+func (s *Sink) Incr(name string, tags []string) {
+	s.send(name, "1", "c", tags)
+}
+
+// Timing implements the cache.MetricsSink interface Timing method, in
+// milliseconds.
+func (s *Sink) Timing(name string, d time.Duration, tags []string) {
+	s.send(name, strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64), "ms", tags)
+}
+
+// Gauge implements the cache.MetricsSink interface Gauge method.
+func (s *Sink) Gauge(name string, value float64, tags []string) {
+	s.send(name, strconv.FormatFloat(value, 'f', -1, 64), "g", tags)
+}
+
+// Close closes the connection of the sink.
+func (s *Sink) Close() error {
+	return s.conn.Close()
+}
+
+// send writes a metric, ignoring failures as StatsD is fire and forget.
+func (s *Sink) send(name, value, kind string, tags []string) {
+	var b strings.Builder
+	b.WriteString(s.prefix)
+	b.WriteString(name)
+	b.WriteByte(':')
+	b.WriteString(value)
+	b.WriteByte('|')
+	b.WriteString(kind)
+	if len(s.tags)+len(tags) > 0 {
+		b.WriteString("|#")
+		b.WriteString(strings.Join(append(append([]string{}, s.tags...), tags...), ","))
+	}
+
+	s.conn.Write([]byte(b.String()))
+}
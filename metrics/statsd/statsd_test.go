@@ -0,0 +1,53 @@
+package statsd
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.ListenPacket() error = %v", err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		name string
+		opts []SinkOptions
+		emit func(s *Sink)
+		want string
+	}{
+		{"counter", nil, func(s *Sink) { s.Incr("hits", nil) }, "http_cache.hits:1|c"},
+		{"timing", nil, func(s *Sink) { s.Timing("adapter.latency", 1500*time.Microsecond, []string{"op:get"}) }, "http_cache.adapter.latency:1.5|ms|#op:get"},
+		{"gauge", nil, func(s *Sink) { s.Gauge("response.size", 42, nil) }, "http_cache.response.size:42|g"},
+		{
+			"prefix and tags",
+			[]SinkOptions{SinkWithPrefix("api.cache."), SinkWithTags("service:api")},
+			func(s *Sink) { s.Incr("errors", []string{"error:decode"}) },
+			"api.cache.errors:1|c|#service:api,error:decode",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSink(conn.LocalAddr().String(), tt.opts...)
+			if err != nil {
+				t.Fatalf("NewSink() error = %v", err)
+			}
+			defer s.Close()
+
+			tt.emit(s)
+
+			b := make([]byte, 1024)
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			n, _, err := conn.ReadFrom(b)
+			if err != nil {
+				t.Fatalf("ReadFrom() error = %v", err)
+			}
+			if got := string(b[:n]); got != tt.want {
+				t.Errorf("Sink sent %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+type metricsSinkMock struct {
+	sync.Mutex
+	metrics []string
+}
+
+func (s *metricsSinkMock) record(kind, name string, tags []string) {
+	s.Lock()
+	defer s.Unlock()
+	metric := kind + ":" + name
+	for _, tag := range tags {
+		metric += "#" + tag
+	}
+	s.metrics = append(s.metrics, metric)
+}
+
+func (s *metricsSinkMock) Incr(name string, tags []string) {
+	s.record("c", name, tags)
+}
+
+func (s *metricsSinkMock) Timing(name string, d time.Duration, tags []string) {
+	s.record("ms", name, tags)
+}
+
+func (s *metricsSinkMock) Gauge(name string, value float64, tags []string) {
+	s.record("g", name, tags)
+}
+
+func TestWithMetrics(t *testing.T) {
+	sink := &metricsSinkMock{}
+	client, err := NewClient(
+		WithAdapter(&adapterMock{store: map[string][]byte{}}),
+		WithTTL(1*time.Minute),
+		WithMetrics(sink),
+		WithHooks(Hooks{}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("value 1"))
+	}))
+
+	for i := 0; i < 2; i++ {
+		r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	want := []string{
+		"c:hits",
+		"c:misses",
+		"c:stores",
+		"g:response.size",
+		"ms:adapter.latency#op:get",
+		"ms:adapter.latency#op:get",
+		"ms:adapter.latency#op:set",
+		"ms:request.latency",
+		"ms:request.latency",
+	}
+	sort.Strings(sink.metrics)
+	if !reflect.DeepEqual(sink.metrics, want) {
+		t.Errorf("metrics = %q, want %q", sink.metrics, want)
+	}
+
+	if _, err := NewClient(WithAdapter(&adapterMock{}), WithTTL(1*time.Minute), WithMetrics(nil)); err == nil {
+		t.Error("NewClient() error = nil, want an error for a nil sink")
+	}
+}
+
+func TestWithMetricTags(t *testing.T) {
+	sink := &metricsSinkMock{}
+	client, err := NewClient(
+		WithAdapter(&adapterMock{store: map[string][]byte{}}),
+		WithTTL(1*time.Minute),
+		WithMetrics(sink),
+		WithMetricTags(func(r *http.Request) []string {
+			return []string{"route:" + r.URL.Path}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("value 1"))
+	}))
+
+	for i := 0; i < 2; i++ {
+		r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	want := []string{
+		"c:hits#route:/test-1",
+		"c:misses#route:/test-1",
+		"c:stores#route:/test-1",
+		"g:response.size#route:/test-1",
+		"ms:adapter.latency#op:get",
+		"ms:adapter.latency#op:get",
+		"ms:adapter.latency#op:set",
+		"ms:request.latency#route:/test-1",
+		"ms:request.latency#route:/test-1",
+	}
+	sort.Strings(sink.metrics)
+	if !reflect.DeepEqual(sink.metrics, want) {
+		t.Errorf("metrics = %q, want %q", sink.metrics, want)
+	}
+
+	if _, err := NewClient(WithAdapter(&adapterMock{}), WithTTL(1*time.Minute), WithMetricTags(nil)); err == nil {
+		t.Error("NewClient() error = nil, want an error for a nil function")
+	}
+}
@@ -0,0 +1,64 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cache
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+// ReverseProxy returns a caching reverse proxy to target: an
+// httputil.ReverseProxy wrapped with the middleware. Responses are streamed
+// to clients as they are received from target while being cached.
+//
+// Requests are sent with the Host of target and the original one in the
+// X-Forwarded-Host header, along with X-Forwarded-For and X-Forwarded-Proto,
+// and hop-by-hop headers are stripped both ways. Accept-Encoding is removed,
+// leaving compression to the proxy transport which decodes it transparently,
+// so that bodies encoded for one client are never served to another unable
+// to decode them; WithCompression compresses cached bodies instead.
+func (c *Client) ReverseProxy(target *url.URL) http.Handler {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		host, proto := r.Host, "http"
+		if r.TLS != nil {
+			proto = "https"
+		}
+
+		director(r)
+		r.Host = target.Host
+		r.Header.Del("Accept-Encoding")
+		if r.Header.Get("X-Forwarded-Host") == "" {
+			r.Header.Set("X-Forwarded-Host", host)
+		}
+		if r.Header.Get("X-Forwarded-Proto") == "" {
+			r.Header.Set("X-Forwarded-Proto", proto)
+		}
+	}
+
+	return c.Middleware(proxy)
+}
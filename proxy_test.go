@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReverseProxy(t *testing.T) {
+	var upstreams int32
+	var received http.Header
+	var host string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&upstreams, 1)
+		received, host = r.Header.Clone(), r.Host
+		w.Header().Set("Connection", "close")
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Write([]byte("value 1"))
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write([]byte("value 1"))
+		gz.Close()
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+
+	client, _ := NewClient(
+		WithAdapter(&adapterMock{store: map[string][]byte{}}),
+		WithTTL(1*time.Minute),
+		WithStatusHeaders(true),
+	)
+	proxy := httptest.NewServer(client.ReverseProxy(target))
+	defer proxy.Close()
+
+	tests := []struct {
+		name       string
+		wantStatus string
+	}{
+		{"proxies misses", "MISS"},
+		{"serves hits", "HIT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, proxy.URL+"/test-1", nil)
+			r.Header.Set("Accept-Encoding", "gzip")
+			resp, err := http.DefaultTransport.RoundTrip(r)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if string(body) != "value 1" {
+				t.Errorf("body = %q, want %q", body, "value 1")
+			}
+			if got := resp.Header.Get("Content-Encoding"); got != "" {
+				t.Errorf("Content-Encoding = %q, want none", got)
+			}
+			if got := resp.Header.Get("X-Cache"); got != tt.wantStatus {
+				t.Errorf("X-Cache = %q, want %q", got, tt.wantStatus)
+			}
+		})
+	}
+
+	if got := atomic.LoadInt32(&upstreams); got != 1 {
+		t.Errorf("upstream requests = %v, want 1", got)
+	}
+	proxyURL, _ := url.Parse(proxy.URL)
+	if host != target.Host {
+		t.Errorf("upstream Host = %v, want %v", host, target.Host)
+	}
+	if got := received.Get("X-Forwarded-Host"); got != proxyURL.Host {
+		t.Errorf("X-Forwarded-Host = %v, want %v", got, proxyURL.Host)
+	}
+	if got := received.Get("X-Forwarded-Proto"); got != "http" {
+		t.Errorf("X-Forwarded-Proto = %v, want http", got)
+	}
+	if got := received.Get("X-Forwarded-For"); got == "" {
+		t.Errorf("X-Forwarded-For is empty")
+	}
+}
@@ -0,0 +1,193 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// refresherCapacity is the number of keys tracked by the refresher per hot
+// key it refreshes.
+const refresherCapacity = 10
+
+// refresher re-renders the most accessed keys shortly before they expire.
+type refresher struct {
+	n     int
+	ahead time.Duration
+	// requestInfo describes the requests tracked, set by start.
+	requestInfo func(*http.Request) *RequestInfo
+	mutex       sync.Mutex
+	keys        map[string]*refresherKey
+	cancel      context.CancelFunc
+	done        chan struct{}
+}
+
+// refresherKey is a key tracked by the refresher, along with the method, URL
+// and metadata headers of a request for it, needed to re-render its
+// response. The request itself isn't kept, to not retain its body, context
+// and credentials.
+type refresherKey struct {
+	accesses   int
+	expiration time.Time
+	request    *RequestInfo
+	next       http.Handler
+}
+
+// WithRefresher makes the client track the n most accessed keys and re-render
+// their responses with the handler ahead of their expiration, in the
+// background, so that popular responses never expire. Accesses are counted
+// over the last few ahead periods, and the requests re-rendering responses
+// have the method and URL of the first request tracked for each key, along
+// with the headers allowed by WithRequestMetadata only. Close stops the
+// refresher.
+func WithRefresher(n int, ahead time.Duration) ClientOption {
+	return func(c *Client) error {
+		if n < 1 {
+			return fmt.Errorf("cache client refresher keys %v is invalid", n)
+		}
+		if int64(ahead) < 2 {
+			return fmt.Errorf("cache client refresher ahead %v is invalid", ahead)
+		}
+
+		c.refresher = &refresher{
+			n:     n,
+			ahead: ahead,
+			keys:  map[string]*refresherKey{},
+		}
+
+		return nil
+	}
+}
+
+// touch counts an access to a key, served by next with a response expiring
+// at a date, zero when unknown. The least accessed key is evicted when the
+// refresher is full.
+func (f *refresher) touch(key string, r *http.Request, next http.Handler, expiration time.Time) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	k, ok := f.keys[key]
+	if !ok {
+		if len(f.keys) >= f.n*refresherCapacity {
+			var evicted string
+			for key, k := range f.keys {
+				if evicted == "" || k.accesses < f.keys[evicted].accesses {
+					evicted = key
+				}
+			}
+			delete(f.keys, evicted)
+		}
+		k = &refresherKey{}
+		f.keys[key] = k
+	}
+	k.accesses++
+	if !expiration.IsZero() {
+		k.expiration = expiration
+	}
+	if k.request == nil {
+		k.request = f.requestInfo(r)
+	}
+	k.next = next
+}
+
+// start runs the refresher until stop is called.
+func (f *refresher) start(c *Client) {
+	f.requestInfo = c.requestInfo
+	ctx, cancel := context.WithCancel(context.Background())
+	f.cancel = cancel
+	f.done = make(chan struct{})
+
+	go func() {
+		defer close(f.done)
+
+		ticker := time.NewTicker(f.ahead / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				f.refresh(c)
+			}
+		}
+	}()
+}
+
+// stop stops the refresher.
+func (f *refresher) stop() {
+	f.cancel()
+	<-f.done
+}
+
+// refresh revalidates the hot keys expiring within the ahead period, then
+// halves the access counts, so that keys cool down once no longer accessed.
+func (f *refresher) refresh(c *Client) {
+	f.mutex.Lock()
+	hot := make([]string, 0, len(f.keys))
+	for key := range f.keys {
+		hot = append(hot, key)
+	}
+	sort.Slice(hot, func(i, j int) bool {
+		return f.keys[hot[i]].accesses > f.keys[hot[j]].accesses
+	})
+	if len(hot) > f.n {
+		hot = hot[:f.n]
+	}
+
+	type refresh struct {
+		key     string
+		request *RequestInfo
+		next    http.Handler
+	}
+	var refreshes []refresh
+	deadline := time.Now().Add(f.ahead)
+	for _, key := range hot {
+		k := f.keys[key]
+		if !k.expiration.IsZero() && k.expiration.Before(deadline) {
+			refreshes = append(refreshes, refresh{key, k.request, k.next})
+			// The expiration is unknown until the next access.
+			k.expiration = time.Time{}
+		}
+	}
+	for key, k := range f.keys {
+		if k.accesses /= 2; k.accesses == 0 {
+			delete(f.keys, key)
+		}
+	}
+	f.mutex.Unlock()
+
+	for _, r := range refreshes {
+		req, err := r.request.NewRequest(context.Background())
+		if err != nil {
+			continue
+		}
+		c.revalidate(r.next, req, r.key)
+	}
+}
@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRefresher(t *testing.T) {
+	adapter := &adapterMock{store: map[string][]byte{}}
+	client, err := NewClient(
+		WithAdapter(adapter),
+		WithTTL(200*time.Millisecond),
+		WithRefresher(1, 100*time.Millisecond),
+		WithRequestMetadata("Accept-Language"),
+	)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer client.Close()
+
+	var mutex sync.Mutex
+	calls := map[string]int{}
+	var refreshed []string
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		calls[r.URL.Path]++
+		if IsRevalidation(r.Context()) {
+			refreshed = append(refreshed, r.Method+" "+r.URL.String()+" "+r.Header.Get("Accept-Language")+r.Header.Get("X-Api-Key"))
+		}
+		mutex.Unlock()
+		w.Write([]byte("value 1"))
+	}))
+	serve := func(url string) {
+		r, _ := http.NewRequest(http.MethodGet, url, nil)
+		r.Header.Set("Accept-Language", "fr")
+		r.Header.Set("X-Api-Key", "secret")
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	for i := 0; i < 5; i++ {
+		serve("http://foo.bar/hot")
+	}
+	serve("http://foo.bar/cold")
+	time.Sleep(250 * time.Millisecond)
+
+	mutex.Lock()
+	hot, cold := calls["/hot"], calls["/cold"]
+	mutex.Unlock()
+	if hot < 2 {
+		t.Errorf("hot handler calls = %v, want the response refreshed", hot)
+	}
+	if cold != 1 {
+		t.Errorf("cold handler calls = %v, want 1", cold)
+	}
+	mutex.Lock()
+	// The refreshing request is rebuilt from the tracked one, with the
+	// metadata headers only.
+	if want := "GET http://foo.bar/hot fr"; len(refreshed) == 0 || refreshed[0] != want {
+		t.Errorf("refreshing requests = %q, want %q", refreshed, want)
+	}
+	mutex.Unlock()
+	adapter.Lock()
+	response := BytesToResponse(adapter.store["http://foo.bar/hot"])
+	adapter.Unlock()
+	if !response.Expiration.After(time.Now()) {
+		t.Errorf("hot response expired at %v, want it refreshed", response.Expiration)
+	}
+}
+
+func TestWithRefresher(t *testing.T) {
+	tests := []struct {
+		name    string
+		n       int
+		ahead   time.Duration
+		wantErr bool
+	}{
+		{"valid", 10, time.Second, false},
+		{"invalid keys", 0, time.Second, true},
+		{"invalid ahead", 10, 0, true},
+		{"ahead too short to tick", 10, 1, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(
+				WithAdapter(&adapterMock{}),
+				WithTTL(1*time.Minute),
+				WithRefresher(tt.n, tt.ahead),
+			)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if client != nil {
+				client.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cache
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// Rule matches requests to override the cache policy of a client, set with
+// WithRules. A rule matches requests meeting all of its set conditions.
+type Rule struct {
+	// Path is a glob pattern matched against the request path, with the
+	// syntax described by InvalidatingAdapter, where "*" matches slashes.
+	Path string
+
+	// PathRegexp is matched against the request path.
+	PathRegexp *regexp.Regexp
+
+	// Route is the route template of the matched requests, such as
+	// "/users/{id}", compared as is to the one returned by the function set
+	// with WithRoute.
+	Route string
+
+	// Methods are the request methods matched. The methods cached remain
+	// those set with WithMethods.
+	Methods []string
+
+	// Header is a predicate on the request header.
+	Header func(http.Header) bool
+
+	// TTL is how long the matched responses are cached, taking precedence
+	// over the TTL function and the response headers, unless zero. Negative
+	// responses keep the TTL set with WithNegativeTTL.
+	TTL time.Duration
+
+	// VaryHeaders are request headers whose values are added to the keys of
+	// the matched requests, besides those set with WithVaryHeaders.
+	VaryHeaders []string
+
+	// Disabled bypasses the cache for the matched requests.
+	Disabled bool
+}
+
+// WithRules sets rules overriding the cache policy by request. The first
+// rule matching a request applies, and requests matching no rule bypass the
+// cache, so a last rule without conditions sets the default policy.
+func WithRules(rules []Rule) ClientOption {
+	return func(c *Client) error {
+		if len(rules) == 0 {
+			return fmt.Errorf("cache client rules can not be empty")
+		}
+
+		c.rules = make([]Rule, len(rules))
+		for i, rule := range rules {
+			if rule.TTL < 0 {
+				return fmt.Errorf("cache client rule %d ttl %v is invalid", i, rule.TTL)
+			}
+			names := rule.VaryHeaders
+			rule.VaryHeaders = nil
+			for _, name := range names {
+				rule.VaryHeaders = append(rule.VaryHeaders, http.CanonicalHeaderKey(name))
+			}
+			c.rules[i] = rule
+		}
+
+		return nil
+	}
+}
+
+// WithRoute sets the function returning the route template of requests,
+// such as "/users/{id}" as resolved by the router, to match rules by route
+// rather than by concrete path.
+func WithRoute(fn func(*http.Request) string) ClientOption {
+	return func(c *Client) error {
+		if fn == nil {
+			return fmt.Errorf("cache client route function can not be nil")
+		}
+
+		c.routeFn = fn
+
+		return nil
+	}
+}
+
+// rule returns the first rule matching a request, if any.
+func (c *Client) rule(r *http.Request) *Rule {
+	if len(c.rules) == 0 {
+		return nil
+	}
+
+	route := ""
+	if c.routeFn != nil {
+		route = c.routeFn(r)
+	}
+	for i := range c.rules {
+		if c.rules[i].matches(r, route) {
+			return &c.rules[i]
+		}
+	}
+
+	return nil
+}
+
+// ruleCacheable wraps a cacheable function to bypass the cache for requests
+// matching no rule or a disabled one.
+func (c *Client) ruleCacheable(cacheable func(*http.Request) bool) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		rule := c.rule(r)
+		return rule != nil && !rule.Disabled && cacheable(r)
+	}
+}
+
+// matches reports whether a rule applies to a request with a route template.
+func (rule *Rule) matches(r *http.Request, route string) bool {
+	if len(rule.Methods) > 0 {
+		matched := false
+		for _, method := range rule.Methods {
+			if r.Method == method {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if rule.Path != "" && !MatchPattern(rule.Path, r.URL.Path) {
+		return false
+	}
+	if rule.PathRegexp != nil && !rule.PathRegexp.MatchString(r.URL.Path) {
+		return false
+	}
+	if rule.Route != "" && rule.Route != route {
+		return false
+	}
+
+	return rule.Header == nil || rule.Header(r.Header)
+}
@@ -0,0 +1,206 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []Rule
+		wantErr bool
+	}{
+		{"accepts rules", []Rule{{Path: "/api/*", TTL: time.Minute}, {}}, false},
+		{"rejects empty rules", nil, true},
+		{"rejects negative ttls", []Rule{{TTL: -time.Minute}}, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClient(WithAdapter(&adapterMock{}), WithTTL(time.Minute), WithRules(tt.rules))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMiddlewareRules(t *testing.T) {
+	adapter := &adapterMock{store: map[string][]byte{}}
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithRules([]Rule{
+			{Path: "/api/*/search", Disabled: true},
+			{PathRegexp: regexp.MustCompile(`^/users/\d+$`), TTL: 10 * time.Second},
+			{
+				Path: "/static/*",
+				Header: func(h http.Header) bool {
+					return h.Get("X-Preview") == ""
+				},
+				TTL:         time.Hour,
+				VaryHeaders: []string{"accept-language"},
+			},
+			{Path: "/api/*"},
+		}),
+	)
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("value"))
+	}))
+
+	tests := []struct {
+		name    string
+		path    string
+		header  http.Header
+		wantKey string
+		wantTTL time.Duration
+	}{
+		{"applies the default ttl", "/api/products", nil, "http://foo.bar/api/products", time.Minute},
+		{"bypasses disabled rules", "/api/products/search", nil, "", 0},
+		{"matches regexps", "/users/1", nil, "http://foo.bar/users/1", 10 * time.Second},
+		{"bypasses unmatched requests", "/users/me", nil, "", 0},
+		{
+			"adds vary headers",
+			"/static/app.js",
+			http.Header{"Accept-Language": {"en"}},
+			"http://foo.bar/static/app.js#headers:Accept-Language=en",
+			time.Hour,
+		},
+		{"matches header predicates", "/static/app.css", http.Header{"X-Preview": {"1"}}, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter.store = map[string][]byte{}
+			r, _ := http.NewRequest(http.MethodGet, "http://foo.bar"+tt.path, nil)
+			for name, values := range tt.header {
+				r.Header[name] = values
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			if tt.wantKey == "" {
+				if len(adapter.store) > 0 {
+					t.Errorf("cached %v, want none", adapter.store)
+				}
+				return
+			}
+			b, ok := adapter.store[tt.wantKey]
+			if !ok {
+				t.Fatalf("response not cached under %v, store = %v", tt.wantKey, adapter.store)
+			}
+			response := BytesToResponse(b)
+			if got := response.Expiration.Sub(response.StoredAt); got != tt.wantTTL {
+				t.Errorf("cached for %v, want %v", got, tt.wantTTL)
+			}
+		})
+	}
+}
+
+func TestMiddlewareRouteRules(t *testing.T) {
+	adapter := &adapterMock{store: map[string][]byte{}}
+	client, err := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithRoute(func(r *http.Request) string {
+			if strings.HasPrefix(r.URL.Path, "/users/") {
+				return "/users/{id}"
+			}
+			return ""
+		}),
+		WithRules([]Rule{
+			{Route: "/users/{id}", TTL: 10 * time.Second},
+			{Path: "/users/*", Disabled: true},
+			{},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("value"))
+	}))
+
+	tests := []struct {
+		name    string
+		path    string
+		wantTTL time.Duration
+	}{
+		{"matches routes", "/users/1", 10 * time.Second},
+		{"matches other urls of routes", "/users/2", 10 * time.Second},
+		{"skips route rules", "/products/1", time.Minute},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			adapter.store = map[string][]byte{}
+			r, _ := http.NewRequest(http.MethodGet, "http://foo.bar"+tt.path, nil)
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			b, ok := adapter.store["http://foo.bar"+tt.path]
+			if !ok {
+				t.Fatalf("response not cached, store = %v", adapter.store)
+			}
+			response := BytesToResponse(b)
+			if got := response.Expiration.Sub(response.StoredAt); got != tt.wantTTL {
+				t.Errorf("cached for %v, want %v", got, tt.wantTTL)
+			}
+		})
+	}
+
+	if _, err := NewClient(WithAdapter(&adapterMock{}), WithTTL(time.Minute), WithRoute(nil)); err == nil {
+		t.Error("NewClient() error = nil, want an error for a nil function")
+	}
+}
+
+func TestMiddlewareRulesNegativeTTL(t *testing.T) {
+	adapter := &adapterMock{store: map[string][]byte{}}
+	client, err := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithNegativeTTL(5*time.Second),
+		WithTTLFunc(func(r *http.Request, res *http.Response) time.Duration {
+			return time.Hour
+		}),
+		WithRules([]Rule{{Path: "/static/*", TTL: 24 * time.Hour}, {}}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write([]byte("value"))
+	}))
+
+	tests := []struct {
+		name    string
+		path    string
+		wantTTL time.Duration
+	}{
+		{"applies rule ttls", "/static/app.js", 24 * time.Hour},
+		{"applies the negative ttl over rules", "/static/missing", 5 * time.Second},
+		{"applies the ttl function", "/app", time.Hour},
+		{"applies the negative ttl over the ttl function", "/missing", 5 * time.Second},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "http://foo.bar"+tt.path, nil)
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			b, ok := adapter.store["http://foo.bar"+tt.path]
+			if !ok {
+				t.Fatalf("response not cached, store = %v", adapter.store)
+			}
+			response := BytesToResponse(b)
+			if got := response.Expiration.Sub(response.StoredAt); got != tt.wantTTL {
+				t.Errorf("cached for %v, want %v", got, tt.wantTTL)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cache
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// signingMagic prefixes entries signed by the client.
+const signingMagic = "\x00hcs"
+
+// minSigningKeySize is the minimum size of signing keys.
+const minSigningKeySize = 16
+
+// signing authenticates entries with HMAC-SHA256, using the primary key for
+// new entries and any known key for existing ones.
+type signing struct {
+	primary [keyIDSize]byte
+	keys    map[[keyIDSize]byte][]byte
+}
+
+func newSigning(key []byte, previous ...[]byte) (*signing, error) {
+	s := &signing{keys: map[[keyIDSize]byte][]byte{}}
+	for i, k := range append([][]byte{key}, previous...) {
+		if len(k) < minSigningKeySize {
+			return nil, fmt.Errorf("key must be at least %v bytes", minSigningKeySize)
+		}
+
+		id := keyID(k)
+		if i == 0 {
+			s.primary = id
+		}
+		s.keys[id] = k
+	}
+
+	return s, nil
+}
+
+// mac computes the signature of an entry, covering the key it's cached
+// under so an entry can't be replayed under another key.
+func (s *signing) mac(id [keyIDSize]byte, key string, b []byte) []byte {
+	m := hmac.New(sha256.New, s.keys[id])
+	m.Write([]byte(key))
+	m.Write([]byte{0})
+	m.Write(b)
+
+	return m.Sum(nil)
+}
+
+// sign prefixes b with its signature under the primary key.
+func (s *signing) sign(key string, b []byte) []byte {
+	out := make([]byte, 0, len(signingMagic)+keyIDSize+sha256.Size+len(b))
+	out = append(out, signingMagic...)
+	out = append(out, s.primary[:]...)
+	out = append(out, s.mac(s.primary, key, b)...)
+
+	return append(out, b...)
+}
+
+// verify checks the signature of an entry cached under key, returning the
+// signed bytes.
+func (s *signing) verify(key string, b []byte) ([]byte, error) {
+	if !bytes.HasPrefix(b, []byte(signingMagic)) || len(b) < len(signingMagic)+keyIDSize+sha256.Size {
+		return nil, errors.New("entry is not signed")
+	}
+	b = b[len(signingMagic):]
+
+	var id [keyIDSize]byte
+	copy(id[:], b)
+	if _, ok := s.keys[id]; !ok {
+		return nil, fmt.Errorf("key id %x is unknown", id)
+	}
+	sum, b := b[keyIDSize:keyIDSize+sha256.Size], b[keyIDSize+sha256.Size:]
+
+	if !hmac.Equal(sum, s.mac(id, key, b)) {
+		return nil, errors.New("signature mismatch")
+	}
+
+	return b, nil
+}
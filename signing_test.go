@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSigning(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 16)
+	newKey := bytes.Repeat([]byte{2}, 32)
+
+	old, _ := newSigning(oldKey)
+	signed := old.sign("key", []byte("value 1"))
+
+	tampered := append([]byte(nil), signed...)
+	tampered[len(tampered)-1] ^= 1
+
+	rotated, _ := newSigning(newKey, oldKey)
+	other, _ := newSigning(newKey)
+
+	tests := []struct {
+		name    string
+		signing *signing
+		key     string
+		b       []byte
+		wantErr bool
+	}{
+		{"verifies signed entry", old, "key", signed, false},
+		{"verifies entry signed with a previous key", rotated, "key", signed, false},
+		{"rejects unknown key id", other, "key", signed, true},
+		{"rejects entry moved to another key", old, "other", signed, true},
+		{"rejects tampered entry", old, "key", tampered, true},
+		{"rejects unsigned entry", old, "key", []byte("value 1"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.signing.verify(tt.key, tt.b)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("signing.verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && string(got) != "value 1" {
+				t.Errorf("signing.verify() = %q, want %q", got, "value 1")
+			}
+		})
+	}
+
+	if _, err := newSigning([]byte("short")); err == nil {
+		t.Errorf("newSigning() error = nil, want an error for a short key")
+	}
+}
+
+func TestMiddlewareSigning(t *testing.T) {
+	adapter := &adapterMock{store: map[string][]byte{
+		"http://foo.bar/test-1": Response{
+			Value:      []byte("injected"),
+			Expiration: time.Now().Add(1 * time.Minute),
+		}.Bytes(),
+	}}
+	var failures []string
+	client, err := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithSigning(bytes.Repeat([]byte{1}, 32)),
+		WithHooks(Hooks{
+			OnVerifyFailure: func(r *http.Request, key string, err error) {
+				failures = append(failures, key)
+			},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("value 1"))
+	}))
+
+	for i := 0; i < 2; i++ {
+		r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Body.String() != "value 1" {
+			t.Errorf("*Client.Middleware() = %v, want %v", w.Body.String(), "value 1")
+		}
+	}
+
+	if len(failures) != 1 || failures[0] != "http://foo.bar/test-1" {
+		t.Errorf("verification failures = %v, want %v", failures, []string{"http://foo.bar/test-1"})
+	}
+}
@@ -0,0 +1,100 @@
+//go:build go1.21
+
+package cache
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithLogger(t *testing.T) {
+	tests := []struct {
+		name    string
+		adapter ClientOption
+		urls    []string
+		want    []string
+	}{
+		{
+			"logs misses and stores",
+			WithAdapter(&adapterMock{store: map[string][]byte{}}),
+			[]string{"http://foo.bar/test-1"},
+			[]string{
+				`level=DEBUG msg="cache miss" method=GET url=http://foo.bar/test-1 key=http://foo.bar/test-1`,
+				`level=DEBUG msg="cache store" method=GET url=http://foo.bar/test-1 key=http://foo.bar/test-1 status=200`,
+			},
+		},
+		{
+			"logs refreshes",
+			WithAdapter(&adapterMock{store: map[string][]byte{}}),
+			[]string{"http://foo.bar/test-1?rk=true"},
+			[]string{
+				`level=DEBUG msg="cache refresh" method=GET url=http://foo.bar/test-1 key=http://foo.bar/test-1`,
+			},
+		},
+		{
+			"logs decode failures",
+			WithAdapter(&adapterMock{store: map[string][]byte{"http://foo.bar/test-1": []byte("garbage")}}),
+			[]string{"http://foo.bar/test-1"},
+			[]string{
+				`level=WARN msg="cache decode failure" method=GET url=http://foo.bar/test-1 key=http://foo.bar/test-1 err="codec unmarshal:`,
+			},
+		},
+		{
+			"logs adapter errors",
+			WithAdapterV2(failingAdapter{}),
+			[]string{"http://foo.bar/test-1"},
+			[]string{
+				`level=WARN msg="cache error" method=GET url=http://foo.bar/test-1 key=http://foo.bar/test-1 err="adapter get: get error"`,
+				`level=WARN msg="cache error" method=GET url=http://foo.bar/test-1 key=http://foo.bar/test-1 err="adapter set: set error"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			var errs int
+			client, err := NewClient(
+				tt.adapter,
+				WithTTL(1*time.Minute),
+				WithRefreshKey("rk"),
+				WithLogger(logger),
+				WithHooks(Hooks{
+					OnError: func(r *http.Request, key string, err error) {
+						errs++
+					},
+				}),
+			)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("value 1"))
+			}))
+
+			for _, url := range tt.urls {
+				r, _ := http.NewRequest(http.MethodGet, url, nil)
+				handler.ServeHTTP(httptest.NewRecorder(), r)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("log = %q, want it to contain %q", buf.String(), want)
+				}
+			}
+			if warns := strings.Count(buf.String(), "level=WARN"); warns != errs {
+				t.Errorf("warnings = %v, want one per reported error, %v", warns, errs)
+			}
+		})
+	}
+
+	if _, err := NewClient(WithAdapter(&adapterMock{}), WithTTL(1*time.Minute), WithLogger(nil)); err == nil {
+		t.Error("NewClient() error = nil, want an error for a nil logger")
+	}
+}
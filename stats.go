@@ -0,0 +1,122 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cache
+
+import (
+	"expvar"
+	"fmt"
+	"sync/atomic"
+)
+
+// KeyCounter is implemented by adapters able to count the keys they hold,
+// reported by Client.Stats.
+type KeyCounter interface {
+	// Len returns the number of keys held.
+	Len() int
+}
+
+// Stats is a snapshot of the client usage.
+type Stats struct {
+	// Hits is the number of requests served from the cache, including
+	// stale responses.
+	Hits uint64 `json:"hits"`
+
+	// Misses is the number of requests no cached response could serve.
+	Misses uint64 `json:"misses"`
+
+	// HitRatio is the ratio of hits over hits and misses.
+	HitRatio float64 `json:"hit_ratio"`
+
+	// Stores is the number of responses cached.
+	Stores uint64 `json:"stores"`
+
+	// StoredBytes estimates the size in bytes of the cached entries, from
+	// the average size of the entries cached by the client times Keys, or
+	// the total size of the entries cached when Keys is unknown.
+	StoredBytes uint64 `json:"stored_bytes"`
+
+	// Keys is the number of keys held by the adapter, or -1 when it doesn't
+	// implement KeyCounter.
+	Keys int `json:"keys"`
+
+	// DroppedWrites is the number of async writes dropped.
+	DroppedWrites uint64 `json:"dropped_writes"`
+}
+
+// Stats returns the current usage of the client.
+func (c *Client) Stats() Stats {
+	stats := Stats{
+		Hits:          atomic.LoadUint64(&c.hits),
+		Misses:        atomic.LoadUint64(&c.misses),
+		Stores:        atomic.LoadUint64(&c.stores),
+		StoredBytes:   atomic.LoadUint64(&c.storedBytes),
+		Keys:          -1,
+		DroppedWrites: c.DroppedWrites(),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(total)
+	}
+	if counter, ok := c.keyCounter(); ok {
+		stats.Keys = counter.Len()
+		if stats.Stores > 0 {
+			stats.StoredBytes = stats.StoredBytes / stats.Stores * uint64(stats.Keys)
+		}
+	}
+
+	return stats
+}
+
+// keyCounter returns the adapter as a KeyCounter, unwrapping the Adapter
+// interface shim.
+func (c *Client) keyCounter() (KeyCounter, bool) {
+	counter, ok := UnwrapAdapterV2(c.adapter).(KeyCounter)
+
+	return counter, ok
+}
+
+// WithExpvar publishes the client stats as an expvar variable with the given
+// name, served at /debug/vars by the expvar package handler. Names are
+// global to the process, so each client needs its own.
+func WithExpvar(name string) ClientOption {
+	return func(c *Client) error {
+		if name == "" {
+			return fmt.Errorf("cache client expvar name can not be empty")
+		}
+		if expvar.Get(name) != nil {
+			return fmt.Errorf("cache client expvar %q is already published", name)
+		}
+
+		c.expvarName = name
+
+		return nil
+	}
+}
+
+// publishExpvar publishes the client stats under the expvar name.
+func (c *Client) publishExpvar() {
+	expvar.Publish(c.expvarName, expvar.Func(func() interface{} {
+		return c.Stats()
+	}))
+}
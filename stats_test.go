@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type countingAdapterMock struct {
+	adapterMock
+}
+
+func (a *countingAdapterMock) Len() int {
+	a.Lock()
+	defer a.Unlock()
+	return len(a.store)
+}
+
+func TestClientStats(t *testing.T) {
+	tests := []struct {
+		name    string
+		adapter Adapter
+		want    Stats
+	}{
+		{
+			"without key count",
+			&adapterMock{store: map[string][]byte{}},
+			Stats{Hits: 3, Misses: 2, HitRatio: 0.6, Stores: 2, Keys: -1},
+		},
+		{
+			"with key count",
+			&countingAdapterMock{adapterMock{store: map[string][]byte{}}},
+			Stats{Hits: 3, Misses: 2, HitRatio: 0.6, Stores: 2, Keys: 2},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := NewClient(
+				WithAdapter(tt.adapter),
+				WithTTL(1*time.Minute),
+			)
+			handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("value 1"))
+			}))
+
+			for _, url := range []string{"/test-1", "/test-1", "/test-2", "/test-2", "/test-2"} {
+				r, _ := http.NewRequest(http.MethodGet, "http://foo.bar"+url, nil)
+				handler.ServeHTTP(httptest.NewRecorder(), r)
+			}
+
+			got := client.Stats()
+			if got.StoredBytes == 0 {
+				t.Errorf("*Client.Stats() StoredBytes = 0, want an estimate")
+			}
+			got.StoredBytes = 0
+			if got != tt.want {
+				t.Errorf("*Client.Stats() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithExpvar(t *testing.T) {
+	client, err := NewClient(
+		WithAdapter(&adapterMock{store: map[string][]byte{}}),
+		WithTTL(1*time.Minute),
+		WithExpvar("http_cache_test"),
+	)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+	client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(httptest.NewRecorder(), r)
+
+	var stats Stats
+	if err := json.Unmarshal([]byte(expvar.Get("http_cache_test").String()), &stats); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if stats.Misses != 1 {
+		t.Errorf("published misses = %v, want 1", stats.Misses)
+	}
+
+	for _, name := range []string{"", "http_cache_test"} {
+		if _, err := NewClient(WithAdapter(&adapterMock{}), WithTTL(1*time.Minute), WithExpvar(name)); err == nil {
+			t.Errorf("NewClient() error = nil, want an error for expvar name %q", name)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TaggingAdapter is implemented by adapters able to index cached responses
+// by tag, so that all the responses carrying a tag can be invalidated at
+// once with Client.InvalidateTag.
+type TaggingAdapter interface {
+	// Tag associates a key with tags until an expiration date.
+	Tag(ctx context.Context, key string, tags []string, expiration time.Time) error
+
+	// InvalidateTag releases every key associated with a tag.
+	InvalidateTag(ctx context.Context, tag string) error
+}
+
+// ErrTaggingUnsupported is returned when invalidating a tag with an adapter
+// that doesn't implement TaggingAdapter.
+var ErrTaggingUnsupported = errors.New("cache adapter does not support tagging")
+
+// InvalidateTag releases every cached response carrying a tag, on every
+// instance when an invalidation bus is set.
+func (c *Client) InvalidateTag(ctx context.Context, tag string) error {
+	if err := c.invalidateTag(ctx, tag); err != nil {
+		return err
+	}
+
+	c.publish(ctx, InvalidationTag, tag)
+
+	return nil
+}
+
+func (c *Client) invalidateTag(ctx context.Context, tag string) error {
+	if c.tagger == nil {
+		return ErrTaggingUnsupported
+	}
+
+	return c.call(ctx, "invalidate_tag", func(ctx context.Context) error {
+		return c.tagger.InvalidateTag(ctx, tag)
+	})
+}
+
+// tags returns the tags of a response written by the handler, from the tag
+// header, which is removed, and the tagger function.
+func (c *Client) tags(r *http.Request, header http.Header) []string {
+	var tags []string
+	seen := map[string]bool{}
+	add := func(tag string) {
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	if c.tagHeader != "" {
+		for _, v := range header.Values(c.tagHeader) {
+			for _, tag := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' }) {
+				add(tag)
+			}
+		}
+		header.Del(c.tagHeader)
+	}
+	if c.taggerFn != nil {
+		for _, tag := range c.taggerFn(r, header) {
+			add(tag)
+		}
+	}
+
+	return tags
+}
+
+// tag associates a key with tags, reporting adapter failures.
+func (c *Client) tag(r *http.Request, key string, tags []string, expiration time.Time) {
+	if c.tagger == nil || len(tags) == 0 {
+		return
+	}
+
+	err := c.call(r.Context(), "tag", func(ctx context.Context) error {
+		return c.tagger.Tag(ctx, c.adapterKey(key), tags, expiration)
+	})
+	if err != nil {
+		c.hooks.error(r, key, fmt.Errorf("adapter tag: %w", err))
+	}
+}
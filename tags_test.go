@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type taggingAdapterMock struct {
+	adapterMock
+	tags map[string][]string
+}
+
+func (a *taggingAdapterMock) Tag(ctx context.Context, key string, tags []string, expiration time.Time) error {
+	a.Lock()
+	defer a.Unlock()
+	for _, tag := range tags {
+		a.tags[tag] = append(a.tags[tag], key)
+	}
+	return nil
+}
+
+func (a *taggingAdapterMock) InvalidateTag(ctx context.Context, tag string) error {
+	a.Lock()
+	defer a.Unlock()
+	for _, key := range a.tags[tag] {
+		delete(a.store, key)
+	}
+	delete(a.tags, tag)
+	return nil
+}
+
+func TestMiddlewareTags(t *testing.T) {
+	adapter := &taggingAdapterMock{
+		adapterMock: adapterMock{store: map[string][]byte{}},
+		tags:        map[string][]string{},
+	}
+	client, _ := NewClient(
+		WithAdapter(adapter),
+		WithTTL(1*time.Minute),
+		WithTagHeader("X-Cache-Tags"),
+		WithTagger(func(r *http.Request, h http.Header) []string {
+			return []string{"path:" + r.URL.Path}
+		}),
+	)
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Cache-Tags", "product-42, catalog catalog")
+		w.Write([]byte("value 1"))
+	}))
+
+	r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if got := w.Header().Get("X-Cache-Tags"); got != "" {
+		t.Errorf("X-Cache-Tags = %q, want it removed", got)
+	}
+	want := map[string][]string{
+		"product-42":   {"http://foo.bar/test-1"},
+		"catalog":      {"http://foo.bar/test-1"},
+		"path:/test-1": {"http://foo.bar/test-1"},
+	}
+	if !reflect.DeepEqual(adapter.tags, want) {
+		t.Errorf("adapter tags = %v, want %v", adapter.tags, want)
+	}
+	if h := BytesToResponse(adapter.store["http://foo.bar/test-1"]).Header; h.Get("X-Cache-Tags") != "" {
+		t.Errorf("stored X-Cache-Tags = %q, want it removed", h.Get("X-Cache-Tags"))
+	}
+
+	if err := client.InvalidateTag(context.Background(), "catalog"); err != nil {
+		t.Fatalf("*Client.InvalidateTag() error = %v", err)
+	}
+	if len(adapter.store) != 0 {
+		t.Errorf("adapter entries = %v, want 0", len(adapter.store))
+	}
+
+	client, _ = NewClient(WithAdapter(&adapterMock{}), WithTTL(1*time.Minute))
+	if err := client.InvalidateTag(context.Background(), "catalog"); err != ErrTaggingUnsupported {
+		t.Errorf("*Client.InvalidateTag() error = %v, want %v", err, ErrTaggingUnsupported)
+	}
+}
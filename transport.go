@@ -0,0 +1,280 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Transport is an http.RoundTripper caching the responses of outbound
+// requests with the adapter and options of a Client, so that a single cache
+// layer serves both the middleware and HTTP clients.
+//
+// Cached responses are served while fresh, and stale ones are revalidated
+// with conditional requests when they carry an ETag or Last-Modified header,
+// provided the adapter still keeps them, which WithStaleWhileRevalidate and
+// WithStaleIfError extend.
+type Transport struct {
+	client *Client
+	base   http.RoundTripper
+}
+
+// Transport returns an http.RoundTripper caching the responses of the
+// requests it sends with base, or http.DefaultTransport when nil.
+func (c *Client) Transport(base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &Transport{client: c, base: base}
+}
+
+// RoundTrip implements the http.RoundTripper interface RoundTrip method.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c := t.client
+	if c.hooks.metrics != nil {
+		defer c.hooks.requestTiming(req, time.Now())
+	}
+
+	r := req.Clone(req.Context())
+	if !c.cacheableFn(r) || r.Header.Get("Range") != "" {
+		return t.base.RoundTrip(req)
+	}
+	sortURLParams(r.URL)
+
+	key, err := c.key(r)
+	if err != nil {
+		c.hooks.error(r, "", err)
+		return t.base.RoundTrip(req)
+	}
+	if r.Body != req.Body {
+		// The key function consumed the body, restored on the clone.
+		req.Body.Close()
+		req = req.WithContext(req.Context())
+		req.Body = r.Body
+	}
+
+	var cached *Response
+	status := cacheMiss
+	if c.bypasses(r) {
+		status = cacheBypass
+	} else if _, response, ok := c.lookup(key, r); ok {
+		now := time.Now()
+		fresh := response.Expiration.After(now)
+		if fresh && c.satisfies(r, response, now) {
+			return t.serve(r, key, response, cacheHit), nil
+		}
+		if !fresh && response.Expiration.Add(c.staleWhileRevalidate).After(now) && c.satisfies(r, response, now) {
+			t.revalidate(req, r, key, response)
+			return t.serve(r, key, response, cacheStale), nil
+		}
+		cached = &response
+	}
+
+	if status == cacheMiss {
+		atomic.AddUint64(&c.misses, 1)
+		c.hooks.miss(r, key)
+	}
+
+	resp, err := t.fetch(req, r, key, status, cached)
+	if cached != nil && cached.Expiration.Add(c.staleIfError).After(time.Now()) {
+		if err != nil {
+			c.hooks.error(r, key, fmt.Errorf("round trip: %w", err))
+			return t.serve(r, key, *cached, cacheStale), nil
+		}
+		if resp.StatusCode >= 500 {
+			resp.Body.Close()
+			return t.serve(r, key, *cached, cacheStale), nil
+		}
+	}
+
+	return resp, err
+}
+
+// fetch sends a request with the base transport and caches its response,
+// annotated with the given cache status. A stale cached response, if any, is
+// revalidated with a conditional request unless the request is conditional
+// itself, and refreshed when the upstream server reports it wasn't
+// modified.
+func (t *Transport) fetch(req, r *http.Request, key, status string, cached *Response) (*http.Response, error) {
+	c := t.client
+	out := req
+	conditional := cached != nil &&
+		req.Header.Get("If-None-Match") == "" && req.Header.Get("If-Modified-Since") == ""
+	if conditional {
+		tag, modified := cached.Header.Get("ETag"), cached.Header.Get("Last-Modified")
+		if conditional = tag != "" || modified != ""; conditional {
+			out = req.Clone(req.Context())
+			if tag != "" {
+				out.Header.Set("If-None-Match", tag)
+			}
+			if modified != "" {
+				out.Header.Set("If-Modified-Since", modified)
+			}
+		}
+	}
+
+	resp, err := t.base.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+
+	if conditional && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+
+		value := cached.Value
+		if cached.Encoding != "" {
+			if value, err = Decompress(cached.Encoding, cached.Value); err != nil {
+				c.hooks.decodeFailure(r, key, fmt.Errorf("decompress: %w", err))
+				return t.fetch(req, r, key, status, nil)
+			}
+		}
+		header := cached.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		for name, values := range resp.Header {
+			header[name] = values
+		}
+		statusCode := cached.StatusCode
+		if statusCode == 0 {
+			statusCode = http.StatusOK
+		}
+		c.save(key, r, statusCode, header.Clone(), value)
+
+		response := *cached
+		response.Header = header
+		return t.serve(r, key, response, cacheHit), nil
+	}
+
+	if resp, err = t.store(r, key, resp); err != nil {
+		return nil, err
+	}
+	c.annotate(r, resp.Header, key, status, nil)
+
+	return resp, nil
+}
+
+// store caches the response of a request, buffering its body. Responses
+// larger than the maximum body size, partial or not modified responses and
+// server errors are passed through as is.
+func (t *Transport) store(r *http.Request, key string, resp *http.Response) (*http.Response, error) {
+	c := t.client
+	switch {
+	case resp.StatusCode == http.StatusNotModified, resp.StatusCode == http.StatusPartialContent, resp.StatusCode >= 500:
+		return resp, nil
+	case c.maxBodySize > 0 && resp.ContentLength > c.maxBodySize:
+		return resp, nil
+	}
+
+	var body io.Reader = resp.Body
+	if c.maxBodySize > 0 {
+		body = io.LimitReader(resp.Body, c.maxBodySize+1)
+	}
+	value, err := ioutil.ReadAll(body)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if c.maxBodySize > 0 && int64(len(value)) > c.maxBodySize {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(value), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(value))
+
+	c.save(key, r, resp.StatusCode, resp.Header.Clone(), value)
+
+	return resp, nil
+}
+
+// serve returns a response cached under key.
+func (t *Transport) serve(r *http.Request, key string, response Response, status string) *http.Response {
+	c := t.client
+	atomic.AddUint64(&c.hits, 1)
+	c.hooks.hit(r, key, response)
+
+	statusCode := response.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	header := c.transform(response.Header).Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	if response.Encoding != "" {
+		if tag := header.Get("ETag"); tag != "" {
+			header.Set("ETag", encodedETag(tag, response.Encoding))
+		}
+		header.Set("Content-Encoding", string(response.Encoding))
+	}
+	header.Set("Content-Length", strconv.Itoa(len(response.Value)))
+	c.annotate(r, header, key, status, &response)
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(response.Value)),
+		ContentLength: int64(len(response.Value)),
+		Request:       r,
+	}
+}
+
+// revalidate refreshes a stale cached response in the background. Only one
+// revalidation per key runs at a time.
+func (t *Transport) revalidate(req, r *http.Request, key string, response Response) {
+	c := t.client
+	if _, running := c.revalidating.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+
+	ctx := detachedContext{req.Context()}
+	req, r = req.Clone(ctx), r.Clone(ctx)
+	go func() {
+		defer c.revalidating.Delete(key)
+
+		resp, err := t.fetch(req, r, key, cacheStale, &response)
+		if err != nil {
+			c.hooks.error(r, key, fmt.Errorf("round trip: %w", err))
+			return
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+}
@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTransport(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		cacheControl  string
+		wantStatuses  []string
+		wantUpstreams int32
+	}{
+		{"caches GET responses", http.MethodGet, "", []string{"MISS", "HIT"}, 1},
+		{"honors no-store", http.MethodGet, "no-store", []string{"MISS", "MISS"}, 2},
+		{"passes other methods through", http.MethodPost, "", []string{"", ""}, 2},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var upstreams int32
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&upstreams, 1)
+				if tt.cacheControl != "" {
+					w.Header().Set("Cache-Control", tt.cacheControl)
+				}
+				w.Write([]byte("value 1"))
+			}))
+			defer upstream.Close()
+
+			client, _ := NewClient(
+				WithAdapter(&adapterMock{store: map[string][]byte{}}),
+				WithTTL(1*time.Minute),
+				WithStatusHeaders(true),
+			)
+			httpClient := &http.Client{Transport: client.Transport(nil)}
+
+			for i, want := range tt.wantStatuses {
+				r, _ := http.NewRequest(tt.method, upstream.URL+"/test-1", nil)
+				resp, err := httpClient.Do(r)
+				if err != nil {
+					t.Fatalf("Client.Do() error = %v", err)
+				}
+				body, _ := ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+
+				if string(body) != "value 1" {
+					t.Errorf("request %d body = %q, want %q", i, body, "value 1")
+				}
+				if got := resp.Header.Get("X-Cache"); got != want {
+					t.Errorf("request %d X-Cache = %q, want %q", i, got, want)
+				}
+			}
+			if got := atomic.LoadInt32(&upstreams); got != tt.wantUpstreams {
+				t.Errorf("upstream requests = %v, want %v", got, tt.wantUpstreams)
+			}
+		})
+	}
+}
+
+func TestTransportPostBody(t *testing.T) {
+	var bodies []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.Write(body)
+	}))
+	defer upstream.Close()
+
+	client, _ := NewClient(
+		WithAdapter(&adapterMock{store: map[string][]byte{}}),
+		WithTTL(1*time.Minute),
+		WithMethods(http.MethodPost),
+	)
+	httpClient := &http.Client{Transport: client.Transport(nil)}
+
+	for _, payload := range []string{"body 1", "body 2", "body 1"} {
+		resp, err := httpClient.Post(upstream.URL+"/test-1", "text/plain", strings.NewReader(payload))
+		if err != nil {
+			t.Fatalf("Client.Post() error = %v", err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != payload {
+			t.Errorf("body = %q, want %q", body, payload)
+		}
+	}
+
+	// The upstream server still reads the body consumed by the POST key.
+	if want := []string{"body 1", "body 2"}; !reflect.DeepEqual(bodies, want) {
+		t.Errorf("upstream bodies = %q, want %q", bodies, want)
+	}
+}
+
+func TestTransportRevalidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		etag      string
+		wantBody  string
+		wantMatch string
+	}{
+		{"refreshes not modified responses", `"v1"`, "value 1", `"v1"`},
+		{"replaces modified responses", `"v0"`, "value 2", `"v0"`},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var ifNoneMatch atomic.Value
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ifNoneMatch.Store(r.Header.Get("If-None-Match"))
+				if r.Header.Get("If-None-Match") == `"v1"` {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Header().Set("ETag", `"v2"`)
+				w.Write([]byte("value 2"))
+			}))
+			defer upstream.Close()
+
+			key := upstream.URL + "/test-1"
+			adapter := &adapterMock{
+				store: map[string][]byte{
+					key: Response{
+						Value:      []byte("value 1"),
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"Etag": {tt.etag}},
+						Expiration: time.Now().Add(-1 * time.Minute),
+					}.Bytes(),
+				},
+			}
+			client, _ := NewClient(WithAdapter(adapter), WithTTL(1*time.Minute))
+			httpClient := &http.Client{Transport: client.Transport(nil)}
+
+			resp, err := httpClient.Get(key)
+			if err != nil {
+				t.Fatalf("Client.Get() error = %v", err)
+			}
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if string(body) != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+			if got := ifNoneMatch.Load(); got != tt.wantMatch {
+				t.Errorf("If-None-Match = %v, want %v", got, tt.wantMatch)
+			}
+			response := BytesToResponse(adapter.store[key])
+			if string(response.Value) != tt.wantBody || !response.Expiration.After(time.Now()) {
+				t.Errorf("cached response = %q expiring %v, want fresh %q", response.Value, response.Expiration, tt.wantBody)
+			}
+		})
+	}
+}
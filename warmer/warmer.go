@@ -0,0 +1,299 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package warmer populates the cache ahead of real traffic, by issuing
+// synthetic requests through the handler chain wrapped by the cache
+// middleware.
+package warmer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// DefaultConcurrency is the number of requests issued concurrently by
+// default.
+const DefaultConcurrency = 4
+
+// Target is a request issued by the warmer. Header sets the request headers
+// a response varies on, warming a Vary-keyed variant, or the refresh header
+// of the cache client to replace responses still cached.
+type Target struct {
+	URL    string
+	Header http.Header
+}
+
+// Report is the outcome of warming the cache.
+type Report struct {
+	// Requests is the number of requests issued.
+	Requests int
+
+	// Failures is the number of requests that failed, or were answered
+	// with a 5xx status code.
+	Failures int
+}
+
+// Warmer issues requests for a list of targets through a handler.
+type Warmer struct {
+	handler     http.Handler
+	targets     []Target
+	crawl       func(context.Context) ([]Target, error)
+	concurrency int
+	limiter     *rate.Limiter
+	onError     func(target Target, err error)
+}
+
+// WarmerOptions is used to set Warmer settings.
+type WarmerOptions func(w *Warmer) error
+
+// NewWarmer initializes a warmer issuing requests through handler, which is
+// usually the cache middleware wrapping the application handler.
+func NewWarmer(handler http.Handler, opts ...WarmerOptions) (*Warmer, error) {
+	if handler == nil {
+		return nil, errors.New("warmer handler can not be nil")
+	}
+
+	w := &Warmer{
+		handler:     handler,
+		concurrency: DefaultConcurrency,
+	}
+	for _, opt := range opts {
+		if err := opt(w); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(w.targets) == 0 && w.crawl == nil {
+		return nil, errors.New("warmer targets are not set")
+	}
+
+	return w, nil
+}
+
+// WarmerWithURLs adds GET requests for URLs to the targets.
+func WarmerWithURLs(urls ...string) WarmerOptions {
+	return func(w *Warmer) error {
+		for _, url := range urls {
+			w.targets = append(w.targets, Target{URL: url})
+		}
+		return nil
+	}
+}
+
+// WarmerWithTargets adds targets with their request headers.
+func WarmerWithTargets(targets ...Target) WarmerOptions {
+	return func(w *Warmer) error {
+		w.targets = append(w.targets, targets...)
+		return nil
+	}
+}
+
+// WarmerWithCrawler sets a function returning targets each time the cache
+// is warmed, such as the URLs of a sitemap or of the most popular pages,
+// added to the static ones.
+func WarmerWithCrawler(fn func(ctx context.Context) ([]Target, error)) WarmerOptions {
+	return func(w *Warmer) error {
+		if fn == nil {
+			return errors.New("warmer crawler function can not be nil")
+		}
+
+		w.crawl = fn
+
+		return nil
+	}
+}
+
+// WarmerWithConcurrency sets the number of requests issued concurrently.
+func WarmerWithConcurrency(n int) WarmerOptions {
+	return func(w *Warmer) error {
+		if n < 1 {
+			return fmt.Errorf("warmer concurrency %v is invalid", n)
+		}
+
+		w.concurrency = n
+
+		return nil
+	}
+}
+
+// WarmerWithRateLimit limits the requests issued to perSecond, so warming
+// doesn't overload the origin.
+func WarmerWithRateLimit(perSecond float64) WarmerOptions {
+	return func(w *Warmer) error {
+		if perSecond <= 0 {
+			return fmt.Errorf("warmer rate limit %v is invalid", perSecond)
+		}
+
+		w.limiter = rate.NewLimiter(rate.Limit(perSecond), 1)
+
+		return nil
+	}
+}
+
+// WarmerWithErrorHandler sets a function called with each failed target,
+// such as to log them, and with a zero Target when Run fails to crawl the
+// targets.
+func WarmerWithErrorHandler(fn func(target Target, err error)) WarmerOptions {
+	return func(w *Warmer) error {
+		if fn == nil {
+			return errors.New("warmer error handler can not be nil")
+		}
+
+		w.onError = fn
+
+		return nil
+	}
+}
+
+// Warm issues a request for each target, returning once they are all done
+// or ctx is done. It fails only when the crawler does.
+func (w *Warmer) Warm(ctx context.Context) (Report, error) {
+	targets := w.targets
+	if w.crawl != nil {
+		crawled, err := w.crawl(ctx)
+		if err != nil {
+			return Report{}, fmt.Errorf("warmer crawl: %w", err)
+		}
+		targets = append(append([]Target(nil), targets...), crawled...)
+	}
+
+	var report Report
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	queue := make(chan Target)
+	for i := 0; i < w.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for target := range queue {
+				err := w.warm(ctx, target)
+				mutex.Lock()
+				report.Requests++
+				if err != nil {
+					report.Failures++
+				}
+				mutex.Unlock()
+				if err != nil && w.onError != nil {
+					w.onError(target, err)
+				}
+			}
+		}()
+	}
+
+loop:
+	for _, target := range targets {
+		if w.limiter != nil {
+			if err := w.limiter.Wait(ctx); err != nil {
+				break loop
+			}
+		}
+		select {
+		case queue <- target:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	return report, nil
+}
+
+// Run warms the cache right away, then every interval until ctx is done.
+// Crawler failures are reported to the error handler, if any, and retried on
+// the next interval.
+func (w *Warmer) Run(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("warmer interval %v is invalid", interval)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.Warm(ctx); err != nil && w.onError != nil {
+			w.onError(Target{}, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// warm issues the request of a target through the handler.
+func (w *Warmer) warm(ctx context.Context, target Target) (err error) {
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, target.URL, nil)
+	if err != nil {
+		return err
+	}
+	for name, values := range target.Header {
+		r.Header[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("handler panic: %v", p)
+		}
+	}()
+
+	rw := &discardWriter{header: http.Header{}}
+	w.handler.ServeHTTP(rw, r)
+	if rw.status >= 500 {
+		return fmt.Errorf("handler status %v", rw.status)
+	}
+
+	return nil
+}
+
+// discardWriter is a response writer discarding the response body.
+type discardWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return len(b), nil
+}
+
+func (w *discardWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
@@ -0,0 +1,192 @@
+package warmer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cache "github.com/cludden/http-cache"
+	"github.com/cludden/http-cache/adapter/memory"
+)
+
+func TestWarm(t *testing.T) {
+	adapter, _ := memory.NewAdapter(memory.AdapterWithAlgorithm(memory.LRU), memory.AdapterWithCapacity(100))
+	client, _ := cache.NewClient(
+		cache.WithAdapter(adapter),
+		cache.WithTTL(1*time.Minute),
+		cache.WithStatusHeaders(true),
+	)
+	var calls int32
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.URL.Path == "/error" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Path == "/panic" {
+			panic("handler failure")
+		}
+		w.Header().Set("Vary", "Accept-Language")
+		w.Write([]byte(r.Header.Get("Accept-Language")))
+	}))
+
+	var failed []string
+	w, err := NewWarmer(handler,
+		WarmerWithURLs("http://foo.bar/test-1", "http://foo.bar/error"),
+		WarmerWithTargets(Target{URL: "http://foo.bar/test-1", Header: http.Header{"accept-language": {"fr"}}}),
+		WarmerWithCrawler(func(ctx context.Context) ([]Target, error) {
+			return []Target{{URL: "http://foo.bar/panic"}}, nil
+		}),
+		WarmerWithErrorHandler(func(target Target, err error) {
+			failed = append(failed, target.URL)
+		}),
+		WarmerWithConcurrency(1),
+	)
+	if err != nil {
+		t.Fatalf("NewWarmer() error = %v", err)
+	}
+
+	report, err := w.Warm(context.Background())
+	if err != nil {
+		t.Fatalf("Warmer.Warm() error = %v", err)
+	}
+	if want := (Report{Requests: 4, Failures: 2}); report != want {
+		t.Errorf("Warmer.Warm() = %+v, want %+v", report, want)
+	}
+	if len(failed) != 2 {
+		t.Errorf("failed targets = %v, want 2", failed)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	for _, language := range []string{"", "fr"} {
+		r, _ := http.NewRequest(http.MethodGet, "http://foo.bar/test-1", nil)
+		if language != "" {
+			r.Header.Set("Accept-Language", language)
+		}
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, r)
+		if got := rw.Header().Get("X-Cache"); got != "HIT" {
+			t.Errorf("X-Cache of variant %q = %v, want HIT", language, got)
+		}
+	}
+	if calls != 0 {
+		t.Errorf("handler calls = %v, want warmed responses", calls)
+	}
+}
+
+func TestWarmLimits(t *testing.T) {
+	var mutex sync.Mutex
+	running, max := 0, 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		running++
+		if running > max {
+			max = running
+		}
+		mutex.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mutex.Lock()
+		running--
+		mutex.Unlock()
+	})
+	urls := make([]string, 10)
+	for i := range urls {
+		urls[i] = "http://foo.bar/test-1"
+	}
+
+	w, _ := NewWarmer(handler, WarmerWithURLs(urls...), WarmerWithConcurrency(3))
+	if report, _ := w.Warm(context.Background()); report.Requests != 10 {
+		t.Errorf("Warmer.Warm() requests = %v, want 10", report.Requests)
+	}
+	if max != 3 {
+		t.Errorf("concurrent requests = %v, want 3", max)
+	}
+
+	w, _ = NewWarmer(handler, WarmerWithURLs(urls...), WarmerWithConcurrency(10), WarmerWithRateLimit(100))
+	start := time.Now()
+	w.Warm(context.Background())
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("Warmer.Warm() took %v, want at least 90ms at 100 requests per second", elapsed)
+	}
+}
+
+func TestNewWarmer(t *testing.T) {
+	handler := http.NotFoundHandler()
+	tests := []struct {
+		name    string
+		handler http.Handler
+		opts    []WarmerOptions
+		wantErr bool
+	}{
+		{"valid", handler, []WarmerOptions{WarmerWithURLs("http://foo.bar/")}, false},
+		{"nil handler", nil, []WarmerOptions{WarmerWithURLs("http://foo.bar/")}, true},
+		{"no targets", handler, nil, true},
+		{"invalid concurrency", handler, []WarmerOptions{WarmerWithURLs("http://foo.bar/"), WarmerWithConcurrency(0)}, true},
+		{"invalid rate limit", handler, []WarmerOptions{WarmerWithURLs("http://foo.bar/"), WarmerWithRateLimit(0)}, true},
+		{"nil crawler", handler, []WarmerOptions{WarmerWithCrawler(nil)}, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewWarmer(tt.handler, tt.opts...); (err != nil) != tt.wantErr {
+				t.Errorf("NewWarmer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	w, _ := NewWarmer(handler, WarmerWithCrawler(func(ctx context.Context) ([]Target, error) {
+		return nil, errors.New("sitemap unavailable")
+	}))
+	if _, err := w.Warm(context.Background()); err == nil {
+		t.Error("Warmer.Warm() error = nil, want the crawler error")
+	}
+}
+
+func TestRun(t *testing.T) {
+	crawlErr := errors.New("sitemap unavailable")
+	tests := []struct {
+		name     string
+		interval time.Duration
+		wantErr  bool
+	}{
+		{"invalid interval", 0, true},
+		{"negative interval", -time.Second, true},
+		{"crawler error", 10 * time.Millisecond, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var errs []error
+			w, _ := NewWarmer(http.NotFoundHandler(),
+				WarmerWithCrawler(func(ctx context.Context) ([]Target, error) {
+					return nil, crawlErr
+				}),
+				WarmerWithErrorHandler(func(target Target, err error) {
+					errs = append(errs, err)
+				}),
+			)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 35*time.Millisecond)
+			defer cancel()
+			if err := w.Run(ctx, tt.interval); (err != nil) != tt.wantErr {
+				t.Fatalf("Warmer.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(errs) < 2 {
+				t.Fatalf("error handler calls = %v, want at least 2", len(errs))
+			}
+			for _, err := range errs {
+				if !errors.Is(err, crawlErr) {
+					t.Errorf("error handler err = %v, want %v", err, crawlErr)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+/*
+MIT License
+
+Copyright (c) 2018 Victor Springer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// responseWriter passes the response of a handler through to the client as
+// it is written, while capturing it to be cached.
+type responseWriter struct {
+	w      http.ResponseWriter
+	header http.Header
+
+	// before is called with the client response header right before it is
+	// written.
+	before func(http.Header)
+
+	// limit is the maximum size of a captured body, if positive. Larger
+	// responses are only passed through.
+	limit int64
+
+	// discard makes the response only pass through, its body not being
+	// captured.
+	discard bool
+
+	// hold reports, when the status code is written, whether the response is
+	// held back from the client instead of passed through.
+	hold func(statusCode int) bool
+
+	// status is the status code written by the handler, and stored is a
+	// snapshot of its header at that moment.
+	status int
+	stored http.Header
+	body   bytes.Buffer
+
+	committed bool
+	held      bool
+	failed    bool
+	hijacked  bool
+	oversized bool
+}
+
+// newResponseWriter initializes a responseWriter passing through to w. A nil
+// w only captures the response.
+func newResponseWriter(w http.ResponseWriter, before func(http.Header)) *responseWriter {
+	return &responseWriter{
+		w:      w,
+		header: http.Header{},
+		before: before,
+	}
+}
+
+// Header implements the http.ResponseWriter interface Header method.
+func (rw *responseWriter) Header() http.Header {
+	return rw.header
+}
+
+// WriteHeader implements the http.ResponseWriter interface WriteHeader
+// method.
+func (rw *responseWriter) WriteHeader(statusCode int) {
+	if rw.committed {
+		return
+	}
+
+	rw.committed = true
+	rw.status = statusCode
+	rw.stored = rw.header.Clone()
+	if n, err := strconv.ParseInt(rw.header.Get("Content-Length"), 10, 64); err == nil && rw.limit > 0 && n > rw.limit {
+		rw.oversized = true
+	}
+	if rw.hold != nil && rw.hold(statusCode) {
+		rw.held = true
+	}
+	if rw.w == nil || rw.held {
+		return
+	}
+
+	copyHeader(rw.w.Header(), rw.header)
+	if rw.before != nil {
+		rw.before(rw.w.Header())
+	}
+	rw.w.WriteHeader(statusCode)
+}
+
+// Write implements the http.ResponseWriter interface Write method.
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if !rw.committed {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	if !rw.oversized && rw.limit > 0 && int64(rw.body.Len()+len(b)) > rw.limit {
+		rw.oversized = true
+		rw.body = bytes.Buffer{}
+	}
+	if !rw.oversized && !rw.discard {
+		rw.body.Write(b)
+	}
+	if rw.w == nil || rw.held || rw.failed {
+		return len(b), nil
+	}
+
+	n, err := rw.w.Write(b)
+	if err != nil {
+		rw.failed = true
+	}
+
+	return n, err
+}
+
+// Flush implements the http.Flusher interface, flushing the response to the
+// client when the underlying writer supports it.
+func (rw *responseWriter) Flush() {
+	if !rw.committed {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := rw.w.(http.Flusher); ok && !rw.held {
+		f.Flush()
+	}
+}
+
+// Hijack implements the http.Hijacker interface, handing the client
+// connection over when the underlying writer supports it. The response of a
+// hijacked connection is never cached.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.w.(http.Hijacker)
+	if !ok || rw.held {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	conn, brw, err := h.Hijack()
+	if err == nil {
+		rw.hijacked = true
+	}
+
+	return conn, brw, err
+}
+
+// Push implements the http.Pusher interface, initiating a server push when
+// the underlying writer supports it.
+func (rw *responseWriter) Push(target string, opts *http.PushOptions) error {
+	p, ok := rw.w.(http.Pusher)
+	if !ok || rw.held {
+		return http.ErrNotSupported
+	}
+
+	return p.Push(target, opts)
+}
+
+// finish commits a response the handler wrote nothing of, and reports
+// whether the captured response is complete, which isn't the case when
+// writing to the client failed, the connection was hijacked or the body
+// exceeded the limit.
+func (rw *responseWriter) finish() bool {
+	if rw.hijacked {
+		return false
+	}
+	if !rw.committed {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	return !rw.failed && !rw.oversized
+}
@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write error")
+}
+
+func TestResponseWriter(t *testing.T) {
+	tests := []struct {
+		name         string
+		w            http.ResponseWriter
+		hold         func(int) bool
+		statusCode   int
+		wantComplete bool
+		wantClient   string
+	}{
+		{"passes response through", httptest.NewRecorder(), nil, 201, true, "value 1"},
+		{"captures response only", nil, nil, 201, true, ""},
+		{"holds response back", httptest.NewRecorder(), func(int) bool { return true }, 500, true, ""},
+		{"reports failed writes", failingWriter{httptest.NewRecorder()}, nil, 201, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw := newResponseWriter(tt.w, func(h http.Header) {
+				h.Set("X-Before", "true")
+			})
+			rw.hold = tt.hold
+			rw.Header().Set("Content-Type", "text/plain")
+			rw.WriteHeader(tt.statusCode)
+			rw.Write([]byte("value 1"))
+
+			if complete := rw.finish(); complete != tt.wantComplete {
+				t.Errorf("responseWriter.finish() = %v, want %v", complete, tt.wantComplete)
+			}
+			if rw.status != tt.statusCode || rw.body.String() != "value 1" || rw.stored.Get("Content-Type") != "text/plain" {
+				t.Errorf("captured %v %v %v, want %v value 1", rw.status, rw.body.String(), rw.stored, tt.statusCode)
+			}
+			if rw.stored.Get("X-Before") != "" {
+				t.Error("captured header includes client only headers")
+			}
+			if rec, ok := tt.w.(*httptest.ResponseRecorder); ok {
+				if rec.Body.String() != tt.wantClient {
+					t.Errorf("client body = %v, want %v", rec.Body.String(), tt.wantClient)
+				}
+				if tt.wantClient != "" && (rec.Code != tt.statusCode || rec.Header().Get("X-Before") != "true") {
+					t.Errorf("client response = %v %v, want %v", rec.Code, rec.Header(), tt.statusCode)
+				}
+			}
+		})
+	}
+}
+
+type hijackableWriter struct {
+	*httptest.ResponseRecorder
+	pushed []string
+}
+
+func (w *hijackableWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	client, server := net.Pipe()
+	client.Close()
+	return server, nil, nil
+}
+
+func (w *hijackableWriter) Push(target string, opts *http.PushOptions) error {
+	w.pushed = append(w.pushed, target)
+	return nil
+}
+
+func TestResponseWriterInterfaces(t *testing.T) {
+	w := &hijackableWriter{ResponseRecorder: httptest.NewRecorder()}
+	rw := newResponseWriter(w, nil)
+
+	if err := rw.Push("/style.css", nil); err != nil || len(w.pushed) != 1 {
+		t.Errorf("responseWriter.Push() error = %v, pushed %v", err, w.pushed)
+	}
+	conn, _, err := rw.Hijack()
+	if err != nil {
+		t.Fatalf("responseWriter.Hijack() error = %v", err)
+	}
+	conn.Close()
+	if rw.finish() {
+		t.Error("responseWriter.finish() = true for hijacked connection")
+	}
+
+	rw = newResponseWriter(nil, nil)
+	if _, _, err := rw.Hijack(); err != http.ErrNotSupported {
+		t.Errorf("responseWriter.Hijack() error = %v, want %v", err, http.ErrNotSupported)
+	}
+	if err := rw.Push("/style.css", nil); err != http.ErrNotSupported {
+		t.Errorf("responseWriter.Push() error = %v, want %v", err, http.ErrNotSupported)
+	}
+}